@@ -5,26 +5,198 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 
+	"github.com/lvjp/s3impl/pkg/s3accesslog"
+	"github.com/lvjp/s3impl/pkg/s3audit"
+	"github.com/lvjp/s3impl/pkg/s3conditional"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3metrics"
+	"github.com/lvjp/s3impl/pkg/s3notify"
+	"github.com/lvjp/s3impl/pkg/s3replication"
+	"github.com/lvjp/s3impl/pkg/s3requestpayment"
 	"github.com/lvjp/s3impl/pkg/s3router"
+	"github.com/lvjp/s3impl/pkg/s3subresource"
+	"github.com/lvjp/s3impl/pkg/s3trace"
+	"github.com/lvjp/s3impl/pkg/s3website"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
+	"github.com/sourcegraph/conc/pool"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type App struct {
-	ctx    context.Context
-	server *http.Server
+	ctx          context.Context
+	server       *http.Server
+	notifier     *s3notify.Notifier
+	replicator   *s3replication.Replicator
+	events       *s3notify.Broker
+	subresources *s3subresource.Handler
+	website      *s3website.Server
+	requestPay   *s3requestpayment.Enforcer
+	usage        *s3requestpayment.Usage
+	metrics      *http.Server
+	registry     *prometheus.Registry
+	middlewares  []s3router.Middleware
+	tracing      *sdktrace.TracerProvider
+	audit        *s3audit.Log
+	accessLogs   *s3accesslog.Logger
 }
 
 func New(ctx context.Context, config Config) (*App, error) {
+	hostID := config.Endpoint.HostID
+	if hostID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("app: cannot determine host id: %w", err)
+		}
+
+		hostID = hostname
+	}
+
 	app := &App{
-		ctx: ctx,
-		server: &http.Server{
-			Addr:              config.Endpoint.Addr,
-			ReadHeaderTimeout: config.Endpoint.HTTPReadHeaderTimeout,
-			Handler:           s3router.New(zerolog.Ctx(ctx), config.Endpoint.Hosts),
+		ctx:      ctx,
+		events:   s3notify.NewBroker(),
+		registry: prometheus.NewRegistry(),
+		middlewares: []s3router.Middleware{
+			s3router.HostID(hostID),
+			s3logging.Middleware(zerolog.Ctx(ctx)),
 		},
 	}
 
+	if config.Audit.File != "" {
+		audit, err := s3audit.Open(config.Audit.File)
+		if err != nil {
+			return nil, fmt.Errorf("app: cannot initialize audit log: %w", err)
+		}
+
+		app.audit = audit
+		app.middlewares = append(app.middlewares, s3audit.Middleware(zerolog.Ctx(ctx), audit))
+	}
+
+	if config.Notifications.QueueDir != "" {
+		notifier, err := s3notify.NewNotifier(zerolog.Ctx(ctx), config.Notifications)
+		if err != nil {
+			return nil, fmt.Errorf("app: cannot initialize notifications: %w", err)
+		}
+
+		app.notifier = notifier
+	}
+
+	if len(config.Replication.Targets) > 0 {
+		replicator, err := s3replication.NewReplicator(zerolog.Ctx(ctx), config.Replication, noObjects{})
+		if err != nil {
+			return nil, fmt.Errorf("app: cannot initialize replication: %w", err)
+		}
+
+		app.replicator = replicator
+	}
+
+	// Without sub-resources, no bucket has a metrics configuration.
+	configurations := s3metrics.ConfigurationsFunc(func(context.Context, string) ([]*s3metrics.Configuration, error) {
+		return nil, nil
+	})
+
+	// Without sub-resources, no bucket has a notification configuration:
+	// events are only streamed.
+	notifications := s3notify.ConfigurationFunc(func(context.Context, string) (*s3notify.Configuration, error) {
+		return nil, nil //nolint:nilnil // no configuration
+	})
+
+	var usage s3metrics.UsageFunc
+
+	if config.Subresources.Dir != "" {
+		store, err := s3subresource.NewStore(config.Subresources.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("app: cannot initialize sub-resources: %w", err)
+		}
+
+		usage = storageUsage(ctx, store)
+
+		app.subresources = s3subresource.NewHandler(zerolog.Ctx(ctx), store, app.subresourceKinds())
+		app.website = &s3website.Server{Store: noWebsiteObjects{}, Configurations: websiteConfiguration(store)}
+		app.usage = s3requestpayment.NewUsage()
+		app.requestPay = s3requestpayment.NewEnforcer(zerolog.Ctx(ctx), payer(store), notOwner, app.usage)
+
+		if err := app.registry.Register(app.usage); err != nil {
+			return nil, fmt.Errorf("app: cannot initialize Requester Pays usage: %w", err)
+		}
+
+		cache := s3metrics.NewConfigurationCache(metricsConfigurations(store))
+		store.Watch("metrics", cache.Invalidate)
+		configurations = cache.Configurations
+
+		if app.notifier != nil {
+			notifications = notificationConfiguration(store, app.notifier)
+		}
+
+		if config.AccessLogs.Dir != "" {
+			writer, err := s3accesslog.NewDirWriter(config.AccessLogs.Dir)
+			if err != nil {
+				return nil, fmt.Errorf("app: cannot initialize access logs: %w", err)
+			}
+
+			app.accessLogs = s3accesslog.NewLogger(zerolog.Ctx(ctx), writer, config.AccessLogs.FlushInterval)
+			app.middlewares = append(app.middlewares, s3accesslog.Middleware(zerolog.Ctx(ctx), app.accessLogs, loggingTarget(store)))
+		}
+	}
+
+	if config.Metrics.Addr != "" {
+		server, err := s3metrics.NewServer(app.registry, usage)
+		if err != nil {
+			return nil, fmt.Errorf("app: cannot initialize server metrics: %w", err)
+		}
+
+		recorder, err := s3metrics.NewRecorder(zerolog.Ctx(ctx), app.registry, configurations)
+		if err != nil {
+			return nil, fmt.Errorf("app: cannot initialize request metrics: %w", err)
+		}
+
+		app.middlewares = append(app.middlewares, server.Middleware, recorder.Middleware)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(app.registry, promhttp.HandlerOpts{}))
+
+		app.metrics = &http.Server{
+			Addr:              config.Metrics.Addr,
+			ReadHeaderTimeout: config.Endpoint.HTTPReadHeaderTimeout,
+			Handler:           mux,
+		}
+	}
+
+	if app.requestPay != nil {
+		app.middlewares = append(app.middlewares, app.requestPay.Middleware)
+	}
+
+	emitter := s3notify.NewEmitter(zerolog.Ctx(ctx), app.events, app.notifier, notifications)
+	app.middlewares = append(app.middlewares, emitter.Middleware)
+
+	// The guard comes last so that the conditional writes are committed
+	// while their key is locked.
+	guard := s3conditional.NewGuard(zerolog.Ctx(ctx), noVersions)
+	app.middlewares = append(app.middlewares, guard.Middleware)
+
+	tracing, err := s3trace.NewProvider(ctx, config.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("app: cannot initialize tracing: %w", err)
+	}
+
+	handler := s3router.New(zerolog.Ctx(ctx), config.Endpoint.Hosts, app.actions(), app.middlewares...)
+	if tracing != nil {
+		s3trace.Install(tracing)
+		app.tracing = tracing
+		handler = s3trace.Handler(handler)
+	}
+
+	app.server = &http.Server{
+		Addr:              config.Endpoint.Addr,
+		ReadHeaderTimeout: config.Endpoint.HTTPReadHeaderTimeout,
+		Handler:           handler,
+	}
+	app.server.RegisterOnShutdown(app.events.Close)
+
 	return app, nil
 }
 
@@ -42,6 +214,245 @@ func (app *App) Run() error {
 	}
 }
 
+func (app *App) actions() map[s3router.Action]s3router.ActionHandler {
+	actions := map[s3router.Action]s3router.ActionHandler{
+		s3router.ActionListenBucketNotification: app.events,
+	}
+
+	if app.subresources != nil {
+		for action, handler := range app.subresources.Actions() {
+			actions[action] = handler
+		}
+	}
+
+	if app.website != nil {
+		actions[s3router.ActionGetWebsiteObject] = app.website
+		actions[s3router.ActionHeadWebsiteObject] = app.website
+	}
+
+	return actions
+}
+
+// payer reads the Requester Pays configuration of the buckets, caching them
+// until they change.
+func payer(store *s3subresource.Store) s3requestpayment.PayerFunc {
+	cache := s3subresource.NewCache(store, "requestPayment", s3requestpayment.ParseConfiguration)
+
+	return func(ctx context.Context, bucket string) (s3requestpayment.Payer, error) {
+		config, found, err := cache.Get(ctx, bucket)
+		if err != nil {
+			return "", err
+		} else if !found {
+			return s3requestpayment.PayerBucketOwner, nil
+		}
+
+		return config.Payer, nil
+	}
+}
+
+// loggingTarget reads the logging status of the buckets, caching them until
+// they change.
+func loggingTarget(store *s3subresource.Store) s3accesslog.TargetFunc {
+	cache := s3subresource.NewCache(store, "logging", s3accesslog.ParseStatus)
+
+	return func(ctx context.Context, bucket string) (s3accesslog.Target, bool, error) {
+		status, found, err := cache.Get(ctx, bucket)
+		if err != nil || !found {
+			return s3accesslog.Target{}, false, err
+		}
+
+		target, enabled := status.Target()
+
+		return target, enabled, nil
+	}
+}
+
+// notificationConfiguration reads the notification configuration of the
+// buckets, caching them until they change.
+func notificationConfiguration(store *s3subresource.Store, notifier *s3notify.Notifier) s3notify.ConfigurationFunc {
+	cache := s3subresource.NewCache(store, "notification", func(document []byte) (*s3notify.Configuration, error) {
+		return s3notify.ParseConfiguration(document, notifier.TargetExists)
+	})
+
+	return func(ctx context.Context, bucket string) (*s3notify.Configuration, error) {
+		config, _, err := cache.Get(ctx, bucket)
+		return config, err
+	}
+}
+
+// websiteConfiguration reads the website configuration of the buckets,
+// caching them until they change.
+func websiteConfiguration(store *s3subresource.Store) s3website.ConfigurationFunc {
+	cache := s3subresource.NewCache(store, "website", s3website.ParseConfiguration)
+
+	return func(ctx context.Context, bucket string) (*s3website.Configuration, error) {
+		config, _, err := cache.Get(ctx, bucket)
+		return config, err
+	}
+}
+
+// noWebsiteObjects is the website Store used while objects are not stored:
+// only the redirections and the error pages of the websites are served.
+type noWebsiteObjects struct{}
+
+func (noWebsiteObjects) GetObject(context.Context, string, string) (*s3website.Object, error) {
+	return nil, s3errors.NoSuchKey.New()
+}
+
+// metricsConfigurations reads the request metrics configurations of the
+// buckets.
+func metricsConfigurations(store *s3subresource.Store) s3metrics.ConfigurationsFunc {
+	return func(ctx context.Context, bucket string) ([]*s3metrics.Configuration, error) {
+		ids, err := store.List(ctx, bucket, "metrics")
+		if err != nil {
+			return nil, err
+		}
+
+		configs := make([]*s3metrics.Configuration, 0, len(ids))
+		for _, id := range ids {
+			document, err := store.Get(ctx, bucket, "metrics", id)
+			if errors.Is(err, s3subresource.ErrNotFound) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			config, err := s3metrics.ParseConfiguration(document, id)
+			if err != nil {
+				return nil, err
+			}
+
+			configs = append(configs, config)
+		}
+
+		return configs, nil
+	}
+}
+
+// storageUsage reports the storage used by the configuration documents of
+// the buckets.
+func storageUsage(ctx context.Context, store *s3subresource.Store) s3metrics.UsageFunc {
+	return func() ([]s3metrics.BucketUsage, error) {
+		documents, err := store.Usage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		usages := make([]s3metrics.BucketUsage, 0, len(documents))
+		for _, document := range documents {
+			usages = append(usages, s3metrics.BucketUsage{Bucket: document.Bucket, Bytes: document.Bytes, Documents: document.Documents})
+		}
+
+		return usages, nil
+	}
+}
+
+// notOwner is the OwnerFunc used while requests are not authenticated:
+// every caller must acknowledge the charges of Requester Pays buckets.
+func notOwner(*http.Request, string) bool {
+	return false
+}
+
+// subresourceKinds returns the stored sub-resources, including those
+// validated against the configured targets.
+func (app *App) subresourceKinds() []s3subresource.Kind {
+	kinds := s3subresource.DefaultKinds()
+
+	if app.notifier != nil {
+		kinds = append(kinds, s3subresource.Kind{
+			Subresource: "notification",
+			Validate: func(payload []byte, _ string) error {
+				_, err := s3notify.ParseConfiguration(payload, app.notifier.TargetExists)
+				return err
+			},
+			Default: "<NotificationConfiguration/>",
+			Put:     s3router.ActionPutBucketNotificationConfiguration,
+			Get:     s3router.ActionGetBucketNotificationConfiguration,
+		})
+	}
+
+	if app.replicator != nil {
+		kinds = append(kinds, s3subresource.Kind{
+			Subresource: "replication",
+			Validate: func(payload []byte, _ string) error {
+				_, err := s3replication.ParseConfiguration(payload, app.replicator.TargetExists)
+				return err
+			},
+			NotFound: s3errors.ReplicationConfigurationNotFoundError,
+			Put:      s3router.ActionPutBucketReplication,
+			Get:      s3router.ActionGetBucketReplication,
+			Delete:   s3router.ActionDeleteBucketReplication,
+		})
+	}
+
+	return kinds
+}
+
+// noVersions is the VersionFunc used while objects are not stored: no key
+// exists.
+func noVersions(context.Context, string, string) (*s3conditional.Version, error) {
+	return nil, nil //nolint:nilnil // no object
+}
+
+// noObjects is the replication Source used while objects are not stored:
+// nothing is enqueued, the replicator only runs for the configurations to
+// be validated against its targets.
+type noObjects struct{}
+
+func (noObjects) GetObject(context.Context, string, string, string) (*s3replication.Object, error) {
+	return nil, errors.New("app: objects are not stored")
+}
+
+func (noObjects) SetReplicationStatus(context.Context, string, string, string, s3replication.Status) error {
+	return errors.New("app: objects are not stored")
+}
+
+// RunWorkers runs the background workers until ctx is done.
+func (app *App) RunWorkers(ctx context.Context) error {
+	workers := pool.New().WithContext(ctx).WithCancelOnError()
+
+	if app.notifier != nil {
+		workers.Go(app.notifier.Run)
+	}
+
+	if app.replicator != nil {
+		workers.Go(app.replicator.Run)
+	}
+
+	if app.metrics != nil {
+		workers.Go(app.serveMetrics)
+	}
+
+	if app.accessLogs != nil {
+		workers.Go(app.accessLogs.Run)
+	}
+
+	if err := workers.Wait(); err != nil {
+		return fmt.Errorf("app: worker error: %w", err)
+	}
+
+	return nil
+}
+
+// serveMetrics serves the Prometheus metrics until ctx is done.
+func (app *App) serveMetrics(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+
+		if err := app.metrics.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			zerolog.Ctx(app.ctx).Warn().Err(err).Msg("app: Cannot shutdown metrics listener")
+		}
+	}()
+
+	zerolog.Ctx(app.ctx).Info().Str("addr", app.metrics.Addr).Msg("app: Start to serve metrics")
+
+	if err := app.metrics.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("app: metrics listen error: %w", err)
+	}
+
+	return nil
+}
+
 func (app *App) Shutdown(ctx context.Context) error {
 	zerolog.Ctx(app.ctx).Info().Msg("app: Shutdown")
 
@@ -49,5 +460,30 @@ func (app *App) Shutdown(ctx context.Context) error {
 		return fmt.Errorf("app: shutdown error: %w", err)
 	}
 
+	if app.audit != nil {
+		if err := app.audit.Close(); err != nil {
+			return fmt.Errorf("app: audit log close error: %w", err)
+		}
+	}
+
+	if app.tracing != nil {
+		if err := app.tracing.Shutdown(ctx); err != nil {
+			return fmt.Errorf("app: tracing shutdown error: %w", err)
+		}
+	}
+
+	if app.usage != nil {
+		for _, line := range app.usage.Report() {
+			zerolog.Ctx(app.ctx).Info().
+				Str("bucket", line.Bucket).
+				Str("requester", line.Requester).
+				Str("operation", line.Operation).
+				Int64("requests", line.Requests).
+				Int64("bytesIn", line.BytesIn).
+				Int64("bytesOut", line.BytesOut).
+				Msg("app: Requester Pays usage")
+		}
+	}
+
 	return nil
 }
@@ -1,11 +1,36 @@
 package app
 
-import "time"
+import (
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3accesslog"
+	"github.com/lvjp/s3impl/pkg/s3audit"
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3notify"
+	"github.com/lvjp/s3impl/pkg/s3replication"
+	"github.com/lvjp/s3impl/pkg/s3subresource"
+	"github.com/lvjp/s3impl/pkg/s3trace"
+)
 
 type Config struct {
 	Endpoint struct {
 		Addr                  string
 		HTTPReadHeaderTimeout time.Duration
 		Hosts                 []string
+		// HostID identifies the node in the x-amz-id-2 header and the
+		// HostId of errors, the hostname by default.
+		HostID string `yaml:"hostId"`
+	}
+	Logging       s3logging.Config
+	Audit         s3audit.Config
+	AccessLogs    s3accesslog.Config `yaml:"accessLogs"`
+	Notifications s3notify.Config
+	Replication   s3replication.Config
+	Subresources  s3subresource.Config
+	Tracing       s3trace.Config
+	Metrics       struct {
+		// Addr is where the Prometheus metrics are served on /metrics,
+		// they are not served when it is empty.
+		Addr string
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/lvjp/s3impl/pkg/s3logging"
 	"github.com/rs/zerolog/log"
 	"github.com/sourcegraph/conc/pool"
 	"gopkg.in/yaml.v3"
@@ -19,6 +20,14 @@ func Execute(configPath string) error {
 		return fmt.Errorf("could not read config: %w", err)
 	}
 
+	logger, output, err := s3logging.New(config.Logging)
+	if err != nil {
+		return fmt.Errorf("could not initialize logging: %w", err)
+	}
+	defer output.Close()
+
+	log.Logger = logger
+
 	stdlog.SetFlags(0)
 	stdlog.SetOutput(log.Logger.With().Str("module", "stdlog").Logger())
 
@@ -40,6 +49,14 @@ func Execute(configPath string) error {
 		return nil
 	})
 
+	pool.Go(func(ctx context.Context) error {
+		if err := app.RunWorkers(ctx); err != nil {
+			return fmt.Errorf("could not run workers: %w", err)
+		}
+
+		return nil
+	})
+
 	pool.Go(func(ctx context.Context) error {
 		<-ctx.Done()
 
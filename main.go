@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/lvjp/s3impl/internal/app"
+	"github.com/lvjp/s3impl/pkg/s3audit"
 	"github.com/spf13/cobra"
 )
 
@@ -21,8 +22,39 @@ var cmd = &cobra.Command{
 	},
 }
 
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit log tools",
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:                   "verify FILE",
+	Short:                 "Verify the hash chain of an audit log",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		count, err := s3audit.Verify(file)
+		if err != nil {
+			return fmt.Errorf("verification failed after %d valid records: %w", count, err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%d records verified\n", count)
+
+		return nil
+	},
+}
+
 func init() {
 	cmd.Flags().StringVar(&configPath, "config", "examples/config.yaml", "Path to configuration file")
+
+	auditCmd.AddCommand(auditVerifyCmd)
+	cmd.AddCommand(auditCmd)
 }
 
 func main() {
@@ -0,0 +1,184 @@
+package s3accesslog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3router"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatus(t *testing.T) {
+	status, err := ParseStatus([]byte(`<BucketLoggingStatus xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+		`<LoggingEnabled><TargetBucket>logs</TargetBucket><TargetPrefix>site/</TargetPrefix></LoggingEnabled>` +
+		`</BucketLoggingStatus>`))
+	require.NoError(t, err)
+
+	target, enabled := status.Target()
+	require.True(t, enabled)
+	require.Equal(t, Target{Bucket: "logs", Prefix: "site/"}, target)
+
+	status, err = ParseStatus([]byte(`<BucketLoggingStatus/>`))
+	require.NoError(t, err)
+
+	_, enabled = status.Target()
+	require.False(t, enabled)
+
+	_, err = ParseStatus([]byte(`<BucketLoggingStatus><LoggingEnabled></LoggingEnabled></BucketLoggingStatus>`))
+	require.Error(t, err)
+
+	_, err = ParseStatus([]byte(`<BucketLoggingStatus><LoggingEnabled><TargetBucket>..</TargetBucket></LoggingEnabled></BucketLoggingStatus>`))
+	require.ErrorContains(t, err, "InvalidTargetBucketForLogging")
+}
+
+func TestOperation(t *testing.T) {
+	for _, tc := range []struct {
+		method   string
+		action   s3router.Action
+		expected string
+	}{
+		{http.MethodGet, s3router.ActionGetObject, "REST.GET.OBJECT"},
+		{http.MethodGet, s3router.ActionListObjects, "REST.GET.BUCKET"},
+		{http.MethodPut, s3router.ActionCopyObject, "REST.COPY.OBJECT"},
+		{http.MethodPut, s3router.ActionUploadPartCopy, "REST.COPY.PART"},
+		{http.MethodPost, s3router.ActionDeleteObjects, "REST.POST.MULTI_OBJECT_DELETE"},
+		{http.MethodGet, s3router.ActionGetWebsiteObject, "WEBSITE.GET.OBJECT"},
+		{http.MethodGet, s3router.ActionUnknow, "REST.GET.UNKNOWN"},
+	} {
+		require.Equal(t, tc.expected, Operation(tc.method, tc.action))
+	}
+}
+
+func TestRecordString(t *testing.T) {
+	record := &Record{
+		BucketOwner: "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be",
+		Bucket:      "awsexamplebucket1",
+		Time:        time.Date(2019, 2, 6, 0, 0, 38, 0, time.UTC),
+		RemoteIP:    "192.0.2.3",
+		Requester:   "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be",
+		RequestID:   "3E57427F3EXAMPLE",
+		Operation:   "REST.GET.OBJECT",
+		Key:         "photos/my puppy.jpg",
+		RequestURI:  "GET /awsexamplebucket1/photos/my%20puppy.jpg HTTP/1.1",
+		HTTPStatus:  200,
+		BytesSent:   113,
+		ObjectSize:  113,
+		TotalTime:   7 * time.Millisecond,
+		UserAgent:   "S3Console/0.4",
+		HostID:      "s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234=",
+		HostHeader:  "awsexamplebucket1.s3.us-west-1.amazonaws.com",
+	}
+
+	require.Equal(t,
+		"79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:38 +0000] "+
+			"192.0.2.3 79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be 3E57427F3EXAMPLE REST.GET.OBJECT "+
+			`photos/my%20puppy.jpg "GET /awsexamplebucket1/photos/my%20puppy.jpg HTTP/1.1" 200 - 113 113 7 - - "S3Console/0.4" - `+
+			"s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234= - - - "+
+			"awsexamplebucket1.s3.us-west-1.amazonaws.com - - -",
+		record.String(),
+	)
+}
+
+type memoryWriter struct {
+	mu      sync.Mutex
+	fail    bool
+	objects map[string]string
+}
+
+func (m *memoryWriter) PutLogObject(_ context.Context, bucket, key string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return errors.New("unavailable")
+	}
+
+	m.objects[bucket+"/"+key] = string(body)
+
+	return nil
+}
+
+func TestLogger(t *testing.T) {
+	writer := &memoryWriter{fail: true, objects: make(map[string]string)}
+	logger := zerolog.Nop()
+	accessLogger := NewLogger(&logger, writer, time.Hour)
+
+	target := Target{Bucket: "logs", Prefix: "site/"}
+	accessLogger.Log(target, &Record{Bucket: "site", RequestID: "1"})
+
+	require.Error(t, accessLogger.Flush(context.Background()))
+
+	accessLogger.Log(target, &Record{Bucket: "site", RequestID: "2"})
+
+	writer.fail = false
+	require.NoError(t, accessLogger.Flush(context.Background()))
+	require.Len(t, writer.objects, 1)
+
+	for key, body := range writer.objects {
+		require.Regexp(t, regexp.MustCompile(`^logs/site/\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2}-[0-9A-F]{16}$`), key)
+		require.Regexp(t, regexp.MustCompile(`^- site \[.*\] - - 1 - .*\n- site \[.*\] - - 2 - .*\n$`), body)
+	}
+
+	require.NoError(t, accessLogger.Flush(context.Background()))
+	require.Len(t, writer.objects, 1)
+}
+
+func TestMiddleware(t *testing.T) {
+	writer := &memoryWriter{objects: make(map[string]string)}
+	logger := zerolog.Nop()
+	accessLogger := NewLogger(&logger, writer, time.Hour)
+
+	targets := func(_ context.Context, bucket string) (Target, bool, error) {
+		return Target{Bucket: "logs", Prefix: bucket + "/"}, bucket == "site", nil
+	}
+
+	handler := s3router.New(&logger, []string{"example.com"}, nil, Middleware(&logger, accessLogger, targets))
+
+	for _, path := range []string{"/site/index.html", "/other/index.html"} {
+		r, err := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		require.NoError(t, err)
+
+		r.RemoteAddr = "192.0.2.3:1234"
+		r.RequestURI = path
+		r.Header.Set("User-Agent", "test/1.0")
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	require.NoError(t, accessLogger.Flush(context.Background()))
+	require.Len(t, writer.objects, 1)
+
+	for key, body := range writer.objects {
+		require.Regexp(t, regexp.MustCompile(`^logs/site/`), key)
+		require.Regexp(t, regexp.MustCompile(`^- site \[.*\] 192\.0\.2\.3 - \S+ REST\.GET\.OBJECT index\.html `+
+			`"GET /site/index\.html HTTP/1\.1" 501 NotImplemented \d+ - (\d+|-) (\d+|-) - "test/1\.0" - - - - - example\.com - - -\n$`), body)
+	}
+}
+
+func TestDirWriter(t *testing.T) {
+	dir := t.TempDir()
+
+	writer, err := NewDirWriter(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.PutLogObject(context.Background(), "logs", "../../site/2024-01-01", []byte("line\n")))
+
+	body, err := os.ReadFile(filepath.Join(dir, "logs", "site", "2024-01-01"))
+	require.NoError(t, err)
+	require.Equal(t, "line\n", string(body))
+
+	require.NoError(t, writer.PutLogObject(context.Background(), "..", "site/2024-01-01", []byte("line\n")))
+
+	body, err = os.ReadFile(filepath.Join(dir, "%2E.", "site", "2024-01-01"))
+	require.NoError(t, err)
+	require.Equal(t, "line\n", string(body))
+}
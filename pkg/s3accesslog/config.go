@@ -0,0 +1,60 @@
+package s3accesslog
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type Status struct {
+	XMLName        xml.Name        `xml:"BucketLoggingStatus"`
+	Namespace      string          `xml:"xmlns,attr,omitempty"`
+	LoggingEnabled *LoggingEnabled `xml:",omitempty"`
+}
+
+type LoggingEnabled struct {
+	TargetBucket string
+	TargetPrefix string
+}
+
+// Target returns where the logs go, if logging is enabled.
+func (s *Status) Target() (Target, bool) {
+	if s.LoggingEnabled == nil {
+		return Target{}, false
+	}
+
+	return Target{
+		Bucket: s.LoggingEnabled.TargetBucket,
+		Prefix: s.LoggingEnabled.TargetPrefix,
+	}, true
+}
+
+// bucketName matches the names S3 accepts for new buckets.
+var bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// ParseStatus decodes and validates a PutBucketLogging payload. An empty
+// BucketLoggingStatus disables logging.
+func ParseStatus(payload []byte) (*Status, error) {
+	var status Status
+	if err := xml.Unmarshal(payload, &status); err != nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	if status.LoggingEnabled != nil {
+		target := status.LoggingEnabled.TargetBucket
+		if target == "" {
+			return nil, s3errors.MalformedXML.New()
+		}
+
+		if !bucketName.MatchString(target) || strings.Contains(target, "..") {
+			return nil, s3errors.InvalidTargetBucketForLogging.New().With("TargetBucket", target)
+		}
+	}
+
+	status.Namespace = s3consts.XMLNamespace
+
+	return &status, nil
+}
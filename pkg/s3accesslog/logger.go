@@ -0,0 +1,186 @@
+package s3accesslog
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Target is where the access logs of a bucket are delivered.
+type Target struct {
+	Bucket string
+	Prefix string
+}
+
+// Writer stores a log object in the target bucket.
+type Writer interface {
+	PutLogObject(ctx context.Context, bucket, key string, body []byte) error
+}
+
+// DefaultFlushInterval is the delay between two deliveries of log objects.
+const DefaultFlushInterval = 5 * time.Minute
+
+type Config struct {
+	// Dir is where the log objects are written, as long as objects are not
+	// stored. Access logging is disabled when it is empty.
+	Dir           string
+	FlushInterval time.Duration `yaml:"flushInterval"`
+}
+
+// DirWriter writes the log objects as <dir>/<bucket>/<key> files.
+type DirWriter struct {
+	dir string
+}
+
+func NewDirWriter(dir string) (*DirWriter, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("s3accesslog: cannot create log directory: %w", err)
+	}
+
+	return &DirWriter{dir: dir}, nil
+}
+
+func (d *DirWriter) PutLogObject(_ context.Context, bucket, key string, body []byte) error {
+	// Cleaning the rooted key keeps the file under the bucket directory.
+	name := filepath.Join(d.dir, escape(bucket), filepath.FromSlash(path.Clean("/"+key)))
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return fmt.Errorf("s3accesslog: cannot create log object: %w", err)
+	}
+
+	if err := os.WriteFile(name, body, 0o640); err != nil {
+		return fmt.Errorf("s3accesslog: cannot write log object: %w", err)
+	}
+
+	return nil
+}
+
+// escape turns a bucket name into a directory name, escaping the leading
+// dot of "." and "..".
+func escape(name string) string {
+	escaped := url.PathEscape(name)
+	if strings.HasPrefix(escaped, ".") {
+		escaped = "%2E" + escaped[1:]
+	}
+
+	return escaped
+}
+
+// Logger buffers the records per target and periodically delivers them as
+// log objects named <prefix>YYYY-mm-DD-HH-MM-SS-<unique>.
+type Logger struct {
+	logger   *zerolog.Logger
+	writer   Writer
+	interval time.Duration
+
+	mu      sync.Mutex
+	buffers map[Target]*bytes.Buffer
+}
+
+func NewLogger(logger *zerolog.Logger, writer Writer, interval time.Duration) *Logger {
+	if interval == 0 {
+		interval = DefaultFlushInterval
+	}
+
+	return &Logger{
+		logger:   logger,
+		writer:   writer,
+		interval: interval,
+		buffers:  make(map[Target]*bytes.Buffer),
+	}
+}
+
+func (l *Logger) Log(target Target, record *Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buffer, exists := l.buffers[target]
+	if !exists {
+		buffer = &bytes.Buffer{}
+		l.buffers[target] = buffer
+	}
+
+	buffer.WriteString(record.String())
+	buffer.WriteByte('\n')
+}
+
+// Run delivers the logs every interval until ctx is done, then delivers
+// the remaining records.
+func (l *Logger) Run(ctx context.Context) error {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return l.Flush(context.WithoutCancel(ctx))
+		case <-ticker.C:
+			if err := l.Flush(ctx); err != nil {
+				l.logger.Error().Err(err).Msg("Cannot deliver access logs")
+			}
+		}
+	}
+}
+
+// Flush delivers the buffered records. Records that could not be delivered
+// are kept for the next attempt.
+func (l *Logger) Flush(ctx context.Context) error {
+	l.mu.Lock()
+	buffers := l.buffers
+	l.buffers = make(map[Target]*bytes.Buffer)
+	l.mu.Unlock()
+
+	var errs []string
+
+	for target, buffer := range buffers {
+		key, err := objectKey(target.Prefix, time.Now())
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", target.Bucket, err))
+			l.restore(target, buffer)
+
+			continue
+		}
+
+		if err := l.writer.PutLogObject(ctx, target.Bucket, key, buffer.Bytes()); err != nil {
+			errs = append(errs, fmt.Sprintf("%s/%s: %v", target.Bucket, key, err))
+			l.restore(target, buffer)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("s3accesslog: delivery failed: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func (l *Logger) restore(target Target, buffer *bytes.Buffer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if current, exists := l.buffers[target]; exists {
+		buffer.Write(current.Bytes())
+	}
+
+	l.buffers[target] = buffer
+}
+
+func objectKey(prefix string, now time.Time) (string, error) {
+	unique := make([]byte, 8)
+	if _, err := rand.Read(unique); err != nil {
+		return "", fmt.Errorf("s3accesslog: cannot generate object key: %w", err)
+	}
+
+	return prefix + now.UTC().Format("2006-01-02-15-04-05-") + strings.ToUpper(hex.EncodeToString(unique)), nil
+}
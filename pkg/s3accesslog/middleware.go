@@ -0,0 +1,124 @@
+package s3accesslog
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lvjp/s3impl/pkg/s3auth"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3response"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// TargetFunc returns where the access logs of a bucket go, enabled being
+// false when the bucket does not log its requests.
+type TargetFunc func(ctx context.Context, bucket string) (target Target, enabled bool, err error)
+
+// Middleware logs the requests to the buckets having logging enabled.
+func Middleware(logger *zerolog.Logger, accessLogger *Logger, targets TargetFunc) s3router.Middleware {
+	return func(next s3router.ActionHandler) s3router.ActionHandler {
+		return s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+			if route.Bucket == "" {
+				next.ServeAction(w, r, route)
+				return
+			}
+
+			target, enabled, err := targets(r.Context(), route.Bucket)
+			if err != nil {
+				s3logging.Ctx(r, logger).Error().Err(err).Str("bucket", route.Bucket).Msg("Cannot read logging status")
+			}
+
+			if !enabled {
+				next.ServeAction(w, r, route)
+				return
+			}
+
+			start := time.Now()
+			body := s3response.CountBody(r)
+			rw := s3response.NewRecorder(w)
+			next.ServeAction(rw, r, route)
+
+			accessLogger.Log(target, newRecord(r, route, rw, body.N, start))
+		})
+	}
+}
+
+func newRecord(r *http.Request, route *s3router.Route, rw *s3response.Recorder, received int64, start time.Time) *Record {
+	record := &Record{
+		Bucket:     route.Bucket,
+		Time:       start,
+		RemoteIP:   remoteIP(r),
+		RequestID:  rw.Header().Get("x-amz-request-id"),
+		Operation:  Operation(r.Method, route.Action),
+		Key:        route.Key,
+		RequestURI: r.Method + " " + r.RequestURI + " " + r.Proto,
+		HTTPStatus: rw.Status(),
+		ErrorCode:  rw.ErrorCode,
+		BytesSent:  rw.Written,
+		ObjectSize: objectSize(r, rw, received),
+		TotalTime:  time.Since(start),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		VersionID:  r.URL.Query().Get("versionId"),
+		HostID:     rw.Header().Get(s3errors.HeaderHostID),
+		HostHeader: r.Host,
+	}
+
+	if !rw.FirstByte.IsZero() {
+		record.TurnAroundTime = rw.FirstByte.Sub(start)
+	}
+
+	if requester := s3auth.AccessKey(r); requester != s3auth.Anonymous {
+		record.Requester = requester
+		record.SignatureVersion, record.AuthenticationType = signature(r)
+	}
+
+	if r.TLS != nil {
+		record.CipherSuite = tls.CipherSuiteName(r.TLS.CipherSuite)
+		record.TLSVersion = strings.ReplaceAll(tls.VersionName(r.TLS.Version), " ", "v")
+	}
+
+	return record
+}
+
+// objectSize is the size of the uploaded or downloaded object, the
+// response length of reads and the request length of writes.
+func objectSize(r *http.Request, rw *s3response.Recorder, received int64) int64 {
+	if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		return received
+	}
+
+	size, err := strconv.ParseInt(rw.Header().Get("Content-Length"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return size
+}
+
+// signature returns the signature version and the authentication type of
+// an authenticated request.
+func signature(r *http.Request) (version, authType string) {
+	if r.URL.Query().Has("X-Amz-Credential") {
+		return "SigV4", "QueryString"
+	}
+
+	return "SigV4", "AuthHeader"
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
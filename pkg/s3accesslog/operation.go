@@ -0,0 +1,123 @@
+package s3accesslog
+
+import (
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// resources maps actions to the resource part of the logged operation name.
+var resources = map[s3router.Action]string{
+	s3router.ActionAbortMultipartUpload:                        "UPLOAD",
+	s3router.ActionCompleteMultipartUpload:                     "UPLOAD",
+	s3router.ActionCopyObject:                                  "OBJECT",
+	s3router.ActionCreateBucket:                                "BUCKET",
+	s3router.ActionCreateMultipartUpload:                       "UPLOADS",
+	s3router.ActionDeleteBucket:                                "BUCKET",
+	s3router.ActionDeleteBucketAnalyticsConfiguration:          "ANALYTICS",
+	s3router.ActionDeleteBucketCors:                            "CORS",
+	s3router.ActionDeleteBucketEncryption:                      "ENCRYPTION",
+	s3router.ActionDeleteBucketIntelligentTieringConfiguration: "INTELLIGENT_TIERING",
+	s3router.ActionDeleteBucketInventoryConfiguration:          "INVENTORY",
+	s3router.ActionDeleteBucketLifecycle:                       "LIFECYCLE",
+	s3router.ActionDeleteBucketMetricsConfiguration:            "METRICS",
+	s3router.ActionDeleteBucketOwnershipControls:               "OWNERSHIP_CONTROLS",
+	s3router.ActionDeleteBucketPolicy:                          "BUCKETPOLICY",
+	s3router.ActionDeleteBucketReplication:                     "REPLICATION",
+	s3router.ActionDeleteBucketTagging:                         "TAGGING",
+	s3router.ActionDeleteBucketWebsite:                         "WEBSITE",
+	s3router.ActionDeleteObject:                                "OBJECT",
+	s3router.ActionDeleteObjects:                               "MULTI_OBJECT_DELETE",
+	s3router.ActionDeleteObjectTagging:                         "OBJECT_TAGGING",
+	s3router.ActionDeletePublicAccessBlock:                     "PUBLIC_ACCESS_BLOCK",
+	s3router.ActionGetBucketAccelerateConfiguration:            "ACCELERATE",
+	s3router.ActionGetBucketACL:                                "ACL",
+	s3router.ActionGetBucketAnalyticsConfiguration:             "ANALYTICS",
+	s3router.ActionGetBucketCors:                               "CORS",
+	s3router.ActionGetBucketEncryption:                         "ENCRYPTION",
+	s3router.ActionGetBucketIntelligentTieringConfiguration:    "INTELLIGENT_TIERING",
+	s3router.ActionGetBucketInventoryConfiguration:             "INVENTORY",
+	s3router.ActionGetBucketLifecycleConfiguration:             "LIFECYCLE",
+	s3router.ActionGetBucketLocation:                           "LOCATION",
+	s3router.ActionGetBucketLogging:                            "LOGGING_STATUS",
+	s3router.ActionGetBucketMetricsConfiguration:               "METRICS",
+	s3router.ActionGetBucketNotificationConfiguration:          "NOTIFICATION",
+	s3router.ActionGetBucketOwnershipControls:                  "OWNERSHIP_CONTROLS",
+	s3router.ActionGetBucketPolicy:                             "BUCKETPOLICY",
+	s3router.ActionGetBucketPolicyStatus:                       "POLICY_STATUS",
+	s3router.ActionGetBucketReplication:                        "REPLICATION",
+	s3router.ActionGetBucketRequestPayment:                     "REQUEST_PAYMENT",
+	s3router.ActionGetBucketTagging:                            "TAGGING",
+	s3router.ActionGetBucketVersioning:                         "VERSIONING",
+	s3router.ActionGetBucketWebsite:                            "WEBSITE",
+	s3router.ActionGetObject:                                   "OBJECT",
+	s3router.ActionGetObjectACL:                                "ACL",
+	s3router.ActionGetObjectAttributes:                         "OBJECT_ATTRIBUTES",
+	s3router.ActionGetObjectLegalHold:                          "LEGAL_HOLD",
+	s3router.ActionGetObjectLockConfiguration:                  "OBJECT_LOCK_CONFIGURATION",
+	s3router.ActionGetObjectRetention:                          "RETENTION",
+	s3router.ActionGetObjectTagging:                            "OBJECT_TAGGING",
+	s3router.ActionGetObjectTorrent:                            "TORRENT",
+	s3router.ActionGetPublicAccessBlock:                        "PUBLIC_ACCESS_BLOCK",
+	s3router.ActionGetWebsiteObject:                            "OBJECT",
+	s3router.ActionHeadBucket:                                  "BUCKET",
+	s3router.ActionHeadObject:                                  "OBJECT",
+	s3router.ActionHeadWebsiteObject:                           "OBJECT",
+	s3router.ActionListBucketAnalyticsConfigurations:           "ANALYTICS",
+	s3router.ActionListBucketIntelligentTieringConfigurations:  "INTELLIGENT_TIERING",
+	s3router.ActionListBucketInventoryConfigurations:           "INVENTORY",
+	s3router.ActionListBucketMetricsConfigurations:             "METRICS",
+	s3router.ActionListBuckets:                                 "SERVICE",
+	s3router.ActionListenBucketNotification:                    "EVENTS",
+	s3router.ActionListMultipartUploads:                        "UPLOADS",
+	s3router.ActionListObjects:                                 "BUCKET",
+	s3router.ActionListObjectVersions:                          "BUCKETVERSIONS",
+	s3router.ActionListParts:                                   "UPLOAD",
+	s3router.ActionPutBucketAccelerateConfiguration:            "ACCELERATE",
+	s3router.ActionPutBucketACL:                                "ACL",
+	s3router.ActionPutBucketAnalyticsConfiguration:             "ANALYTICS",
+	s3router.ActionPutBucketCors:                               "CORS",
+	s3router.ActionPutBucketEncryption:                         "ENCRYPTION",
+	s3router.ActionPutBucketIntelligentTieringConfiguration:    "INTELLIGENT_TIERING",
+	s3router.ActionPutBucketInventoryConfiguration:             "INVENTORY",
+	s3router.ActionPutBucketLifecycleConfiguration:             "LIFECYCLE",
+	s3router.ActionPutBucketLogging:                            "LOGGING_STATUS",
+	s3router.ActionPutBucketMetricsConfiguration:               "METRICS",
+	s3router.ActionPutBucketNotificationConfiguration:          "NOTIFICATION",
+	s3router.ActionPutBucketOwnershipControls:                  "OWNERSHIP_CONTROLS",
+	s3router.ActionPutBucketPolicy:                             "BUCKETPOLICY",
+	s3router.ActionPutBucketReplication:                        "REPLICATION",
+	s3router.ActionPutBucketRequestPayment:                     "REQUEST_PAYMENT",
+	s3router.ActionPutBucketTagging:                            "TAGGING",
+	s3router.ActionPutBucketVersioning:                         "VERSIONING",
+	s3router.ActionPutBucketWebsite:                            "WEBSITE",
+	s3router.ActionPutObject:                                   "OBJECT",
+	s3router.ActionPutObjectACL:                                "ACL",
+	s3router.ActionPutObjectLegalHold:                          "LEGAL_HOLD",
+	s3router.ActionPutObjectLockConfiguration:                  "OBJECT_LOCK_CONFIGURATION",
+	s3router.ActionPutObjectRetention:                          "RETENTION",
+	s3router.ActionPutObjectTagging:                            "OBJECT_TAGGING",
+	s3router.ActionPutPublicAccessBlock:                        "PUBLIC_ACCESS_BLOCK",
+	s3router.ActionRestoreObject:                               "RESTORE",
+	s3router.ActionSelectObjectContent:                         "SELECT",
+	s3router.ActionUploadPart:                                  "PART",
+	s3router.ActionUploadPartCopy:                              "PART",
+}
+
+// Operation returns the operation name of the access log records, like
+// REST.GET.OBJECT. Copies use the COPY pseudo method and website requests
+// the WEBSITE API, as S3 does.
+func Operation(method string, action s3router.Action) string {
+	resource, exists := resources[action]
+	if !exists {
+		resource = "UNKNOWN"
+	}
+
+	if action == s3router.ActionCopyObject || action == s3router.ActionUploadPartCopy {
+		method = "COPY"
+	}
+
+	if action == s3router.ActionGetWebsiteObject || action == s3router.ActionHeadWebsiteObject {
+		return "WEBSITE." + method + "." + resource
+	}
+
+	return "REST." + method + "." + resource
+}
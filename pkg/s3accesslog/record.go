@@ -0,0 +1,96 @@
+package s3accesslog
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one line of an access log, in the AWS server access log format.
+type Record struct {
+	BucketOwner        string
+	Bucket             string
+	Time               time.Time
+	RemoteIP           string
+	Requester          string
+	RequestID          string
+	Operation          string
+	Key                string
+	RequestURI         string
+	HTTPStatus         int
+	ErrorCode          string
+	BytesSent          int64
+	ObjectSize         int64
+	TotalTime          time.Duration
+	TurnAroundTime     time.Duration
+	Referer            string
+	UserAgent          string
+	VersionID          string
+	HostID             string
+	SignatureVersion   string
+	CipherSuite        string
+	AuthenticationType string
+	HostHeader         string
+	TLSVersion         string
+}
+
+const timeLayout = "[02/Jan/2006:15:04:05 -0700]"
+
+// String formats the record as a log line, without the trailing newline.
+func (r *Record) String() string {
+	fields := []string{
+		dash(r.BucketOwner),
+		dash(r.Bucket),
+		r.Time.UTC().Format(timeLayout),
+		dash(r.RemoteIP),
+		dash(r.Requester),
+		dash(r.RequestID),
+		dash(r.Operation),
+		dash(strings.ReplaceAll(url.PathEscape(r.Key), "%2F", "/")),
+		quote(r.RequestURI),
+		number(int64(r.HTTPStatus)),
+		dash(r.ErrorCode),
+		number(r.BytesSent),
+		number(r.ObjectSize),
+		number(r.TotalTime.Milliseconds()),
+		number(r.TurnAroundTime.Milliseconds()),
+		quote(r.Referer),
+		quote(r.UserAgent),
+		dash(r.VersionID),
+		dash(r.HostID),
+		dash(r.SignatureVersion),
+		dash(r.CipherSuite),
+		dash(r.AuthenticationType),
+		dash(r.HostHeader),
+		dash(r.TLSVersion),
+		"-", // Access point ARN
+		"-", // ACL required
+	}
+
+	return strings.Join(fields, " ")
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func quote(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func number(value int64) string {
+	if value <= 0 {
+		return "-"
+	}
+
+	return strconv.FormatInt(value, 10)
+}
@@ -0,0 +1,95 @@
+package s3archive
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+func requireCode(t *testing.T, code string, err error) {
+	t.Helper()
+
+	var s3err *s3errors.S3Error
+	require.ErrorAs(t, err, &s3err)
+	require.Equal(t, code, s3err.Code)
+}
+
+func TestParseStorageClass(t *testing.T) {
+	class, err := ParseStorageClass(http.Header{})
+	require.NoError(t, err)
+	require.Equal(t, StorageClassStandard, class)
+
+	class, err = ParseStorageClass(http.Header{"X-Amz-Storage-Class": {"DEEP_ARCHIVE"}})
+	require.NoError(t, err)
+	require.True(t, class.Archived())
+
+	_, err = ParseStorageClass(http.Header{"X-Amz-Storage-Class": {"COLD"}})
+	requireCode(t, "InvalidStorageClass", err)
+
+	header := http.Header{}
+	StorageClassStandard.SetHeader(header)
+	require.Empty(t, header)
+	StorageClassGlacier.SetHeader(header)
+	require.Equal(t, "GLACIER", header.Get(HeaderStorageClass))
+}
+
+func TestParseRestoreRequest(t *testing.T) {
+	req, err := ParseRestoreRequest([]byte(`<RestoreRequest><Days>2</Days></RestoreRequest>`))
+	require.NoError(t, err)
+	require.Equal(t, 2, req.Days)
+	require.Equal(t, TierStandard, req.GlacierJobParameters.Tier)
+
+	_, err = ParseRestoreRequest([]byte(`<RestoreRequest><Days>0</Days></RestoreRequest>`))
+	requireCode(t, "MalformedXML", err)
+
+	_, err = ParseRestoreRequest([]byte(`<RestoreRequest><Days>1</Days><GlacierJobParameters><Tier>Fast</Tier></GlacierJobParameters></RestoreRequest>`))
+	requireCode(t, "MalformedXML", err)
+
+	_, err = ParseRestoreRequest([]byte(`<RestoreRequest><Type>SELECT</Type></RestoreRequest>`))
+	requireCode(t, "NotImplemented", err)
+}
+
+func TestRestorer(t *testing.T) {
+	r := NewRestorer(Config{RestoreDelay: time.Minute, DayDuration: time.Hour})
+	id := ObjectID{Bucket: "bucket", Key: "key"}
+	req := &RestoreRequest{Days: 2}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, r.CheckRead(id, StorageClassStandard, now))
+	requireCode(t, "InvalidObjectState", r.CheckRead(id, StorageClassGlacier, now))
+
+	_, err := r.Restore(id, StorageClassStandardIA, req, now)
+	requireCode(t, "InvalidObjectState", err)
+
+	status, err := r.Restore(id, StorageClassGlacier, req, now)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, status)
+
+	header := http.Header{}
+	r.SetHeaders(header, id, now)
+	require.Equal(t, `ongoing-request="true"`, header.Get(HeaderRestore))
+	requireCode(t, "InvalidObjectState", r.CheckRead(id, StorageClassGlacier, now))
+
+	_, err = r.Restore(id, StorageClassGlacier, req, now.Add(time.Second))
+	requireCode(t, "RestoreAlreadyInProgress", err)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, r.CheckRead(id, StorageClassGlacier, now))
+	r.SetHeaders(header, id, now)
+	require.Equal(t, `ongoing-request="false", expiry-date="Mon, 01 Jan 2024 02:01:00 GMT"`, header.Get(HeaderRestore))
+
+	status, err = r.Restore(id, StorageClassGlacier, &RestoreRequest{Days: 3}, now)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+
+	now = now.Add(3 * time.Hour)
+	requireCode(t, "InvalidObjectState", r.CheckRead(id, StorageClassGlacier, now))
+
+	header = http.Header{}
+	r.SetHeaders(header, id, now)
+	require.Empty(t, header)
+}
@@ -0,0 +1,10 @@
+package s3archive
+
+import (
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+func notImplemented(feature string) *s3errors.S3Error {
+	return s3errors.NotImplemented.New().
+		WithMessage("A header or parameter you provided implies functionality that is not implemented: " + feature)
+}
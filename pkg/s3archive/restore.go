@@ -0,0 +1,192 @@
+// Package s3archive emulates the archive storage classes and their restores.
+//
+// It is a library only: the server stores no objects yet, so nothing builds
+// a Restorer and RestoreObject is not served. Only the storage class header
+// and the RestoreRequest body are decoded by s3request.
+package s3archive
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type Tier string
+
+const (
+	TierStandard  Tier = "Standard"
+	TierBulk      Tier = "Bulk"
+	TierExpedited Tier = "Expedited"
+
+	HeaderRestore = "x-amz-restore"
+)
+
+type RestoreRequest struct {
+	XMLName              xml.Name              `xml:"RestoreRequest"`
+	Days                 int                   `xml:",omitempty"`
+	GlacierJobParameters *GlacierJobParameters `xml:",omitempty"`
+	Type                 string                `xml:",omitempty"`
+}
+
+type GlacierJobParameters struct {
+	Tier Tier
+}
+
+// ParseRestoreRequest decodes and validates a RestoreObject payload. Only
+// the restoration of an archived copy is supported, not select queries.
+func ParseRestoreRequest(payload []byte) (*RestoreRequest, error) {
+	var req RestoreRequest
+	if err := xml.Unmarshal(payload, &req); err != nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	if req.Type != "" {
+		return nil, notImplemented("RestoreRequest Type")
+	}
+
+	if req.Days < 1 {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	if req.GlacierJobParameters == nil {
+		req.GlacierJobParameters = &GlacierJobParameters{Tier: TierStandard}
+	}
+
+	switch req.GlacierJobParameters.Tier {
+	case TierStandard, TierBulk, TierExpedited:
+	default:
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	return &req, nil
+}
+
+type Config struct {
+	// RestoreDelay is the simulated time an archived object takes to be
+	// restored.
+	RestoreDelay time.Duration `yaml:"restoreDelay"`
+	// DayDuration is the length of a day of restoration, shorter than 24h
+	// to test the expiry of restored copies.
+	DayDuration time.Duration `yaml:"dayDuration"`
+}
+
+const DefaultDayDuration = 24 * time.Hour
+
+// ObjectID identifies an object version.
+type ObjectID struct {
+	Bucket    string
+	Key       string
+	VersionID string
+}
+
+type restoration struct {
+	ready   time.Time
+	expires time.Time
+}
+
+// Restorer emulates the restoration of archived objects: a restored copy
+// becomes readable after the configured delay and expires after the
+// requested number of days.
+type Restorer struct {
+	config Config
+
+	mu           sync.Mutex
+	restorations map[ObjectID]restoration
+}
+
+func NewRestorer(config Config) *Restorer {
+	if config.DayDuration == 0 {
+		config.DayDuration = DefaultDayDuration
+	}
+
+	return &Restorer{
+		config:       config,
+		restorations: make(map[ObjectID]restoration),
+	}
+}
+
+// Restore starts or extends the restoration of an object and returns the
+// status of the RestoreObject response: 202 when a restoration starts and
+// 200 when the expiry of an available copy is updated.
+func (r *Restorer) Restore(id ObjectID, class StorageClass, req *RestoreRequest, now time.Time) (int, error) {
+	if !class.Archived() {
+		return 0, s3errors.InvalidObjectState.New().WithMessage("Restore is not allowed for the object's current storage class")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	days := time.Duration(req.Days) * r.config.DayDuration
+
+	if current, ok := r.current(id, now); ok {
+		if now.Before(current.ready) {
+			return 0, s3errors.RestoreAlreadyInProgress.New()
+		}
+
+		current.expires = now.Add(days)
+		r.restorations[id] = current
+
+		return http.StatusOK, nil
+	}
+
+	ready := now.Add(r.config.RestoreDelay)
+	r.restorations[id] = restoration{ready: ready, expires: ready.Add(days)}
+
+	return http.StatusAccepted, nil
+}
+
+// current returns the restoration of id, forgetting it once expired. The
+// caller must hold the lock.
+func (r *Restorer) current(id ObjectID, now time.Time) (restoration, bool) {
+	current, ok := r.restorations[id]
+	if ok && !now.Before(current.expires) {
+		delete(r.restorations, id)
+		return restoration{}, false
+	}
+
+	return current, ok
+}
+
+// CheckRead returns InvalidObjectState when an archived object has no
+// restored copy available.
+func (r *Restorer) CheckRead(id ObjectID, class StorageClass, now time.Time) error {
+	if !class.Archived() {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.current(id, now); ok && !now.Before(current.ready) {
+		return nil
+	}
+
+	return s3errors.InvalidObjectState.New().WithMessage("The operation is not valid for the object's storage class")
+}
+
+// SetHeaders sets the x-amz-restore header of a HeadObject or GetObject
+// response when the object is being or has been restored.
+func (r *Restorer) SetHeaders(header http.Header, id ObjectID, now time.Time) {
+	r.mu.Lock()
+	current, ok := r.current(id, now)
+	r.mu.Unlock()
+
+	switch {
+	case !ok:
+	case now.Before(current.ready):
+		header.Set(HeaderRestore, `ongoing-request="true"`)
+	default:
+		header.Set(HeaderRestore, `ongoing-request="false", expiry-date="`+current.expires.UTC().Format(http.TimeFormat)+`"`)
+	}
+}
+
+// Forget drops the restoration of a deleted or overwritten object.
+func (r *Restorer) Forget(id ObjectID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.restorations, id)
+}
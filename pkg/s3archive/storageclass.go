@@ -0,0 +1,67 @@
+package s3archive
+
+import (
+	"net/http"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type StorageClass string
+
+const (
+	StorageClassStandard           StorageClass = "STANDARD"
+	StorageClassReducedRedundancy  StorageClass = "REDUCED_REDUNDANCY"
+	StorageClassStandardIA         StorageClass = "STANDARD_IA"
+	StorageClassOneZoneIA          StorageClass = "ONEZONE_IA"
+	StorageClassIntelligentTiering StorageClass = "INTELLIGENT_TIERING"
+	StorageClassGlacier            StorageClass = "GLACIER"
+	StorageClassDeepArchive        StorageClass = "DEEP_ARCHIVE"
+	StorageClassGlacierIR          StorageClass = "GLACIER_IR"
+	StorageClassOutposts           StorageClass = "OUTPOSTS"
+	StorageClassSnow               StorageClass = "SNOW"
+	StorageClassExpressOneZone     StorageClass = "EXPRESS_ONEZONE"
+
+	HeaderStorageClass = "x-amz-storage-class"
+)
+
+func (c StorageClass) Valid() bool {
+	switch c {
+	case StorageClassStandard, StorageClassReducedRedundancy, StorageClassStandardIA,
+		StorageClassOneZoneIA, StorageClassIntelligentTiering, StorageClassGlacier,
+		StorageClassDeepArchive, StorageClassGlacierIR, StorageClassOutposts,
+		StorageClassSnow, StorageClassExpressOneZone:
+		return true
+	default:
+		return false
+	}
+}
+
+// Archived reports whether objects of the class must be restored before
+// they can be read. GLACIER_IR objects are immediately readable.
+func (c StorageClass) Archived() bool {
+	return c == StorageClassGlacier || c == StorageClassDeepArchive
+}
+
+// ParseStorageClass reads the storage class of a PutObject, CopyObject or
+// CreateMultipartUpload request, STANDARD when it is not set.
+func ParseStorageClass(header http.Header) (StorageClass, error) {
+	value := header.Get(HeaderStorageClass)
+	if value == "" {
+		return StorageClassStandard, nil
+	}
+
+	class := StorageClass(value)
+	if !class.Valid() {
+		return "", s3errors.InvalidStorageClass.New()
+	}
+
+	return class, nil
+}
+
+// SetHeader sets the storage class of a HeadObject or GetObject response.
+// Like S3, nothing is set for STANDARD objects.
+func (c StorageClass) SetHeader(header http.Header) {
+	if c != "" && c != StorageClassStandard {
+		header.Set(HeaderStorageClass, string(c))
+	}
+}
@@ -0,0 +1,69 @@
+package s3attributes
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/lvjp/s3impl/pkg/s3checksum"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	attribute, found := Lookup("objectsize")
+	require.True(t, found)
+	require.Equal(t, AttributeObjectSize, attribute)
+
+	_, found = Lookup("Owner")
+	require.False(t, found)
+}
+
+func TestNewResponse(t *testing.T) {
+	obj := &Object{
+		ETag:     `"d41d8cd98f00b204e9800998ecf8427e-3"`,
+		Checksum: &s3checksum.Checksum{Algorithm: s3checksum.AlgorithmCRC32, Type: s3checksum.TypeComposite, Value: "AAAAAA==", PartsCount: 3},
+		Size:     30,
+		Parts: []Part{
+			{PartNumber: 3, Size: 10, Checksum: &s3checksum.Checksum{Algorithm: s3checksum.AlgorithmCRC32, Type: s3checksum.TypeFullObject, Value: "AAAAAw=="}},
+			{PartNumber: 1, Size: 10},
+			{PartNumber: 2, Size: 10},
+		},
+	}
+
+	req := &Request{
+		Attributes: map[Attribute]bool{
+			AttributeETag:         true,
+			AttributeChecksum:     true,
+			AttributeObjectParts:  true,
+			AttributeStorageClass: true,
+			AttributeObjectSize:   true,
+		},
+		MaxParts:         1,
+		PartNumberMarker: 1,
+	}
+
+	payload, err := xml.Marshal(NewResponse(obj, req))
+	require.NoError(t, err)
+	require.Equal(t,
+		`<GetObjectAttributesResponse xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`+
+			`<ETag>d41d8cd98f00b204e9800998ecf8427e-3</ETag>`+
+			`<Checksum><ChecksumCRC32>AAAAAA==</ChecksumCRC32><ChecksumType>COMPOSITE</ChecksumType></Checksum>`+
+			`<ObjectParts>`+
+			`<IsTruncated>true</IsTruncated><MaxParts>1</MaxParts><NextPartNumberMarker>2</NextPartNumberMarker><PartNumberMarker>1</PartNumberMarker>`+
+			`<Part><PartNumber>2</PartNumber><Size>10</Size></Part>`+
+			`<PartsCount>3</PartsCount>`+
+			`</ObjectParts>`+
+			`<StorageClass>STANDARD</StorageClass>`+
+			`<ObjectSize>30</ObjectSize>`+
+			`</GetObjectAttributesResponse>`,
+		string(payload),
+	)
+
+	req.PartNumberMarker = 2
+	parts := NewResponse(obj, req).ObjectParts
+	require.False(t, parts.IsTruncated)
+	require.Equal(t, []ResponsePart{{
+		Fields:     s3checksum.Fields{ChecksumCRC32: "AAAAAw=="},
+		PartNumber: 3,
+		Size:       10,
+	}}, parts.Parts)
+}
@@ -0,0 +1,42 @@
+package s3attributes
+
+import (
+	"strings"
+)
+
+type Attribute string
+
+const (
+	AttributeChecksum     Attribute = "Checksum"
+	AttributeETag         Attribute = "ETag"
+	AttributeObjectParts  Attribute = "ObjectParts"
+	AttributeObjectSize   Attribute = "ObjectSize"
+	AttributeStorageClass Attribute = "StorageClass"
+)
+
+var attributes = []Attribute{
+	AttributeChecksum,
+	AttributeETag,
+	AttributeObjectParts,
+	AttributeObjectSize,
+	AttributeStorageClass,
+}
+
+// Request holds the attributes asked by GetObjectAttributes and the page of
+// parts to return, as decoded by s3request.
+type Request struct {
+	Attributes       map[Attribute]bool
+	MaxParts         int
+	PartNumberMarker int
+}
+
+// Lookup returns the attribute named name, regardless of its case.
+func Lookup(name string) (Attribute, bool) {
+	for _, attribute := range attributes {
+		if strings.EqualFold(name, string(attribute)) {
+			return attribute, true
+		}
+	}
+
+	return "", false
+}
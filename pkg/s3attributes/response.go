@@ -0,0 +1,146 @@
+// Package s3attributes builds the GetObjectAttributes responses.
+//
+// It is a library only: s3request decodes the requests, but no handler
+// serves GetObjectAttributes until objects are stored.
+package s3attributes
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3checksum"
+	"github.com/lvjp/s3impl/pkg/s3consts"
+)
+
+// Object is what the storage knows about the object being queried.
+type Object struct {
+	ETag         string
+	Checksum     *s3checksum.Checksum
+	StorageClass string
+	Size         int64
+	LastModified time.Time
+	VersionID    string
+	// Parts is only set for objects created by a multipart upload.
+	Parts []Part
+}
+
+type Part struct {
+	PartNumber int
+	Size       int64
+	Checksum   *s3checksum.Checksum
+}
+
+type Response struct {
+	XMLName      xml.Name           `xml:"GetObjectAttributesResponse"`
+	Namespace    string             `xml:"xmlns,attr"`
+	ETag         string             `xml:",omitempty"`
+	Checksum     *s3checksum.Fields `xml:",omitempty"`
+	ObjectParts  *ObjectParts       `xml:",omitempty"`
+	StorageClass string             `xml:",omitempty"`
+	ObjectSize   *int64             `xml:",omitempty"`
+}
+
+type ObjectParts struct {
+	IsTruncated          bool
+	MaxParts             int
+	NextPartNumberMarker int
+	PartNumberMarker     int
+	Parts                []ResponsePart `xml:"Part"`
+	PartsCount           int
+}
+
+type ResponsePart struct {
+	s3checksum.Fields
+	PartNumber int
+	Size       int64
+}
+
+// NewResponse builds the GetObjectAttributes payload, with only the
+// requested attributes and a single page of parts.
+func NewResponse(obj *Object, req *Request) *Response {
+	resp := &Response{
+		Namespace: s3consts.XMLNamespace,
+	}
+
+	if req.Attributes[AttributeETag] {
+		resp.ETag = strings.Trim(obj.ETag, `"`)
+	}
+
+	if req.Attributes[AttributeChecksum] && obj.Checksum != nil {
+		fields := obj.Checksum.Fields()
+		resp.Checksum = &fields
+	}
+
+	if req.Attributes[AttributeObjectParts] && len(obj.Parts) > 0 {
+		resp.ObjectParts = newObjectParts(obj.Parts, req)
+	}
+
+	if req.Attributes[AttributeStorageClass] {
+		resp.StorageClass = obj.StorageClass
+		if resp.StorageClass == "" {
+			resp.StorageClass = "STANDARD"
+		}
+	}
+
+	if req.Attributes[AttributeObjectSize] {
+		resp.ObjectSize = &obj.Size
+	}
+
+	return resp
+}
+
+func newObjectParts(parts []Part, req *Request) *ObjectParts {
+	sorted := make([]Part, len(parts))
+	copy(sorted, parts)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].PartNumber < sorted[j].PartNumber
+	})
+
+	objectParts := &ObjectParts{
+		MaxParts:         req.MaxParts,
+		PartNumberMarker: req.PartNumberMarker,
+		PartsCount:       len(sorted),
+	}
+
+	for _, part := range sorted {
+		if part.PartNumber <= req.PartNumberMarker {
+			continue
+		}
+
+		if len(objectParts.Parts) == req.MaxParts {
+			objectParts.IsTruncated = true
+			break
+		}
+
+		respPart := ResponsePart{
+			PartNumber: part.PartNumber,
+			Size:       part.Size,
+		}
+
+		if part.Checksum != nil {
+			respPart.Fields = part.Checksum.Fields()
+			respPart.ChecksumType = ""
+		}
+
+		objectParts.Parts = append(objectParts.Parts, respPart)
+		objectParts.NextPartNumberMarker = part.PartNumber
+	}
+
+	return objectParts
+}
+
+// SetHeaders sets the response headers accompanying the payload.
+func SetHeaders(header http.Header, obj *Object) {
+	header.Set("Content-Type", s3consts.MimetypeApplicationXML)
+
+	if !obj.LastModified.IsZero() {
+		header.Set("Last-Modified", obj.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if obj.VersionID != "" {
+		header.Set("x-amz-version-id", obj.VersionID)
+	}
+}
@@ -0,0 +1,215 @@
+package s3audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Genesis is the previous hash of the first record.
+var Genesis = strings.Repeat("0", sha256.Size*2)
+
+type Config struct {
+	// File is the JSON Lines audit log, auditing is disabled when it is
+	// empty.
+	File string
+}
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Record is an audited request. Hash covers every other field, including
+// the hash of the previous record.
+//
+// UnverifiedPrincipal is the access key the request claims to be signed
+// with: signatures are not verified yet.
+type Record struct {
+	Sequence            int64     `json:"sequence"`
+	Time                time.Time `json:"time"`
+	Action              string    `json:"action"`
+	UnverifiedPrincipal string    `json:"unverifiedPrincipal"`
+	SourceIP            string    `json:"sourceIp"`
+	RequestID           string    `json:"requestId"`
+	Resource            string    `json:"resource"`
+	Status              int       `json:"status"`
+	ErrorCode           string    `json:"errorCode,omitempty"`
+	Outcome             Outcome   `json:"outcome"`
+	PrevHash            string    `json:"prevHash"`
+	Hash                string    `json:"hash,omitempty"`
+}
+
+func (r *Record) computeHash() (string, error) {
+	unhashed := *r
+	unhashed.Hash = ""
+
+	payload, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", fmt.Errorf("s3audit: cannot encode record: %w", err)
+	}
+
+	sum := sha256.Sum256(payload)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends the records to the audit file, each one chained to the
+// previous one by its hash.
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	sequence int64
+	prevHash string
+}
+
+// Open opens the audit file, the chain continues from its last record.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("s3audit: cannot open log: %w", err)
+	}
+
+	log := &Log{file: file, prevHash: Genesis}
+
+	last, err := lastRecord(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if last != nil {
+		log.sequence = last.Sequence
+		log.prevHash = last.Hash
+	}
+
+	return log, nil
+}
+
+func lastRecord(r io.Reader) (*Record, error) {
+	var last []byte
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("s3audit: cannot read log: %w", err)
+	}
+
+	if last == nil {
+		return nil, nil //nolint:nilnil // the log is empty
+	}
+
+	var record Record
+	if err := json.Unmarshal(last, &record); err != nil {
+		return nil, fmt.Errorf("s3audit: corrupted last record: %w", err)
+	}
+
+	return &record, nil
+}
+
+// Append chains record to the log and writes it synchronously.
+func (l *Log) Append(record Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.Sequence = l.sequence + 1
+	record.PrevHash = l.prevHash
+
+	hash, err := record.computeHash()
+	if err != nil {
+		return err
+	}
+
+	record.Hash = hash
+
+	line, err := json.Marshal(&record)
+	if err != nil {
+		return fmt.Errorf("s3audit: cannot encode record: %w", err)
+	}
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("s3audit: cannot write record: %w", err)
+	}
+
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("s3audit: cannot sync log: %w", err)
+	}
+
+	l.sequence = record.Sequence
+	l.prevHash = record.Hash
+
+	return nil
+}
+
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// ErrBrokenChain is returned by Verify when a record was altered, removed
+// or inserted.
+var ErrBrokenChain = errors.New("s3audit: broken hash chain")
+
+// Verify checks the hash chain of an audit log and returns the number of
+// verified records.
+func Verify(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	prevHash := Genesis
+	var count int64
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("%w: line %d: invalid record: %v", ErrBrokenChain, line, err)
+		}
+
+		if record.Sequence != count+1 {
+			return count, fmt.Errorf("%w: line %d: sequence %d, expected %d", ErrBrokenChain, line, record.Sequence, count+1)
+		}
+
+		if record.PrevHash != prevHash {
+			return count, fmt.Errorf("%w: line %d: previous hash mismatch", ErrBrokenChain, line)
+		}
+
+		hash, err := record.computeHash()
+		if err != nil {
+			return count, err
+		}
+
+		if record.Hash != hash {
+			return count, fmt.Errorf("%w: line %d: record hash mismatch", ErrBrokenChain, line)
+		}
+
+		prevHash = record.Hash
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("s3audit: cannot read log: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,99 @@
+package s3audit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	log, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, log.Append(Record{Time: time.Now(), Action: "ActionCreateBucket", Outcome: OutcomeSuccess}))
+	require.NoError(t, log.Append(Record{Time: time.Now(), Action: "ActionPutObject", Outcome: OutcomeSuccess}))
+	require.NoError(t, log.Close())
+
+	// The chain continues after reopening.
+	log, err = Open(path)
+	require.NoError(t, err)
+	require.NoError(t, log.Append(Record{Time: time.Now(), Action: "ActionDeleteObject", Outcome: OutcomeFailure}))
+	require.NoError(t, log.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	count, err := Verify(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+
+	lines := strings.SplitAfter(string(content), "\n")
+
+	for name, tampered := range map[string]string{
+		"altered":   lines[0] + strings.Replace(lines[1], "ActionPutObject", "ActionGetObject", 1) + lines[2],
+		"removed":   lines[0] + lines[2],
+		"reordered": lines[1] + lines[0] + lines[2],
+		"garbage":   lines[0] + "{\n" + lines[1],
+	} {
+		_, err := Verify(strings.NewReader(tampered))
+		require.ErrorIs(t, err, ErrBrokenChain, name)
+	}
+
+	count, err = Verify(strings.NewReader(lines[0] + lines[1]))
+	require.NoError(t, err, "truncated logs are not detected")
+	require.Equal(t, int64(2), count)
+}
+
+func TestMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	log, err := Open(path)
+	require.NoError(t, err)
+
+	logger := zerolog.Nop()
+	handler := Middleware(&logger, log)(s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, _ *s3router.Route) {
+		if r.Method == http.MethodDelete {
+			writer := s3errors.APIWriter{}
+			require.NoError(t, writer.Write(&s3errors.S3Error{HTTPStatusCode: http.StatusForbidden, Code: "AccessDenied"}, w, r))
+		}
+	}))
+
+	serve := func(method string, action s3router.Action) {
+		r := httptest.NewRequest(method, "/bucket/key", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request")
+
+		w := httptest.NewRecorder()
+		w.Header().Set("x-amz-request-id", "REQ")
+		handler.ServeAction(w, r, &s3router.Route{Action: action, Bucket: "bucket", Key: "key"})
+	}
+
+	serve(http.MethodGet, s3router.ActionGetObject)
+	serve(http.MethodPost, s3router.ActionSelectObjectContent)
+	serve(http.MethodPut, s3router.ActionPutObject)
+	serve(http.MethodDelete, s3router.ActionDeleteObject)
+	require.NoError(t, log.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	count, err := Verify(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Contains(t, lines[0], `"action":"ActionPutObject","unverifiedPrincipal":"AKID","sourceIp":"192.0.2.1","requestId":"REQ","resource":"arn:aws:s3:::bucket/key","status":200,"outcome":"success"`)
+	require.Contains(t, lines[1], `"status":403,"errorCode":"AccessDenied","outcome":"failure"`)
+}
@@ -0,0 +1,82 @@
+package s3audit
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lvjp/s3impl/pkg/s3auth"
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3response"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// Mutating reports whether a request changes a bucket or an object.
+func Mutating(r *http.Request, route *s3router.Route) bool {
+	switch route.Action {
+	case s3router.ActionUnknow, s3router.ActionSelectObjectContent:
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// Middleware appends the mutating requests to log once they are served.
+func Middleware(logger *zerolog.Logger, log *Log) s3router.Middleware {
+	return func(next s3router.ActionHandler) s3router.ActionHandler {
+		return s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+			if !Mutating(r, route) {
+				next.ServeAction(w, r, route)
+				return
+			}
+
+			rw := s3response.NewRecorder(w)
+			next.ServeAction(rw, r, route)
+
+			outcome := OutcomeSuccess
+			if rw.Status() >= http.StatusBadRequest {
+				outcome = OutcomeFailure
+			}
+
+			err := log.Append(Record{
+				Time:                time.Now().UTC(),
+				Action:              route.Action.String(),
+				UnverifiedPrincipal: s3auth.AccessKey(r),
+				SourceIP:            sourceIP(r),
+				RequestID:           w.Header().Get("x-amz-request-id"),
+				Resource:            resource(route),
+				Status:              rw.Status(),
+				ErrorCode:           rw.ErrorCode,
+				Outcome:             outcome,
+			})
+			if err != nil {
+				s3logging.Ctx(r, logger).Error().Err(err).Msg("Cannot append audit record")
+			}
+		})
+	}
+}
+
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// resource returns the ARN of the bucket or object of a route.
+func resource(route *s3router.Route) string {
+	if route.Key == "" {
+		return "arn:aws:s3:::" + route.Bucket
+	}
+
+	return "arn:aws:s3:::" + route.Bucket + "/" + route.Key
+}
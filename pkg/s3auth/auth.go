@@ -0,0 +1,26 @@
+package s3auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Anonymous is the access key of unsigned requests.
+const Anonymous = "anonymous"
+
+// AccessKey returns the access key a request claims to be signed with, from
+// the Authorization header or the X-Amz-Credential query parameter of
+// presigned URLs. The signature is not verified: the access key identifies
+// the claimed sender, not an authenticated one.
+func AccessKey(r *http.Request) string {
+	credential := r.URL.Query().Get("X-Amz-Credential")
+	if _, after, found := strings.Cut(r.Header.Get("Authorization"), "Credential="); found {
+		credential = after
+	}
+
+	if accessKey, _, _ := strings.Cut(credential, "/"); accessKey != "" {
+		return accessKey
+	}
+
+	return Anonymous
+}
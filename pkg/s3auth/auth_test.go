@@ -0,0 +1,20 @@
+package s3auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	require.Equal(t, Anonymous, AccessKey(r))
+
+	r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=x")
+	require.Equal(t, "AKID", AccessKey(r))
+
+	r = httptest.NewRequest(http.MethodGet, "/bucket/key?X-Amz-Credential=PRESIGNED%2F20240101%2Fus-east-1%2Fs3%2Faws4_request", nil)
+	require.Equal(t, "PRESIGNED", AccessKey(r))
+}
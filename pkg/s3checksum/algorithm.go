@@ -0,0 +1,85 @@
+package s3checksum
+
+import (
+	"crypto/sha1" //nolint:gosec // SHA1 is part of the S3 API, not used for security here
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"strings"
+)
+
+type Algorithm string
+
+const (
+	AlgorithmCRC32     Algorithm = "CRC32"
+	AlgorithmCRC32C    Algorithm = "CRC32C"
+	AlgorithmCRC64NVME Algorithm = "CRC64NVME"
+	AlgorithmSHA1      Algorithm = "SHA1"
+	AlgorithmSHA256    Algorithm = "SHA256"
+)
+
+// Algorithms lists every supported algorithm, in the order headers are looked up.
+var Algorithms = []Algorithm{
+	AlgorithmCRC32,
+	AlgorithmCRC32C,
+	AlgorithmCRC64NVME,
+	AlgorithmSHA1,
+	AlgorithmSHA256,
+}
+
+// crc64NVMEPolynomial is the reversed form of the CRC-64/NVME polynomial 0xad93d23594c93659.
+const crc64NVMEPolynomial = 0x9a6c9329ac4bc9b5
+
+var (
+	crc32cTable    = crc32.MakeTable(crc32.Castagnoli)
+	crc64NVMETable = crc64.MakeTable(crc64NVMEPolynomial)
+)
+
+func ParseAlgorithm(name string) (Algorithm, error) {
+	upper := Algorithm(strings.ToUpper(name))
+	for _, algorithm := range Algorithms {
+		if algorithm == upper {
+			return algorithm, nil
+		}
+	}
+
+	return "", fmt.Errorf("s3checksum: unknown algorithm %q", name)
+}
+
+// HeaderName returns the canonical x-amz-checksum-* header (or trailer) name.
+func (a Algorithm) HeaderName() string {
+	return "x-amz-checksum-" + strings.ToLower(string(a))
+}
+
+func (a Algorithm) New() hash.Hash {
+	switch a {
+	case AlgorithmCRC32:
+		return crc32.NewIEEE()
+	case AlgorithmCRC32C:
+		return crc32.New(crc32cTable)
+	case AlgorithmCRC64NVME:
+		return crc64.New(crc64NVMETable)
+	case AlgorithmSHA1:
+		return sha1.New() //nolint:gosec // see import
+	case AlgorithmSHA256:
+		return sha256.New()
+	default:
+		panic(fmt.Sprintf("s3checksum: unknown algorithm %q", string(a)))
+	}
+}
+
+// Size returns the length in bytes of the raw (non base64) digest.
+func (a Algorithm) Size() int {
+	return a.New().Size()
+}
+
+func (a Algorithm) isCRC() bool {
+	switch a {
+	case AlgorithmCRC32, AlgorithmCRC32C, AlgorithmCRC64NVME:
+		return true
+	default:
+		return false
+	}
+}
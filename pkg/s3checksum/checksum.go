@@ -0,0 +1,184 @@
+// Package s3checksum computes and verifies the integrity checksums of the
+// objects and their parts.
+//
+// The server only uses it to verify the XML bodies decoded by s3request.
+// The object checksums are a library only: no object is stored yet, so they
+// are neither computed on upload nor returned on read.
+package s3checksum
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type Type string
+
+const (
+	TypeComposite  Type = "COMPOSITE"
+	TypeFullObject Type = "FULL_OBJECT"
+)
+
+func ParseType(value string) (Type, error) {
+	switch t := Type(strings.ToUpper(value)); t {
+	case TypeComposite, TypeFullObject:
+		return t, nil
+	default:
+		return "", fmt.Errorf("s3checksum: unknown checksum type %q", value)
+	}
+}
+
+const (
+	HeaderChecksumMode      = "x-amz-checksum-mode"
+	HeaderChecksumType      = "x-amz-checksum-type"
+	HeaderContentMD5        = "Content-MD5"
+	HeaderSDKChecksum       = "x-amz-sdk-checksum-algorithm"
+	HeaderChecksumAlgorithm = "x-amz-checksum-algorithm"
+	HeaderTrailer           = "x-amz-trailer"
+)
+
+// Checksum is a stored checksum, as exposed through the x-amz-checksum-* headers.
+type Checksum struct {
+	Algorithm Algorithm
+	Type      Type
+	// Value is the base64 encoded digest, without the parts count suffix.
+	Value string
+	// PartsCount is only set on composite checksums of multipart objects.
+	PartsCount int `json:",omitempty"`
+}
+
+func newChecksum(algorithm Algorithm, raw []byte) Checksum {
+	return Checksum{
+		Algorithm: algorithm,
+		Type:      TypeFullObject,
+		Value:     base64.StdEncoding.EncodeToString(raw),
+	}
+}
+
+// HeaderValue returns the value as sent on the wire, e.g. "ZJZwbg==-3" for a
+// composite checksum of a three parts object.
+func (c Checksum) HeaderValue() string {
+	if c.Type == TypeComposite && c.PartsCount > 0 {
+		return c.Value + "-" + strconv.Itoa(c.PartsCount)
+	}
+
+	return c.Value
+}
+
+func (c Checksum) Raw() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(c.Value)
+}
+
+// SetHeaders exposes the checksum on a GetObject or HeadObject response.
+func (c Checksum) SetHeaders(header http.Header) {
+	header.Set(c.Algorithm.HeaderName(), c.HeaderValue())
+	header.Set(HeaderChecksumType, string(c.Type))
+}
+
+// ModeEnabled reports whether the client asked for checksums with x-amz-checksum-mode.
+func ModeEnabled(header http.Header) bool {
+	return strings.EqualFold(header.Get(HeaderChecksumMode), "ENABLED")
+}
+
+func parseValue(algorithm Algorithm, value string) (Checksum, error) {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(raw) != algorithm.Size() {
+		return Checksum{}, s3errors.InvalidRequest.New().WithMessage(
+			"Value for " + algorithm.HeaderName() + " header is invalid.",
+		)
+	}
+
+	return newChecksum(algorithm, raw), nil
+}
+
+// Request gathers the integrity information sent along a PutObject or
+// UploadPart request.
+type Request struct {
+	ContentMD5 []byte
+	// Checksum is the value sent as a x-amz-checksum-* header, if any.
+	Checksum *Checksum
+	// Trailer is set when the checksum value will be sent as a trailer.
+	Trailer Algorithm
+	// Algorithm is the algorithm to compute and store for the object.
+	Algorithm Algorithm
+}
+
+func ParseRequest(header http.Header) (*Request, error) {
+	req := &Request{}
+
+	if raw, exists := header[http.CanonicalHeaderKey(HeaderContentMD5)]; exists {
+		md5, err := base64.StdEncoding.DecodeString(raw[0])
+		if err != nil || len(md5) != 16 {
+			return nil, s3errors.InvalidDigest.New()
+		}
+
+		req.ContentMD5 = md5
+	}
+
+	for _, algorithm := range Algorithms {
+		value := header.Get(algorithm.HeaderName())
+		if value == "" {
+			continue
+		}
+
+		if req.Checksum != nil {
+			return nil, s3errors.InvalidRequest.New().WithMessage("Expecting a single x-amz-checksum- header. Multiple checksum Types are not allowed.")
+		}
+
+		checksum, err := parseValue(algorithm, value)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Checksum = &checksum
+		req.Algorithm = algorithm
+	}
+
+	if trailer := header.Get(HeaderTrailer); trailer != "" {
+		algorithm, found := algorithmFromHeaderName(trailer)
+		if !found {
+			return nil, s3errors.InvalidRequest.New().WithMessage("The value specified in the x-amz-trailer header is not supported")
+		}
+
+		if req.Checksum != nil {
+			return nil, s3errors.InvalidRequest.New().WithMessage("Expecting a single x-amz-checksum- header. Multiple checksum Types are not allowed.")
+		}
+
+		req.Trailer = algorithm
+		req.Algorithm = algorithm
+	}
+
+	if sdk := header.Get(HeaderSDKChecksum); sdk != "" {
+		algorithm, err := ParseAlgorithm(sdk)
+		if err != nil {
+			return nil, s3errors.InvalidRequest.New().WithMessage("Checksum algorithm provided is unsupported. Please try again with any of the valid types: [CRC32, CRC32C, CRC64NVME, SHA1, SHA256]")
+		}
+
+		if req.Algorithm != "" && req.Algorithm != algorithm {
+			return nil, s3errors.InvalidRequest.New().WithMessage("Value for x-amz-sdk-checksum-algorithm header is invalid.")
+		}
+
+		req.Algorithm = algorithm
+	}
+
+	if req.Algorithm == "" {
+		// Like AWS, compute a CRC64NVME checksum when the client did not ask for one.
+		req.Algorithm = AlgorithmCRC64NVME
+	}
+
+	return req, nil
+}
+
+func algorithmFromHeaderName(name string) (Algorithm, bool) {
+	for _, algorithm := range Algorithms {
+		if strings.EqualFold(strings.TrimSpace(name), algorithm.HeaderName()) {
+			return algorithm, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,194 @@
+package s3checksum
+
+import (
+	"crypto/md5" //nolint:gosec // test vectors
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/stretchr/testify/require"
+)
+
+func digest(algorithm Algorithm, data string) string {
+	h := algorithm.New()
+	h.Write([]byte(data))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func requireS3Error(t *testing.T, err error, code string) {
+	t.Helper()
+
+	require.Error(t, err)
+	s3err, ok := err.(*s3errors.S3Error)
+	require.True(t, ok, "not a S3Error: %v", err)
+	require.Equal(t, code, s3err.Code)
+}
+
+func TestCRC64NVME(t *testing.T) {
+	h := AlgorithmCRC64NVME.New()
+	h.Write([]byte("123456789"))
+	require.Equal(t, uint64(0xae8b14860a799888), binary.BigEndian.Uint64(h.Sum(nil)))
+}
+
+func TestParseRequest(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		req, err := ParseRequest(http.Header{})
+		require.NoError(t, err)
+		require.Equal(t, AlgorithmCRC64NVME, req.Algorithm)
+		require.Nil(t, req.Checksum)
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("x-amz-checksum-crc32c", digest(AlgorithmCRC32C, "data"))
+
+		req, err := ParseRequest(header)
+		require.NoError(t, err)
+		require.Equal(t, AlgorithmCRC32C, req.Algorithm)
+		require.Equal(t, digest(AlgorithmCRC32C, "data"), req.Checksum.Value)
+	})
+
+	for name, header := range map[string]http.Header{
+		"InvalidValue":      {"X-Amz-Checksum-Sha256": {"Zm9v"}},
+		"MultipleHeaders":   {"X-Amz-Checksum-Crc32": {digest(AlgorithmCRC32, "")}, "X-Amz-Checksum-Sha1": {digest(AlgorithmSHA1, "")}},
+		"UnknownTrailer":    {"X-Amz-Trailer": {"x-amz-checksum-md5"}},
+		"UnknownAlgorithm":  {"X-Amz-Sdk-Checksum-Algorithm": {"MD5"}},
+		"AlgorithmMismatch": {"X-Amz-Checksum-Crc32": {digest(AlgorithmCRC32, "")}, "X-Amz-Sdk-Checksum-Algorithm": {"SHA1"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseRequest(header)
+			requireS3Error(t, err, "InvalidRequest")
+		})
+	}
+
+	t.Run("InvalidContentMD5", func(t *testing.T) {
+		_, err := ParseRequest(http.Header{"Content-Md5": {"Zm9v"}})
+		requireS3Error(t, err, "InvalidDigest")
+	})
+}
+
+func TestReader(t *testing.T) {
+	const body = "Hello, World!"
+	md5sum := md5.Sum([]byte(body)) //nolint:gosec // test vectors
+
+	for name, tc := range map[string]struct {
+		header   http.Header
+		expected string
+	}{
+		"NoChecksum":       {header: http.Header{}},
+		"ValidMD5":         {header: http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(md5sum[:])}}},
+		"InvalidMD5":       {header: http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(make([]byte, 16))}}, expected: "BadDigest"},
+		"ValidChecksum":    {header: http.Header{"X-Amz-Checksum-Sha256": {digest(AlgorithmSHA256, body)}}},
+		"InvalidChecksum":  {header: http.Header{"X-Amz-Checksum-Sha256": {digest(AlgorithmSHA256, "other")}}, expected: "BadDigest"},
+		"MissingTrailer":   {header: http.Header{"X-Amz-Trailer": {"x-amz-checksum-crc32"}}, expected: "InvalidRequest"},
+		"ValidCRC64NVME":   {header: http.Header{"X-Amz-Checksum-Crc64nvme": {digest(AlgorithmCRC64NVME, body)}}},
+		"InvalidCRC64NVME": {header: http.Header{"X-Amz-Checksum-Crc64nvme": {digest(AlgorithmCRC64NVME, "")}}, expected: "BadDigest"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := ParseRequest(tc.header)
+			require.NoError(t, err)
+
+			reader := req.NewReader(strings.NewReader(body), nil)
+			_, err = io.ReadAll(reader)
+
+			if tc.expected != "" {
+				requireS3Error(t, err, tc.expected)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, md5sum[:], reader.MD5())
+
+			checksum, ok := reader.Checksum()
+			require.True(t, ok)
+			require.Equal(t, digest(req.Algorithm, body), checksum.Value)
+		})
+	}
+}
+
+func TestChunkedReader(t *testing.T) {
+	crc := digest(AlgorithmCRC32, "Hello, World!")
+
+	for name, tc := range map[string]struct {
+		body     string
+		expected string
+	}{
+		"Valid": {
+			body: "7;chunk-signature=abc\r\nHello, \r\n6\r\nWorld!\r\n0\r\nx-amz-checksum-crc32:" + crc + "\r\n\r\n",
+		},
+		"NoFinalLine": {
+			body: "d\r\nHello, World!\r\n0\r\nx-amz-checksum-crc32:" + crc + "\r\n",
+		},
+		"Mismatch": {
+			body:     "d\r\nHello, World?\r\n0\r\nx-amz-checksum-crc32:" + crc + "\r\n\r\n",
+			expected: "BadDigest",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := ParseRequest(http.Header{
+				"X-Amz-Content-Sha256": {"STREAMING-UNSIGNED-PAYLOAD-TRAILER"},
+				"X-Amz-Trailer":        {"x-amz-checksum-crc32"},
+			})
+			require.NoError(t, err)
+
+			chunked := NewChunkedReader(strings.NewReader(tc.body))
+			data, err := io.ReadAll(req.NewReader(chunked, chunked.Trailer))
+
+			if tc.expected != "" {
+				requireS3Error(t, err, tc.expected)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "Hello, World!", string(data))
+		})
+	}
+
+	t.Run("Truncated", func(t *testing.T) {
+		_, err := io.ReadAll(NewChunkedReader(strings.NewReader("d\r\nHello")))
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
+
+func TestMultipart(t *testing.T) {
+	parts := []string{"first part, ", "", "second part, ", "and the last one"}
+	whole := strings.Join(parts, "")
+
+	for _, algorithm := range Algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			var checksums []Part
+			composite := algorithm.New()
+
+			for _, part := range parts {
+				checksum, err := parseValue(algorithm, digest(algorithm, part))
+				require.NoError(t, err)
+
+				checksums = append(checksums, Part{Checksum: checksum, Size: int64(len(part))})
+
+				raw, err := checksum.Raw()
+				require.NoError(t, err)
+				composite.Write(raw)
+			}
+
+			checksum, err := Composite(algorithm, checksums)
+			require.NoError(t, err)
+			require.Equal(t, TypeComposite, checksum.Type)
+			require.Equal(t, base64.StdEncoding.EncodeToString(composite.Sum(nil))+"-4", checksum.HeaderValue())
+
+			checksum, err = FullObject(algorithm, checksums)
+			if !algorithm.isCRC() {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, TypeFullObject, checksum.Type)
+			require.Equal(t, digest(algorithm, whole), checksum.HeaderValue())
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package s3checksum
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const HeaderContentSHA256 = "x-amz-content-sha256"
+
+// IsChunked reports whether the request body uses the aws-chunked encoding,
+// which is how the SDKs send trailing checksums on streaming uploads.
+func IsChunked(header http.Header) bool {
+	return strings.HasPrefix(header.Get(HeaderContentSHA256), "STREAMING-")
+}
+
+var errMalformedChunk = errors.New("s3checksum: malformed aws-chunked body")
+
+// ChunkedReader decodes an aws-chunked body. Chunk signatures are not
+// verified. Trailers are available once Read has returned io.EOF.
+type ChunkedReader struct {
+	reader    *bufio.Reader
+	remaining int64
+	trailer   http.Header
+	err       error
+}
+
+func NewChunkedReader(body io.Reader) *ChunkedReader {
+	return &ChunkedReader{
+		reader:  bufio.NewReader(body),
+		trailer: make(http.Header),
+	}
+}
+
+func (c *ChunkedReader) Trailer() http.Header {
+	return c.trailer
+}
+
+func (c *ChunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.remaining == 0 {
+		if err := c.nextChunk(); err != nil {
+			c.err = err
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err := c.reader.Read(p)
+	c.remaining -= int64(n)
+
+	if c.remaining == 0 && err == nil {
+		err = c.expectCRLF()
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	c.err = err
+
+	return n, err
+}
+
+func (c *ChunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+
+	sizeStr, _, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+	if err != nil || size < 0 {
+		return errMalformedChunk
+	}
+
+	if size == 0 {
+		if err := c.readTrailers(); err != nil {
+			return err
+		}
+
+		return io.EOF
+	}
+
+	c.remaining = size
+
+	return nil
+}
+
+func (c *ChunkedReader) readTrailers() error {
+	for {
+		line, err := c.readLine()
+		switch {
+		case errors.Is(err, io.ErrUnexpectedEOF) && line == "":
+			// Some clients omit the final empty line.
+			return nil
+		case err != nil:
+			return err
+		case line == "":
+			return nil
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return errMalformedChunk
+		}
+
+		c.trailer.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+}
+
+func (c *ChunkedReader) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return line, io.ErrUnexpectedEOF
+		}
+
+		return "", fmt.Errorf("s3checksum: read chunk: %w", err)
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+func (c *ChunkedReader) expectCRLF() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+
+	if line != "" {
+		return errMalformedChunk
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package s3checksum
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Part is the checksum of an uploaded part along with its size, as needed
+// to compute the checksum of the completed multipart object.
+type Part struct {
+	Checksum Checksum
+	Size     int64
+}
+
+// Composite computes the checksum of checksums of a multipart object: the
+// digest of the concatenated raw part digests, suffixed by the parts count.
+func Composite(algorithm Algorithm, parts []Part) (Checksum, error) {
+	h := algorithm.New()
+
+	for i, part := range parts {
+		if part.Checksum.Algorithm != algorithm {
+			return Checksum{}, fmt.Errorf("s3checksum: part %d: algorithm %s instead of %s", i+1, part.Checksum.Algorithm, algorithm)
+		}
+
+		raw, err := part.Checksum.Raw()
+		if err != nil {
+			return Checksum{}, fmt.Errorf("s3checksum: part %d: %w", i+1, err)
+		}
+
+		h.Write(raw)
+	}
+
+	checksum := newChecksum(algorithm, h.Sum(nil))
+	checksum.Type = TypeComposite
+	checksum.PartsCount = len(parts)
+
+	return checksum, nil
+}
+
+// FullObject combines the CRC of each part into the CRC of the whole object,
+// as if it had been uploaded in a single request. Only CRC algorithms
+// support this.
+func FullObject(algorithm Algorithm, parts []Part) (Checksum, error) {
+	if !algorithm.isCRC() {
+		return Checksum{}, fmt.Errorf("s3checksum: %s does not support the %s checksum type", algorithm, TypeFullObject)
+	}
+
+	c := newCombiner(algorithm)
+	crc := c.sum(nil)
+
+	for i, part := range parts {
+		if part.Checksum.Algorithm != algorithm {
+			return Checksum{}, fmt.Errorf("s3checksum: part %d: algorithm %s instead of %s", i+1, part.Checksum.Algorithm, algorithm)
+		}
+
+		raw, err := part.Checksum.Raw()
+		if err != nil || len(raw) != c.width/8 {
+			return Checksum{}, fmt.Errorf("s3checksum: part %d: invalid checksum", i+1)
+		}
+
+		crc = c.combine(crc, c.sum(raw), part.Size)
+	}
+
+	return newChecksum(algorithm, c.bytes(crc)), nil
+}
+
+// combiner implements the zlib crc32_combine algorithm for any reflected CRC
+// with all ones initial and final xor values, up to 64 bits wide.
+type combiner struct {
+	poly  uint64
+	width int
+	// x2n[k] holds x^(2^k) mod poly.
+	x2n [67]uint64
+}
+
+func newCombiner(algorithm Algorithm) *combiner {
+	c := &combiner{}
+
+	switch algorithm {
+	case AlgorithmCRC32:
+		c.poly, c.width = 0xedb88320, 32
+	case AlgorithmCRC32C:
+		c.poly, c.width = 0x82f63b78, 32
+	case AlgorithmCRC64NVME:
+		c.poly, c.width = crc64NVMEPolynomial, 64
+	default:
+		panic("s3checksum: not a CRC algorithm: " + string(algorithm))
+	}
+
+	c.x2n[0] = 1 << (c.width - 2)
+	for k := 1; k < len(c.x2n); k++ {
+		c.x2n[k] = c.multModP(c.x2n[k-1], c.x2n[k-1])
+	}
+
+	return c
+}
+
+func (c *combiner) multModP(a, b uint64) uint64 {
+	m := uint64(1) << (c.width - 1)
+	var p uint64
+
+	for {
+		if a&m != 0 {
+			p ^= b
+			if a&(m-1) == 0 {
+				break
+			}
+		}
+
+		m >>= 1
+		if b&1 != 0 {
+			b = (b >> 1) ^ c.poly
+		} else {
+			b >>= 1
+		}
+	}
+
+	return p
+}
+
+// x2nModP returns x^(n*2^k) mod poly.
+func (c *combiner) x2nModP(n uint64, k int) uint64 {
+	p := uint64(1) << (c.width - 1)
+
+	for n != 0 {
+		if n&1 != 0 {
+			p = c.multModP(c.x2n[k], p)
+		}
+
+		n >>= 1
+		k++
+	}
+
+	return p
+}
+
+func (c *combiner) combine(crc1, crc2 uint64, len2 int64) uint64 {
+	return c.multModP(c.x2nModP(uint64(len2), 3), crc1) ^ crc2
+}
+
+// sum decodes a big endian raw digest, nil being the CRC of no data.
+func (c *combiner) sum(raw []byte) uint64 {
+	switch {
+	case raw == nil:
+		return 0
+	case c.width == 32:
+		return uint64(binary.BigEndian.Uint32(raw))
+	default:
+		return binary.BigEndian.Uint64(raw)
+	}
+}
+
+func (c *combiner) bytes(crc uint64) []byte {
+	if c.width == 32 {
+		return binary.BigEndian.AppendUint32(nil, uint32(crc))
+	}
+
+	return binary.BigEndian.AppendUint64(nil, crc)
+}
@@ -0,0 +1,109 @@
+package s3checksum
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // Content-MD5 is part of the S3 API
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+// Reader computes the digests of a request body while it is read and
+// verifies them against the expected values when the end of the body is
+// reached. A mismatch is reported by Read instead of io.EOF.
+type Reader struct {
+	reader  io.Reader
+	request *Request
+	trailer func() http.Header
+
+	md5      hash.Hash
+	checksum hash.Hash
+
+	computed *Checksum
+	err      error
+}
+
+// NewReader wraps body. The trailer function is only called once body is
+// exhausted, when the request announced a trailing checksum.
+func (req *Request) NewReader(body io.Reader, trailer func() http.Header) *Reader {
+	return &Reader{
+		reader:   body,
+		request:  req,
+		trailer:  trailer,
+		md5:      md5.New(), //nolint:gosec // see import
+		checksum: req.Algorithm.New(),
+	}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.reader.Read(p)
+	r.md5.Write(p[:n])
+	r.checksum.Write(p[:n])
+
+	if errors.Is(err, io.EOF) {
+		if verr := r.verify(); verr != nil {
+			r.err = verr
+			return n, verr
+		}
+	}
+
+	return n, err
+}
+
+// MD5 returns the MD5 digest of the data read so far, used to build ETags.
+func (r *Reader) MD5() []byte {
+	return r.md5.Sum(nil)
+}
+
+// Checksum returns the verified checksum to store along the object. It is
+// only available once the body has been fully and successfully read.
+func (r *Reader) Checksum() (Checksum, bool) {
+	if r.computed == nil {
+		return Checksum{}, false
+	}
+
+	return *r.computed, true
+}
+
+func (r *Reader) verify() error {
+	if r.request.ContentMD5 != nil && !bytes.Equal(r.request.ContentMD5, r.MD5()) {
+		return s3errors.BadDigest.New().WithMessage("The Content-MD5 you specified did not match what we received.")
+	}
+
+	computed := newChecksum(r.request.Algorithm, r.checksum.Sum(nil))
+
+	expected := r.request.Checksum
+	if r.request.Trailer != "" {
+		var trailers http.Header
+		if r.trailer != nil {
+			trailers = r.trailer()
+		}
+
+		value := trailers.Get(r.request.Trailer.HeaderName())
+		if value == "" {
+			return s3errors.InvalidRequest.New().WithMessage("The " + r.request.Trailer.HeaderName() + " trailer was announced but not received.")
+		}
+
+		checksum, err := parseValue(r.request.Trailer, value)
+		if err != nil {
+			return err
+		}
+
+		expected = &checksum
+	}
+
+	if expected != nil && expected.Value != computed.Value {
+		return s3errors.BadDigest.New().WithMessage("The " + string(expected.Algorithm) + " you specified did not match the calculated checksum.")
+	}
+
+	r.computed = &computed
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package s3checksum
+
+// Fields is the set of checksum elements embedded in S3 XML payloads, like
+// the Checksum element of GetObjectAttributes or the Part elements of
+// ListParts.
+type Fields struct {
+	ChecksumCRC32     string `xml:",omitempty"`
+	ChecksumCRC32C    string `xml:",omitempty"`
+	ChecksumCRC64NVME string `xml:",omitempty"`
+	ChecksumSHA1      string `xml:",omitempty"`
+	ChecksumSHA256    string `xml:",omitempty"`
+	ChecksumType      Type   `xml:",omitempty"`
+}
+
+func (c Checksum) Fields() Fields {
+	fields := Fields{ChecksumType: c.Type}
+
+	switch c.Algorithm {
+	case AlgorithmCRC32:
+		fields.ChecksumCRC32 = c.Value
+	case AlgorithmCRC32C:
+		fields.ChecksumCRC32C = c.Value
+	case AlgorithmCRC64NVME:
+		fields.ChecksumCRC64NVME = c.Value
+	case AlgorithmSHA1:
+		fields.ChecksumSHA1 = c.Value
+	case AlgorithmSHA256:
+		fields.ChecksumSHA256 = c.Value
+	}
+
+	return fields
+}
@@ -0,0 +1,82 @@
+package s3conditional
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+const (
+	HeaderIfMatch           = "If-Match"
+	HeaderIfModifiedSince   = "If-Modified-Since"
+	HeaderIfNoneMatch       = "If-None-Match"
+	HeaderIfUnmodifiedSince = "If-Unmodified-Since"
+)
+
+// Version identifies the current state of an object.
+type Version struct {
+	ETag         string
+	LastModified time.Time
+}
+
+// CheckRead evaluates the conditional headers of a GetObject or HeadObject
+// request against the object. It returns a NotModified (304) or
+// PreconditionFailed (412) S3Error when the object must not be returned.
+//
+// Like S3, If-Match takes precedence over If-Unmodified-Since, and
+// If-None-Match takes precedence over If-Modified-Since.
+func CheckRead(header http.Header, current Version) error {
+	if ifMatch := header.Get(HeaderIfMatch); ifMatch != "" {
+		if !matchETag(ifMatch, current.ETag) {
+			return s3errors.PreconditionFailed.New()
+		}
+	} else if since, ok := parseTime(header.Get(HeaderIfUnmodifiedSince)); ok && modifiedSince(current, since) {
+		return s3errors.PreconditionFailed.New()
+	}
+
+	if ifNoneMatch := header.Get(HeaderIfNoneMatch); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, current.ETag) {
+			return s3errors.NotModified.New()
+		}
+	} else if since, ok := parseTime(header.Get(HeaderIfModifiedSince)); ok && !modifiedSince(current, since) {
+		return s3errors.NotModified.New()
+	}
+
+	return nil
+}
+
+// matchETag reports whether etag matches one of a comma separated list of
+// entity tags, "*" matching any. Weak tags are compared as strong ones.
+func matchETag(list, etag string) bool {
+	etag = normalizeETag(etag)
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || normalizeETag(candidate) == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
+
+func parseTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	t, err := http.ParseTime(value)
+
+	return t, err == nil
+}
+
+// modifiedSince compares at the second precision of HTTP dates.
+func modifiedSince(current Version, since time.Time) bool {
+	return current.LastModified.Truncate(time.Second).After(since)
+}
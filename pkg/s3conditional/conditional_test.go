@@ -0,0 +1,226 @@
+package s3conditional
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+func requireCode(t *testing.T, expected string, err error) {
+	t.Helper()
+
+	if expected == "" {
+		require.NoError(t, err)
+		return
+	}
+
+	require.Error(t, err)
+	require.Equal(t, expected, err.(*s3errors.S3Error).Code)
+}
+
+func TestCheckRead(t *testing.T) {
+	lastModified := time.Date(2023, 12, 1, 10, 0, 0, 500, time.UTC)
+	current := Version{ETag: `"abc"`, LastModified: lastModified}
+
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+	same := lastModified.Format(http.TimeFormat)
+	after := lastModified.Add(time.Hour).Format(http.TimeFormat)
+
+	for name, tc := range map[string]struct {
+		header   http.Header
+		expected string
+	}{
+		"None":                       {header: http.Header{}},
+		"IfMatch":                    {header: http.Header{HeaderIfMatch: {`"abc"`}}},
+		"IfMatchList":                {header: http.Header{HeaderIfMatch: {`"xyz", "abc"`}}},
+		"IfMatchStar":                {header: http.Header{HeaderIfMatch: {"*"}}},
+		"IfMatchFailed":              {header: http.Header{HeaderIfMatch: {`"xyz"`}}, expected: "PreconditionFailed"},
+		"IfNoneMatch":                {header: http.Header{HeaderIfNoneMatch: {`"xyz"`}}},
+		"IfNoneMatchFailed":          {header: http.Header{HeaderIfNoneMatch: {"abc"}}, expected: "NotModified"},
+		"IfModifiedSince":            {header: http.Header{HeaderIfModifiedSince: {before}}},
+		"IfModifiedSinceSame":        {header: http.Header{HeaderIfModifiedSince: {same}}, expected: "NotModified"},
+		"IfModifiedSinceInvalid":     {header: http.Header{HeaderIfModifiedSince: {"yesterday"}}},
+		"IfUnmodifiedSince":          {header: http.Header{HeaderIfUnmodifiedSince: {after}}},
+		"IfUnmodifiedSinceFailed":    {header: http.Header{HeaderIfUnmodifiedSince: {before}}, expected: "PreconditionFailed"},
+		"IfMatchOverUnmodified":      {header: http.Header{HeaderIfMatch: {`"abc"`}, HeaderIfUnmodifiedSince: {before}}},
+		"IfNoneMatchOverModified":    {header: http.Header{HeaderIfNoneMatch: {`"xyz"`}, HeaderIfModifiedSince: {after}}},
+		"IfNoneMatchFailedBeforeAll": {header: http.Header{HeaderIfNoneMatch: {`"abc"`}, HeaderIfModifiedSince: {before}}, expected: "NotModified"},
+		"PreconditionBeforeModified": {header: http.Header{HeaderIfMatch: {`"xyz"`}, HeaderIfNoneMatch: {`"abc"`}}, expected: "PreconditionFailed"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			requireCode(t, tc.expected, CheckRead(tc.header, current))
+		})
+	}
+}
+
+func TestWriteCondition(t *testing.T) {
+	current := &Version{ETag: `"abc"`}
+
+	for name, tc := range map[string]struct {
+		header   http.Header
+		current  *Version
+		expected string
+	}{
+		"Unconditional":      {header: http.Header{}, current: current},
+		"IfNoneMatchAbsent":  {header: http.Header{HeaderIfNoneMatch: {"*"}}},
+		"IfNoneMatchExists":  {header: http.Header{HeaderIfNoneMatch: {"*"}}, current: current, expected: "PreconditionFailed"},
+		"IfMatch":            {header: http.Header{HeaderIfMatch: {`"abc"`}}, current: current},
+		"IfMatchMismatch":    {header: http.Header{HeaderIfMatch: {`"xyz"`}}, current: current, expected: "PreconditionFailed"},
+		"IfMatchAbsent":      {header: http.Header{HeaderIfMatch: {`"abc"`}}, expected: "NoSuchKey"},
+		"IfNoneMatchNotStar": {header: http.Header{HeaderIfNoneMatch: {`"abc"`}}, expected: "NotImplemented"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cond, err := ParseWrite(tc.header)
+			if err != nil {
+				requireCode(t, tc.expected, err)
+				return
+			}
+
+			require.Equal(t, len(tc.header) > 0, cond.Conditional())
+			requireCode(t, tc.expected, cond.Check(tc.current))
+		})
+	}
+}
+
+func TestLocks(t *testing.T) {
+	var (
+		locks   Locks
+		current *Version
+
+		mu      sync.Mutex
+		results = map[string]int{}
+		wg      sync.WaitGroup
+	)
+
+	cond, err := ParseWrite(http.Header{HeaderIfNoneMatch: {"*"}})
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			code := "Created"
+			unlock, err := locks.Lock("bucket/leader", cond.Conditional())
+			if err == nil {
+				if err = cond.Check(current); err == nil {
+					time.Sleep(time.Millisecond)
+					current = &Version{ETag: `"leader"`}
+				}
+				unlock()
+			}
+
+			if err != nil {
+				code = err.(*s3errors.S3Error).Code
+			}
+
+			mu.Lock()
+			results[code]++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	require.Equal(t, 1, results["Created"])
+	require.Equal(t, 99, results["PreconditionFailed"]+results["ConditionalRequestConflict"])
+	require.Empty(t, locks.locks)
+
+	t.Run("Unconditional", func(t *testing.T) {
+		unlock, err := locks.Lock("bucket/key", false)
+		require.NoError(t, err)
+
+		_, err = locks.Lock("bucket/key", true)
+		requireCode(t, "ConditionalRequestConflict", err)
+
+		done := make(chan struct{})
+		go func() {
+			unlockOther, err := locks.Lock("bucket/key", false)
+			require.NoError(t, err)
+			unlockOther()
+			close(done)
+		}()
+
+		unlock()
+		<-done
+		require.Empty(t, locks.locks)
+	})
+}
+
+func TestGuard(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		versions = map[string]*Version{"bucket/old": {ETag: `"old"`}}
+	)
+
+	logger := zerolog.Nop()
+	guard := NewGuard(&logger, func(_ context.Context, bucket, key string) (*Version, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return versions[bucket+"/"+key], nil
+	})
+
+	actions := map[s3router.Action]s3router.ActionHandler{
+		s3router.ActionGetObject: s3router.ActionHandlerFunc(func(http.ResponseWriter, *http.Request, *s3router.Route) {}),
+		s3router.ActionPutObject: s3router.ActionHandlerFunc(func(_ http.ResponseWriter, _ *http.Request, route *s3router.Route) {
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			versions[route.Bucket+"/"+route.Key] = &Version{ETag: `"new"`}
+		}),
+	}
+
+	handler := s3router.New(&logger, []string{"example.com"}, actions, guard.Middleware)
+
+	serve := func(method, target string, header http.Header) int {
+		r := httptest.NewRequest(method, "http://example.com"+target, http.NoBody)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusNotModified, serve(http.MethodGet, "/bucket/old", http.Header{HeaderIfNoneMatch: {`"old"`}}))
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/bucket/old", http.Header{HeaderIfNoneMatch: {`"other"`}}))
+	require.Equal(t, http.StatusPreconditionFailed, serve(http.MethodPut, "/bucket/old", http.Header{HeaderIfNoneMatch: {"*"}}))
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPut, "/bucket/missing", http.Header{HeaderIfMatch: {`"old"`}}))
+	require.Equal(t, http.StatusNotImplemented, serve(http.MethodPut, "/bucket/old", http.Header{HeaderIfNoneMatch: {`"old"`}}))
+
+	var (
+		wg      sync.WaitGroup
+		results sync.Map
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			code := serve(http.MethodPut, "/bucket/leader", http.Header{HeaderIfNoneMatch: {"*"}})
+			count, _ := results.LoadOrStore(code, new(atomic.Int32))
+			count.(*atomic.Int32).Add(1)
+		}()
+	}
+
+	wg.Wait()
+
+	created, _ := results.Load(http.StatusOK)
+	require.Equal(t, int32(1), created.(*atomic.Int32).Load())
+}
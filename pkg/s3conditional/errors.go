@@ -0,0 +1,10 @@
+package s3conditional
+
+import (
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+func notImplemented(header string) *s3errors.S3Error {
+	return s3errors.NotImplemented.New().
+		WithMessage("A header you provided implies functionality that is not implemented: " + header)
+}
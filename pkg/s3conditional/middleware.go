@@ -0,0 +1,113 @@
+package s3conditional
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// VersionFunc returns the current version of an object, nil when the key
+// does not exist.
+type VersionFunc func(ctx context.Context, bucket, key string) (*Version, error)
+
+// Guard evaluates the conditional headers of the object requests before
+// they reach the action handlers.
+type Guard struct {
+	logger   *zerolog.Logger
+	versions VersionFunc
+	locks    Locks
+}
+
+func NewGuard(logger *zerolog.Logger, versions VersionFunc) *Guard {
+	return &Guard{logger: logger, versions: versions}
+}
+
+// Middleware checks the reads against the current object and serializes the
+// writes per key: the write condition is checked and the handler commits
+// the object while the key is locked, so that concurrent conditional writes
+// have a single winner.
+func (g *Guard) Middleware(next s3router.ActionHandler) s3router.ActionHandler {
+	return s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+		var err error
+
+		switch route.Action {
+		case s3router.ActionGetObject, s3router.ActionHeadObject:
+			err = g.read(r, route)
+		case s3router.ActionPutObject, s3router.ActionCompleteMultipartUpload:
+			var unlock func()
+			if unlock, err = g.write(r, route); err == nil {
+				defer unlock()
+			}
+		}
+
+		if err != nil {
+			g.writeError(w, r, err)
+			return
+		}
+
+		next.ServeAction(w, r, route)
+	})
+}
+
+func (g *Guard) read(r *http.Request, route *s3router.Route) error {
+	current, err := g.versions(r.Context(), route.Bucket, route.Key)
+	if err != nil || current == nil {
+		// The handler answers the missing keys.
+		return err
+	}
+
+	return CheckRead(r.Header, *current)
+}
+
+// write locks the key and checks the write condition, the returned function
+// releasing the key once the object is committed.
+func (g *Guard) write(r *http.Request, route *s3router.Route) (func(), error) {
+	cond, err := ParseWrite(r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := g.locks.Lock(route.Bucket+"/"+route.Key, cond.Conditional())
+	if err != nil {
+		return nil, err
+	}
+
+	if !cond.Conditional() {
+		return unlock, nil
+	}
+
+	current, err := g.versions(r.Context(), route.Bucket, route.Key)
+	if err == nil {
+		err = cond.Check(current)
+	}
+
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return unlock, nil
+}
+
+func (g *Guard) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var s3err *s3errors.S3Error
+	if !errors.As(err, &s3err) {
+		s3logging.Ctx(r, g.logger).Error().Err(err).Str("path", r.URL.Path).Msg("Cannot read object version")
+		s3err = s3errors.InternalError.New()
+	}
+
+	resp := *s3err
+	resp.RequestID = w.Header().Get("x-amz-request-id")
+	resp.Resource = r.URL.Path
+
+	writer := s3errors.APIWriter{}
+	if err := writer.Write(&resp, w, r); err != nil {
+		s3logging.Ctx(r, g.logger).Warn().Err(err).Msg("Cannot write response")
+	}
+}
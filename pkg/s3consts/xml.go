@@ -0,0 +1,5 @@
+package s3consts
+
+const (
+	XMLNamespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+)
@@ -0,0 +1,85 @@
+package s3delete
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequest(t *testing.T) {
+	body := `<Delete xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+		`<Object><Key>a</Key></Object>` +
+		`<Object><Key>b</Key><VersionId>v1</VersionId></Object>` +
+		`<Quiet>true</Quiet>` +
+		`</Delete>`
+
+	req, err := ParseRequest([]byte(body))
+	require.NoError(t, err)
+	require.True(t, req.Quiet)
+	require.Equal(t, []ObjectIdentifier{{Key: "a"}, {Key: "b", VersionID: "v1"}}, req.Objects)
+
+	for name, payload := range map[string]string{
+		"NotXML":   "{}",
+		"Empty":    "<Delete></Delete>",
+		"EmptyKey": "<Delete><Object></Object></Delete>",
+		"TooMany":  "<Delete>" + strings.Repeat("<Object><Key>k</Key></Object>", MaxKeys+1) + "</Delete>",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseRequest([]byte(payload))
+			require.Error(t, err)
+			require.Equal(t, "MalformedXML", err.(*s3errors.S3Error).Code)
+		})
+	}
+}
+
+func TestExecute(t *testing.T) {
+	req := &Request{}
+	for i := 0; i < MaxKeys; i++ {
+		req.Objects = append(req.Objects, ObjectIdentifier{Key: fmt.Sprintf("key-%04d", i)})
+	}
+
+	var calls atomic.Int32
+	deleter := func(_ context.Context, object ObjectIdentifier) (Deleted, error) {
+		calls.Add(1)
+
+		switch object.Key {
+		case "key-0001":
+			return Deleted{}, &s3errors.S3Error{Code: "AccessDenied", Message: "Access Denied"}
+		case "key-0002":
+			return Deleted{}, errors.New("disk failure")
+		default:
+			return Deleted{DeleteMarker: object.Key == "key-0003"}, nil
+		}
+	}
+
+	result := Execute(context.Background(), req, DefaultConcurrency, deleter)
+	require.Equal(t, int32(MaxKeys), calls.Load())
+	require.Len(t, result.Deleted, MaxKeys-2)
+	require.Equal(t, Deleted{Key: "key-0000"}, result.Deleted[0])
+	require.Equal(t, Deleted{Key: "key-0003", DeleteMarker: true}, result.Deleted[1])
+	require.Equal(t, []Error{
+		{Key: "key-0001", Code: "AccessDenied", Message: "Access Denied"},
+		{Key: "key-0002", Code: "InternalError", Message: "We encountered an internal error. Please try again."},
+	}, result.Errors)
+
+	req.Quiet = true
+	req.Objects = req.Objects[:4]
+
+	// A zero concurrency falls back to DefaultConcurrency.
+	payload, err := xml.Marshal(Execute(context.Background(), req, 0, deleter))
+	require.NoError(t, err)
+	require.Equal(t,
+		`<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`+
+			`<Error><Key>key-0001</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`+
+			`<Error><Key>key-0002</Key><Code>InternalError</Code><Message>We encountered an internal error. Please try again.</Message></Error>`+
+			`</DeleteResult>`,
+		string(payload),
+	)
+}
@@ -0,0 +1,107 @@
+package s3delete
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/sourcegraph/conc/pool"
+)
+
+// DefaultConcurrency is the number of objects deleted in parallel by Execute.
+const DefaultConcurrency = 32
+
+type Result struct {
+	XMLName   xml.Name  `xml:"DeleteResult"`
+	Namespace string    `xml:"xmlns,attr"`
+	Deleted   []Deleted `xml:"Deleted"`
+	Errors    []Error   `xml:"Error"`
+}
+
+type Deleted struct {
+	Key                   string
+	VersionID             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:",omitempty"`
+	DeleteMarkerVersionID string `xml:"DeleteMarkerVersionId,omitempty"`
+}
+
+type Error struct {
+	Key       string
+	VersionID string `xml:"VersionId,omitempty"`
+	Code      string
+	Message   string
+}
+
+// Deleter deletes a single object, the same way DeleteObject would. A
+// *s3errors.S3Error is reported as is in the result, any other error as an
+// InternalError.
+type Deleter func(ctx context.Context, object ObjectIdentifier) (Deleted, error)
+
+// Execute deletes every object of the request in parallel, with at most
+// concurrency deletions at once, DefaultConcurrency when it is not
+// positive. Entries of the result keep the request order, and successful
+// deletions are omitted in quiet mode.
+func Execute(ctx context.Context, req *Request, concurrency int, deleter Deleter) *Result {
+	type outcome struct {
+		deleted Deleted
+		err     error
+	}
+
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	outcomes := make([]outcome, len(req.Objects))
+	p := pool.New().WithMaxGoroutines(concurrency)
+
+	for i := range req.Objects {
+		i := i
+		p.Go(func() {
+			if err := ctx.Err(); err != nil {
+				outcomes[i].err = err
+				return
+			}
+
+			outcomes[i].deleted, outcomes[i].err = deleter(ctx, req.Objects[i])
+		})
+	}
+
+	p.Wait()
+
+	result := &Result{
+		Namespace: s3consts.XMLNamespace,
+	}
+
+	for i, outcome := range outcomes {
+		object := req.Objects[i]
+
+		if outcome.err == nil {
+			if !req.Quiet {
+				deleted := outcome.deleted
+				deleted.Key = object.Key
+				result.Deleted = append(result.Deleted, deleted)
+			}
+
+			continue
+		}
+
+		entry := Error{
+			Key:       object.Key,
+			VersionID: object.VersionID,
+			Code:      s3errors.InternalError.Code,
+			Message:   s3errors.InternalError.Message,
+		}
+
+		var s3err *s3errors.S3Error
+		if errors.As(outcome.err, &s3err) {
+			entry.Code = s3err.Code
+			entry.Message = s3err.Message
+		}
+
+		result.Errors = append(result.Errors, entry)
+	}
+
+	return result
+}
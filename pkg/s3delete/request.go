@@ -0,0 +1,44 @@
+package s3delete
+
+import (
+	"encoding/xml"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+const (
+	// MaxKeys is the maximum number of objects a single DeleteObjects
+	// request can delete.
+	MaxKeys = 1000
+	// MaxPayloadSize bounds the Delete payload, leaving room for MaxKeys
+	// objects with escaped 1024 bytes keys and their version ids.
+	MaxPayloadSize = 4 << 20
+)
+
+type Request struct {
+	XMLName xml.Name           `xml:"Delete"`
+	Objects []ObjectIdentifier `xml:"Object"`
+	Quiet   bool
+}
+
+type ObjectIdentifier struct {
+	Key       string
+	VersionID string `xml:"VersionId,omitempty"`
+}
+
+// ParseRequest decodes and validates the Delete payload of a DeleteObjects
+// request.
+func ParseRequest(payload []byte) (*Request, error) {
+	var req Request
+	if err := xml.Unmarshal(payload, &req); err != nil || len(req.Objects) == 0 || len(req.Objects) > MaxKeys {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	for _, object := range req.Objects {
+		if object.Key == "" {
+			return nil, s3errors.MalformedXML.New()
+		}
+	}
+
+	return &req, nil
+}
@@ -11,8 +11,55 @@ type S3Error struct {
 	Message   string
 	RequestID string
 	Resource  string
+
+	// Extra holds the code specific elements of the error, such as
+	// BucketName or ArgumentName.
+	Extra []Field
+}
+
+// Field is an extra element of an error.
+type Field struct {
+	Name  string
+	Value string
 }
 
 func (e *S3Error) Error() string {
 	return fmt.Sprintf("s3error: %s %s", e.Code, e.Message)
 }
+
+func (e *S3Error) WithMessage(message string) *S3Error {
+	e.Message = message
+	return e
+}
+
+func (e *S3Error) WithResource(resource string) *S3Error {
+	e.Resource = resource
+	return e
+}
+
+// With sets the extra element name of the error.
+func (e *S3Error) With(name, value string) *S3Error {
+	for i := range e.Extra {
+		if e.Extra[i].Name == name {
+			e.Extra[i].Value = value
+			return e
+		}
+	}
+
+	e.Extra = append(e.Extra, Field{Name: name, Value: value})
+
+	return e
+}
+
+func (e *S3Error) WithBucketName(bucket string) *S3Error {
+	return e.With("BucketName", bucket)
+}
+
+func (e *S3Error) WithKey(key string) *S3Error {
+	return e.With("Key", key)
+}
+
+// WithArgument names the invalid argument of an InvalidArgument error.
+func (e *S3Error) WithArgument(name, value string) *S3Error {
+	return e.With("ArgumentName", name).With("ArgumentValue", value)
+}
@@ -0,0 +1,185 @@
+package s3errors
+
+import (
+	"net/http"
+)
+
+// ErrorCode is an error code of the S3 API with its HTTP status and default
+// message.
+type ErrorCode struct {
+	Code           string
+	HTTPStatusCode int
+	Message        string
+}
+
+// New returns an error of the code with its default message.
+func (c ErrorCode) New() *S3Error {
+	return &S3Error{
+		HTTPStatusCode: c.HTTPStatusCode,
+		Code:           c.Code,
+		Message:        c.Message,
+	}
+}
+
+var catalog = make(map[string]ErrorCode)
+
+func define(code string, status int, message string) ErrorCode {
+	errorCode := ErrorCode{Code: code, HTTPStatusCode: status, Message: message}
+	catalog[code] = errorCode
+
+	return errorCode
+}
+
+// Lookup returns the error code of the catalog named code.
+func Lookup(code string) (ErrorCode, bool) {
+	errorCode, exists := catalog[code]
+	return errorCode, exists
+}
+
+// The documented error codes of the S3 API.
+var (
+	AccessControlListNotSupported                  = define("AccessControlListNotSupported", http.StatusBadRequest, "The bucket does not allow ACLs.")
+	AccessDenied                                   = define("AccessDenied", http.StatusForbidden, "Access Denied")
+	AccessPointAlreadyOwnedByYou                   = define("AccessPointAlreadyOwnedByYou", http.StatusConflict, "An access point with an identical name already exists in your account.")
+	AccountProblem                                 = define("AccountProblem", http.StatusForbidden, "There is a problem with your AWS account that prevents the action from completing successfully.")
+	AllAccessDisabled                              = define("AllAccessDisabled", http.StatusForbidden, "All access to this Amazon S3 resource has been disabled.")
+	AmbiguousGrantByEmailAddress                   = define("AmbiguousGrantByEmailAddress", http.StatusBadRequest, "The email address you provided is associated with more than one account.")
+	AuthorizationHeaderMalformed                   = define("AuthorizationHeaderMalformed", http.StatusBadRequest, "The authorization header you provided is invalid.")
+	BadDigest                                      = define("BadDigest", http.StatusBadRequest, "The Content-MD5 or checksum value you specified did not match what we received.")
+	BucketAlreadyExists                            = define("BucketAlreadyExists", http.StatusConflict, "The requested bucket name is not available. The bucket namespace is shared by all users of the system. Please select a different name and try again.")
+	BucketAlreadyOwnedByYou                        = define("BucketAlreadyOwnedByYou", http.StatusConflict, "Your previous request to create the named bucket succeeded and you already own it.")
+	BucketNotEmpty                                 = define("BucketNotEmpty", http.StatusConflict, "The bucket you tried to delete is not empty.")
+	ClientTokenConflict                            = define("ClientTokenConflict", http.StatusConflict, "There is a conflict with a previous request with the same client token.")
+	ConditionalRequestConflict                     = define("ConditionalRequestConflict", http.StatusConflict, "A conflicting operation occurred. If using PutObject you can retry the request. If using multipart upload you should initiate another CreateMultipartUpload request and re-upload each part.")
+	CredentialsNotSupported                        = define("CredentialsNotSupported", http.StatusBadRequest, "This request does not support credentials.")
+	CrossLocationLoggingProhibited                 = define("CrossLocationLoggingProhibited", http.StatusForbidden, "Cross-location logging not allowed. Buckets in one geographic location cannot log information to a bucket in another location.")
+	EntityTooLarge                                 = define("EntityTooLarge", http.StatusBadRequest, "Your proposed upload exceeds the maximum allowed object size.")
+	EntityTooSmall                                 = define("EntityTooSmall", http.StatusBadRequest, "Your proposed upload is smaller than the minimum allowed object size.")
+	ExpiredToken                                   = define("ExpiredToken", http.StatusBadRequest, "The provided token has expired.")
+	IllegalLocationConstraintException             = define("IllegalLocationConstraintException", http.StatusBadRequest, "The unspecified location constraint is incompatible for the region specific endpoint this request was sent to.")
+	IllegalVersioningConfigurationException        = define("IllegalVersioningConfigurationException", http.StatusBadRequest, "The versioning configuration specified in the request is invalid.")
+	IncompleteBody                                 = define("IncompleteBody", http.StatusBadRequest, "You did not provide the number of bytes specified by the Content-Length HTTP header.")
+	IncorrectNumberOfFilesInPostRequest            = define("IncorrectNumberOfFilesInPostRequest", http.StatusBadRequest, "POST requires exactly one file upload per request.")
+	InlineDataTooLarge                             = define("InlineDataTooLarge", http.StatusBadRequest, "Inline data exceeds the maximum allowed size.")
+	InternalError                                  = define("InternalError", http.StatusInternalServerError, "We encountered an internal error. Please try again.")
+	InvalidAccessKeyID                             = define("InvalidAccessKeyId", http.StatusForbidden, "The AWS access key ID you provided does not exist in our records.")
+	InvalidAccessPoint                             = define("InvalidAccessPoint", http.StatusBadRequest, "The specified access point name or account is not valid.")
+	InvalidAccessPointAliasError                   = define("InvalidAccessPointAliasError", http.StatusBadRequest, "The specified access point alias name is not valid.")
+	InvalidAddressingHeader                        = define("InvalidAddressingHeader", http.StatusBadRequest, "You must specify the Anonymous role.")
+	InvalidArgument                                = define("InvalidArgument", http.StatusBadRequest, "Invalid Argument")
+	InvalidBucketACLWithObjectOwnership            = define("InvalidBucketAclWithObjectOwnership", http.StatusBadRequest, "Bucket cannot have ACLs set with ObjectOwnership's BucketOwnerEnforced setting.")
+	InvalidBucketName                              = define("InvalidBucketName", http.StatusBadRequest, "The specified bucket is not valid.")
+	InvalidBucketState                             = define("InvalidBucketState", http.StatusConflict, "The request is not valid with the current state of the bucket.")
+	InvalidDigest                                  = define("InvalidDigest", http.StatusBadRequest, "The Content-MD5 you specified is not valid.")
+	InvalidEncryptionAlgorithmError                = define("InvalidEncryptionAlgorithmError", http.StatusBadRequest, "The encryption request you specified is not valid. The valid value is AES256.")
+	InvalidLocationConstraint                      = define("InvalidLocationConstraint", http.StatusBadRequest, "The specified location constraint is not valid.")
+	InvalidObjectState                             = define("InvalidObjectState", http.StatusForbidden, "The operation is not valid for the current state of the object.")
+	InvalidPart                                    = define("InvalidPart", http.StatusBadRequest, "One or more of the specified parts could not be found. The part might not have been uploaded, or the specified entity tag might not have matched the part's entity tag.")
+	InvalidPartNumber                              = define("InvalidPartNumber", http.StatusRequestedRangeNotSatisfiable, "The requested partnumber is not satisfiable.")
+	InvalidPartOrder                               = define("InvalidPartOrder", http.StatusBadRequest, "The list of parts was not in ascending order. The parts list must be specified in order by part number.")
+	InvalidPayer                                   = define("InvalidPayer", http.StatusForbidden, "All access to this object has been disabled.")
+	InvalidPolicyDocument                          = define("InvalidPolicyDocument", http.StatusBadRequest, "The content of the form does not meet the conditions specified in the policy document.")
+	InvalidRange                                   = define("InvalidRange", http.StatusRequestedRangeNotSatisfiable, "The requested range is not satisfiable.")
+	InvalidRequest                                 = define("InvalidRequest", http.StatusBadRequest, "Invalid Request")
+	InvalidSecurity                                = define("InvalidSecurity", http.StatusForbidden, "The provided security credentials are not valid.")
+	InvalidSOAPRequest                             = define("InvalidSOAPRequest", http.StatusBadRequest, "The SOAP request body is invalid.")
+	InvalidStorageClass                            = define("InvalidStorageClass", http.StatusBadRequest, "The storage class you specified is not valid.")
+	InvalidTag                                     = define("InvalidTag", http.StatusBadRequest, "The tag provided was not a valid tag.")
+	InvalidTargetBucketForLogging                  = define("InvalidTargetBucketForLogging", http.StatusBadRequest, "The target bucket for logging does not exist, is not owned by you, or does not have the appropriate grants for the log-delivery group.")
+	InvalidToken                                   = define("InvalidToken", http.StatusBadRequest, "The provided token is malformed or otherwise invalid.")
+	InvalidURI                                     = define("InvalidURI", http.StatusBadRequest, "Couldn't parse the specified URI.")
+	KeyTooLongError                                = define("KeyTooLongError", http.StatusBadRequest, "Your key is too long.")
+	MalformedACLError                              = define("MalformedACLError", http.StatusBadRequest, "The XML you provided was not well-formed or did not validate against our published schema.")
+	MalformedPolicy                                = define("MalformedPolicy", http.StatusBadRequest, "The policy is not valid JSON or does not validate against the policy grammar.")
+	MalformedPOSTRequest                           = define("MalformedPOSTRequest", http.StatusBadRequest, "The body of your POST request is not well-formed multipart/form-data.")
+	MalformedXML                                   = define("MalformedXML", http.StatusBadRequest, "The XML you provided was not well-formed or did not validate against our published schema.")
+	MaxMessageLengthExceeded                       = define("MaxMessageLengthExceeded", http.StatusBadRequest, "Your request was too big.")
+	MaxPostPreDataLengthExceededError              = define("MaxPostPreDataLengthExceededError", http.StatusBadRequest, "Your POST request fields preceding the upload file were too large.")
+	MetadataTooLarge                               = define("MetadataTooLarge", http.StatusBadRequest, "Your metadata headers exceed the maximum allowed metadata size.")
+	MethodNotAllowed                               = define("MethodNotAllowed", http.StatusMethodNotAllowed, "The specified method is not allowed against this resource.")
+	MissingAttachment                              = define("MissingAttachment", http.StatusBadRequest, "A SOAP attachment was expected, but none were found.")
+	MissingContentLength                           = define("MissingContentLength", http.StatusLengthRequired, "You must provide the Content-Length HTTP header.")
+	MissingRequestBodyError                        = define("MissingRequestBodyError", http.StatusBadRequest, "Request Body is empty.")
+	MissingSecurityElement                         = define("MissingSecurityElement", http.StatusBadRequest, "The SOAP 1.1 request is missing a security element.")
+	MissingSecurityHeader                          = define("MissingSecurityHeader", http.StatusBadRequest, "Your request is missing a required header.")
+	NoLoggingStatusForKey                          = define("NoLoggingStatusForKey", http.StatusBadRequest, "There is no such thing as a logging status subresource for a key.")
+	NoSuchAccessPoint                              = define("NoSuchAccessPoint", http.StatusNotFound, "The specified access point does not exist.")
+	NoSuchBucket                                   = define("NoSuchBucket", http.StatusNotFound, "The specified bucket does not exist.")
+	NoSuchBucketPolicy                             = define("NoSuchBucketPolicy", http.StatusNotFound, "The specified bucket does not have a bucket policy.")
+	NoSuchConfiguration                            = define("NoSuchConfiguration", http.StatusNotFound, "The specified configuration does not exist.")
+	NoSuchCORSConfiguration                        = define("NoSuchCORSConfiguration", http.StatusNotFound, "The CORS configuration does not exist")
+	NoSuchKey                                      = define("NoSuchKey", http.StatusNotFound, "The specified key does not exist.")
+	NoSuchLifecycleConfiguration                   = define("NoSuchLifecycleConfiguration", http.StatusNotFound, "The lifecycle configuration does not exist.")
+	NoSuchObjectLockConfiguration                  = define("NoSuchObjectLockConfiguration", http.StatusNotFound, "The specified object does not have an ObjectLock configuration.")
+	NoSuchPublicAccessBlockConfiguration           = define("NoSuchPublicAccessBlockConfiguration", http.StatusNotFound, "The public access block configuration was not found")
+	NoSuchTagSet                                   = define("NoSuchTagSet", http.StatusNotFound, "The TagSet does not exist")
+	NoSuchUpload                                   = define("NoSuchUpload", http.StatusNotFound, "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.")
+	NoSuchVersion                                  = define("NoSuchVersion", http.StatusNotFound, "The version ID specified in the request does not match an existing version.")
+	NoSuchWebsiteConfiguration                     = define("NoSuchWebsiteConfiguration", http.StatusNotFound, "The specified bucket does not have a website configuration")
+	NotImplemented                                 = define("NotImplemented", http.StatusNotImplemented, "A header you provided implies functionality that is not implemented.")
+	NotModified                                    = define("NotModified", http.StatusNotModified, "Not Modified")
+	NotSignedUp                                    = define("NotSignedUp", http.StatusForbidden, "Your account is not signed up for the Amazon S3 service.")
+	ObjectLockConfigurationNotFoundError           = define("ObjectLockConfigurationNotFoundError", http.StatusNotFound, "Object Lock configuration does not exist for this bucket")
+	OperationAborted                               = define("OperationAborted", http.StatusConflict, "A conflicting conditional operation is currently in progress against this resource. Try again.")
+	OwnershipControlsNotFoundError                 = define("OwnershipControlsNotFoundError", http.StatusNotFound, "The bucket ownership controls were not found")
+	PermanentRedirect                              = define("PermanentRedirect", http.StatusMovedPermanently, "The bucket you are attempting to access must be addressed using the specified endpoint. Please send all future requests to this endpoint.")
+	PreconditionFailed                             = define("PreconditionFailed", http.StatusPreconditionFailed, "At least one of the pre-conditions you specified did not hold")
+	Redirect                                       = define("Redirect", http.StatusTemporaryRedirect, "Temporary redirect.")
+	ReplicationConfigurationNotFoundError          = define("ReplicationConfigurationNotFoundError", http.StatusNotFound, "The replication configuration was not found")
+	RequestHeaderSectionTooLarge                   = define("RequestHeaderSectionTooLarge", http.StatusBadRequest, "Your request header section exceeds the maximum allowed size.")
+	RequestIsNotMultiPartContent                   = define("RequestIsNotMultiPartContent", http.StatusBadRequest, "Bucket POST must be of the enclosure-type multipart/form-data.")
+	RequestTimeout                                 = define("RequestTimeout", http.StatusBadRequest, "Your socket connection to the server was not read from or written to within the timeout period.")
+	RequestTimeTooSkewed                           = define("RequestTimeTooSkewed", http.StatusForbidden, "The difference between the request time and the server's time is too large.")
+	RequestTorrentOfBucketError                    = define("RequestTorrentOfBucketError", http.StatusBadRequest, "Requesting the torrent file of a bucket is not permitted.")
+	RestoreAlreadyInProgress                       = define("RestoreAlreadyInProgress", http.StatusConflict, "Object restore is already in progress")
+	ServerSideEncryptionConfigurationNotFoundError = define("ServerSideEncryptionConfigurationNotFoundError", http.StatusNotFound, "The server side encryption configuration was not found")
+	ServiceUnavailable                             = define("ServiceUnavailable", http.StatusServiceUnavailable, "Service is unable to handle request.")
+	SignatureDoesNotMatch                          = define("SignatureDoesNotMatch", http.StatusForbidden, "The request signature we calculated does not match the signature you provided. Check your key and signing method.")
+	SlowDown                                       = define("SlowDown", http.StatusServiceUnavailable, "Please reduce your request rate.")
+	TemporaryRedirect                              = define("TemporaryRedirect", http.StatusTemporaryRedirect, "You are being redirected to the bucket while DNS updates.")
+	TokenRefreshRequired                           = define("TokenRefreshRequired", http.StatusBadRequest, "The provided token must be refreshed.")
+	TooManyAccessPoints                            = define("TooManyAccessPoints", http.StatusBadRequest, "You have attempted to create more access points than are allowed for an account.")
+	TooManyBuckets                                 = define("TooManyBuckets", http.StatusBadRequest, "You have attempted to create more buckets than allowed.")
+	TooManyConfigurations                          = define("TooManyConfigurations", http.StatusBadRequest, "You are attempting to create a new configuration but have already reached the limit.")
+	UnexpectedContent                              = define("UnexpectedContent", http.StatusBadRequest, "This request does not support content.")
+	UnresolvableGrantByEmailAddress                = define("UnresolvableGrantByEmailAddress", http.StatusBadRequest, "The email address you provided does not match any account on record.")
+	UserKeyMustBeSpecified                         = define("UserKeyMustBeSpecified", http.StatusBadRequest, "The bucket POST must contain the specified field name. If it is specified, check the order of the fields.")
+	XAmzContentSHA256Mismatch                      = define("XAmzContentSHA256Mismatch", http.StatusBadRequest, "The provided 'x-amz-content-sha256' header does not match what was computed.")
+)
+
+// The error codes of SelectObjectContent.
+var (
+	BusyResources                    = define("BusyResources", http.StatusServiceUnavailable, "Failed to complete the request because of resource constraints. Try again later.")
+	CastFailed                       = define("CastFailed", http.StatusBadRequest, "Attempt to convert from one data type to another using CAST failed in the SQL expression.")
+	CSVParsingError                  = define("CSVParsingError", http.StatusBadRequest, "Failed to parse CSV file; see the specific error message for details.")
+	DivisionByZero                   = define("DivisionByZero", http.StatusBadRequest, "Division by zero is not allowed.")
+	EmptyRequestBody                 = define("EmptyRequestBody", http.StatusBadRequest, "The request body cannot be empty.")
+	EvaluatorInvalidArguments        = define("EvaluatorInvalidArguments", http.StatusBadRequest, "Incorrect number of arguments in the function call in the SQL expression.")
+	ExpressionTooLong                = define("ExpressionTooLong", http.StatusBadRequest, "The SQL expression is too long. The maximum byte-length for an SQL expression is 256 KB.")
+	IncorrectSQLFunctionArgumentType = define("IncorrectSqlFunctionArgumentType", http.StatusBadRequest, "Incorrect type of arguments in function call in the SQL expression.")
+	IllegalSQLFunctionArgument       = define("IllegalSqlFunctionArgument", http.StatusBadRequest, "Illegal argument was used in the SQL function.")
+	InvalidCompressionFormat         = define("InvalidCompressionFormat", http.StatusBadRequest, "The file is not in a supported compression format. GZIP and BZIP2 are supported.")
+	InvalidDataSource                = define("InvalidDataSource", http.StatusBadRequest, "Invalid data source type. Only CSV, JSON, and Parquet are supported.")
+	InvalidExpressionType            = define("InvalidExpressionType", http.StatusBadRequest, "The ExpressionType value is not valid. Only SQL expressions are supported.")
+	InvalidFileHeaderInfo            = define("InvalidFileHeaderInfo", http.StatusBadRequest, "The FileHeaderInfo value is not valid. Only NONE, USE, and IGNORE are supported.")
+	InvalidJSONType                  = define("InvalidJsonType", http.StatusBadRequest, "The JsonType value is not valid. Only DOCUMENT and LINES are supported.")
+	InvalidQuoteFields               = define("InvalidQuoteFields", http.StatusBadRequest, "The QuoteFields value is not valid. Only ALWAYS and ASNEEDED are supported.")
+	InvalidScanRange                 = define("InvalidScanRange", http.StatusBadRequest, "The provided scan range is not valid.")
+	JSONParsingError                 = define("JSONParsingError", http.StatusBadRequest, "Encountered an error parsing the JSON file. Check the file and try again.")
+	LexerInvalidChar                 = define("LexerInvalidChar", http.StatusBadRequest, "The SQL expression contains a character that is not valid.")
+	LexerInvalidLiteral              = define("LexerInvalidLiteral", http.StatusBadRequest, "The SQL expression contains an operator literal that is not valid.")
+	MissingRequiredParameter         = define("MissingRequiredParameter", http.StatusBadRequest, "The SelectRequest entity is missing a required parameter.")
+	ParseExpectedExpression          = define("ParseExpectedExpression", http.StatusBadRequest, "Did not find the expected SQL expression.")
+	ParseInvalidPathComponent        = define("ParseInvalidPathComponent", http.StatusBadRequest, "The SQL expression contains a path component that is not valid.")
+	ParseInvalidTableReference       = define("ParseInvalidTableReference", http.StatusBadRequest, "The table reference is not valid.")
+	ParseInvalidTypeParam            = define("ParseInvalidTypeParam", http.StatusBadRequest, "The SQL expression contains a parameter value that is not valid.")
+	ParseUnexpectedTerm              = define("ParseUnexpectedTerm", http.StatusBadRequest, "The SQL expression contains an unexpected term.")
+	ParseUnexpectedToken             = define("ParseUnexpectedToken", http.StatusBadRequest, "The SQL expression contains an unexpected token.")
+	ParseUnsupportedCallWithStar     = define("ParseUnsupportedCallWithStar", http.StatusBadRequest, "Only COUNT with (*) as a parameter is supported in the SQL expression.")
+	ParseUnsupportedType             = define("ParseUnsupportedType", http.StatusBadRequest, "The SQL expression contains an unsupported parameter type.")
+	UnsupportedScanRangeInput        = define("UnsupportedScanRangeInput", http.StatusBadRequest, "Scan range queries are not supported on this type of object.")
+	UnsupportedSyntax                = define("UnsupportedSyntax", http.StatusBadRequest, "The SQL expression contains unsupported syntax.")
+	UnsupportedSQLOperation          = define("UnsupportedSqlOperation", http.StatusBadRequest, "Encountered an unsupported SQL operation.")
+	UnsupportedSQLStructure          = define("UnsupportedSqlStructure", http.StatusBadRequest, "Encountered an unsupported SQL structure. Check the SQL Reference.")
+	ValueParseFailure                = define("ValueParseFailure", http.StatusBadRequest, "The timestamp value could not be parsed in the SQL expression.")
+)
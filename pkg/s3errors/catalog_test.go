@@ -0,0 +1,54 @@
+package s3errors
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	testCases := map[string]int{
+		"AccessDenied":          http.StatusForbidden,
+		"InvalidArgument":       http.StatusBadRequest,
+		"MethodNotAllowed":      http.StatusMethodNotAllowed,
+		"NoSuchKey":             http.StatusNotFound,
+		"NotImplemented":        http.StatusNotImplemented,
+		"PreconditionFailed":    http.StatusPreconditionFailed,
+		"TooManyConfigurations": http.StatusBadRequest,
+	}
+
+	for code, status := range testCases {
+		errorCode, exists := Lookup(code)
+		require.True(t, exists, code)
+		require.Equal(t, code, errorCode.Code)
+		require.Equal(t, status, errorCode.HTTPStatusCode)
+		require.NotEmpty(t, errorCode.Message)
+	}
+
+	_, exists := Lookup("Badrequest")
+	require.False(t, exists)
+}
+
+func TestS3Error_With(t *testing.T) {
+	s3err := InvalidArgument.New().
+		WithMessage("Argument max-keys must be an integer between 0 and 2147483647").
+		WithResource("/bucket").
+		WithBucketName("bucket").
+		WithArgument("max-keys", "-1").
+		WithArgument("max-keys", "abc")
+
+	require.Equal(t, &S3Error{
+		HTTPStatusCode: http.StatusBadRequest,
+		Code:           "InvalidArgument",
+		Message:        "Argument max-keys must be an integer between 0 and 2147483647",
+		Resource:       "/bucket",
+		Extra: []Field{
+			{Name: "BucketName", Value: "bucket"},
+			{Name: "ArgumentName", Value: "max-keys"},
+			{Name: "ArgumentValue", Value: "abc"},
+		},
+	}, s3err)
+
+	require.Equal(t, "Invalid Argument", InvalidArgument.New().Message)
+}
@@ -7,14 +7,18 @@ import (
 	"github.com/lvjp/s3impl/pkg/s3consts"
 )
 
+// ErrorWriter writes the error answered to a request.
 type ErrorWriter interface {
-	Write(*S3Error, http.ResponseWriter)
+	Write(err *S3Error, w http.ResponseWriter, r *http.Request) error
 }
 
 type apierror struct {
 	Code      string
 	Message   string
+	Extra     []Field
+	Resource  string
 	RequestID string
+	HostID    string
 }
 
 func (a *apierror) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
@@ -23,18 +27,22 @@ func (a *apierror) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
 		return err
 	}
 
-	type Entry struct {
-		Name  string
-		Value string
-	}
-
-	entries := []Entry{
+	entries := []Field{
 		{"Code", a.Code},
 		{"Message", a.Message},
-		{"RequestId", a.RequestID},
-		{"HostId", a.RequestID},
 	}
 
+	entries = append(entries, a.Extra...)
+
+	if a.Resource != "" {
+		entries = append(entries, Field{"Resource", a.Resource})
+	}
+
+	entries = append(entries,
+		Field{"RequestId", a.RequestID},
+		Field{"HostId", a.HostID},
+	)
+
 	for _, entry := range entries {
 		if err := enc.EncodeElement(entry.Value, xml.StartElement{Name: xml.Name{Local: entry.Name}}); err != nil {
 			return err
@@ -44,12 +52,60 @@ func (a *apierror) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
 	return enc.EncodeToken(start.End())
 }
 
-type APIWriter struct{}
+// CodeRecorder is implemented by the response writers which keep the code
+// of the written errors. Writers wrapping another writer must expose it with
+// an Unwrap method, as expected by http.ResponseController, for every
+// CodeRecorder of the chain to be notified.
+type CodeRecorder interface {
+	RecordCode(code string)
+}
+
+func recordCode(w http.ResponseWriter, code string) {
+	for {
+		if recorder, ok := w.(CodeRecorder); ok {
+			recorder.RecordCode(code)
+		}
+
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+
+		w = wrapper.Unwrap()
+	}
+}
+
+// HeaderHostID is the response header identifying the node which served the
+// request.
+const HeaderHostID = "x-amz-id-2"
+
+// APIWriter writes the errors as the S3 API does.
+type APIWriter struct {
+	// HostID identifies the node, the x-amz-id-2 header already set on the
+	// response is used when it is empty.
+	HostID string
+}
+
+var _ ErrorWriter = (*APIWriter)(nil)
+
+// Write answers err to r. HEAD requests and Not Modified responses get the
+// status and headers without body.
+func (a *APIWriter) Write(err *S3Error, w http.ResponseWriter, r *http.Request) error {
+	hostID := a.HostID
+	if hostID == "" {
+		hostID = w.Header().Get(HeaderHostID)
+	}
 
-func (*APIWriter) Write(err *S3Error, w http.ResponseWriter) error {
 	header := w.Header()
 	header.Set("x-amz-request-id", err.RequestID)
-	header.Set("x-amz-id-2", err.RequestID)
+	header.Set(HeaderHostID, hostID)
+	recordCode(w, err.Code)
+
+	if !bodyAllowed(r, err.HTTPStatusCode) {
+		w.WriteHeader(err.HTTPStatusCode)
+		return nil
+	}
+
 	header.Set("Content-Type", s3consts.MimetypeApplicationXML)
 	w.WriteHeader(err.HTTPStatusCode)
 
@@ -60,8 +116,24 @@ func (*APIWriter) Write(err *S3Error, w http.ResponseWriter) error {
 	payload := &apierror{
 		Code:      err.Code,
 		Message:   err.Message,
+		Extra:     err.Extra,
+		Resource:  err.Resource,
 		RequestID: err.RequestID,
+		HostID:    hostID,
 	}
 
 	return xml.NewEncoder(w).Encode(payload)
 }
+
+func bodyAllowed(r *http.Request, status int) bool {
+	if r.Method == http.MethodHead {
+		return false
+	}
+
+	switch status {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	default:
+		return status >= http.StatusOK
+	}
+}
@@ -1,6 +1,7 @@
 package s3errors
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -13,20 +14,24 @@ func TestAPIWriter_Write(t *testing.T) {
 		Code:           "NoSuchBucket",
 		Message:        "The specified bucket does not exist",
 		RequestID:      "5CZT884BVHY7AYJN",
+		Resource:       "/mybucket",
 	}
+	input.WithBucketName("mybucket")
 
 	expectedBody := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
 		"<Error>" +
 		"<Code>NoSuchBucket</Code>" +
 		"<Message>The specified bucket does not exist</Message>" +
+		"<BucketName>mybucket</BucketName>" +
+		"<Resource>/mybucket</Resource>" +
 		"<RequestId>5CZT884BVHY7AYJN</RequestId>" +
-		"<HostId>5CZT884BVHY7AYJN</HostId>" +
+		"<HostId>node-1</HostId>" +
 		"</Error>"
 
 	recorder := httptest.NewRecorder()
 
-	writer := APIWriter{}
-	err := writer.Write(input, recorder)
+	writer := APIWriter{HostID: "node-1"}
+	err := writer.Write(input, recorder, httptest.NewRequest(http.MethodGet, "/mybucket", nil))
 	require.NoError(t, err)
 
 	resp := recorder.Result()
@@ -34,7 +39,74 @@ func TestAPIWriter_Write(t *testing.T) {
 
 	require.Equal(t, input.HTTPStatusCode, resp.StatusCode)
 	require.Equal(t, input.RequestID, resp.Header.Get("X-Amz-Request-Id"))
-	require.Equal(t, input.RequestID, resp.Header.Get("X-Amz-Id-2"))
+	require.Equal(t, "node-1", resp.Header.Get("X-Amz-Id-2"))
 	require.NotNil(t, recorder.Body)
 	require.Equal(t, expectedBody, recorder.Body.String())
 }
+
+func TestAPIWriter_WriteHostIDHeader(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set(HeaderHostID, "node-2")
+
+	writer := APIWriter{}
+	err := writer.Write(AccessDenied.New(), recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+
+	require.Equal(t, "node-2", recorder.Header().Get("X-Amz-Id-2"))
+	require.Contains(t, recorder.Body.String(), "<HostId>node-2</HostId>")
+}
+
+func TestAPIWriter_WriteWithoutBody(t *testing.T) {
+	testCases := map[string]struct {
+		method string
+		err    *S3Error
+	}{
+		"Head":        {method: http.MethodHead, err: NoSuchKey.New()},
+		"NotModified": {method: http.MethodGet, err: NotModified.New()},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			tc.err.RequestID = "5CZT884BVHY7AYJN"
+			recorder := httptest.NewRecorder()
+
+			writer := APIWriter{HostID: "node-1"}
+			err := writer.Write(tc.err, recorder, httptest.NewRequest(tc.method, "/bucket/key", nil))
+			require.NoError(t, err)
+
+			require.Equal(t, tc.err.HTTPStatusCode, recorder.Code)
+			require.Equal(t, "5CZT884BVHY7AYJN", recorder.Header().Get("X-Amz-Request-Id"))
+			require.Equal(t, "node-1", recorder.Header().Get("X-Amz-Id-2"))
+			require.Empty(t, recorder.Header().Get("Content-Type"))
+			require.Zero(t, recorder.Body.Len())
+		})
+	}
+}
+
+type codeRecorder struct {
+	http.ResponseWriter
+	code string
+}
+
+func (c *codeRecorder) RecordCode(code string) {
+	c.code = code
+}
+
+type wrapper struct {
+	http.ResponseWriter
+}
+
+func (w *wrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestAPIWriter_RecordCode(t *testing.T) {
+	recorder := &codeRecorder{ResponseWriter: httptest.NewRecorder()}
+
+	writer := APIWriter{}
+	err := writer.Write(&S3Error{HTTPStatusCode: 403, Code: "AccessDenied"}, &wrapper{ResponseWriter: recorder}, httptest.NewRequest(http.MethodPut, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, "AccessDenied", recorder.code)
+}
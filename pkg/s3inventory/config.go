@@ -0,0 +1,217 @@
+package s3inventory
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+const (
+	FormatCSV     = "CSV"
+	FormatJSON    = "JSON"
+	FormatORC     = "ORC"
+	FormatParquet = "Parquet"
+
+	FrequencyDaily  = "Daily"
+	FrequencyWeekly = "Weekly"
+
+	VersionsAll     = "All"
+	VersionsCurrent = "Current"
+
+	// MaxListResults is the number of configurations returned per
+	// ListBucketInventoryConfigurations page.
+	MaxListResults = 100
+
+	maxIDLength = 64
+)
+
+// Optional fields of an inventory report, in the order of the report
+// columns.
+const (
+	FieldSize                      = "Size"
+	FieldLastModifiedDate          = "LastModifiedDate"
+	FieldETag                      = "ETag"
+	FieldStorageClass              = "StorageClass"
+	FieldIsMultipartUploaded       = "IsMultipartUploaded"
+	FieldReplicationStatus         = "ReplicationStatus"
+	FieldEncryptionStatus          = "EncryptionStatus"
+	FieldObjectLockRetainUntilDate = "ObjectLockRetainUntilDate"
+	FieldObjectLockMode            = "ObjectLockMode"
+	FieldObjectLockLegalHoldStatus = "ObjectLockLegalHoldStatus"
+	FieldChecksumAlgorithm         = "ChecksumAlgorithm"
+)
+
+var optionalFields = []string{
+	FieldSize,
+	FieldLastModifiedDate,
+	FieldETag,
+	FieldStorageClass,
+	FieldIsMultipartUploaded,
+	FieldReplicationStatus,
+	FieldEncryptionStatus,
+	FieldObjectLockRetainUntilDate,
+	FieldObjectLockMode,
+	FieldObjectLockLegalHoldStatus,
+	FieldChecksumAlgorithm,
+}
+
+type Configuration struct {
+	XMLName                xml.Name        `xml:"InventoryConfiguration"`
+	Namespace              string          `xml:"xmlns,attr,omitempty"`
+	Destination            Destination     `xml:"Destination"`
+	IsEnabled              bool            `xml:"IsEnabled"`
+	Filter                 *Filter         `xml:",omitempty"`
+	ID                     string          `xml:"Id"`
+	IncludedObjectVersions string          `xml:"IncludedObjectVersions"`
+	OptionalFields         *OptionalFields `xml:",omitempty"`
+	Schedule               Schedule        `xml:"Schedule"`
+}
+
+type Destination struct {
+	S3BucketDestination S3BucketDestination
+}
+
+type S3BucketDestination struct {
+	AccountID string `xml:"AccountId,omitempty"`
+	Bucket    string
+	Format    string
+	Prefix    string `xml:",omitempty"`
+}
+
+type Filter struct {
+	Prefix string
+}
+
+type OptionalFields struct {
+	Fields []string `xml:"Field"`
+}
+
+type Schedule struct {
+	Frequency string
+}
+
+// BucketARN returns the destination Bucket ARN designating bucket.
+func BucketARN(bucket string) string {
+	return "arn:aws:s3:::" + bucket
+}
+
+// DestinationBucket returns the name of the bucket the reports are written
+// to.
+func (c *Configuration) DestinationBucket() string {
+	return strings.TrimPrefix(c.Destination.S3BucketDestination.Bucket, "arn:aws:s3:::")
+}
+
+// ParseConfiguration decodes and validates a PutBucketInventoryConfiguration
+// payload, id being the value of the id query parameter.
+func ParseConfiguration(payload []byte, id string) (*Configuration, error) {
+	var config Configuration
+	if err := xml.Unmarshal(payload, &config); err != nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	config.Namespace = s3consts.XMLNamespace
+
+	switch {
+	case id == "" || len(id) > maxIDLength:
+		return nil, s3errors.InvalidArgument.New().WithMessage("The id query parameter must be between 1 and 64 characters long")
+	case config.ID != id:
+		return nil, s3errors.InvalidArgument.New().WithMessage("The configuration Id does not match the id query parameter")
+	case config.IncludedObjectVersions != VersionsAll && config.IncludedObjectVersions != VersionsCurrent:
+		return nil, s3errors.MalformedXML.New()
+	case config.Schedule.Frequency != FrequencyDaily && config.Schedule.Frequency != FrequencyWeekly:
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	destination := config.Destination.S3BucketDestination
+	if !strings.HasPrefix(destination.Bucket, "arn:aws:s3:::") || config.DestinationBucket() == "" {
+		return nil, s3errors.InvalidArgument.New().WithMessage("Invalid destination bucket ARN: " + destination.Bucket)
+	}
+
+	switch destination.Format {
+	case FormatCSV, FormatJSON:
+	case FormatORC, FormatParquet:
+		return nil, s3errors.NotImplemented.New().
+			WithMessage("Only CSV and JSON inventory reports are supported")
+	default:
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	if config.OptionalFields != nil {
+		seen := make(map[string]bool)
+		for _, field := range config.OptionalFields.Fields {
+			if !validField(field) || seen[field] {
+				return nil, s3errors.InvalidArgument.New().WithMessage("Invalid optional field: " + field)
+			}
+
+			seen[field] = true
+		}
+	}
+
+	return &config, nil
+}
+
+func validField(field string) bool {
+	for _, candidate := range optionalFields {
+		if field == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Fields returns the selected optional fields in report order.
+func (c *Configuration) Fields() []string {
+	if c.OptionalFields == nil {
+		return nil
+	}
+
+	var fields []string
+	for _, field := range optionalFields {
+		for _, selected := range c.OptionalFields.Fields {
+			if field == selected {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
+type ListResult struct {
+	XMLName               xml.Name         `xml:"ListInventoryConfigurationsResult"`
+	Namespace             string           `xml:"xmlns,attr"`
+	ContinuationToken     string           `xml:",omitempty"`
+	Configurations        []*Configuration `xml:"InventoryConfiguration"`
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+}
+
+// NewListResult returns the ListBucketInventoryConfigurations page starting
+// after the continuation token, which is the last Id of the previous page.
+func NewListResult(configs []*Configuration, token string) *ListResult {
+	sorted := make([]*Configuration, len(configs))
+	copy(sorted, configs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	start := sort.Search(len(sorted), func(i int) bool { return sorted[i].ID > token })
+
+	result := &ListResult{
+		Namespace:         s3consts.XMLNamespace,
+		ContinuationToken: token,
+	}
+
+	page := sorted[start:]
+	if len(page) > MaxListResults {
+		page = page[:MaxListResults]
+		result.IsTruncated = true
+		result.NextContinuationToken = page[len(page)-1].ID
+	}
+
+	result.Configurations = page
+
+	return result
+}
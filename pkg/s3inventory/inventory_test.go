@@ -0,0 +1,193 @@
+package s3inventory
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5" //nolint:gosec // see report.go
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+func configuration(id, format, versions string, fields ...string) string {
+	optional := ""
+	for _, field := range fields {
+		optional += "<Field>" + field + "</Field>"
+	}
+
+	return `<InventoryConfiguration>
+  <Destination><S3BucketDestination><Bucket>arn:aws:s3:::reports</Bucket><Format>` + format + `</Format><Prefix>inv</Prefix></S3BucketDestination></Destination>
+  <IsEnabled>true</IsEnabled>
+  <Filter><Prefix>data/</Prefix></Filter>
+  <Id>` + id + `</Id>
+  <IncludedObjectVersions>` + versions + `</IncludedObjectVersions>
+  <OptionalFields>` + optional + `</OptionalFields>
+  <Schedule><Frequency>Daily</Frequency></Schedule>
+</InventoryConfiguration>`
+}
+
+func TestParseConfiguration(t *testing.T) {
+	config, err := ParseConfiguration([]byte(configuration("daily", FormatCSV, VersionsCurrent, FieldETag, FieldSize)), "daily")
+	require.NoError(t, err)
+	require.Equal(t, "reports", config.DestinationBucket())
+	require.Equal(t, []string{FieldSize, FieldETag}, config.Fields())
+
+	testCases := []struct {
+		name    string
+		payload string
+		id      string
+		code    string
+	}{
+		{"malformed", "<InventoryConfiguration>", "a", "MalformedXML"},
+		{"id mismatch", configuration("a", FormatCSV, VersionsAll), "b", "InvalidArgument"},
+		{"format", configuration("a", "XML", VersionsAll), "a", "MalformedXML"},
+		{"parquet", configuration("a", FormatParquet, VersionsAll), "a", "NotImplemented"},
+		{"versions", configuration("a", FormatCSV, "Some"), "a", "MalformedXML"},
+		{"field", configuration("a", FormatCSV, VersionsAll, "Owner"), "a", "InvalidArgument"},
+		{"duplicate field", configuration("a", FormatCSV, VersionsAll, FieldSize, FieldSize), "a", "InvalidArgument"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseConfiguration([]byte(tc.payload), tc.id)
+
+			var s3err *s3errors.S3Error
+			require.ErrorAs(t, err, &s3err)
+			require.Equal(t, tc.code, s3err.Code)
+		})
+	}
+}
+
+func TestNewListResult(t *testing.T) {
+	var configs []*Configuration
+	for i := 0; i < MaxListResults+5; i++ {
+		configs = append(configs, &Configuration{ID: fmt.Sprintf("id-%03d", i)})
+	}
+
+	first := NewListResult(configs, "")
+	require.True(t, first.IsTruncated)
+	require.Len(t, first.Configurations, MaxListResults)
+	require.Equal(t, "id-099", first.NextContinuationToken)
+
+	second := NewListResult(configs, first.NextContinuationToken)
+	require.False(t, second.IsTruncated)
+	require.Len(t, second.Configurations, 5)
+	require.Equal(t, "id-100", second.Configurations[0].ID)
+}
+
+type source []*Object
+
+func (s source) ListObjects(_ context.Context, _, prefix string, versions bool, fn func(*Object) error) error {
+	for _, object := range s {
+		if strings.HasPrefix(object.Key, prefix) && (versions || object.IsLatest) {
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type writer map[string][]byte
+
+func (w writer) PutReportObject(_ context.Context, bucket, key, _ string, body []byte) error {
+	w[bucket+"/"+key] = body
+	return nil
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return string(content)
+}
+
+var (
+	now     = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	objects = source{
+		{Key: "data/a", VersionID: "v2", IsLatest: true, Size: 3, ETag: `"abc"`, LastModified: now},
+		{Key: "data/a", VersionID: "v1", Size: 1, ETag: `"def"`, LastModified: now.Add(-time.Hour)},
+		{Key: "other", VersionID: "v1", IsLatest: true},
+	}
+)
+
+func TestGenerate(t *testing.T) {
+	config, err := ParseConfiguration([]byte(configuration("daily", FormatCSV, VersionsAll, FieldSize, FieldETag)), "daily")
+	require.NoError(t, err)
+
+	w := writer{}
+	manifest, err := Generate(context.Background(), objects, w, "bucket", config, now)
+	require.NoError(t, err)
+
+	require.Equal(t, "Bucket, Key, VersionId, IsLatest, IsDeleteMarker, Size, ETag", manifest.FileSchema)
+	require.Len(t, manifest.Files, 1)
+	require.True(t, strings.HasPrefix(manifest.Files[0].Key, "inv/bucket/daily/data/"))
+	require.Equal(t, "bucket,data/a,v2,true,false,3,abc\nbucket,data/a,v1,false,false,1,def\n", gunzip(t, w["reports/"+manifest.Files[0].Key]))
+
+	encoded := w["reports/inv/bucket/daily/2024-03-01T12-30Z/manifest.json"]
+	var decoded Manifest
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	require.Equal(t, *manifest, decoded)
+
+	sum := md5.Sum(encoded) //nolint:gosec // see import
+	require.Equal(t, hex.EncodeToString(sum[:]), string(w["reports/inv/bucket/daily/2024-03-01T12-30Z/manifest.checksum"]))
+
+	config, err = ParseConfiguration([]byte(configuration("json", FormatJSON, VersionsCurrent, FieldLastModifiedDate)), "json")
+	require.NoError(t, err)
+
+	manifest, err = Generate(context.Background(), objects, w, "bucket", config, now)
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(manifest.Files[0].Key, ".json.gz"))
+	require.Equal(t, `{"Bucket":"bucket","Key":"data/a","LastModifiedDate":"2024-03-01T12:30:00.000Z"}`+"\n", gunzip(t, w["reports/"+manifest.Files[0].Key]))
+}
+
+type configurations []BucketConfiguration
+
+func (c configurations) InventoryConfigurations(context.Context) ([]BucketConfiguration, error) {
+	return c, nil
+}
+
+func TestScheduler(t *testing.T) {
+	config, err := ParseConfiguration([]byte(configuration("daily", FormatCSV, VersionsCurrent)), "daily")
+	require.NoError(t, err)
+
+	manifests := func(w writer) int {
+		count := 0
+		for key := range w {
+			if strings.HasSuffix(key, "manifest.json") {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	w := writer{}
+	logger := zerolog.Nop()
+	s := NewScheduler(&logger, configurations{{Bucket: "bucket", Configuration: config}}, objects, w, 0)
+
+	s.generateDue(context.Background(), now)
+	require.Equal(t, 1, manifests(w))
+
+	s.generateDue(context.Background(), now.Add(time.Hour))
+	require.Equal(t, 1, manifests(w))
+
+	s.generateDue(context.Background(), now.Add(24*time.Hour))
+	require.Equal(t, 2, manifests(w))
+}
@@ -0,0 +1,274 @@
+package s3inventory
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5" //nolint:gosec // the manifest checksums are MD5 like in S3
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ObjectsPerFile is the number of objects listed in each data file of a
+// report.
+const ObjectsPerFile = 100000
+
+// Object is an object version listed in an inventory report.
+type Object struct {
+	Key                       string
+	VersionID                 string
+	IsLatest                  bool
+	IsDeleteMarker            bool
+	Size                      int64
+	LastModified              time.Time
+	ETag                      string
+	StorageClass              string
+	IsMultipartUploaded       bool
+	ReplicationStatus         string
+	EncryptionStatus          string
+	ObjectLockRetainUntilDate time.Time
+	ObjectLockMode            string
+	ObjectLockLegalHoldStatus string
+	ChecksumAlgorithm         string
+}
+
+// Source lists the objects of the inventoried buckets.
+type Source interface {
+	// ListObjects calls fn for each object under prefix in key order, for
+	// every version when versions is set.
+	ListObjects(ctx context.Context, bucket, prefix string, versions bool, fn func(*Object) error) error
+}
+
+// Writer stores the report files in the destination bucket.
+type Writer interface {
+	PutReportObject(ctx context.Context, bucket, key, contentType string, body []byte) error
+}
+
+type ManifestFile struct {
+	Key         string `json:"key"`
+	Size        int    `json:"size"`
+	MD5Checksum string `json:"MD5checksum"`
+}
+
+type Manifest struct {
+	SourceBucket      string         `json:"sourceBucket"`
+	DestinationBucket string         `json:"destinationBucket"`
+	Version           string         `json:"version"`
+	CreationTimestamp string         `json:"creationTimestamp"`
+	FileFormat        string         `json:"fileFormat"`
+	FileSchema        string         `json:"fileSchema"`
+	Files             []ManifestFile `json:"files"`
+}
+
+// Generate writes the inventory report of bucket to the destination bucket: the
+// gzipped data files, then manifest.json and manifest.checksum holding its
+// MD5, in the layout used by S3:
+//
+//	prefix/bucket/id/data/<uuid>.csv.gz
+//	prefix/bucket/id/YYYY-MM-DDTHH-MMZ/manifest.json
+func Generate(ctx context.Context, source Source, writer Writer, bucket string, config *Configuration, now time.Time) (*Manifest, error) {
+	columns := append([]string{"Bucket", "Key"}, config.Fields()...)
+	if config.IncludedObjectVersions == VersionsAll {
+		columns = append([]string{"Bucket", "Key", "VersionId", "IsLatest", "IsDeleteMarker"}, config.Fields()...)
+	}
+
+	base := strings.TrimPrefix(config.Destination.S3BucketDestination.Prefix+"/"+bucket+"/"+config.ID, "/")
+	destinationBucket := config.DestinationBucket()
+
+	manifest := &Manifest{
+		SourceBucket:      bucket,
+		DestinationBucket: config.Destination.S3BucketDestination.Bucket,
+		Version:           "2016-11-30",
+		CreationTimestamp: strconv.FormatInt(now.UnixMilli(), 10),
+		FileFormat:        config.Destination.S3BucketDestination.Format,
+		FileSchema:        strings.Join(columns, ", "),
+		Files:             []ManifestFile{},
+	}
+
+	w := newDataWriter(manifest.FileFormat, columns)
+
+	flush := func() error {
+		if w.count == 0 {
+			return nil
+		}
+
+		data, err := w.close()
+		if err != nil {
+			return err
+		}
+
+		key := base + "/data/" + uuid.NewString() + w.extension()
+		if err := writer.PutReportObject(ctx, destinationBucket, key, "application/gzip", data); err != nil {
+			return err
+		}
+
+		sum := md5.Sum(data) //nolint:gosec // see import
+		manifest.Files = append(manifest.Files, ManifestFile{Key: key, Size: len(data), MD5Checksum: hex.EncodeToString(sum[:])})
+		w = newDataWriter(manifest.FileFormat, columns)
+
+		return nil
+	}
+
+	filter := ""
+	if config.Filter != nil {
+		filter = config.Filter.Prefix
+	}
+
+	err := source.ListObjects(ctx, bucket, filter, config.IncludedObjectVersions == VersionsAll, func(object *Object) error {
+		if err := w.write(row(bucket, object, columns)); err != nil {
+			return err
+		}
+
+		if w.count < ObjectsPerFile {
+			return nil
+		}
+
+		return flush()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3inventory: cannot list %s: %w", bucket, err)
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("s3inventory: cannot encode manifest: %w", err)
+	}
+
+	prefix := base + "/" + now.UTC().Format("2006-01-02T15-04Z") + "/"
+	if err := writer.PutReportObject(ctx, destinationBucket, prefix+"manifest.json", "application/json", encoded); err != nil {
+		return nil, err
+	}
+
+	sum := md5.Sum(encoded) //nolint:gosec // see import
+	if err := writer.PutReportObject(ctx, destinationBucket, prefix+"manifest.checksum", "text/plain", []byte(hex.EncodeToString(sum[:]))); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// row returns the report columns of an object.
+func row(bucket string, object *Object, columns []string) []string {
+	values := make([]string, len(columns))
+
+	for i, column := range columns {
+		switch column {
+		case "Bucket":
+			values[i] = bucket
+		case "Key":
+			values[i] = object.Key
+		case "VersionId":
+			values[i] = object.VersionID
+		case "IsLatest":
+			values[i] = strconv.FormatBool(object.IsLatest)
+		case "IsDeleteMarker":
+			values[i] = strconv.FormatBool(object.IsDeleteMarker)
+		case FieldSize:
+			values[i] = strconv.FormatInt(object.Size, 10)
+		case FieldLastModifiedDate:
+			values[i] = formatTime(object.LastModified)
+		case FieldETag:
+			values[i] = strings.Trim(object.ETag, `"`)
+		case FieldStorageClass:
+			values[i] = object.StorageClass
+		case FieldIsMultipartUploaded:
+			values[i] = strconv.FormatBool(object.IsMultipartUploaded)
+		case FieldReplicationStatus:
+			values[i] = object.ReplicationStatus
+		case FieldEncryptionStatus:
+			values[i] = object.EncryptionStatus
+		case FieldObjectLockRetainUntilDate:
+			values[i] = formatTime(object.ObjectLockRetainUntilDate)
+		case FieldObjectLockMode:
+			values[i] = object.ObjectLockMode
+		case FieldObjectLockLegalHoldStatus:
+			values[i] = object.ObjectLockLegalHoldStatus
+		case FieldChecksumAlgorithm:
+			values[i] = object.ChecksumAlgorithm
+		}
+	}
+
+	return values
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// dataWriter writes a gzipped CSV or JSON Lines data file.
+type dataWriter struct {
+	format  string
+	columns []string
+	buf     bytes.Buffer
+	gzip    *gzip.Writer
+	csv     *csv.Writer
+	count   int
+}
+
+func newDataWriter(format string, columns []string) *dataWriter {
+	w := &dataWriter{format: format, columns: columns}
+	w.gzip = gzip.NewWriter(&w.buf)
+	w.csv = csv.NewWriter(w.gzip)
+
+	return w
+}
+
+func (w *dataWriter) extension() string {
+	if w.format == FormatJSON {
+		return ".json.gz"
+	}
+
+	return ".csv.gz"
+}
+
+func (w *dataWriter) write(values []string) error {
+	w.count++
+
+	if w.format != FormatJSON {
+		return w.csv.Write(values)
+	}
+
+	object := make(map[string]string, len(values))
+	for i, value := range values {
+		if value != "" {
+			object[w.columns[i]] = value
+		}
+	}
+
+	line, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.gzip.Write(append(line, '\n'))
+
+	return err
+}
+
+func (w *dataWriter) close() ([]byte, error) {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return nil, err
+	}
+
+	if err := w.gzip.Close(); err != nil {
+		return nil, err
+	}
+
+	return w.buf.Bytes(), nil
+}
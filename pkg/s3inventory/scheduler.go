@@ -0,0 +1,105 @@
+// Package s3inventory validates the inventory configurations and generates
+// their reports.
+//
+// The configurations are stored through s3subresource. The reports are a
+// library only: the server starts no Scheduler until objects are stored.
+package s3inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultCheckInterval is the delay between two looks for due reports.
+const DefaultCheckInterval = time.Hour
+
+// BucketConfiguration is an inventory configuration of a source bucket.
+type BucketConfiguration struct {
+	Bucket        string
+	Configuration *Configuration
+}
+
+// Configurations gives the inventory configurations of every bucket.
+type Configurations interface {
+	InventoryConfigurations(ctx context.Context) ([]BucketConfiguration, error)
+}
+
+// Scheduler generates the reports of enabled configurations daily or
+// weekly, according to their schedule.
+type Scheduler struct {
+	logger   *zerolog.Logger
+	configs  Configurations
+	source   Source
+	writer   Writer
+	interval time.Duration
+
+	last map[string]time.Time
+}
+
+func NewScheduler(logger *zerolog.Logger, configs Configurations, source Source, writer Writer, interval time.Duration) *Scheduler {
+	if interval == 0 {
+		interval = DefaultCheckInterval
+	}
+
+	return &Scheduler{
+		logger:   logger,
+		configs:  configs,
+		source:   source,
+		writer:   writer,
+		interval: interval,
+		last:     make(map[string]time.Time),
+	}
+}
+
+// Run generates the due reports every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.generateDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// generateDue generates the reports not generated for a period, failed
+// reports being retried on the next call.
+func (s *Scheduler) generateDue(ctx context.Context, now time.Time) {
+	configs, err := s.configs.InventoryConfigurations(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Cannot load inventory configurations")
+		return
+	}
+
+	for _, bc := range configs {
+		config := bc.Configuration
+		if !config.IsEnabled {
+			continue
+		}
+
+		id := bc.Bucket + "/" + config.ID
+
+		period := 24 * time.Hour
+		if config.Schedule.Frequency == FrequencyWeekly {
+			period *= 7
+		}
+
+		if last, ok := s.last[id]; ok && now.Sub(last) < period {
+			continue
+		}
+
+		if _, err := Generate(ctx, s.source, s.writer, bc.Bucket, config, now); err != nil {
+			s.logger.Error().Err(err).Str("bucket", bc.Bucket).Str("id", config.ID).Msg("Cannot generate inventory report")
+			continue
+		}
+
+		s.last[id] = now
+	}
+}
@@ -0,0 +1,102 @@
+package s3logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+type Config struct {
+	// Level is trace, debug, info, warn or error, info by default.
+	Level string
+	// Format is json or console, json by default.
+	Format string
+	// File is where the logs are written with rotation, the standard
+	// error when it is empty.
+	File       string
+	MaxSize    int `yaml:"maxSize"`
+	MaxBackups int `yaml:"maxBackups"`
+	MaxAge     int `yaml:"maxAge"`
+	Compress   bool
+	Sampling   struct {
+		// Burst messages of a level are logged every Period, then one
+		// message out of Thereafter. Sampling is disabled when Burst and
+		// Thereafter are zero, warnings and errors are never sampled.
+		Burst      uint32
+		Period     time.Duration
+		Thereafter uint32
+	}
+}
+
+// New returns the logger configured by config and the writer to close on
+// exit.
+func New(config Config) (zerolog.Logger, io.Closer, error) {
+	level := zerolog.InfoLevel
+	if config.Level != "" {
+		parsed, err := zerolog.ParseLevel(config.Level)
+		if err != nil {
+			return zerolog.Logger{}, nil, fmt.Errorf("s3logging: invalid level: %w", err)
+		}
+
+		level = parsed
+	}
+
+	var output io.WriteCloser = nopCloser{os.Stderr}
+	if config.File != "" {
+		output = &lumberjack.Logger{
+			Filename:   config.File,
+			MaxSize:    config.MaxSize,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAge,
+			Compress:   config.Compress,
+		}
+	}
+
+	var writer io.Writer = output
+
+	switch config.Format {
+	case "", FormatJSON:
+	case FormatConsole:
+		writer = zerolog.ConsoleWriter{Out: output, NoColor: config.File != ""}
+	default:
+		return zerolog.Logger{}, nil, fmt.Errorf("s3logging: unknown format %q", config.Format)
+	}
+
+	logger := zerolog.New(writer).Level(level).With().Timestamp().Logger()
+
+	if sampling := config.Sampling; sampling.Burst > 0 || sampling.Thereafter > 0 {
+		sampler := &zerolog.BurstSampler{
+			Burst:  sampling.Burst,
+			Period: sampling.Period,
+		}
+
+		if sampling.Thereafter > 0 {
+			sampler.NextSampler = &zerolog.BasicSampler{N: sampling.Thereafter}
+		}
+
+		logger = logger.Sample(zerolog.LevelSampler{
+			TraceSampler: sampler,
+			DebugSampler: sampler,
+			InfoSampler:  sampler,
+		})
+	}
+
+	return logger, output, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
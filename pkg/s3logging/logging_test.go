@@ -0,0 +1,98 @@
+package s3logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+func TestNew(t *testing.T) {
+	_, _, err := New(Config{Level: "verbose"})
+	require.Error(t, err)
+
+	_, _, err = New(Config{Format: "xml"})
+	require.Error(t, err)
+
+	config := Config{Level: "warn", File: filepath.Join(t.TempDir(), "s3impl.log")}
+	logger, output, err := New(config)
+	require.NoError(t, err)
+
+	logger.Info().Msg("hidden")
+	logger.Warn().Msg("shown")
+	require.NoError(t, output.Close())
+
+	content, err := os.ReadFile(config.File)
+	require.NoError(t, err)
+	require.NotContains(t, string(content), "hidden")
+	require.Contains(t, string(content), `"message":"shown"`)
+}
+
+func TestNewSampling(t *testing.T) {
+	config := Config{File: filepath.Join(t.TempDir(), "s3impl.log")}
+	config.Sampling.Burst = 2
+	config.Sampling.Period = time.Hour
+
+	logger, output, err := New(config)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		logger.Info().Msg("sampled")
+		logger.Error().Msg("kept")
+	}
+	require.NoError(t, output.Close())
+
+	content, err := os.ReadFile(config.File)
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(content), "sampled"))
+	require.Equal(t, 5, strings.Count(string(content), "kept"))
+}
+
+func TestMiddleware(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(buf)
+	fallback := zerolog.Nop()
+
+	handler := Middleware(&logger)(s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, _ *s3router.Route) {
+		Ctx(r, &fallback).Info().Msg("Inside handler")
+
+		writer := s3errors.APIWriter{}
+		require.NoError(t, writer.Write(&s3errors.S3Error{HTTPStatusCode: http.StatusNotFound, Code: "NoSuchKey"}, w, r))
+	}))
+
+	w := httptest.NewRecorder()
+	w.Header().Set("x-amz-request-id", "REQ1")
+	r := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	handler.ServeAction(w, r, &s3router.Route{Action: s3router.ActionGetObject, Bucket: "bucket", Key: "key"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var inside, access map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &inside))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+
+	require.Equal(t, "REQ1", inside["requestId"])
+	require.Equal(t, "ActionGetObject", inside["action"])
+	require.Equal(t, "bucket", inside["bucket"])
+
+	require.Equal(t, "Request served", access["message"])
+	require.Equal(t, "REQ1", access["requestId"])
+	require.Equal(t, "GET", access["method"])
+	require.Equal(t, 404.0, access["status"])
+	require.Equal(t, "NoSuchKey", access["errorCode"])
+	require.NotZero(t, access["bytesOut"])
+
+	require.Same(t, &fallback, Ctx(r, &fallback))
+}
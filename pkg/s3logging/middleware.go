@@ -0,0 +1,66 @@
+package s3logging
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvjp/s3impl/pkg/s3response"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// Middleware puts a logger carrying the request ID and route in the
+// context of the requests and logs an access line for each of them.
+func Middleware(logger *zerolog.Logger) s3router.Middleware {
+	return func(next s3router.ActionHandler) s3router.ActionHandler {
+		return s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+			fields := logger.With().
+				Str("requestId", w.Header().Get("x-amz-request-id")).
+				Stringer("action", route.Action).
+				Str("bucket", route.Bucket).
+				Str("key", route.Key)
+
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				fields = fields.Stringer("traceId", span.TraceID())
+			}
+
+			requestLogger := fields.Logger()
+			r = r.WithContext(requestLogger.WithContext(r.Context()))
+
+			start := time.Now()
+			body := s3response.CountBody(r)
+			rw := s3response.NewRecorder(w)
+
+			next.ServeAction(rw, r, route)
+
+			event := requestLogger.Info()
+			if status := rw.Status(); status >= http.StatusInternalServerError && status != http.StatusNotImplemented {
+				event = requestLogger.Error()
+			}
+
+			event.
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("remoteAddr", r.RemoteAddr).
+				Str("userAgent", r.UserAgent()).
+				Int("status", rw.Status()).
+				Str("errorCode", rw.ErrorCode).
+				Int64("bytesIn", body.N).
+				Int64("bytesOut", rw.Written).
+				Dur("duration", time.Since(start)).
+				Msg("Request served")
+		})
+	}
+}
+
+// Ctx returns the logger of the request, fallback when the request was not
+// served through Middleware.
+func Ctx(r *http.Request, fallback *zerolog.Logger) *zerolog.Logger {
+	if logger := zerolog.Ctx(r.Context()); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+
+	return fallback
+}
@@ -0,0 +1,125 @@
+package s3metrics
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type Tag struct {
+	Key   string
+	Value string
+}
+
+type And struct {
+	Prefix         string `xml:",omitempty"`
+	Tags           []Tag  `xml:"Tag"`
+	AccessPointArn string `xml:",omitempty"`
+}
+
+type Filter struct {
+	Prefix         string `xml:",omitempty"`
+	Tag            *Tag
+	AccessPointArn string `xml:",omitempty"`
+	And            *And
+}
+
+// Configuration is a request metrics configuration of a bucket. Without
+// filter, it covers every request of the bucket.
+type Configuration struct {
+	XMLName xml.Name `xml:"MetricsConfiguration"`
+	ID      string   `xml:"Id"`
+	Filter  *Filter
+}
+
+// ParseConfiguration decodes a PutBucketMetricsConfiguration payload, whose
+// Id must be the id query parameter.
+func ParseConfiguration(payload []byte, id string) (*Configuration, error) {
+	var config Configuration
+	if err := xml.Unmarshal(payload, &config); err != nil || config.ID == "" {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	if config.ID != id {
+		return nil, s3errors.InvalidArgument.New().WithMessage("The configuration Id does not match the id query parameter")
+	}
+
+	if config.Filter != nil && !config.Filter.valid() {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	return &config, nil
+}
+
+// valid reports whether the filter has a single condition, several ones
+// being combined with And, and whether its tags have a key.
+func (f *Filter) valid() bool {
+	conditions := 0
+	for _, set := range []bool{f.Prefix != "", f.Tag != nil, f.AccessPointArn != "", f.And != nil} {
+		if set {
+			conditions++
+		}
+	}
+
+	if conditions > 1 {
+		return false
+	}
+
+	tags := []Tag(nil)
+	if f.Tag != nil {
+		tags = append(tags, *f.Tag)
+	}
+
+	if f.And != nil {
+		tags = append(tags, f.And.Tags...)
+	}
+
+	for _, tag := range tags {
+		if tag.Key == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Request is what the filters of the configurations are matched against.
+type Request struct {
+	Key            string
+	Tags           map[string]string
+	AccessPointArn string
+}
+
+// Matches reports whether the filter of the configuration selects a
+// request. Every condition of the filter must hold.
+func (c *Configuration) Matches(req Request) bool {
+	if c.Filter == nil {
+		return true
+	}
+
+	prefix, tags, accessPoint := c.Filter.Prefix, []Tag(nil), c.Filter.AccessPointArn
+	if c.Filter.Tag != nil {
+		tags = append(tags, *c.Filter.Tag)
+	}
+
+	if and := c.Filter.And; and != nil {
+		prefix, tags, accessPoint = and.Prefix, and.Tags, and.AccessPointArn
+	}
+
+	if !strings.HasPrefix(req.Key, prefix) {
+		return false
+	}
+
+	if accessPoint != "" && accessPoint != req.AccessPointArn {
+		return false
+	}
+
+	for _, tag := range tags {
+		if value, exists := req.Tags[tag.Key]; !exists || value != tag.Value {
+			return false
+		}
+	}
+
+	return true
+}
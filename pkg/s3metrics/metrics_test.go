@@ -0,0 +1,249 @@
+package s3metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+func mustParse(t *testing.T, id, payload string) *Configuration {
+	t.Helper()
+
+	config, err := ParseConfiguration([]byte(payload), id)
+	require.NoError(t, err)
+
+	return config
+}
+
+func TestParseConfiguration(t *testing.T) {
+	config := mustParse(t, "logs", `<MetricsConfiguration><Id>logs</Id><Filter><Prefix>logs/</Prefix></Filter></MetricsConfiguration>`)
+	require.Equal(t, "logs", config.ID)
+	require.Equal(t, "logs/", config.Filter.Prefix)
+
+	_, err := ParseConfiguration([]byte(`<MetricsConfiguration/>`), "")
+	require.Error(t, err)
+
+	_, err = ParseConfiguration([]byte(`<MetricsConfiguration>`), "")
+	require.Error(t, err)
+
+	_, err = ParseConfiguration([]byte(`<MetricsConfiguration><Id>both</Id><Filter><Prefix>logs/</Prefix><Tag><Key>k</Key><Value>v</Value></Tag></Filter></MetricsConfiguration>`), "both")
+	require.Error(t, err)
+
+	_, err = ParseConfiguration([]byte(`<MetricsConfiguration><Id>tag</Id><Filter><And><Tag><Value>v</Value></Tag></And></Filter></MetricsConfiguration>`), "tag")
+	require.Error(t, err)
+
+	_, err = ParseConfiguration([]byte(`<MetricsConfiguration><Id>b</Id></MetricsConfiguration>`), "a")
+	require.ErrorContains(t, err, "InvalidArgument")
+}
+
+func TestMatches(t *testing.T) {
+	all := mustParse(t, "all", `<MetricsConfiguration><Id>all</Id></MetricsConfiguration>`)
+	prefix := mustParse(t, "p", `<MetricsConfiguration><Id>p</Id><Filter><Prefix>logs/</Prefix></Filter></MetricsConfiguration>`)
+	tag := mustParse(t, "t", `<MetricsConfiguration><Id>t</Id><Filter><Tag><Key>env</Key><Value>prod</Value></Tag></Filter></MetricsConfiguration>`)
+	accessPoint := mustParse(t, "ap", `<MetricsConfiguration><Id>ap</Id><Filter><AccessPointArn>arn:ap</AccessPointArn></Filter></MetricsConfiguration>`)
+	and := mustParse(t, "and", `<MetricsConfiguration><Id>and</Id><Filter><And>
+		<Prefix>logs/</Prefix>
+		<Tag><Key>env</Key><Value>prod</Value></Tag>
+		<Tag><Key>team</Key><Value>ops</Value></Tag>
+	</And></Filter></MetricsConfiguration>`)
+
+	prod := map[string]string{"env": "prod", "team": "ops"}
+
+	for _, tc := range []struct {
+		config   *Configuration
+		request  Request
+		expected bool
+	}{
+		{all, Request{}, true},
+		{prefix, Request{Key: "logs/today"}, true},
+		{prefix, Request{Key: "data/today"}, false},
+		{prefix, Request{}, false},
+		{tag, Request{Key: "a", Tags: prod}, true},
+		{tag, Request{Key: "a", Tags: map[string]string{"env": "dev"}}, false},
+		{tag, Request{Key: "a"}, false},
+		{accessPoint, Request{AccessPointArn: "arn:ap"}, true},
+		{accessPoint, Request{}, false},
+		{and, Request{Key: "logs/a", Tags: prod}, true},
+		{and, Request{Key: "logs/a", Tags: map[string]string{"env": "prod"}}, false},
+		{and, Request{Key: "data/a", Tags: prod}, false},
+	} {
+		require.Equal(t, tc.expected, tc.config.Matches(tc.request), "%s %+v", tc.config.ID, tc.request)
+	}
+}
+
+func TestRequestType(t *testing.T) {
+	require.Equal(t, "Get", RequestType(http.MethodGet, s3router.ActionGetObject))
+	require.Equal(t, "List", RequestType(http.MethodGet, s3router.ActionListObjects))
+	require.Equal(t, "Select", RequestType(http.MethodPost, s3router.ActionSelectObjectContent))
+	require.Equal(t, "Put", RequestType(http.MethodPut, s3router.ActionPutObject))
+	require.Equal(t, "Head", RequestType(http.MethodHead, s3router.ActionHeadObject))
+	require.Equal(t, "Delete", RequestType(http.MethodDelete, s3router.ActionDeleteObject))
+	require.Equal(t, "Post", RequestType(http.MethodPost, s3router.ActionDeleteObjects))
+}
+
+func TestRecorder(t *testing.T) {
+	configs := map[string][]*Configuration{
+		"bucket": {
+			mustParse(t, "all", `<MetricsConfiguration><Id>all</Id></MetricsConfiguration>`),
+			mustParse(t, "logs", `<MetricsConfiguration><Id>logs</Id><Filter><Prefix>logs/</Prefix></Filter></MetricsConfiguration>`),
+		},
+	}
+
+	logger := zerolog.Nop()
+	registry := prometheus.NewRegistry()
+
+	recorder, err := NewRecorder(&logger, registry, func(_ context.Context, bucket string) ([]*Configuration, error) {
+		if bucket == "broken" {
+			return nil, errors.New("store failure")
+		}
+
+		return configs[bucket], nil
+	})
+	require.NoError(t, err)
+
+	handler := recorder.Middleware(s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		if route.Key == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, err = w.Write(body)
+		require.NoError(t, err)
+	}))
+
+	serve := func(method, bucket, key, body string) {
+		r := httptest.NewRequest(method, "/"+bucket+"/"+key, strings.NewReader(body))
+		route := &s3router.Route{Bucket: bucket, Key: key, Action: s3router.ActionPutObject}
+
+		if method == http.MethodGet {
+			route.Action = s3router.ActionGetObject
+		}
+
+		handler.ServeAction(httptest.NewRecorder(), r, route)
+	}
+
+	serve(http.MethodPut, "bucket", "logs/a", "hello")
+	serve(http.MethodGet, "bucket", "data/b", "")
+	serve(http.MethodGet, "bucket", "missing", "")
+	serve(http.MethodPut, "other", "logs/a", "ignored")
+	serve(http.MethodPut, "broken", "logs/a", "ignored")
+
+	require.Equal(t, 1.0, testutil.ToFloat64(recorder.requests.WithLabelValues("bucket", "all", "Put")))
+	require.Equal(t, 2.0, testutil.ToFloat64(recorder.requests.WithLabelValues("bucket", "all", "Get")))
+	require.Equal(t, 1.0, testutil.ToFloat64(recorder.requests.WithLabelValues("bucket", "logs", "Put")))
+	require.Equal(t, 0.0, testutil.ToFloat64(recorder.requests.WithLabelValues("bucket", "logs", "Get")))
+	require.Equal(t, 1.0, testutil.ToFloat64(recorder.errors.WithLabelValues("bucket", "all", "4xx")))
+	require.Equal(t, 5.0, testutil.ToFloat64(recorder.bytesUploaded.WithLabelValues("bucket", "logs")))
+	require.Equal(t, 5.0, testutil.ToFloat64(recorder.bytesDownloaded.WithLabelValues("bucket", "logs")))
+
+	count, err := testutil.GatherAndCount(registry, "s3impl_bucket_total_request_latency_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	count, err = testutil.GatherAndCount(registry, "s3impl_bucket_requests_total")
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+}
+
+func TestConfigurationCache(t *testing.T) {
+	loads := 0
+	cache := NewConfigurationCache(func(_ context.Context, bucket string) ([]*Configuration, error) {
+		loads++
+		if bucket == "broken" {
+			return nil, errors.New("store failure")
+		}
+
+		return []*Configuration{{ID: "all"}}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		configs, err := cache.Configurations(context.Background(), "bucket")
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+	}
+	require.Equal(t, 1, loads)
+
+	cache.Invalidate("bucket")
+	_, err := cache.Configurations(context.Background(), "bucket")
+	require.NoError(t, err)
+	require.Equal(t, 2, loads)
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.Configurations(context.Background(), "broken")
+		require.Error(t, err)
+	}
+	require.Equal(t, 4, loads)
+}
+
+func TestServer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	server, err := NewServer(registry, func() ([]BucketUsage, error) {
+		return []BucketUsage{{Bucket: "bucket", Bytes: 42, Documents: 2}}, nil
+	})
+	require.NoError(t, err)
+
+	logger := zerolog.Nop()
+	recorder, err := NewRecorder(&logger, registry, func(context.Context, string) ([]*Configuration, error) {
+		return []*Configuration{{ID: "all"}}, nil
+	})
+	require.NoError(t, err)
+
+	actions := map[s3router.Action]s3router.ActionHandler{
+		s3router.ActionGetObject: s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, _ *s3router.Route) {
+			writer := s3errors.APIWriter{}
+			require.NoError(t, writer.Write(&s3errors.S3Error{HTTPStatusCode: http.StatusNotFound, Code: "NoSuchKey"}, w, r))
+		}),
+		s3router.ActionPutObject: s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, _ *s3router.Route) {
+			_, err := io.Copy(io.Discard, r.Body)
+			require.NoError(t, err)
+		}),
+	}
+
+	router := s3router.New(&logger, []string{"example.com"}, actions, server.Middleware, recorder.Middleware)
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "http://example.com/bucket/key", nil),
+		httptest.NewRequest(http.MethodPut, "http://example.com/bucket/key", strings.NewReader("hello")),
+		httptest.NewRequest(http.MethodDelete, "http://example.com/bucket/key", nil),
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	require.Equal(t, 1.0, testutil.ToFloat64(server.requests.WithLabelValues("ActionGetObject", "404", "NoSuchKey")))
+	require.Equal(t, 1.0, testutil.ToFloat64(server.requests.WithLabelValues("ActionPutObject", "200", "")))
+	require.Equal(t, 1.0, testutil.ToFloat64(server.requests.WithLabelValues("ActionDeleteObject", "501", "NotImplemented")))
+	require.Equal(t, 1.0, testutil.ToFloat64(recorder.errors.WithLabelValues("bucket", "all", "4xx")))
+	require.Equal(t, 1.0, testutil.ToFloat64(recorder.errors.WithLabelValues("bucket", "all", "5xx")))
+	require.Equal(t, 1.0, testutil.ToFloat64(recorder.requests.WithLabelValues("bucket", "all", "Put")))
+	require.Equal(t, 5.0, testutil.ToFloat64(server.requestBytes.WithLabelValues("ActionPutObject")))
+	require.Equal(t, 0.0, testutil.ToFloat64(server.inFlight.WithLabelValues("ActionGetObject")))
+
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP s3impl_bucket_configuration_bytes Bytes of the configuration documents stored for the bucket.
+# TYPE s3impl_bucket_configuration_bytes gauge
+s3impl_bucket_configuration_bytes{bucket="bucket"} 42
+# HELP s3impl_bucket_configuration_documents Configuration documents stored for the bucket.
+# TYPE s3impl_bucket_configuration_documents gauge
+s3impl_bucket_configuration_documents{bucket="bucket"} 2
+`), "s3impl_bucket_configuration_bytes", "s3impl_bucket_configuration_documents"))
+
+	count, err := testutil.GatherAndCount(registry, "go_goroutines")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
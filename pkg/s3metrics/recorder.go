@@ -0,0 +1,260 @@
+package s3metrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3request"
+	"github.com/lvjp/s3impl/pkg/s3response"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// ConfigurationsFunc returns the metrics configurations of a bucket.
+type ConfigurationsFunc func(ctx context.Context, bucket string) ([]*Configuration, error)
+
+// Recorder exports the requests of the buckets as Prometheus series, one
+// per metrics configuration, like the CloudWatch request metrics.
+type Recorder struct {
+	logger  *zerolog.Logger
+	configs ConfigurationsFunc
+
+	requests         *prometheus.CounterVec
+	errors           *prometheus.CounterVec
+	bytesDownloaded  *prometheus.CounterVec
+	bytesUploaded    *prometheus.CounterVec
+	firstByteLatency *prometheus.HistogramVec
+	totalLatency     *prometheus.HistogramVec
+}
+
+const (
+	namespace = "s3impl"
+	subsystem = "bucket"
+)
+
+var labels = []string{"bucket", "filter_id"}
+
+func NewRecorder(logger *zerolog.Logger, registerer prometheus.Registerer, configs ConfigurationsFunc) (*Recorder, error) {
+	r := &Recorder{
+		logger:  logger,
+		configs: configs,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Requests by type: Get, Put, Delete, Head, Post, Select or List.",
+		}, append(labels, "type")),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "errors_total",
+			Help:      "Requests answered with a 4xx or 5xx status.",
+		}, append(labels, "class")),
+		bytesDownloaded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "bytes_downloaded_total",
+			Help:      "Bytes of the response bodies.",
+		}, labels),
+		bytesUploaded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "bytes_uploaded_total",
+			Help:      "Bytes of the request bodies.",
+		}, labels),
+		firstByteLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "first_byte_latency_seconds",
+			Help:      "Time until the response starts.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		totalLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "total_request_latency_seconds",
+			Help:      "Time until the response is complete.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+	}
+
+	for _, collector := range []prometheus.Collector{
+		r.requests, r.errors, r.bytesDownloaded, r.bytesUploaded, r.firstByteLatency, r.totalLatency,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Measurement is a served request.
+type Measurement struct {
+	Bucket     string
+	Type       string
+	Request    Request
+	StatusCode int
+	BytesIn    int64
+	BytesOut   int64
+	FirstByte  time.Duration
+	Total      time.Duration
+}
+
+// Record adds a request to the series of the matching configurations.
+func (r *Recorder) Record(configs []*Configuration, m *Measurement) {
+	for _, config := range configs {
+		if !config.Matches(m.Request) {
+			continue
+		}
+
+		values := []string{m.Bucket, config.ID}
+
+		r.requests.WithLabelValues(m.Bucket, config.ID, m.Type).Inc()
+		switch {
+		case m.StatusCode >= 500:
+			r.errors.WithLabelValues(m.Bucket, config.ID, "5xx").Inc()
+		case m.StatusCode >= 400:
+			r.errors.WithLabelValues(m.Bucket, config.ID, "4xx").Inc()
+		}
+
+		r.bytesDownloaded.WithLabelValues(values...).Add(float64(m.BytesOut))
+		r.bytesUploaded.WithLabelValues(values...).Add(float64(m.BytesIn))
+		r.firstByteLatency.WithLabelValues(values...).Observe(m.FirstByte.Seconds())
+		r.totalLatency.WithLabelValues(values...).Observe(m.Total.Seconds())
+	}
+}
+
+// Middleware measures every routed request for the metrics configurations
+// of the requested bucket.
+func (r *Recorder) Middleware(handler s3router.ActionHandler) s3router.ActionHandler {
+	return s3router.ActionHandlerFunc(func(w http.ResponseWriter, req *http.Request, route *s3router.Route) {
+		if route.Bucket == "" {
+			handler.ServeAction(w, req, route)
+			return
+		}
+
+		configs, err := r.configs(req.Context(), route.Bucket)
+		if err != nil {
+			s3logging.Ctx(req, r.logger).Warn().Err(err).Str("bucket", route.Bucket).Msg("Cannot read metrics configurations")
+		}
+
+		if len(configs) == 0 {
+			handler.ServeAction(w, req, route)
+			return
+		}
+
+		start := time.Now()
+		body := s3response.CountBody(req)
+		rw := s3response.NewRecorder(w)
+
+		handler.ServeAction(rw, req, route)
+
+		end := time.Now()
+		if rw.FirstByte.IsZero() {
+			rw.FirstByte = end
+		}
+
+		// The decoders answer the invalid tags, the metrics only match
+		// the valid ones.
+		tags, _ := s3request.Tagging(req.Header)
+
+		r.Record(configs, &Measurement{
+			Bucket: route.Bucket,
+			Type:   RequestType(req.Method, route.Action),
+			Request: Request{
+				Key:  route.Key,
+				Tags: tags,
+				// Access points are not implemented, the requests are
+				// only matched by filters without AccessPointArn.
+			},
+			StatusCode: rw.Status(),
+			BytesIn:    body.N,
+			BytesOut:   rw.Written,
+			FirstByte:  rw.FirstByte.Sub(start),
+			Total:      end.Sub(start),
+		})
+	})
+}
+
+// ConfigurationCache keeps the metrics configurations of the buckets read
+// by load until they are invalidated.
+type ConfigurationCache struct {
+	load ConfigurationsFunc
+
+	mu         sync.Mutex
+	configs    map[string][]*Configuration
+	generation uint64
+}
+
+func NewConfigurationCache(load ConfigurationsFunc) *ConfigurationCache {
+	return &ConfigurationCache{load: load, configs: make(map[string][]*Configuration)}
+}
+
+// Configurations is the ConfigurationsFunc of the cache.
+func (c *ConfigurationCache) Configurations(ctx context.Context, bucket string) ([]*Configuration, error) {
+	c.mu.Lock()
+	configs, cached := c.configs[bucket]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if cached {
+		return configs, nil
+	}
+
+	configs, err := c.load(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A configuration changed while it was loaded, the next request
+	// loads it again.
+	if generation == c.generation {
+		c.configs[bucket] = configs
+	}
+
+	return configs, nil
+}
+
+// Invalidate drops the cached configurations of a bucket.
+func (c *ConfigurationCache) Invalidate(bucket string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.configs, bucket)
+	c.generation++
+}
+
+var listActions = map[s3router.Action]bool{
+	s3router.ActionListObjects:          true,
+	s3router.ActionListObjectVersions:   true,
+	s3router.ActionListMultipartUploads: true,
+}
+
+// RequestType returns the CloudWatch request type of an action.
+func RequestType(method string, action s3router.Action) string {
+	switch {
+	case action == s3router.ActionSelectObjectContent:
+		return "Select"
+	case listActions[action]:
+		return "List"
+	case method == http.MethodGet:
+		return "Get"
+	case method == http.MethodPut:
+		return "Put"
+	case method == http.MethodDelete:
+		return "Delete"
+	case method == http.MethodHead:
+		return "Head"
+	default:
+		return "Post"
+	}
+}
@@ -0,0 +1,151 @@
+package s3metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/lvjp/s3impl/pkg/s3response"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// BucketUsage is the storage used by the configuration documents of a
+// bucket.
+type BucketUsage struct {
+	Bucket    string
+	Bytes     int64
+	Documents int64
+}
+
+// UsageFunc returns the storage used by the buckets, it is called on each
+// scrape.
+type UsageFunc func() ([]BucketUsage, error)
+
+// Server exports the internals of the server: the requests by action,
+// status and error code, the configuration documents stored and the Go
+// runtime.
+type Server struct {
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	inFlight      *prometheus.GaugeVec
+	requestBytes  *prometheus.CounterVec
+	responseBytes *prometheus.CounterVec
+}
+
+// NewServer registers the server metrics. The storage usage is not exported
+// when usage is nil.
+func NewServer(registerer prometheus.Registerer, usage UsageFunc) (*Server, error) {
+	s := &Server{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Requests by action, status and S3 error code.",
+		}, []string{"action", "status", "error_code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time to serve the requests by action, status and S3 error code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"action", "status", "error_code"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "Requests being served by action.",
+		}, []string{"action"}),
+		requestBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_bytes_total",
+			Help:      "Bytes of the request bodies by action.",
+		}, []string{"action"}),
+		responseBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "response_bytes_total",
+			Help:      "Bytes of the response bodies by action.",
+		}, []string{"action"}),
+	}
+
+	registered := []prometheus.Collector{
+		s.requests, s.duration, s.inFlight, s.requestBytes, s.responseBytes,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}
+
+	if usage != nil {
+		registered = append(registered, &usageCollector{usage: usage})
+	}
+
+	for _, collector := range registered {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Middleware measures every request served by the router.
+func (s *Server) Middleware(next s3router.ActionHandler) s3router.ActionHandler {
+	return s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+		action := route.Action.String()
+
+		inFlight := s.inFlight.WithLabelValues(action)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		body := s3response.CountBody(r)
+		rw := s3response.NewRecorder(w)
+
+		next.ServeAction(rw, r, route)
+
+		status := strconv.Itoa(rw.Status())
+
+		s.requests.WithLabelValues(action, status, rw.ErrorCode).Inc()
+		s.duration.WithLabelValues(action, status, rw.ErrorCode).Observe(time.Since(start).Seconds())
+		s.requestBytes.WithLabelValues(action).Add(float64(body.N))
+		s.responseBytes.WithLabelValues(action).Add(float64(rw.Written))
+	})
+}
+
+var (
+	configurationBytes = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "configuration_bytes"),
+		"Bytes of the configuration documents stored for the bucket.",
+		[]string{"bucket"}, nil,
+	)
+	configurationDocuments = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "configuration_documents"),
+		"Configuration documents stored for the bucket.",
+		[]string{"bucket"}, nil,
+	)
+)
+
+type usageCollector struct {
+	usage UsageFunc
+}
+
+func (c *usageCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- configurationBytes
+	descs <- configurationDocuments
+}
+
+func (c *usageCollector) Collect(metrics chan<- prometheus.Metric) {
+	usages, err := c.usage()
+	if err != nil {
+		metrics <- prometheus.NewInvalidMetric(configurationBytes, err)
+		return
+	}
+
+	for _, usage := range usages {
+		metrics <- prometheus.MustNewConstMetric(configurationBytes, prometheus.GaugeValue, float64(usage.Bytes), usage.Bucket)
+		metrics <- prometheus.MustNewConstMetric(configurationDocuments, prometheus.GaugeValue, float64(usage.Documents), usage.Bucket)
+	}
+}
@@ -0,0 +1,156 @@
+package s3notify
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+// TargetARN returns the ARN identifying a webhook target declared in the
+// configuration file, to be used as Topic, Queue or CloudFunction.
+func TargetARN(name string) string {
+	return "arn:s3impl:webhook:::" + name
+}
+
+type Configuration struct {
+	XMLName   xml.Name  `xml:"NotificationConfiguration"`
+	Namespace string    `xml:"xmlns,attr,omitempty"`
+	Topics    []Binding `xml:"TopicConfiguration"`
+	Queues    []Binding `xml:"QueueConfiguration"`
+	Functions []Binding `xml:"CloudFunctionConfiguration"`
+}
+
+// Binding is a TopicConfiguration, QueueConfiguration or
+// CloudFunctionConfiguration element: they only differ by the name of the
+// element holding the target ARN.
+type Binding struct {
+	ID            string      `xml:"Id,omitempty"`
+	Topic         string      `xml:",omitempty"`
+	Queue         string      `xml:",omitempty"`
+	CloudFunction string      `xml:",omitempty"`
+	Events        []EventName `xml:"Event"`
+	Filter        *Filter     `xml:",omitempty"`
+}
+
+type Filter struct {
+	S3Key struct {
+		Rules []FilterRule `xml:"FilterRule"`
+	}
+}
+
+type FilterRule struct {
+	Name  string
+	Value string
+}
+
+func (b *Binding) arn() string {
+	return b.Topic + b.Queue + b.CloudFunction
+}
+
+func (c *Configuration) all() []*Binding {
+	var all []*Binding
+
+	for _, list := range [][]Binding{c.Topics, c.Queues, c.Functions} {
+		for i := range list {
+			all = append(all, &list[i])
+		}
+	}
+
+	return all
+}
+
+// ParseConfiguration decodes and validates a PutBucketNotificationConfiguration
+// payload. targetExists tells whether an ARN designates a known target.
+func ParseConfiguration(payload []byte, targetExists func(arn string) bool) (*Configuration, error) {
+	var config Configuration
+	if err := xml.Unmarshal(payload, &config); err != nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	config.Namespace = s3consts.XMLNamespace
+	ids := make(map[string]bool)
+
+	for _, binding := range config.all() {
+		if binding.ID == "" {
+			binding.ID = uuid.NewString()
+		}
+
+		if ids[binding.ID] {
+			return nil, s3errors.InvalidArgument.New().WithMessage("Configurations must have unique IDs: " + binding.ID)
+		}
+		ids[binding.ID] = true
+
+		if !targetExists(binding.arn()) {
+			return nil, s3errors.InvalidArgument.New().WithMessage("Unable to validate the following destination configurations: " + binding.arn())
+		}
+
+		if len(binding.Events) == 0 {
+			return nil, s3errors.MalformedXML.New()
+		}
+
+		for _, event := range binding.Events {
+			if !event.Valid() {
+				return nil, s3errors.InvalidArgument.New().WithMessage("The event is not supported for notifications: " + string(event))
+			}
+		}
+
+		if err := binding.validateFilter(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+func (b *Binding) validateFilter() error {
+	if b.Filter == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+
+	for i, rule := range b.Filter.S3Key.Rules {
+		name := strings.ToLower(rule.Name)
+		if name != "prefix" && name != "suffix" {
+			return s3errors.InvalidArgument.New().WithMessage("filter rule name must be either prefix or suffix")
+		}
+
+		if seen[name] {
+			return s3errors.InvalidArgument.New().WithMessage("Cannot specify more than one " + name + " rule in a filter.")
+		}
+		seen[name] = true
+
+		b.Filter.S3Key.Rules[i].Name = name
+	}
+
+	return nil
+}
+
+// match reports whether the binding applies to an event on key.
+func (b *Binding) match(name EventName, key string) bool {
+	matched := false
+	for _, event := range b.Events {
+		if event.Match(name) {
+			matched = true
+			break
+		}
+	}
+
+	if !matched || b.Filter == nil {
+		return matched
+	}
+
+	for _, rule := range b.Filter.S3Key.Rules {
+		switch rule.Name {
+		case "prefix":
+			matched = matched && strings.HasPrefix(key, rule.Value)
+		case "suffix":
+			matched = matched && strings.HasSuffix(key, rule.Value)
+		}
+	}
+
+	return matched
+}
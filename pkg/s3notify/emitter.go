@@ -0,0 +1,184 @@
+package s3notify
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lvjp/s3impl/pkg/s3auth"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3request"
+	"github.com/lvjp/s3impl/pkg/s3response"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// ConfigurationFunc returns the notification configuration of a bucket, nil
+// when it has none.
+type ConfigurationFunc func(ctx context.Context, bucket string) (*Configuration, error)
+
+// Emitter reports the events of the successful object requests to the
+// event streams of the broker and, when configured, to the notification
+// targets of the buckets.
+type Emitter struct {
+	logger   *zerolog.Logger
+	broker   *Broker
+	notifier *Notifier
+	configs  ConfigurationFunc
+}
+
+// NewEmitter returns an emitter publishing to broker. Events are only
+// streamed when notifier is nil.
+func NewEmitter(logger *zerolog.Logger, broker *Broker, notifier *Notifier, configs ConfigurationFunc) *Emitter {
+	return &Emitter{logger: logger, broker: broker, notifier: notifier, configs: configs}
+}
+
+// Emit publishes event and queues its deliveries.
+func (e *Emitter) Emit(ctx context.Context, event Event) error {
+	e.broker.Publish(event)
+
+	if e.notifier == nil {
+		return nil
+	}
+
+	config, err := e.configs(ctx, event.Bucket)
+	if err != nil || config == nil {
+		return err
+	}
+
+	return e.notifier.Notify(config, event)
+}
+
+// actionEvents are the events emitted by the object actions.
+var actionEvents = map[s3router.Action]EventName{
+	s3router.ActionPutObject:               EventObjectCreatedPut,
+	s3router.ActionCopyObject:              EventObjectCreatedCopy,
+	s3router.ActionCompleteMultipartUpload: EventObjectCreatedCompleteMultipartUpload,
+	s3router.ActionDeleteObject:            EventObjectRemovedDelete,
+	s3router.ActionDeleteObjects:           EventObjectRemovedDelete,
+	s3router.ActionRestoreObject:           EventObjectRestorePost,
+	s3router.ActionPutObjectTagging:        EventObjectTaggingPut,
+	s3router.ActionDeleteObjectTagging:     EventObjectTaggingDelete,
+	s3router.ActionPutObjectACL:            EventObjectACLPut,
+}
+
+// Result is an object created or removed by a request.
+type Result struct {
+	Key          string
+	Size         int64
+	ETag         string
+	VersionID    string
+	DeleteMarker bool
+}
+
+// ResultReporter is implemented by the response writers collecting the
+// results of the action handlers.
+type ResultReporter interface {
+	ReportResult(result Result)
+}
+
+// Report hands result to the ResultReporter of the writer chain of w.
+// Handlers report the objects they create or remove, DeleteObjects one
+// result per deleted key.
+func Report(w http.ResponseWriter, result Result) {
+	for {
+		if reporter, ok := w.(ResultReporter); ok {
+			reporter.ReportResult(result)
+			return
+		}
+
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+
+		w = wrapper.Unwrap()
+	}
+}
+
+type resultWriter struct {
+	*s3response.Recorder
+	results []Result
+}
+
+func (r *resultWriter) ReportResult(result Result) {
+	r.results = append(r.results, result)
+}
+
+// Middleware emits the events of every successful object request, one per
+// reported result.
+func (e *Emitter) Middleware(next s3router.ActionHandler) s3router.ActionHandler {
+	return s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+		name, emitted := actionEvents[route.Action]
+		if !emitted {
+			next.ServeAction(w, r, route)
+			return
+		}
+
+		rw := &resultWriter{Recorder: s3response.NewRecorder(w)}
+		next.ServeAction(rw, r, route)
+
+		if rw.Status() >= http.StatusMultipleChoices {
+			return
+		}
+
+		results := rw.results
+		if len(results) == 0 && route.Action != s3router.ActionDeleteObjects {
+			results = []Result{fallbackResult(r, route, rw.Header())}
+		}
+
+		for _, result := range results {
+			event := Event{
+				Name:      name,
+				Time:      time.Now(),
+				Bucket:    route.Bucket,
+				Key:       result.Key,
+				Size:      result.Size,
+				ETag:      result.ETag,
+				VersionID: result.VersionID,
+				Principal: s3auth.AccessKey(r),
+				SourceIP:  sourceIP(r),
+				RequestID: rw.Header().Get("x-amz-request-id"),
+				HostID:    rw.Header().Get(s3errors.HeaderHostID),
+			}
+
+			if name == EventObjectRemovedDelete && result.DeleteMarker {
+				event.Name = EventObjectRemovedDeleteMarkerCreated
+			}
+
+			if err := e.Emit(r.Context(), event); err != nil {
+				s3logging.Ctx(r, e.logger).Error().Err(err).Str("bucket", route.Bucket).Str("event", string(event.Name)).Msg("Cannot emit event")
+			}
+		}
+	})
+}
+
+// fallbackResult is the result of the handlers reporting none, read from
+// the response headers. The size is only known for PutObject, whose payload
+// is the object.
+func fallbackResult(r *http.Request, route *s3router.Route, header http.Header) Result {
+	result := Result{
+		Key:          route.Key,
+		ETag:         header.Get("ETag"),
+		VersionID:    header.Get("x-amz-version-id"),
+		DeleteMarker: header.Get("x-amz-delete-marker") == "true",
+	}
+
+	if route.Action == s3router.ActionPutObject {
+		result.Size, _ = s3request.ContentLength(r)
+	}
+
+	return result
+}
+
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,75 @@
+package s3notify
+
+import "strings"
+
+type EventName string
+
+const (
+	EventObjectCreatedAll                     EventName = "s3:ObjectCreated:*"
+	EventObjectCreatedPut                     EventName = "s3:ObjectCreated:Put"
+	EventObjectCreatedPost                    EventName = "s3:ObjectCreated:Post"
+	EventObjectCreatedCopy                    EventName = "s3:ObjectCreated:Copy"
+	EventObjectCreatedCompleteMultipartUpload EventName = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedAll                     EventName = "s3:ObjectRemoved:*"
+	EventObjectRemovedDelete                  EventName = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedDeleteMarkerCreated     EventName = "s3:ObjectRemoved:DeleteMarkerCreated"
+	EventObjectRestoreAll                     EventName = "s3:ObjectRestore:*"
+	EventObjectRestorePost                    EventName = "s3:ObjectRestore:Post"
+	EventObjectRestoreCompleted               EventName = "s3:ObjectRestore:Completed"
+	EventObjectRestoreDelete                  EventName = "s3:ObjectRestore:Delete"
+	EventObjectTaggingAll                     EventName = "s3:ObjectTagging:*"
+	EventObjectTaggingPut                     EventName = "s3:ObjectTagging:Put"
+	EventObjectTaggingDelete                  EventName = "s3:ObjectTagging:Delete"
+	EventObjectACLPut                         EventName = "s3:ObjectAcl:Put"
+	EventReplicationAll                       EventName = "s3:Replication:*"
+	EventReplicationOperationFailed           EventName = "s3:Replication:OperationFailedReplication"
+	EventReplicationOperationCompleted        EventName = "s3:Replication:OperationReplicatedAfterThreshold"
+	EventLifecycleExpirationAll               EventName = "s3:LifecycleExpiration:*"
+	EventLifecycleExpirationDelete            EventName = "s3:LifecycleExpiration:Delete"
+	EventLifecycleTransition                  EventName = "s3:LifecycleTransition"
+	EventTestEvent                            EventName = "s3:TestEvent"
+)
+
+var eventNames = map[EventName]bool{
+	EventObjectCreatedAll:                     true,
+	EventObjectCreatedPut:                     true,
+	EventObjectCreatedPost:                    true,
+	EventObjectCreatedCopy:                    true,
+	EventObjectCreatedCompleteMultipartUpload: true,
+	EventObjectRemovedAll:                     true,
+	EventObjectRemovedDelete:                  true,
+	EventObjectRemovedDeleteMarkerCreated:     true,
+	EventObjectRestoreAll:                     true,
+	EventObjectRestorePost:                    true,
+	EventObjectRestoreCompleted:               true,
+	EventObjectRestoreDelete:                  true,
+	EventObjectTaggingAll:                     true,
+	EventObjectTaggingPut:                     true,
+	EventObjectTaggingDelete:                  true,
+	EventObjectACLPut:                         true,
+	EventReplicationAll:                       true,
+	EventReplicationOperationFailed:           true,
+	EventReplicationOperationCompleted:        true,
+	EventLifecycleExpirationAll:               true,
+	EventLifecycleExpirationDelete:            true,
+	EventLifecycleTransition:                  true,
+}
+
+func (e EventName) Valid() bool {
+	return eventNames[e]
+}
+
+// Match reports whether the configured event name, which may be a wildcard
+// like s3:ObjectCreated:*, covers the emitted event.
+func (e EventName) Match(emitted EventName) bool {
+	if prefix, wildcard := strings.CutSuffix(string(e), "*"); wildcard {
+		return strings.HasPrefix(string(emitted), prefix)
+	}
+
+	return e == emitted
+}
+
+// recordName is the name used in event records, without the s3: prefix.
+func (e EventName) recordName() string {
+	return strings.TrimPrefix(string(e), "s3:")
+}
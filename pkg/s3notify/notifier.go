@@ -0,0 +1,250 @@
+package s3notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sourcegraph/conc/pool"
+)
+
+type Webhook struct {
+	Name     string
+	Endpoint string
+	// AuthToken, when set, is sent as a bearer token.
+	AuthToken string `yaml:"authToken"`
+}
+
+type Config struct {
+	// QueueDir is where pending deliveries are stored. Notifications are
+	// disabled when empty.
+	QueueDir string `yaml:"queueDir"`
+	Webhooks []Webhook
+	// MaxAttempts before a delivery is dropped, 0 meaning DefaultMaxAttempts.
+	MaxAttempts  int           `yaml:"maxAttempts"`
+	MinBackoff   time.Duration `yaml:"minBackoff"`
+	MaxBackoff   time.Duration `yaml:"maxBackoff"`
+	PollInterval time.Duration `yaml:"pollInterval"`
+}
+
+const (
+	DefaultMaxAttempts  = 10
+	DefaultMinBackoff   = time.Second
+	DefaultMaxBackoff   = 5 * time.Minute
+	DefaultPollInterval = time.Second
+
+	deliveryTimeout = 10 * time.Second
+)
+
+// Notifier queues the events matching bucket notification configurations
+// and delivers them to webhooks, retrying with exponential backoff.
+type Notifier struct {
+	logger   *zerolog.Logger
+	config   Config
+	client   *http.Client
+	queue    *Queue
+	webhooks map[string]Webhook
+	// wake signals the worker of each target that events were queued.
+	wake map[string]chan struct{}
+}
+
+func NewNotifier(logger *zerolog.Logger, config Config) (*Notifier, error) {
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.MinBackoff == 0 {
+		config.MinBackoff = DefaultMinBackoff
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+
+	queue, err := OpenQueue(config.QueueDir)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Notifier{
+		logger:   logger,
+		config:   config,
+		client:   &http.Client{Timeout: deliveryTimeout},
+		queue:    queue,
+		webhooks: make(map[string]Webhook),
+		wake:     make(map[string]chan struct{}),
+	}
+
+	for _, webhook := range config.Webhooks {
+		arn := TargetARN(webhook.Name)
+		n.webhooks[arn] = webhook
+		n.wake[arn] = make(chan struct{}, 1)
+	}
+
+	return n, nil
+}
+
+// TargetExists is meant to validate configurations with ParseConfiguration.
+func (n *Notifier) TargetExists(arn string) bool {
+	_, exists := n.webhooks[arn]
+	return exists
+}
+
+// Notify queues a delivery for every target of config interested in event.
+func (n *Notifier) Notify(config *Configuration, event Event) error {
+	for arn, records := range config.Match(event) {
+		payload, err := json.Marshal(Records{Records: records})
+		if err != nil {
+			return fmt.Errorf("s3notify: cannot encode records: %w", err)
+		}
+
+		if err := n.queue.Push(arn, payload); err != nil {
+			return err
+		}
+
+		select {
+		case n.wake[arn] <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Run delivers queued events until ctx is done, each target by its own
+// worker so that a slow target does not hold up the others. The events left
+// for targets which are no longer configured are dropped.
+func (n *Notifier) Run(ctx context.Context) error {
+	for _, target := range n.queue.targets() {
+		if _, exists := n.webhooks[target]; exists {
+			continue
+		}
+
+		n.logger.Error().Str("target", target).Msg("Dropping the events of an unknown target")
+
+		if err := n.queue.drop(target); err != nil {
+			return err
+		}
+	}
+
+	workers := pool.New()
+
+	for target := range n.webhooks {
+		target := target
+		workers.Go(func() {
+			n.deliverTarget(ctx, target)
+		})
+	}
+
+	workers.Wait()
+
+	return nil
+}
+
+// deliverTarget delivers the events of target in order until ctx is done:
+// the oldest event is retried until it is delivered or dropped.
+func (n *Notifier) deliverTarget(ctx context.Context, target string) {
+	ticker := time.NewTicker(n.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			e, due := n.queue.head(target, time.Now())
+			if !due {
+				break
+			}
+
+			n.attempt(ctx, e)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-n.wake[target]:
+		}
+	}
+}
+
+// attempt delivers an event, rescheduling or dropping it on failure. An
+// attempt interrupted by ctx is not counted.
+func (n *Notifier) attempt(ctx context.Context, e entry) {
+	err := n.deliver(ctx, e)
+	if err == nil {
+		if err := n.queue.remove(e); err != nil {
+			n.logger.Error().Err(err).Str("target", e.Target).Msg("Cannot remove delivered event")
+		}
+
+		return
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	logger := n.logger.With().Err(err).Str("target", e.Target).Int("attempts", e.Attempts+1).Logger()
+
+	if e.Attempts+1 >= n.config.MaxAttempts {
+		logger.Error().Msg("Dropping event after too many delivery attempts")
+
+		if err := n.queue.remove(e); err != nil {
+			logger.Error().Err(err).Msg("Cannot remove dropped event")
+		}
+
+		return
+	}
+
+	logger.Warn().Msg("Event delivery failed, will retry")
+
+	if err := n.queue.retry(e, time.Now().Add(n.backoff(e.Attempts))); err != nil {
+		logger.Error().Err(err).Msg("Cannot reschedule event")
+	}
+}
+
+// backoff returns the delay before the next attempt, doubling after each
+// failure.
+func (n *Notifier) backoff(attempts int) time.Duration {
+	delay := n.config.MinBackoff
+	for i := 0; i < attempts && delay < n.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, n.config.MaxBackoff)
+}
+
+func (n *Notifier) deliver(ctx context.Context, e entry) error {
+	webhook, exists := n.webhooks[e.Target]
+	if !exists {
+		return fmt.Errorf("s3notify: unknown target %s", e.Target)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Endpoint, bytes.NewReader(e.Payload))
+	if err != nil {
+		return fmt.Errorf("s3notify: cannot create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+webhook.AuthToken)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3notify: delivery failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("s3notify: delivery failed with status %s", resp.Status)
+	}
+
+	return nil
+}
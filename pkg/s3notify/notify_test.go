@@ -0,0 +1,306 @@
+package s3notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfiguration = `<NotificationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <QueueConfiguration>
+    <Id>images</Id>
+    <Queue>arn:s3impl:webhook:::local</Queue>
+    <Event>s3:ObjectCreated:*</Event>
+    <Filter><S3Key>
+      <FilterRule><Name>Prefix</Name><Value>images/</Value></FilterRule>
+      <FilterRule><Name>Suffix</Name><Value>.jpg</Value></FilterRule>
+    </S3Key></Filter>
+  </QueueConfiguration>
+  <TopicConfiguration>
+    <Id>removals</Id>
+    <Topic>arn:s3impl:webhook:::local</Topic>
+    <Event>s3:ObjectRemoved:Delete</Event>
+  </TopicConfiguration>
+</NotificationConfiguration>`
+
+func targetExists(arn string) bool {
+	return arn == TargetARN("local")
+}
+
+func TestParseConfiguration(t *testing.T) {
+	config, err := ParseConfiguration([]byte(testConfiguration), targetExists)
+	require.NoError(t, err)
+	require.Len(t, config.all(), 2)
+
+	for name, tc := range map[string]struct {
+		payload  string
+		expected string
+	}{
+		"NotXML":        {payload: "{}", expected: "MalformedXML"},
+		"NoEvent":       {payload: `<NotificationConfiguration><QueueConfiguration><Queue>arn:s3impl:webhook:::local</Queue></QueueConfiguration></NotificationConfiguration>`, expected: "MalformedXML"},
+		"UnknownTarget": {payload: `<NotificationConfiguration><QueueConfiguration><Queue>arn:aws:sqs:::q</Queue><Event>s3:ObjectCreated:*</Event></QueueConfiguration></NotificationConfiguration>`, expected: "InvalidArgument"},
+		"UnknownEvent":  {payload: `<NotificationConfiguration><QueueConfiguration><Queue>arn:s3impl:webhook:::local</Queue><Event>s3:ObjectRead:*</Event></QueueConfiguration></NotificationConfiguration>`, expected: "InvalidArgument"},
+		"DuplicateID": {
+			payload: `<NotificationConfiguration>` +
+				`<QueueConfiguration><Id>a</Id><Queue>arn:s3impl:webhook:::local</Queue><Event>s3:ObjectCreated:*</Event></QueueConfiguration>` +
+				`<TopicConfiguration><Id>a</Id><Topic>arn:s3impl:webhook:::local</Topic><Event>s3:ObjectCreated:*</Event></TopicConfiguration>` +
+				`</NotificationConfiguration>`,
+			expected: "InvalidArgument",
+		},
+		"BadFilter": {
+			payload: `<NotificationConfiguration><QueueConfiguration><Queue>arn:s3impl:webhook:::local</Queue><Event>s3:ObjectCreated:*</Event>` +
+				`<Filter><S3Key><FilterRule><Name>regex</Name><Value>.*</Value></FilterRule></S3Key></Filter>` +
+				`</QueueConfiguration></NotificationConfiguration>`,
+			expected: "InvalidArgument",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConfiguration([]byte(tc.payload), targetExists)
+			require.Error(t, err)
+			require.Equal(t, tc.expected, err.(*s3errors.S3Error).Code)
+		})
+	}
+}
+
+func TestConfigurationMatch(t *testing.T) {
+	config, err := ParseConfiguration([]byte(testConfiguration), targetExists)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     EventName
+		key      string
+		expected []string
+	}{
+		{EventObjectCreatedPut, "images/cat.jpg", []string{"images"}},
+		{EventObjectCreatedCompleteMultipartUpload, "images/dir/dog.jpg", []string{"images"}},
+		{EventObjectCreatedPut, "images/cat.png", nil},
+		{EventObjectCreatedPut, "docs/cat.jpg", nil},
+		{EventObjectRemovedDelete, "images/cat.jpg", []string{"removals"}},
+		{EventObjectRemovedDeleteMarkerCreated, "images/cat.jpg", nil},
+	} {
+		var ids []string
+		for _, records := range config.Match(Event{Name: tc.name, Key: tc.key}) {
+			for _, record := range records {
+				ids = append(ids, record.S3.ConfigurationID)
+			}
+		}
+
+		require.Equal(t, tc.expected, ids, "%s %s", tc.name, tc.key)
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	record := NewRecord(Event{
+		Name:      EventObjectCreatedPut,
+		Time:      time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC),
+		Bucket:    "bucket",
+		Key:       "my dir/file+1.txt",
+		Size:      42,
+		ETag:      `"abc"`,
+		RequestID: "request",
+	}, "config")
+
+	raw, err := json.Marshal(record)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"eventVersion": "2.1",
+		"eventSource": "aws:s3",
+		"awsRegion": "us-east-1",
+		"eventTime": "2023-12-01T10:00:00.000Z",
+		"eventName": "ObjectCreated:Put",
+		"userIdentity": {"principalId": ""},
+		"requestParameters": {"sourceIPAddress": ""},
+		"responseElements": {"x-amz-request-id": "request", "x-amz-id-2": ""},
+		"s3": {
+			"s3SchemaVersion": "1.0",
+			"configurationId": "config",
+			"bucket": {"name": "bucket", "ownerIdentity": {"principalId": ""}, "arn": "arn:aws:s3:::bucket"},
+			"object": {"key": "my+dir%2Ffile%2B1.txt", "size": 42, "eTag": "abc", "sequencer": "179CACD7500A4000"}
+		}
+	}`, string(raw))
+}
+
+func TestNotifier(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		failures = 2
+		received = make(chan Records, 1)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var records Records
+		require.NoError(t, json.Unmarshal(body, &records))
+		received <- records
+	}))
+	defer server.Close()
+
+	logger := zerolog.Nop()
+	config := Config{
+		QueueDir:     t.TempDir(),
+		Webhooks:     []Webhook{{Name: "local", Endpoint: server.URL, AuthToken: "secret"}},
+		MinBackoff:   time.Millisecond,
+		PollInterval: time.Millisecond,
+	}
+
+	bucketConfig, err := ParseConfiguration([]byte(testConfiguration), targetExists)
+	require.NoError(t, err)
+
+	// Queue an event without running the notifier, as if the process
+	// stopped before delivering it.
+	notifier, err := NewNotifier(&logger, config)
+	require.NoError(t, err)
+	require.NoError(t, notifier.Notify(bucketConfig, Event{Name: EventObjectCreatedPut, Key: "images/cat.jpg"}))
+	require.NoError(t, notifier.Notify(bucketConfig, Event{Name: EventObjectCreatedPut, Key: "images/cat.gif"}))
+
+	notifier, err = NewNotifier(&logger, config)
+	require.NoError(t, err)
+	require.Equal(t, 1, notifier.queue.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- notifier.Run(ctx)
+	}()
+
+	select {
+	case records := <-received:
+		require.Len(t, records.Records, 1)
+		require.Equal(t, "images%2Fcat.jpg", records.Records[0].S3.Object.Key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+
+	require.Eventually(t, func() bool {
+		return notifier.queue.Len() == 0
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestNotifierBackoff(t *testing.T) {
+	n := &Notifier{config: Config{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}}
+
+	for attempts, expected := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		require.Equal(t, expected*time.Second, n.backoff(attempts))
+	}
+}
+
+func TestNotifierSlowTarget(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	received := make(chan struct{}, 1)
+	fast := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		received <- struct{}{}
+	}))
+	defer fast.Close()
+
+	logger := zerolog.Nop()
+	notifier, err := NewNotifier(&logger, Config{
+		QueueDir: t.TempDir(),
+		Webhooks: []Webhook{
+			{Name: "slow", Endpoint: slow.URL},
+			{Name: "fast", Endpoint: fast.URL},
+		},
+		PollInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, notifier.queue.Push(TargetARN("slow"), []byte("{}")))
+	require.NoError(t, notifier.queue.Push(TargetARN("fast"), []byte("{}")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- notifier.Run(ctx)
+	}()
+
+	// The next rounds of the fast target do not wait for the slow one.
+	for i := 0; i < 3; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("event held up by the slow target")
+		}
+
+		require.NoError(t, notifier.queue.Push(TargetARN("fast"), []byte("{}")))
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestNotifierOrder(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		payloads []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		payloads = append(payloads, string(body))
+		if len(payloads) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	logger := zerolog.Nop()
+	notifier, err := NewNotifier(&logger, Config{
+		QueueDir:     t.TempDir(),
+		Webhooks:     []Webhook{{Name: "local", Endpoint: server.URL}},
+		MinBackoff:   10 * time.Millisecond,
+		PollInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, notifier.queue.Push(TargetARN("local"), []byte(`"first"`)))
+	require.NoError(t, notifier.queue.Push(TargetARN("local"), []byte(`"second"`)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- notifier.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return notifier.queue.Len() == 0
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	require.Equal(t, []string{`"first"`, `"first"`, `"second"`}, payloads)
+}
@@ -0,0 +1,228 @@
+package s3notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Queue is a durable queue of event deliveries: each pending delivery is a
+// JSON file in a directory, so that events survive restarts.
+type Queue struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	ID          string
+	Target      string
+	Payload     json.RawMessage
+	Attempts    int
+	CreatedAt   time.Time
+	NextAttempt time.Time
+}
+
+const queueFileSuffix = ".json"
+
+// OpenQueue loads the pending deliveries of dir, creating it if needed.
+func OpenQueue(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("s3notify: cannot create queue directory: %w", err)
+	}
+
+	q := &Queue{
+		dir:     dir,
+		entries: make(map[string]*entry),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("s3notify: cannot read queue directory: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), queueFileSuffix) {
+			continue
+		}
+
+		raw, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("s3notify: cannot read queue entry: %w", err)
+		}
+
+		var e entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("s3notify: corrupted queue entry %s: %w", file.Name(), err)
+		}
+
+		q.entries[e.ID] = &e
+	}
+
+	return q, nil
+}
+
+func (q *Queue) Push(target string, payload []byte) error {
+	now := time.Now()
+	e := &entry{
+		ID:          uuid.NewString(),
+		Target:      target,
+		Payload:     payload,
+		CreatedAt:   now,
+		NextAttempt: now,
+	}
+
+	if err := q.save(e); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.entries[e.ID] = e
+	q.mu.Unlock()
+
+	return nil
+}
+
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.entries)
+}
+
+// head returns the oldest entry of target, due reporting whether it exists
+// and is to be delivered now.
+func (q *Queue) head(target string, now time.Time) (_ entry, due bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var oldest *entry
+	for _, e := range q.entries {
+		if e.Target == target && (oldest == nil || e.CreatedAt.Before(oldest.CreatedAt)) {
+			oldest = e
+		}
+	}
+
+	if oldest == nil || oldest.NextAttempt.After(now) {
+		return entry{}, false
+	}
+
+	return *oldest, true
+}
+
+// targets returns the targets having queued entries, sorted.
+func (q *Queue) targets() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, e := range q.entries {
+		seen[e.Target] = true
+	}
+
+	targets := make([]string, 0, len(seen))
+	for target := range seen {
+		targets = append(targets, target)
+	}
+
+	sort.Strings(targets)
+
+	return targets
+}
+
+// drop removes the entries of target.
+func (q *Queue) drop(target string) error {
+	q.mu.Lock()
+	var dropped []entry
+	for _, e := range q.entries {
+		if e.Target == target {
+			dropped = append(dropped, *e)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, e := range dropped {
+		if err := q.remove(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *Queue) retry(e entry, next time.Time) error {
+	e.Attempts++
+	e.NextAttempt = next
+
+	if err := q.save(&e); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.entries[e.ID] = &e
+	q.mu.Unlock()
+
+	return nil
+}
+
+func (q *Queue) remove(e entry) error {
+	q.mu.Lock()
+	delete(q.entries, e.ID)
+	q.mu.Unlock()
+
+	err := os.Remove(q.path(e.ID))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("s3notify: cannot remove queue entry: %w", err)
+	}
+
+	return nil
+}
+
+func (q *Queue) path(id string) string {
+	return filepath.Join(q.dir, id+queueFileSuffix)
+}
+
+// save atomically writes the entry, so that a crash never leaves a
+// truncated file behind.
+func (q *Queue) save(e *entry) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("s3notify: cannot encode queue entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(q.dir, e.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("s3notify: cannot create queue entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("s3notify: cannot write queue entry: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("s3notify: cannot sync queue entry: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("s3notify: cannot close queue entry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), q.path(e.ID)); err != nil {
+		return fmt.Errorf("s3notify: cannot commit queue entry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package s3notify
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Event is what happened to an object, as reported by the handlers.
+type Event struct {
+	Name      EventName
+	Time      time.Time
+	Region    string
+	Bucket    string
+	Key       string
+	Size      int64
+	ETag      string
+	VersionID string
+
+	Principal string
+	SourceIP  string
+	RequestID string
+	HostID    string
+}
+
+// Records is the payload sent to targets, in the AWS event message format.
+type Records struct {
+	Records []Record
+}
+
+type Record struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AWSRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      Identity          `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                Entity            `json:"s3"`
+}
+
+type Identity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type Entity struct {
+	SchemaVersion   string       `json:"s3SchemaVersion"`
+	ConfigurationID string       `json:"configurationId"`
+	Bucket          BucketEntity `json:"bucket"`
+	Object          ObjectEntity `json:"object"`
+}
+
+type BucketEntity struct {
+	Name          string   `json:"name"`
+	OwnerIdentity Identity `json:"ownerIdentity"`
+	ARN           string   `json:"arn"`
+}
+
+type ObjectEntity struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// NewRecord builds the record of event for the given notification
+// configuration identifier.
+func NewRecord(event Event, configurationID string) Record {
+	region := event.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return Record{
+		EventVersion: "2.1",
+		EventSource:  "aws:s3",
+		AWSRegion:    region,
+		EventTime:    event.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:    event.Name.recordName(),
+		UserIdentity: Identity{PrincipalID: event.Principal},
+		RequestParameters: map[string]string{
+			"sourceIPAddress": event.SourceIP,
+		},
+		ResponseElements: map[string]string{
+			"x-amz-request-id": event.RequestID,
+			"x-amz-id-2":       event.HostID,
+		},
+		S3: Entity{
+			SchemaVersion:   "1.0",
+			ConfigurationID: configurationID,
+			Bucket: BucketEntity{
+				Name:          event.Bucket,
+				OwnerIdentity: Identity{PrincipalID: event.Principal},
+				ARN:           "arn:aws:s3:::" + event.Bucket,
+			},
+			Object: ObjectEntity{
+				// Like S3, keys are URL encoded in records.
+				Key:       url.QueryEscape(event.Key),
+				Size:      event.Size,
+				ETag:      trimQuotes(event.ETag),
+				VersionID: event.VersionID,
+				Sequencer: fmt.Sprintf("%016X", event.Time.UnixNano()),
+			},
+		},
+	}
+}
+
+func trimQuotes(etag string) string {
+	if len(etag) >= 2 && etag[0] == '"' && etag[len(etag)-1] == '"' {
+		return etag[1 : len(etag)-1]
+	}
+
+	return etag
+}
+
+// Match returns a record for each binding of the configuration interested
+// in event, keyed by target ARN.
+func (c *Configuration) Match(event Event) map[string][]Record {
+	records := make(map[string][]Record)
+
+	for _, binding := range c.all() {
+		if binding.match(event.Name, event.Key) {
+			records[binding.arn()] = append(records[binding.arn()], NewRecord(event, binding.ID))
+		}
+	}
+
+	return records
+}
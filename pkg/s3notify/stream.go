@@ -0,0 +1,232 @@
+package s3notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3request"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// streamBuffer is the number of records a slow subscriber can lag behind
+// before records are dropped for it.
+const streamBuffer = 256
+
+// Broker fans out event records to live subscribers, as used by the event
+// stream endpoint. Publishing never blocks: records are dropped for
+// subscribers not reading fast enough.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+type StreamFilter struct {
+	Bucket string
+	Prefix string
+	Suffix string
+	// Events is the list of event names to match, all when empty.
+	Events []EventName
+}
+
+func (f *StreamFilter) match(event Event) bool {
+	if event.Bucket != f.Bucket ||
+		!strings.HasPrefix(event.Key, f.Prefix) ||
+		!strings.HasSuffix(event.Key, f.Suffix) {
+		return false
+	}
+
+	if len(f.Events) == 0 {
+		return true
+	}
+
+	for _, name := range f.Events {
+		if name.Match(event.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Subscription struct {
+	filter  StreamFilter
+	records chan Record
+}
+
+// Records is closed when the subscription is cancelled.
+func (s *Subscription) Records() <-chan Record {
+	return s.records
+}
+
+func (b *Broker) Subscribe(filter StreamFilter) *Subscription {
+	s := &Subscription{
+		filter:  filter,
+		records: make(chan Record, streamBuffer),
+	}
+
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.subscribers[s]; exists {
+		delete(b.subscribers, s)
+		close(s.records)
+	}
+}
+
+// Close ends every subscription, so that streams do not hold up a server
+// shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		delete(b.subscribers, s)
+		close(s.records)
+	}
+}
+
+func (b *Broker) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for s := range b.subscribers {
+		if !s.filter.match(event) {
+			continue
+		}
+
+		select {
+		case s.records <- NewRecord(event, "stream"):
+		default:
+		}
+	}
+}
+
+// KeepAliveInterval is the delay between keep alive messages on idle streams.
+const KeepAliveInterval = 15 * time.Second
+
+// ServeAction serves ActionListenBucketNotification, streaming the events
+// of the bucket selected by the prefix, suffix and events (comma separated
+// or repeated) query parameters.
+func (b *Broker) ServeAction(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+	decoded, err := s3request.Decode(r, route)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	input, ok := decoded.(*s3request.ListenBucketNotification)
+	if !ok {
+		writeError(w, r, fmt.Errorf("s3notify: unexpected input %T for %s", decoded, route.Action))
+		return
+	}
+
+	filter := StreamFilter{
+		Bucket: input.Bucket,
+		Prefix: input.Prefix,
+		Suffix: input.Suffix,
+	}
+
+	for _, name := range input.Events {
+		filter.Events = append(filter.Events, EventName(name))
+	}
+
+	b.ServeStream(w, r, filter)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var s3err *s3errors.S3Error
+	if !errors.As(err, &s3err) {
+		s3err = s3errors.InternalError.New()
+	}
+
+	resp := *s3err
+	resp.RequestID = w.Header().Get("x-amz-request-id")
+	resp.Resource = r.URL.Path
+
+	writer := s3errors.APIWriter{}
+	_ = writer.Write(&resp, w, r)
+}
+
+// ServeStream streams the events matching filter until the client goes
+// away. Records are sent as Server-Sent Events when the client accepts
+// text/event-stream, newline delimited JSON otherwise.
+func (b *Broker) ServeStream(w http.ResponseWriter, r *http.Request, filter StreamFilter) {
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	header := w.Header()
+	header.Set("Cache-Control", "no-cache")
+	if sse {
+		header.Set("Content-Type", "text/event-stream")
+	} else {
+		header.Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	subscription := b.Subscribe(filter)
+	defer b.Unsubscribe(subscription)
+
+	keepAlive := time.NewTicker(KeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var payload []byte
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			payload = []byte("\n")
+			if sse {
+				payload = []byte(": keep-alive\n\n")
+			}
+		case record, ok := <-subscription.Records():
+			if !ok {
+				return
+			}
+
+			raw, err := json.Marshal(Records{Records: []Record{record}})
+			if err != nil {
+				return
+			}
+
+			payload = append(raw, '\n')
+			if sse {
+				payload = []byte("event: " + record.EventName + "\ndata: " + string(raw) + "\n\n")
+			}
+		}
+
+		if _, err := w.Write(payload); err != nil {
+			return
+		}
+
+		flush()
+	}
+}
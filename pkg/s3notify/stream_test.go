@@ -0,0 +1,209 @@
+package s3notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+func TestBrokerServeStream(t *testing.T) {
+	broker := NewBroker()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		broker.ServeStream(w, r, StreamFilter{Bucket: "bucket", Prefix: "images/", Events: []EventName{"s3:ObjectCreated:*"}})
+	}))
+	defer server.Close()
+
+	for name, accept := range map[string]string{
+		"NDJSON": "",
+		"SSE":    "text/event-stream",
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, http.NoBody)
+			require.NoError(t, err)
+			req.Header.Set("Accept", accept)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Eventually(t, func() bool {
+				broker.mu.RLock()
+				defer broker.mu.RUnlock()
+				return len(broker.subscribers) == 1
+			}, 5*time.Second, time.Millisecond)
+
+			broker.Publish(Event{Name: EventObjectCreatedPut, Bucket: "other", Key: "images/a.jpg"})
+			broker.Publish(Event{Name: EventObjectRemovedDelete, Bucket: "bucket", Key: "images/a.jpg"})
+			broker.Publish(Event{Name: EventObjectCreatedPut, Bucket: "bucket", Key: "docs/a.txt"})
+			broker.Publish(Event{Name: EventObjectCreatedPut, Bucket: "bucket", Key: "images/a.jpg"})
+
+			scanner := bufio.NewScanner(resp.Body)
+			require.True(t, scanner.Scan())
+			line := scanner.Text()
+
+			if accept != "" {
+				require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+				require.Equal(t, "event: ObjectCreated:Put", line)
+				require.True(t, scanner.Scan())
+				line = strings.TrimPrefix(scanner.Text(), "data: ")
+			} else {
+				require.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+			}
+
+			var records Records
+			require.NoError(t, json.Unmarshal([]byte(line), &records))
+			require.Len(t, records.Records, 1)
+			require.Equal(t, "images%2Fa.jpg", records.Records[0].S3.Object.Key)
+
+			cancel()
+			require.Eventually(t, func() bool {
+				broker.mu.RLock()
+				defer broker.mu.RUnlock()
+				return len(broker.subscribers) == 0
+			}, 5*time.Second, time.Millisecond)
+		})
+	}
+}
+
+func TestEmitterRouted(t *testing.T) {
+	logger := zerolog.Nop()
+	broker := NewBroker()
+
+	notifier, err := NewNotifier(&logger, Config{QueueDir: t.TempDir(), Webhooks: []Webhook{{Name: "local"}}})
+	require.NoError(t, err)
+
+	bucketConfig, err := ParseConfiguration([]byte(testConfiguration), targetExists)
+	require.NoError(t, err)
+
+	emitter := NewEmitter(&logger, broker, notifier, func(_ context.Context, bucket string) (*Configuration, error) {
+		if bucket != "bucket" {
+			return nil, nil //nolint:nilnil // no configuration
+		}
+
+		return bucketConfig, nil
+	})
+
+	actions := map[s3router.Action]s3router.ActionHandler{
+		s3router.ActionListenBucketNotification: broker,
+		s3router.ActionPutObject: s3router.ActionHandlerFunc(func(w http.ResponseWriter, _ *http.Request, route *s3router.Route) {
+			if route.Key == "images/denied.jpg" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("ETag", `"etag"`)
+		}),
+	}
+
+	server := httptest.NewServer(s3router.New(&logger, []string{"127.0.0.1"}, actions, emitter.Middleware))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/bucket?events=s3:ObjectCreated:*", http.NoBody)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Eventually(t, func() bool {
+		broker.mu.RLock()
+		defer broker.mu.RUnlock()
+		return len(broker.subscribers) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	for _, key := range []string{"images/denied.jpg", "images/cat.jpg"} {
+		put, err := http.NewRequestWithContext(ctx, http.MethodPut, server.URL+"/bucket/"+key, strings.NewReader("meow"))
+		require.NoError(t, err)
+
+		putResp, err := http.DefaultClient.Do(put)
+		require.NoError(t, err)
+		require.NoError(t, putResp.Body.Close())
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	require.True(t, scanner.Scan())
+
+	var records Records
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &records))
+	require.Len(t, records.Records, 1)
+	require.Equal(t, "ObjectCreated:Put", records.Records[0].EventName)
+	require.Equal(t, "images%2Fcat.jpg", records.Records[0].S3.Object.Key)
+	require.Equal(t, int64(4), records.Records[0].S3.Object.Size)
+	require.Equal(t, "etag", records.Records[0].S3.Object.ETag)
+
+	require.Equal(t, 1, notifier.queue.Len())
+}
+
+func TestEmitterResults(t *testing.T) {
+	logger := zerolog.Nop()
+	broker := NewBroker()
+	emitter := NewEmitter(&logger, broker, nil, nil)
+
+	subscription := broker.Subscribe(StreamFilter{Bucket: "bucket"})
+	defer broker.Unsubscribe(subscription)
+
+	actions := map[s3router.Action]s3router.ActionHandler{
+		s3router.ActionPutObject: s3router.ActionHandlerFunc(func(w http.ResponseWriter, _ *http.Request, _ *s3router.Route) {
+			w.Header().Set("ETag", `"put"`)
+		}),
+		s3router.ActionCopyObject: s3router.ActionHandlerFunc(func(w http.ResponseWriter, _ *http.Request, route *s3router.Route) {
+			Report(w, Result{Key: route.Key, Size: 42, ETag: `"copy"`})
+		}),
+		s3router.ActionDeleteObjects: s3router.ActionHandlerFunc(func(w http.ResponseWriter, _ *http.Request, _ *s3router.Route) {
+			Report(w, Result{Key: "a"})
+			Report(w, Result{Key: "b", DeleteMarker: true})
+		}),
+	}
+
+	handler := s3router.New(&logger, []string{"example.com"}, actions, emitter.Middleware)
+
+	serve := func(method, target string, header http.Header, body string) {
+		r := httptest.NewRequest(method, "http://example.com"+target, strings.NewReader(body))
+		for name, values := range header {
+			r.Header[name] = values
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve(http.MethodPut, "/bucket/chunked", http.Header{
+		"X-Amz-Content-Sha256":         {"STREAMING-AWS4-HMAC-SHA256-PAYLOAD"},
+		"X-Amz-Decoded-Content-Length": {"4"},
+	}, "4;chunk-signature=x\r\nmeow\r\n0;chunk-signature=x\r\n\r\n")
+	serve(http.MethodPut, "/bucket/copy", http.Header{"X-Amz-Copy-Source": {"bucket/chunked"}}, "")
+	serve(http.MethodPost, "/bucket?delete", nil, "<Delete/>")
+
+	expected := []ObjectEntity{
+		{Key: "chunked", Size: 4, ETag: "put"},
+		{Key: "copy", Size: 42, ETag: "copy"},
+		{Key: "a"},
+		{Key: "b"},
+	}
+
+	var names []string
+	for _, object := range expected {
+		record := <-subscription.Records()
+		record.S3.Object.Sequencer = ""
+		require.Equal(t, object, record.S3.Object)
+		names = append(names, record.EventName)
+	}
+
+	require.Equal(t, []string{"ObjectCreated:Put", "ObjectCreated:Copy", "ObjectRemoved:Delete", "ObjectRemoved:DeleteMarkerCreated"}, names)
+}
@@ -0,0 +1,61 @@
+package s3range
+
+import (
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+const HeaderPartsCount = "x-amz-mp-parts-count"
+
+// Selection is the part of an object a GetObject or HeadObject request
+// asked for, through either the Range header or the partNumber query
+// parameter. No range means the whole object.
+type Selection struct {
+	Ranges []ByteRange
+	// PartsCount is set when a part of a multipart object was requested.
+	PartsCount int
+	// Empty is set when the requested part holds no byte.
+	Empty bool
+}
+
+// Select resolves the Range header or the part number of a request, as
+// decoded by s3request, against an object of size bytes. A zero partNumber
+// means no part was requested. partSizes holds the size of each part, in
+// order, for multipart objects only.
+func Select(rangeHeader string, partNumber int, size int64, partSizes []int64) (*Selection, error) {
+	if partNumber == 0 {
+		ranges, err := Parse(rangeHeader, size)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Selection{Ranges: ranges}, nil
+	}
+
+	if len(partSizes) == 0 {
+		// Objects uploaded at once are made of a single part.
+		if partNumber != 1 {
+			return nil, s3errors.InvalidPartNumber.New()
+		}
+
+		return &Selection{}, nil
+	}
+
+	if partNumber > len(partSizes) {
+		return nil, s3errors.InvalidPartNumber.New()
+	}
+
+	var start int64
+	for _, partSize := range partSizes[:partNumber-1] {
+		start += partSize
+	}
+
+	selection := &Selection{PartsCount: len(partSizes)}
+
+	if partSizes[partNumber-1] == 0 {
+		selection.Empty = true
+	} else {
+		selection.Ranges = []ByteRange{{Start: start, End: start + partSizes[partNumber-1] - 1}}
+	}
+
+	return selection, nil
+}
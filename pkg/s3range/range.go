@@ -0,0 +1,93 @@
+package s3range
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+// ByteRange is an inclusive range of bytes, as in the Range header.
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+func (r ByteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ContentRange returns the Content-Range header value for an object of size bytes.
+func (r ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)
+}
+
+// Parse resolves a Range header against an object of size bytes. Like S3,
+// a header with a syntax error or a unit other than bytes is ignored, which
+// results in no range at all: the whole object must be returned.
+func Parse(header string, size int64) ([]ByteRange, error) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found {
+		return nil, nil
+	}
+
+	var ranges []ByteRange
+
+	for _, raw := range strings.Split(spec, ",") {
+		r, valid, satisfiable := parseOne(strings.TrimSpace(raw), size)
+		if !valid {
+			return nil, nil
+		}
+
+		if satisfiable {
+			ranges = append(ranges, r)
+		}
+	}
+
+	if len(ranges) == 0 {
+		return nil, s3errors.InvalidRange.New()
+	}
+
+	return ranges, nil
+}
+
+func parseOne(raw string, size int64) (r ByteRange, valid, satisfiable bool) {
+	startStr, endStr, found := strings.Cut(raw, "-")
+	if !found {
+		return ByteRange{}, false, false
+	}
+
+	if startStr == "" {
+		// Suffix range: the last N bytes.
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix < 0 {
+			return ByteRange{}, false, false
+		}
+
+		if suffix == 0 || size == 0 {
+			return ByteRange{}, true, false
+		}
+
+		return ByteRange{Start: max(size-suffix, 0), End: size - 1}, true, true
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return ByteRange{}, false, false
+	}
+
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return ByteRange{}, false, false
+		}
+	}
+
+	if start >= size {
+		return ByteRange{}, true, false
+	}
+
+	return ByteRange{Start: start, End: min(end, size-1)}, true, true
+}
@@ -0,0 +1,155 @@
+package s3range
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	const size = 100
+
+	for header, expected := range map[string][]ByteRange{
+		"":                 nil,
+		"items=0-10":       nil,
+		"bytes=10":         nil,
+		"bytes=10-5":       nil,
+		"bytes=a-5":        nil,
+		"bytes=0-9":        {{0, 9}},
+		"bytes=90-":        {{90, 99}},
+		"bytes=90-200":     {{90, 99}},
+		"bytes=-10":        {{90, 99}},
+		"bytes=-200":       {{0, 99}},
+		"bytes=0-0,-1":     {{0, 0}, {99, 99}},
+		"bytes=200-,10-19": {{10, 19}},
+	} {
+		t.Run(header, func(t *testing.T) {
+			ranges, err := Parse(header, size)
+			require.NoError(t, err)
+			require.Equal(t, expected, ranges)
+		})
+	}
+
+	for _, tc := range []struct {
+		header string
+		size   int64
+	}{
+		{"bytes=100-", 100},
+		{"bytes=100-200", 100},
+		{"bytes=-0", 100},
+		{"bytes=0-", 0},
+		{"bytes=-1", 0},
+	} {
+		t.Run(tc.header, func(t *testing.T) {
+			_, err := Parse(tc.header, tc.size)
+			require.Error(t, err)
+			require.Equal(t, "InvalidRange", err.(*s3errors.S3Error).Code)
+			require.Equal(t, http.StatusRequestedRangeNotSatisfiable, err.(*s3errors.S3Error).HTTPStatusCode)
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	parts := []int64{10, 20, 5}
+
+	for name, tc := range map[string]struct {
+		rangeHeader string
+		partNumber  int
+		partSizes   []int64
+		expected    *Selection
+		code        string
+	}{
+		"Whole":            {expected: &Selection{}},
+		"Range":            {rangeHeader: "bytes=1-2", expected: &Selection{Ranges: []ByteRange{{1, 2}}}},
+		"FirstPart":        {partNumber: 1, partSizes: parts, expected: &Selection{Ranges: []ByteRange{{0, 9}}, PartsCount: 3}},
+		"LastPart":         {partNumber: 3, partSizes: parts, expected: &Selection{Ranges: []ByteRange{{30, 34}}, PartsCount: 3}},
+		"EmptyPart":        {partNumber: 2, partSizes: []int64{35, 0}, expected: &Selection{PartsCount: 2, Empty: true}},
+		"SinglePart":       {partNumber: 1, expected: &Selection{}},
+		"PartOutOfRange":   {partNumber: 4, partSizes: parts, code: "InvalidPartNumber"},
+		"SinglePartNumber": {partNumber: 2, code: "InvalidPartNumber"},
+		"Unsatisfiable":    {rangeHeader: "bytes=40-", code: "InvalidRange"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			selection, err := Select(tc.rangeHeader, tc.partNumber, 35, tc.partSizes)
+			if tc.code != "" {
+				require.Error(t, err)
+				require.Equal(t, tc.code, err.(*s3errors.S3Error).Code)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, selection)
+		})
+	}
+}
+
+func TestSelectionWrite(t *testing.T) {
+	content := strings.NewReader("0123456789")
+
+	t.Run("Whole", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		require.NoError(t, (&Selection{}).Write(recorder, content, 10))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "10", recorder.Header().Get("Content-Length"))
+		require.Equal(t, "0123456789", recorder.Body.String())
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		selection := &Selection{Ranges: []ByteRange{{2, 4}}, PartsCount: 2}
+		require.NoError(t, selection.Write(recorder, content, 10))
+		require.Equal(t, http.StatusPartialContent, recorder.Code)
+		require.Equal(t, "bytes 2-4/10", recorder.Header().Get("Content-Range"))
+		require.Equal(t, "3", recorder.Header().Get("Content-Length"))
+		require.Equal(t, "2", recorder.Header().Get("X-Amz-Mp-Parts-Count"))
+		require.Equal(t, "234", recorder.Body.String())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		selection := &Selection{PartsCount: 2, Empty: true}
+		require.NoError(t, selection.Write(recorder, content, 10))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "0", recorder.Header().Get("Content-Length"))
+		require.Empty(t, recorder.Header().Get("Content-Range"))
+		require.Equal(t, "2", recorder.Header().Get("X-Amz-Mp-Parts-Count"))
+		require.Empty(t, recorder.Body.String())
+	})
+
+	t.Run("Multiple", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		recorder.Header().Set("Content-Type", "text/plain")
+		selection := &Selection{Ranges: []ByteRange{{0, 1}, {8, 9}}}
+		require.NoError(t, selection.Write(recorder, content, 10))
+		require.Equal(t, http.StatusPartialContent, recorder.Code)
+
+		mediaType, params, err := mime.ParseMediaType(recorder.Header().Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/byteranges", mediaType)
+
+		reader := multipart.NewReader(recorder.Body, params["boundary"])
+		for _, expected := range []struct{ contentRange, body string }{
+			{"bytes 0-1/10", "01"},
+			{"bytes 8-9/10", "89"},
+		} {
+			part, err := reader.NextPart()
+			require.NoError(t, err)
+			require.Equal(t, "text/plain", part.Header.Get("Content-Type"))
+			require.Equal(t, expected.contentRange, part.Header.Get("Content-Range"))
+
+			body, err := io.ReadAll(part)
+			require.NoError(t, err)
+			require.Equal(t, expected.body, string(body))
+		}
+
+		_, err = reader.NextPart()
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
@@ -0,0 +1,95 @@
+package s3range
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+)
+
+// SetHeaders sets the headers describing the selection for an object of
+// size bytes and returns the status code of the response. It is enough for
+// a HeadObject response.
+func (s *Selection) SetHeaders(header http.Header, size int64) int {
+	header.Set("Accept-Ranges", "bytes")
+
+	if s.PartsCount > 0 {
+		header.Set(HeaderPartsCount, strconv.Itoa(s.PartsCount))
+	}
+
+	switch {
+	case s.Empty:
+		header.Set("Content-Length", "0")
+
+		return http.StatusOK
+	case len(s.Ranges) == 0:
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+
+		return http.StatusOK
+	case len(s.Ranges) == 1:
+		header.Set("Content-Range", s.Ranges[0].ContentRange(size))
+		header.Set("Content-Length", strconv.FormatInt(s.Ranges[0].Length(), 10))
+	default:
+		// The multipart/byteranges content type and length are only known
+		// when writing the body.
+		header.Del("Content-Length")
+	}
+
+	return http.StatusPartialContent
+}
+
+// Write sends the selected bytes of content as a GetObject response body,
+// using a multipart/byteranges payload when several ranges were requested.
+// Other headers, like Content-Type, must be set beforehand.
+func (s *Selection) Write(w http.ResponseWriter, content io.ReaderAt, size int64) error {
+	header := w.Header()
+	contentType := header.Get("Content-Type")
+	status := s.SetHeaders(header, size)
+
+	if len(s.Ranges) <= 1 {
+		section := io.NewSectionReader(content, 0, size)
+		switch {
+		case s.Empty:
+			section = io.NewSectionReader(content, 0, 0)
+		case len(s.Ranges) == 1:
+			section = io.NewSectionReader(content, s.Ranges[0].Start, s.Ranges[0].Length())
+		}
+
+		w.WriteHeader(status)
+
+		if _, err := io.Copy(w, section); err != nil {
+			return fmt.Errorf("s3range: cannot write body: %w", err)
+		}
+
+		return nil
+	}
+
+	mw := multipart.NewWriter(w)
+	header.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.WriteHeader(status)
+
+	for _, r := range s.Ranges {
+		partHeader := textproto.MIMEHeader{}
+		if contentType != "" {
+			partHeader.Set("Content-Type", contentType)
+		}
+		partHeader.Set("Content-Range", r.ContentRange(size))
+
+		part, err := mw.CreatePart(partHeader)
+		if err != nil {
+			return fmt.Errorf("s3range: cannot write part header: %w", err)
+		}
+
+		if _, err := io.Copy(part, io.NewSectionReader(content, r.Start, r.Length())); err != nil {
+			return fmt.Errorf("s3range: cannot write part body: %w", err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("s3range: cannot close multipart body: %w", err)
+	}
+
+	return nil
+}
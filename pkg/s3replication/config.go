@@ -0,0 +1,183 @@
+package s3replication
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+const (
+	StatusEnabled  = "Enabled"
+	StatusDisabled = "Disabled"
+
+	maxRules = 1000
+)
+
+type Configuration struct {
+	XMLName   xml.Name `xml:"ReplicationConfiguration"`
+	Namespace string   `xml:"xmlns,attr,omitempty"`
+	Role      string   `xml:",omitempty"`
+	Rules     []Rule   `xml:"Rule"`
+}
+
+type Rule struct {
+	ID                      string                   `xml:",omitempty"`
+	Priority                int                      `xml:",omitempty"`
+	Status                  string                   `xml:"Status"`
+	Prefix                  *string                  `xml:",omitempty"`
+	Filter                  *Filter                  `xml:",omitempty"`
+	Destination             Destination              `xml:"Destination"`
+	DeleteMarkerReplication *DeleteMarkerReplication `xml:",omitempty"`
+}
+
+type Filter struct {
+	Prefix *string `xml:",omitempty"`
+	Tag    *Tag    `xml:",omitempty"`
+	And    *struct {
+		Prefix *string `xml:",omitempty"`
+		Tags   []Tag   `xml:"Tag"`
+	} `xml:",omitempty"`
+}
+
+type Tag struct {
+	Key   string
+	Value string
+}
+
+type Destination struct {
+	Bucket       string
+	StorageClass string `xml:",omitempty"`
+}
+
+type DeleteMarkerReplication struct {
+	Status string
+}
+
+// BucketARN returns the destination Bucket ARN designating bucket on one
+// of the targets declared in the configuration file.
+func BucketARN(target, bucket string) string {
+	return "arn:s3impl:s3:::" + target + "/" + bucket
+}
+
+func parseBucketARN(arn string) (target, bucket string, ok bool) {
+	resource, found := strings.CutPrefix(arn, "arn:s3impl:s3:::")
+	if !found {
+		return "", "", false
+	}
+
+	target, bucket, ok = strings.Cut(resource, "/")
+
+	return target, bucket, ok && target != "" && bucket != ""
+}
+
+// ParseConfiguration decodes and validates a PutBucketReplication payload.
+// targetExists tells whether a target name is declared.
+func ParseConfiguration(payload []byte, targetExists func(string) bool) (*Configuration, error) {
+	var config Configuration
+	if err := xml.Unmarshal(payload, &config); err != nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	if len(config.Rules) == 0 || len(config.Rules) > maxRules {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	config.Namespace = s3consts.XMLNamespace
+	ids := make(map[string]bool)
+	priorities := make(map[int]bool)
+
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+
+		if rule.ID != "" {
+			if ids[rule.ID] {
+				return nil, s3errors.InvalidArgument.New().WithMessage("Rule Id must be unique: " + rule.ID)
+			}
+			ids[rule.ID] = true
+		}
+
+		if rule.Status != StatusEnabled && rule.Status != StatusDisabled {
+			return nil, s3errors.MalformedXML.New()
+		}
+
+		if rule.Filter != nil {
+			if priorities[rule.Priority] {
+				return nil, s3errors.InvalidArgument.New().WithMessage("Found duplicate priority " + rule.ID)
+			}
+			priorities[rule.Priority] = true
+		}
+
+		if rule.Prefix != nil && rule.Filter != nil {
+			return nil, s3errors.MalformedXML.New()
+		}
+
+		target, _, ok := parseBucketARN(rule.Destination.Bucket)
+		if !ok || !targetExists(target) {
+			return nil, s3errors.InvalidArgument.New().WithMessage("Destination bucket must be a known target: " + rule.Destination.Bucket)
+		}
+
+		if dmr := rule.DeleteMarkerReplication; dmr != nil && dmr.Status != StatusEnabled && dmr.Status != StatusDisabled {
+			return nil, s3errors.MalformedXML.New()
+		}
+	}
+
+	return &config, nil
+}
+
+// Match returns the enabled rule with the highest priority applying to an
+// object with the given key and tags.
+func (c *Configuration) Match(key string, tags map[string]string) (*Rule, bool) {
+	var matches []*Rule
+
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Status == StatusEnabled && rule.match(key, tags) {
+			matches = append(matches, rule)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Priority > matches[j].Priority
+	})
+
+	return matches[0], true
+}
+
+func (r *Rule) match(key string, tags map[string]string) bool {
+	switch {
+	case r.Prefix != nil:
+		return strings.HasPrefix(key, *r.Prefix)
+	case r.Filter == nil:
+		return true
+	case r.Filter.Prefix != nil:
+		return strings.HasPrefix(key, *r.Filter.Prefix)
+	case r.Filter.Tag != nil:
+		return tags[r.Filter.Tag.Key] == r.Filter.Tag.Value
+	case r.Filter.And != nil:
+		if r.Filter.And.Prefix != nil && !strings.HasPrefix(key, *r.Filter.And.Prefix) {
+			return false
+		}
+
+		for _, tag := range r.Filter.And.Tags {
+			if value, exists := tags[tag.Key]; !exists || value != tag.Value {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return true
+	}
+}
+
+// ReplicateDeleteMarkers reports whether delete markers must be replicated.
+func (r *Rule) ReplicateDeleteMarkers() bool {
+	return r.DeleteMarkerReplication != nil && r.DeleteMarkerReplication.Status == StatusEnabled
+}
@@ -0,0 +1,280 @@
+package s3replication
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfiguration = `<ReplicationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Role>arn:aws:iam::123456789012:role/replication</Role>
+  <Rule>
+    <ID>logs</ID>
+    <Priority>2</Priority>
+    <Status>Enabled</Status>
+    <Filter><Prefix>logs/</Prefix></Filter>
+    <Destination><Bucket>arn:s3impl:s3:::dr/logs-copy</Bucket><StorageClass>STANDARD_IA</StorageClass></Destination>
+    <DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication>
+  </Rule>
+  <Rule>
+    <ID>tagged</ID>
+    <Priority>1</Priority>
+    <Status>Enabled</Status>
+    <Filter><And><Prefix>logs/</Prefix><Tag><Key>replicate</Key><Value>no</Value></Tag></And></Filter>
+    <Destination><Bucket>arn:s3impl:s3:::dr/other</Bucket></Destination>
+  </Rule>
+  <Rule>
+    <ID>disabled</ID>
+    <Priority>3</Priority>
+    <Status>Disabled</Status>
+    <Filter><Prefix></Prefix></Filter>
+    <Destination><Bucket>arn:s3impl:s3:::dr/other</Bucket></Destination>
+  </Rule>
+</ReplicationConfiguration>`
+
+func targetExists(name string) bool {
+	return name == "dr"
+}
+
+func TestParseConfiguration(t *testing.T) {
+	config, err := ParseConfiguration([]byte(testConfiguration), targetExists)
+	require.NoError(t, err)
+	require.Len(t, config.Rules, 3)
+
+	rule, matched := config.Match("logs/2023/12/01.log", map[string]string{"replicate": "no"})
+	require.True(t, matched)
+	require.Equal(t, "logs", rule.ID)
+	require.True(t, rule.ReplicateDeleteMarkers())
+
+	_, matched = config.Match("data/file", nil)
+	require.False(t, matched)
+
+	for name, tc := range map[string]struct {
+		payload  string
+		expected string
+	}{
+		"NotXML":        {payload: "{}", expected: "MalformedXML"},
+		"NoRule":        {payload: "<ReplicationConfiguration></ReplicationConfiguration>", expected: "MalformedXML"},
+		"BadStatus":     {payload: strings.Replace(testConfiguration, "<Status>Disabled</Status>", "<Status>Off</Status>", 1), expected: "MalformedXML"},
+		"DuplicateID":   {payload: strings.Replace(testConfiguration, "<ID>tagged</ID>", "<ID>logs</ID>", 1), expected: "InvalidArgument"},
+		"DuplicatePrio": {payload: strings.Replace(testConfiguration, "<Priority>1</Priority>", "<Priority>2</Priority>", 1), expected: "InvalidArgument"},
+		"UnknownTarget": {payload: strings.Replace(testConfiguration, "arn:s3impl:s3:::dr/logs-copy", "arn:aws:s3:::logs-copy", 1), expected: "InvalidArgument"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConfiguration([]byte(tc.payload), targetExists)
+			require.Error(t, err)
+			require.Equal(t, tc.expected, err.(*s3errors.S3Error).Code)
+		})
+	}
+}
+
+type memorySource struct {
+	mu       sync.Mutex
+	objects  map[string]*Object
+	statuses map[string]Status
+}
+
+func (m *memorySource) GetObject(_ context.Context, bucket, key, _ string) (*Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj := *m.objects[bucket+"/"+key]
+	obj.Body = io.NopCloser(strings.NewReader("content"))
+
+	return &obj, nil
+}
+
+func (m *memorySource) SetReplicationStatus(_ context.Context, bucket, key, _ string, status Status) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.statuses[bucket+"/"+key] = status
+
+	return nil
+}
+
+func (m *memorySource) status(key string) Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.statuses[key]
+}
+
+func TestReplicator(t *testing.T) {
+	received := make(chan *http.Request, 2)
+	bodies := make(chan string, 2)
+	readErrs := make(chan error, 2)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+
+		received <- r
+		bodies <- string(body)
+		readErrs <- err
+
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	source := &memorySource{
+		objects: map[string]*Object{
+			"src/logs/a.log": {
+				ContentType: "text/plain",
+				Metadata:    map[string]string{"origin": "test"},
+				Tags:        map[string]string{"team": "ops"},
+				Size:        int64(len("content")),
+			},
+			"src/logs/b.log": {DeleteMarker: true},
+		},
+		statuses: make(map[string]Status),
+	}
+
+	logger := zerolog.Nop()
+	replicator, err := NewReplicator(&logger, Config{
+		Targets:    []Target{{Name: "dr", Endpoint: server.URL, AccessKeyID: "AKID", SecretAccessKey: "SECRET"}},
+		QueueDir:   t.TempDir(),
+		RetryDelay: time.Millisecond,
+	}, source)
+	require.NoError(t, err)
+
+	config, err := ParseConfiguration([]byte(testConfiguration), replicator.TargetExists)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- replicator.Run(ctx)
+	}()
+
+	require.Equal(t, StatusPending, replicator.Enqueue(config, "src", "logs/a.log", "", map[string]string{"team": "ops"}, false))
+
+	r := <-received
+	require.Equal(t, http.MethodPut, r.Method)
+	require.Equal(t, "/logs-copy/logs/a.log", r.URL.Path)
+	require.Equal(t, "text/plain", r.Header.Get("Content-Type"))
+	require.Equal(t, "test", r.Header.Get("X-Amz-Meta-Origin"))
+	require.Equal(t, "team=ops", r.Header.Get("X-Amz-Tagging"))
+	require.Equal(t, "STANDARD_IA", r.Header.Get("X-Amz-Storage-Class"))
+	require.Equal(t, "content", <-bodies)
+	require.NoError(t, <-readErrs)
+
+	require.Eventually(t, func() bool {
+		return source.status("src/logs/a.log") == StatusCompleted
+	}, 5*time.Second, time.Millisecond)
+
+	require.Equal(t, StatusPending, replicator.Enqueue(config, "src", "logs/b.log", "", nil, true))
+
+	r = <-received
+	<-bodies
+	require.NoError(t, <-readErrs)
+	require.Equal(t, http.MethodDelete, r.Method)
+	require.Equal(t, "/logs-copy/logs/b.log", r.URL.Path)
+
+	require.Eventually(t, func() bool {
+		return source.status("src/logs/b.log") == StatusCompleted
+	}, 5*time.Second, time.Millisecond)
+
+	require.Equal(t, Status(""), replicator.Enqueue(config, "src", "data/c", "", nil, false))
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestReplicatorFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	source := &memorySource{
+		objects:  map[string]*Object{"src/logs/a.log": {Size: int64(len("content"))}},
+		statuses: make(map[string]Status),
+	}
+
+	logger := zerolog.Nop()
+	replicator, err := NewReplicator(&logger, Config{
+		Targets:     []Target{{Name: "dr", Endpoint: server.URL}},
+		QueueDir:    t.TempDir(),
+		MaxAttempts: 1,
+		// No delay may follow the last attempt.
+		RetryDelay: time.Hour,
+	}, source)
+	require.NoError(t, err)
+
+	config, err := ParseConfiguration([]byte(testConfiguration), replicator.TargetExists)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- replicator.Run(ctx)
+	}()
+
+	require.Equal(t, StatusPending, replicator.Enqueue(config, "src", "logs/a.log", "", map[string]string{"team": "ops"}, false))
+
+	require.Eventually(t, func() bool {
+		return source.status("src/logs/a.log") == StatusFailed
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestReplicatorResume(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	source := &memorySource{
+		objects:  map[string]*Object{"src/logs/a.log": {Size: int64(len("content"))}},
+		statuses: make(map[string]Status),
+	}
+
+	logger := zerolog.Nop()
+	config := Config{
+		Targets:  []Target{{Name: "dr", Endpoint: server.URL}},
+		QueueDir: t.TempDir(),
+	}
+
+	// The first replicator stops before replicating the queued object.
+	stopped, err := NewReplicator(&logger, config, source)
+	require.NoError(t, err)
+
+	replication, err := ParseConfiguration([]byte(testConfiguration), stopped.TargetExists)
+	require.NoError(t, err)
+
+	require.Equal(t, StatusPending, stopped.Enqueue(replication, "src", "logs/a.log", "", map[string]string{"team": "ops"}, false))
+
+	replicator, err := NewReplicator(&logger, config, source)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- replicator.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return source.status("src/logs/a.log") == StatusCompleted
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	files, err := os.ReadDir(config.QueueDir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
@@ -0,0 +1,390 @@
+package s3replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/sourcegraph/conc/pool"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusCompleted Status = "COMPLETED"
+	StatusFailed    Status = "FAILED"
+	StatusReplica   Status = "REPLICA"
+
+	HeaderReplicationStatus = "x-amz-replication-status"
+)
+
+// Target is a remote S3 compatible endpoint objects are replicated to.
+type Target struct {
+	Name            string
+	Endpoint        string
+	Region          string
+	AccessKeyID     string `yaml:"accessKeyId"`
+	SecretAccessKey string `yaml:"secretAccessKey"`
+}
+
+type Config struct {
+	Targets []Target
+	// QueueDir keeps the pending replications, so that they resume after a
+	// restart.
+	QueueDir string `yaml:"queueDir"`
+	// Workers is the number of objects replicated in parallel.
+	Workers     int
+	MaxAttempts int           `yaml:"maxAttempts"`
+	RetryDelay  time.Duration `yaml:"retryDelay"`
+	QueueSize   int           `yaml:"queueSize"`
+}
+
+const (
+	DefaultWorkers     = 4
+	DefaultMaxAttempts = 5
+	DefaultRetryDelay  = time.Second
+	DefaultQueueSize   = 1024
+)
+
+// Object is the replicated state of an object version, as read from the
+// source bucket.
+type Object struct {
+	ContentType  string
+	Metadata     map[string]string
+	Tags         map[string]string
+	StorageClass string
+	DeleteMarker bool
+	Body         io.ReadCloser
+	Size         int64
+}
+
+// Source gives access to the local objects being replicated.
+type Source interface {
+	GetObject(ctx context.Context, bucket, key, versionID string) (*Object, error)
+	SetReplicationStatus(ctx context.Context, bucket, key, versionID string, status Status) error
+}
+
+type task struct {
+	ID        string
+	Bucket    string
+	Key       string
+	VersionID string
+	Rule      Rule
+}
+
+// Replicator asynchronously copies new objects, their metadata and tags,
+// and delete markers to the destination of the matching rule. Each pending
+// replication is a JSON file of the queue directory until its final status
+// is stored.
+type Replicator struct {
+	logger  *zerolog.Logger
+	config  Config
+	source  Source
+	clients map[string]*s3.Client
+	tasks   chan task
+	// pending are the tasks left by a previous run.
+	pending []task
+}
+
+const taskFileSuffix = ".json"
+
+func NewReplicator(logger *zerolog.Logger, config Config, source Source) (*Replicator, error) {
+	if config.QueueDir == "" {
+		return nil, errors.New("s3replication: queue directory is not set")
+	}
+
+	if err := os.MkdirAll(config.QueueDir, 0o750); err != nil {
+		return nil, fmt.Errorf("s3replication: cannot create queue directory: %w", err)
+	}
+
+	if config.Workers == 0 {
+		config.Workers = DefaultWorkers
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.RetryDelay == 0 {
+		config.RetryDelay = DefaultRetryDelay
+	}
+	if config.QueueSize == 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+
+	r := &Replicator{
+		logger:  logger,
+		config:  config,
+		source:  source,
+		clients: make(map[string]*s3.Client),
+		tasks:   make(chan task, config.QueueSize),
+	}
+
+	for _, target := range config.Targets {
+		r.clients[target.Name] = newClient(target)
+	}
+
+	var err error
+	if r.pending, err = r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func newClient(target Target) *s3.Client {
+	region := target.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	credentials := aws.Credentials{
+		AccessKeyID:     target.AccessKeyID,
+		SecretAccessKey: target.SecretAccessKey,
+		Source:          "s3impl replication target " + target.Name,
+	}
+
+	return s3.New(s3.Options{
+		Region:       region,
+		BaseEndpoint: aws.String(target.Endpoint),
+		UsePathStyle: true,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return credentials, nil
+		}),
+	})
+}
+
+// TargetExists is meant to validate configurations with ParseConfiguration.
+func (r *Replicator) TargetExists(name string) bool {
+	_, exists := r.clients[name]
+	return exists
+}
+
+// Enqueue schedules the replication of an object version written to bucket,
+// and returns the status to store with it: PENDING when a rule matched, an
+// empty status otherwise.
+func (r *Replicator) Enqueue(config *Configuration, bucket, key, versionID string, tags map[string]string, deleteMarker bool) Status {
+	rule, matched := config.Match(key, tags)
+	if !matched || (deleteMarker && !rule.ReplicateDeleteMarkers()) {
+		return ""
+	}
+
+	t := task{ID: uuid.NewString(), Bucket: bucket, Key: key, VersionID: versionID, Rule: *rule}
+	if err := r.save(t); err != nil {
+		r.logger.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Cannot queue replication")
+		return StatusFailed
+	}
+
+	select {
+	case r.tasks <- t:
+		return StatusPending
+	default:
+		r.logger.Error().Str("bucket", bucket).Str("key", key).Msg("Replication queue is full")
+		r.remove(t)
+		return StatusFailed
+	}
+}
+
+func (r *Replicator) taskPath(id string) string {
+	return filepath.Join(r.config.QueueDir, id+taskFileSuffix)
+}
+
+func (r *Replicator) save(t task) error {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("s3replication: cannot encode task: %w", err)
+	}
+
+	tmp := r.taskPath(t.ID) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o640); err != nil {
+		return fmt.Errorf("s3replication: cannot write task: %w", err)
+	}
+
+	if err := os.Rename(tmp, r.taskPath(t.ID)); err != nil {
+		return fmt.Errorf("s3replication: cannot write task: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Replicator) remove(t task) {
+	if err := os.Remove(r.taskPath(t.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		r.logger.Error().Err(err).Str("task", t.ID).Msg("Cannot remove replication task")
+	}
+}
+
+// load returns the tasks left pending by a previous run.
+func (r *Replicator) load() ([]task, error) {
+	files, err := os.ReadDir(r.config.QueueDir)
+	if err != nil {
+		return nil, fmt.Errorf("s3replication: cannot read queue directory: %w", err)
+	}
+
+	var tasks []task
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), taskFileSuffix) {
+			continue
+		}
+
+		raw, err := os.ReadFile(filepath.Join(r.config.QueueDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("s3replication: cannot read task: %w", err)
+		}
+
+		var t task
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, fmt.Errorf("s3replication: corrupted task %s: %w", file.Name(), err)
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
+
+// Run resumes the replications left pending by a previous run, then
+// replicates queued objects until ctx is done. Interrupted replications
+// stay queued for the next run.
+func (r *Replicator) Run(ctx context.Context) error {
+	workers := pool.New().WithMaxGoroutines(r.config.Workers)
+
+	for _, t := range r.pending {
+		t := t
+		workers.Go(func() {
+			r.process(ctx, t)
+		})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			workers.Wait()
+			return nil
+		case t := <-r.tasks:
+			workers.Go(func() {
+				r.process(ctx, t)
+			})
+		}
+	}
+}
+
+func (r *Replicator) process(ctx context.Context, t task) {
+	logger := r.logger.With().Str("bucket", t.Bucket).Str("key", t.Key).Str("versionId", t.VersionID).Logger()
+	status := StatusFailed
+
+	for attempt := 1; attempt <= r.config.MaxAttempts; attempt++ {
+		err := r.replicate(ctx, t)
+		if err == nil {
+			status = StatusCompleted
+			break
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Warn().Err(err).Int("attempt", attempt).Msg("Replication failed")
+
+		if attempt == r.config.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.config.RetryDelay * time.Duration(attempt)):
+		}
+	}
+
+	if err := r.source.SetReplicationStatus(ctx, t.Bucket, t.Key, t.VersionID, status); err != nil {
+		// The task is kept for the next run.
+		logger.Error().Err(err).Str("status", string(status)).Msg("Cannot update replication status")
+		return
+	}
+
+	r.remove(t)
+}
+
+func (r *Replicator) replicate(ctx context.Context, t task) error {
+	target, bucket, _ := parseBucketARN(t.Rule.Destination.Bucket)
+
+	client, exists := r.clients[target]
+	if !exists {
+		return fmt.Errorf("s3replication: unknown target %q", target)
+	}
+
+	obj, err := r.source.GetObject(ctx, t.Bucket, t.Key, t.VersionID)
+	if err != nil {
+		return fmt.Errorf("s3replication: cannot read source object: %w", err)
+	}
+
+	if obj.DeleteMarker {
+		if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(t.Key),
+		}); err != nil {
+			return fmt.Errorf("s3replication: cannot replicate delete marker: %w", err)
+		}
+
+		return nil
+	}
+
+	defer obj.Body.Close()
+
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(t.Key),
+		Body:          obj.Body,
+		ContentLength: aws.Int64(obj.Size),
+		Metadata:      obj.Metadata,
+	}
+
+	if obj.ContentType != "" {
+		input.ContentType = aws.String(obj.ContentType)
+	}
+
+	storageClass := t.Rule.Destination.StorageClass
+	if storageClass == "" {
+		storageClass = obj.StorageClass
+	}
+	if storageClass != "" {
+		input.StorageClass = types.StorageClass(storageClass)
+	}
+
+	if len(obj.Tags) > 0 {
+		tags := url.Values{}
+		for key, value := range obj.Tags {
+			tags.Set(key, value)
+		}
+
+		input.Tagging = aws.String(tags.Encode())
+	}
+
+	// The body is streamed from the source, so it cannot be read twice to
+	// compute the payload signature.
+	if _, err := client.PutObject(ctx, input, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)); err != nil {
+		return fmt.Errorf("s3replication: cannot replicate object: %w", err)
+	}
+
+	return nil
+}
+
+// SetHeader exposes the replication status on HeadObject and GetObject.
+func SetHeader(header http.Header, status Status) {
+	if status != "" {
+		header.Set(HeaderReplicationStatus, string(status))
+	}
+}
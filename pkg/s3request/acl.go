@@ -0,0 +1,191 @@
+package s3request
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+const HeaderACL = "x-amz-acl"
+
+// CannedACLs are the values of the x-amz-acl header.
+var CannedACLs = []string{
+	"private",
+	"public-read",
+	"public-read-write",
+	"authenticated-read",
+	"aws-exec-read",
+	"bucket-owner-read",
+	"bucket-owner-full-control",
+	"log-delivery-write",
+}
+
+// grantHeaders maps the x-amz-grant- headers to their permission, in the
+// order of the decoded grants.
+var grantHeaders = []struct {
+	name       string
+	permission string
+}{
+	{name: "x-amz-grant-full-control", permission: "FULL_CONTROL"},
+	{name: "x-amz-grant-read", permission: "READ"},
+	{name: "x-amz-grant-read-acp", permission: "READ_ACP"},
+	{name: "x-amz-grant-write", permission: "WRITE"},
+	{name: "x-amz-grant-write-acp", permission: "WRITE_ACP"},
+}
+
+// Grantee types, the grantees being identified by ID, EmailAddress and URI
+// respectively.
+const (
+	GranteeCanonicalUser = "CanonicalUser"
+	GranteeEmail         = "AmazonCustomerByEmail"
+	GranteeGroup         = "Group"
+)
+
+type Grantee struct {
+	Type         string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	ID           string `xml:",omitempty"`
+	EmailAddress string `xml:",omitempty"`
+	URI          string `xml:",omitempty"`
+}
+
+type Grant struct {
+	Grantee    Grantee
+	Permission string
+}
+
+// PutACL is the input of PutBucketAcl and PutObjectAcl. Exactly one of
+// CannedACL and Grants is set, Owner being the owner ID of an
+// AccessControlPolicy body.
+type PutACL struct {
+	Target
+	VersionID string
+	CannedACL string
+	Grants    []Grant
+	Owner     string
+}
+
+type accessControlPolicy struct {
+	XMLName xml.Name `xml:"AccessControlPolicy"`
+	Owner   struct {
+		ID string
+	}
+	Grants []Grant `xml:"AccessControlList>Grant"`
+}
+
+func decodePutACL(r *http.Request, target Target) (*PutACL, error) {
+	input := &PutACL{
+		Target:    target,
+		VersionID: r.URL.Query().Get(QueryVersionID),
+	}
+
+	var err error
+
+	if input.CannedACL, err = headerEnum(r.Header, HeaderACL, CannedACLs...); err != nil {
+		return nil, err
+	}
+
+	for _, header := range grantHeaders {
+		for _, value := range r.Header.Values(header.name) {
+			grants, err := parseGrants(header.name, value, header.permission)
+			if err != nil {
+				return nil, err
+			}
+
+			input.Grants = append(input.Grants, grants...)
+		}
+	}
+
+	if input.CannedACL != "" && input.Grants != nil {
+		return nil, s3errors.InvalidRequest.New().
+			WithMessage("Specifying both Canned ACLs and Header Grants is not allowed")
+	}
+
+	payload, err := readPayload(r, MaxPayloadSize, s3errors.MaxMessageLengthExceeded)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) == 0 {
+		if input.CannedACL == "" && input.Grants == nil {
+			return nil, s3errors.MissingSecurityHeader.New().
+				WithMessage("Your request was missing a required header").
+				With("MissingHeaderName", HeaderACL)
+		}
+
+		return input, nil
+	}
+
+	if input.CannedACL != "" || input.Grants != nil {
+		return nil, s3errors.InvalidRequest.New().
+			WithMessage("Specifying both an AccessControlPolicy and ACL headers is not allowed")
+	}
+
+	var policy accessControlPolicy
+	if err := xml.Unmarshal(payload, &policy); err != nil {
+		return nil, s3errors.MalformedACLError.New()
+	}
+
+	for _, grant := range policy.Grants {
+		if !validGrant(grant) {
+			return nil, s3errors.MalformedACLError.New()
+		}
+	}
+
+	input.Owner = policy.Owner.ID
+	input.Grants = policy.Grants
+
+	return input, nil
+}
+
+func validGrant(grant Grant) bool {
+	switch grant.Permission {
+	case "FULL_CONTROL", "READ", "READ_ACP", "WRITE", "WRITE_ACP":
+	default:
+		return false
+	}
+
+	switch grant.Grantee.Type {
+	case GranteeCanonicalUser:
+		return grant.Grantee.ID != ""
+	case GranteeEmail:
+		return grant.Grantee.EmailAddress != ""
+	case GranteeGroup:
+		return grant.Grantee.URI != ""
+	default:
+		return false
+	}
+}
+
+// parseGrants reads the grantees of a x-amz-grant- header, a comma
+// separated list of id="...", emailAddress="..." and uri="...".
+func parseGrants(name, value, permission string) ([]Grant, error) {
+	var grants []Grant
+
+	for _, item := range strings.Split(value, ",") {
+		kind, raw, found := strings.Cut(strings.TrimSpace(item), "=")
+		grantee := strings.Trim(strings.TrimSpace(raw), `"`)
+
+		if !found || grantee == "" {
+			return nil, invalidArgument(name, value, "Invalid grantee in the "+name+" header")
+		}
+
+		grant := Grant{Permission: permission}
+
+		switch strings.ToLower(kind) {
+		case "id":
+			grant.Grantee = Grantee{Type: GranteeCanonicalUser, ID: grantee}
+		case "emailaddress":
+			grant.Grantee = Grantee{Type: GranteeEmail, EmailAddress: grantee}
+		case "uri":
+			grant.Grantee = Grantee{Type: GranteeGroup, URI: grantee}
+		default:
+			return nil, invalidArgument(name, value, "Invalid grantee in the "+name+" header")
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return grants, nil
+}
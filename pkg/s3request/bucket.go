@@ -0,0 +1,290 @@
+package s3request
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3checksum"
+	"github.com/lvjp/s3impl/pkg/s3delete"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type CreateBucket struct {
+	Target
+	LocationConstraint string
+	ACL                string
+	ObjectLockEnabled  bool
+	ObjectOwnership    string
+}
+
+type createBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string
+}
+
+func decodeCreateBucket(r *http.Request, target Target) (*CreateBucket, error) {
+	input := &CreateBucket{Target: target}
+
+	var err error
+
+	if input.ACL, err = headerEnum(r.Header, HeaderACL, "private", "public-read", "public-read-write", "authenticated-read"); err != nil {
+		return nil, err
+	}
+
+	if input.ObjectOwnership, err = headerEnum(r.Header, "x-amz-object-ownership", "BucketOwnerPreferred", "ObjectWriter", "BucketOwnerEnforced"); err != nil {
+		return nil, err
+	}
+
+	if input.ObjectLockEnabled, err = headerBool(r.Header, "x-amz-bucket-object-lock-enabled"); err != nil {
+		return nil, err
+	}
+
+	payload, err := readPayload(r, MaxPayloadSize, s3errors.MaxMessageLengthExceeded)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) > 0 {
+		var config createBucketConfiguration
+		if err := xml.Unmarshal(payload, &config); err != nil {
+			return nil, s3errors.MalformedXML.New()
+		}
+
+		input.LocationConstraint = config.LocationConstraint
+	}
+
+	return input, nil
+}
+
+type DeleteObjects struct {
+	Target
+	*s3delete.Request
+}
+
+// decodeDeleteObjects requires the payload integrity to be provided, as S3
+// does, either with Content-MD5 or with a checksum header or trailer.
+func decodeDeleteObjects(r *http.Request, target Target) (*DeleteObjects, error) {
+	integrity, err := s3checksum.ParseRequest(r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	if integrity.ContentMD5 == nil && integrity.Checksum == nil && integrity.Trailer == "" {
+		return nil, s3errors.InvalidRequest.New().
+			WithMessage("Missing required header for this request: Content-MD5 OR x-amz-checksum-*")
+	}
+
+	payload, err := readPayload(r, s3delete.MaxPayloadSize, s3errors.MalformedXML)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := s3delete.ParseRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeleteObjects{Target: target, Request: req}, nil
+}
+
+// Listing holds the parameters shared by the listings of a bucket.
+type Listing struct {
+	Prefix       string
+	Delimiter    string
+	EncodingType string
+	MaxKeys      int
+}
+
+func decodeListing(r *http.Request, maxKeysName string) (Listing, error) {
+	query := r.URL.Query()
+
+	listing := Listing{
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get(QueryDelimiter),
+	}
+
+	var err error
+
+	if listing.EncodingType, err = encodingType(query); err != nil {
+		return Listing{}, err
+	}
+
+	if listing.MaxKeys, err = maxKeys(query, maxKeysName); err != nil {
+		return Listing{}, err
+	}
+
+	return listing, nil
+}
+
+type ListObjects struct {
+	Target
+	Listing
+	Marker string
+}
+
+func decodeListObjects(r *http.Request, target Target) (*ListObjects, error) {
+	listing, err := decodeListing(r, QueryMaxKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListObjects{
+		Target:  target,
+		Listing: listing,
+		Marker:  r.URL.Query().Get("marker"),
+	}, nil
+}
+
+type ListObjectsV2 struct {
+	Target
+	Listing
+	ContinuationToken string
+	StartAfter        string
+	FetchOwner        bool
+}
+
+func decodeListObjectsV2(r *http.Request, target Target) (*ListObjectsV2, error) {
+	listing, err := decodeListing(r, QueryMaxKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+
+	input := &ListObjectsV2{
+		Target:            target,
+		Listing:           listing,
+		ContinuationToken: query.Get("continuation-token"),
+		StartAfter:        query.Get("start-after"),
+	}
+
+	if query.Has("continuation-token") && input.ContinuationToken == "" {
+		return nil, invalidArgument("continuation-token", "", "The continuation token provided is incorrect")
+	}
+
+	if input.FetchOwner, err = queryBool(query, "fetch-owner"); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
+
+type ListObjectVersions struct {
+	Target
+	Listing
+	KeyMarker       string
+	VersionIDMarker string
+}
+
+func decodeListObjectVersions(r *http.Request, target Target) (*ListObjectVersions, error) {
+	listing, err := decodeListing(r, QueryMaxKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+
+	input := &ListObjectVersions{
+		Target:          target,
+		Listing:         listing,
+		KeyMarker:       query.Get("key-marker"),
+		VersionIDMarker: query.Get("version-id-marker"),
+	}
+
+	if input.VersionIDMarker != "" && input.KeyMarker == "" {
+		return nil, invalidArgument("version-id-marker", input.VersionIDMarker, "A version-id marker cannot be specified without a key marker.")
+	}
+
+	return input, nil
+}
+
+type ListMultipartUploads struct {
+	Target
+	Listing
+	KeyMarker      string
+	UploadIDMarker string
+}
+
+func decodeListMultipartUploads(r *http.Request, target Target) (*ListMultipartUploads, error) {
+	listing, err := decodeListing(r, QueryMaxUploads)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+
+	return &ListMultipartUploads{
+		Target:         target,
+		Listing:        listing,
+		KeyMarker:      query.Get("key-marker"),
+		UploadIDMarker: query.Get("upload-id-marker"),
+	}, nil
+}
+
+// ListenBucketNotification filters the event stream of a bucket. Events
+// holds the requested event names, every event being streamed when it is
+// empty.
+type ListenBucketNotification struct {
+	Target
+	Prefix string
+	Suffix string
+	Events []string
+}
+
+func decodeListenBucketNotification(r *http.Request, target Target) (*ListenBucketNotification, error) {
+	query := r.URL.Query()
+
+	input := &ListenBucketNotification{
+		Target: target,
+		Prefix: query.Get("prefix"),
+		Suffix: query.Get("suffix"),
+	}
+
+	for _, value := range query["events"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				input.Events = append(input.Events, name)
+			}
+		}
+	}
+
+	return input, nil
+}
+
+// MaxPolicySize bounds the bucket policies.
+const MaxPolicySize = 20 << 10
+
+type PutBucketPolicy struct {
+	Target
+	Policy                        []byte
+	ConfirmRemoveSelfBucketAccess bool
+}
+
+func decodePutBucketPolicy(r *http.Request, target Target) (*PutBucketPolicy, error) {
+	input := &PutBucketPolicy{Target: target}
+
+	var err error
+	if input.ConfirmRemoveSelfBucketAccess, err = headerBool(r.Header, "x-amz-confirm-remove-self-bucket-access"); err != nil {
+		return nil, err
+	}
+
+	if input.Policy, err = readPayload(r, MaxPolicySize, s3errors.MalformedPolicy); err != nil {
+		return nil, err
+	}
+
+	var policy struct {
+		Statement json.RawMessage
+	}
+
+	if err := json.Unmarshal(input.Policy, &policy); err != nil {
+		return nil, s3errors.MalformedPolicy.New().WithMessage("Policies must be valid JSON and the first byte must be '{'")
+	}
+
+	if len(policy.Statement) == 0 || string(policy.Statement) == "null" {
+		return nil, s3errors.MalformedPolicy.New().WithMessage("Missing required field Statement")
+	}
+
+	return input, nil
+}
@@ -0,0 +1,136 @@
+package s3request
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+const (
+	QueryID                = "id"
+	QueryContinuationToken = "continuation-token"
+)
+
+// Configuration is the input of the actions on the bucket configurations
+// stored as sub-resources. ID selects a document of the collections, like
+// the metrics configurations, and Payload is the document of Put actions.
+type Configuration struct {
+	Target
+	ID                string
+	ContinuationToken string
+	Payload           []byte
+}
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+func configurationID(r *http.Request) (string, error) {
+	id := r.URL.Query().Get(QueryID)
+	if !validID.MatchString(id) {
+		return "", invalidArgument(QueryID, id, "The id query parameter must be 1 to 64 letters, digits, '.', '_' or '-'")
+	}
+
+	return id, nil
+}
+
+// configurationPayload reads a configuration document, larger documents
+// being rejected as malformed.
+func configurationPayload(r *http.Request) ([]byte, error) {
+	return readPayload(r, MaxPayloadSize, s3errors.MalformedXML)
+}
+
+func decodeConfiguration(_ *http.Request, target Target) (*Configuration, error) {
+	return &Configuration{Target: target}, nil
+}
+
+func decodePutConfiguration(r *http.Request, target Target) (*Configuration, error) {
+	payload, err := configurationPayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Configuration{Target: target, Payload: payload}, nil
+}
+
+func decodeCollectionItem(r *http.Request, target Target) (*Configuration, error) {
+	id, err := configurationID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Configuration{Target: target, ID: id}, nil
+}
+
+func decodePutCollectionItem(r *http.Request, target Target) (*Configuration, error) {
+	id, err := configurationID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := configurationPayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Configuration{Target: target, ID: id, Payload: payload}, nil
+}
+
+func decodeListConfigurations(r *http.Request, target Target) (*Configuration, error) {
+	return &Configuration{Target: target, ContinuationToken: r.URL.Query().Get(QueryContinuationToken)}, nil
+}
+
+var configurationDecoders = map[s3router.Action]decodeFunc{
+	s3router.ActionDeleteBucketAnalyticsConfiguration:          decoder(decodeCollectionItem),
+	s3router.ActionDeleteBucketCors:                            decoder(decodeConfiguration),
+	s3router.ActionDeleteBucketEncryption:                      decoder(decodeConfiguration),
+	s3router.ActionDeleteBucketIntelligentTieringConfiguration: decoder(decodeCollectionItem),
+	s3router.ActionDeleteBucketInventoryConfiguration:          decoder(decodeCollectionItem),
+	s3router.ActionDeleteBucketLifecycle:                       decoder(decodeConfiguration),
+	s3router.ActionDeleteBucketMetricsConfiguration:            decoder(decodeCollectionItem),
+	s3router.ActionDeleteBucketOwnershipControls:               decoder(decodeConfiguration),
+	s3router.ActionDeleteBucketReplication:                     decoder(decodeConfiguration),
+	s3router.ActionDeleteBucketTagging:                         decoder(decodeConfiguration),
+	s3router.ActionDeleteBucketWebsite:                         decoder(decodeConfiguration),
+	s3router.ActionDeletePublicAccessBlock:                     decoder(decodeConfiguration),
+	s3router.ActionGetBucketAccelerateConfiguration:            decoder(decodeConfiguration),
+	s3router.ActionGetBucketAnalyticsConfiguration:             decoder(decodeCollectionItem),
+	s3router.ActionGetBucketCors:                               decoder(decodeConfiguration),
+	s3router.ActionGetBucketEncryption:                         decoder(decodeConfiguration),
+	s3router.ActionGetBucketIntelligentTieringConfiguration:    decoder(decodeCollectionItem),
+	s3router.ActionGetBucketInventoryConfiguration:             decoder(decodeCollectionItem),
+	s3router.ActionGetBucketLifecycleConfiguration:             decoder(decodeConfiguration),
+	s3router.ActionGetBucketLogging:                            decoder(decodeConfiguration),
+	s3router.ActionGetBucketMetricsConfiguration:               decoder(decodeCollectionItem),
+	s3router.ActionGetBucketNotificationConfiguration:          decoder(decodeConfiguration),
+	s3router.ActionGetBucketOwnershipControls:                  decoder(decodeConfiguration),
+	s3router.ActionGetBucketReplication:                        decoder(decodeConfiguration),
+	s3router.ActionGetBucketRequestPayment:                     decoder(decodeConfiguration),
+	s3router.ActionGetBucketTagging:                            decoder(decodeConfiguration),
+	s3router.ActionGetBucketVersioning:                         decoder(decodeConfiguration),
+	s3router.ActionGetBucketWebsite:                            decoder(decodeConfiguration),
+	s3router.ActionGetObjectLockConfiguration:                  decoder(decodeConfiguration),
+	s3router.ActionGetPublicAccessBlock:                        decoder(decodeConfiguration),
+	s3router.ActionListBucketAnalyticsConfigurations:           decoder(decodeListConfigurations),
+	s3router.ActionListBucketIntelligentTieringConfigurations:  decoder(decodeListConfigurations),
+	s3router.ActionListBucketInventoryConfigurations:           decoder(decodeListConfigurations),
+	s3router.ActionListBucketMetricsConfigurations:             decoder(decodeListConfigurations),
+	s3router.ActionPutBucketAccelerateConfiguration:            decoder(decodePutConfiguration),
+	s3router.ActionPutBucketAnalyticsConfiguration:             decoder(decodePutCollectionItem),
+	s3router.ActionPutBucketCors:                               decoder(decodePutConfiguration),
+	s3router.ActionPutBucketEncryption:                         decoder(decodePutConfiguration),
+	s3router.ActionPutBucketIntelligentTieringConfiguration:    decoder(decodePutCollectionItem),
+	s3router.ActionPutBucketInventoryConfiguration:             decoder(decodePutCollectionItem),
+	s3router.ActionPutBucketLifecycleConfiguration:             decoder(decodePutConfiguration),
+	s3router.ActionPutBucketLogging:                            decoder(decodePutConfiguration),
+	s3router.ActionPutBucketMetricsConfiguration:               decoder(decodePutCollectionItem),
+	s3router.ActionPutBucketNotificationConfiguration:          decoder(decodePutConfiguration),
+	s3router.ActionPutBucketOwnershipControls:                  decoder(decodePutConfiguration),
+	s3router.ActionPutBucketReplication:                        decoder(decodePutConfiguration),
+	s3router.ActionPutBucketRequestPayment:                     decoder(decodePutConfiguration),
+	s3router.ActionPutBucketTagging:                            decoder(decodePutConfiguration),
+	s3router.ActionPutBucketVersioning:                         decoder(decodePutConfiguration),
+	s3router.ActionPutBucketWebsite:                            decoder(decodePutConfiguration),
+	s3router.ActionPutObjectLockConfiguration:                  decoder(decodePutConfiguration),
+	s3router.ActionPutPublicAccessBlock:                        decoder(decodePutConfiguration),
+}
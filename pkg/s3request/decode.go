@@ -0,0 +1,78 @@
+package s3request
+
+import (
+	"net/http"
+
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// Target is the bucket and key addressed by a request, embedded in every
+// input.
+type Target struct {
+	Bucket string
+	Key    string
+}
+
+type decodeFunc func(r *http.Request, target Target) (any, error)
+
+func decoder[T any](decode func(r *http.Request, target Target) (*T, error)) decodeFunc {
+	return func(r *http.Request, target Target) (any, error) {
+		return decode(r, target)
+	}
+}
+
+var decoders = map[s3router.Action]decodeFunc{
+	s3router.ActionAbortMultipartUpload:     decoder(decodeAbortMultipartUpload),
+	s3router.ActionCompleteMultipartUpload:  decoder(decodeCompleteMultipartUpload),
+	s3router.ActionCopyObject:               decoder(decodeCopyObject),
+	s3router.ActionCreateBucket:             decoder(decodeCreateBucket),
+	s3router.ActionCreateMultipartUpload:    decoder(decodeCreateMultipartUpload),
+	s3router.ActionDeleteObject:             decoder(decodeDeleteObject),
+	s3router.ActionDeleteObjects:            decoder(decodeDeleteObjects),
+	s3router.ActionGetObject:                decoder(decodeGetObject),
+	s3router.ActionGetObjectAttributes:      decoder(decodeGetObjectAttributes),
+	s3router.ActionHeadObject:               decoder(decodeGetObject),
+	s3router.ActionListenBucketNotification: decoder(decodeListenBucketNotification),
+	s3router.ActionListMultipartUploads:     decoder(decodeListMultipartUploads),
+	s3router.ActionListObjects:              decoder(decodeListObjects),
+	s3router.ActionListObjectVersions:       decoder(decodeListObjectVersions),
+	s3router.ActionListParts:                decoder(decodeListParts),
+	s3router.ActionPutBucketACL:             decoder(decodePutACL),
+	s3router.ActionPutBucketPolicy:          decoder(decodePutBucketPolicy),
+	s3router.ActionPutObject:                decoder(decodePutObject),
+	s3router.ActionPutObjectACL:             decoder(decodePutACL),
+	s3router.ActionPutObjectLegalHold:       decoder(decodePutObjectLegalHold),
+	s3router.ActionPutObjectRetention:       decoder(decodePutObjectRetention),
+	s3router.ActionPutObjectTagging:         decoder(decodePutObjectTagging),
+	s3router.ActionRestoreObject:            decoder(decodeRestoreObject),
+	s3router.ActionSelectObjectContent:      decoder(decodeSelectObjectContent),
+	s3router.ActionUploadPart:               decoder(decodeUploadPart),
+	s3router.ActionUploadPartCopy:           decoder(decodeUploadPartCopy),
+}
+
+// Decode returns the typed and validated input of the routed action, such
+// as *GetObject for ActionGetObject and ActionHeadObject, *PutACL for
+// ActionPutBucketACL and ActionPutObjectACL, or *Configuration for the
+// actions on bucket configurations. Actions without parameters of
+// their own decode to *Target.
+//
+// The returned errors are *s3errors.S3Error, except when the body cannot be
+// read.
+func Decode(r *http.Request, route *s3router.Route) (any, error) {
+	target := Target{Bucket: route.Bucket, Key: route.Key}
+
+	decode, exists := decoders[route.Action]
+	if !exists {
+		decode, exists = configurationDecoders[route.Action]
+	}
+
+	if !exists {
+		return &target, nil
+	}
+
+	if route.Action == s3router.ActionListObjects && r.URL.Query().Get("list-type") == "2" {
+		return decodeListObjectsV2(r, target)
+	}
+
+	return decode(r, target)
+}
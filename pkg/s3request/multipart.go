@@ -0,0 +1,261 @@
+package s3request
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/lvjp/s3impl/pkg/s3checksum"
+	"github.com/lvjp/s3impl/pkg/s3conditional"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type CreateMultipartUpload struct {
+	Target
+	NewObject
+	// ChecksumAlgorithm and ChecksumType are empty when no checksum was
+	// asked for.
+	ChecksumAlgorithm s3checksum.Algorithm
+	ChecksumType      s3checksum.Type
+}
+
+func decodeCreateMultipartUpload(r *http.Request, target Target) (*CreateMultipartUpload, error) {
+	input := &CreateMultipartUpload{Target: target}
+
+	var err error
+
+	if input.NewObject, err = decodeNewObject(r.Header); err != nil {
+		return nil, err
+	}
+
+	if raw := r.Header.Get(s3checksum.HeaderChecksumAlgorithm); raw != "" {
+		if input.ChecksumAlgorithm, err = s3checksum.ParseAlgorithm(raw); err != nil {
+			return nil, invalidArgument(s3checksum.HeaderChecksumAlgorithm, raw, "Checksum algorithm provided is unsupported.")
+		}
+	}
+
+	if raw := r.Header.Get(s3checksum.HeaderChecksumType); raw != "" {
+		if input.ChecksumType, err = s3checksum.ParseType(raw); err != nil {
+			return nil, invalidArgument(s3checksum.HeaderChecksumType, raw, "Value for x-amz-checksum-type header is invalid.")
+		}
+	}
+
+	return input, nil
+}
+
+// Part identifies the part of a multipart upload.
+type Part struct {
+	UploadID   string
+	PartNumber int
+}
+
+func decodePart(r *http.Request) (Part, error) {
+	query := r.URL.Query()
+
+	var (
+		part Part
+		err  error
+	)
+
+	if part.UploadID, err = uploadID(query); err != nil {
+		return Part{}, err
+	}
+
+	if part.PartNumber, err = partNumber(query); err != nil {
+		return Part{}, err
+	}
+
+	return part, nil
+}
+
+type UploadPart struct {
+	Target
+	Part
+	ContentLength int64
+	Integrity     *s3checksum.Request
+	Body          io.Reader
+}
+
+func decodeUploadPart(r *http.Request, target Target) (*UploadPart, error) {
+	input := &UploadPart{Target: target, Body: r.Body}
+
+	var err error
+
+	if input.Part, err = decodePart(r); err != nil {
+		return nil, err
+	}
+
+	if input.ContentLength, err = ContentLength(r); err != nil {
+		return nil, err
+	}
+
+	if input.Integrity, err = s3checksum.ParseRequest(r.Header); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
+
+// SourceRange is the inclusive byte range of x-amz-copy-source-range.
+type SourceRange struct {
+	First int64
+	Last  int64
+}
+
+var sourceRangeRegexp = regexp.MustCompile(`^bytes=(\d+)-(\d+)$`)
+
+func decodeSourceRange(header http.Header) (*SourceRange, error) {
+	raw := header.Get(HeaderCopySourceRange)
+	if raw == "" {
+		return nil, nil //nolint:nilnil // the whole source is copied
+	}
+
+	invalid := invalidArgument(HeaderCopySourceRange, raw, "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+
+	match := sourceRangeRegexp.FindStringSubmatch(raw)
+	if match == nil {
+		return nil, invalid
+	}
+
+	first, errFirst := strconv.ParseInt(match[1], 10, 64)
+	last, errLast := strconv.ParseInt(match[2], 10, 64)
+	if errFirst != nil || errLast != nil || first > last {
+		return nil, invalid
+	}
+
+	return &SourceRange{First: first, Last: last}, nil
+}
+
+type UploadPartCopy struct {
+	Target
+	Part
+	Source           CopySource
+	SourceRange      *SourceRange
+	SourceConditions Conditions
+}
+
+func decodeUploadPartCopy(r *http.Request, target Target) (*UploadPartCopy, error) {
+	input := &UploadPartCopy{
+		Target:           target,
+		SourceConditions: decodeConditions(r.Header, "x-amz-copy-source-"),
+	}
+
+	var err error
+
+	if input.Part, err = decodePart(r); err != nil {
+		return nil, err
+	}
+
+	if input.Source, err = decodeCopySource(r.Header); err != nil {
+		return nil, err
+	}
+
+	if input.SourceRange, err = decodeSourceRange(r.Header); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
+
+type CompletedPart struct {
+	PartNumber        int
+	ETag              string
+	ChecksumCRC32     string `xml:",omitempty"`
+	ChecksumCRC32C    string `xml:",omitempty"`
+	ChecksumCRC64NVME string `xml:",omitempty"`
+	ChecksumSHA1      string `xml:",omitempty"`
+	ChecksumSHA256    string `xml:",omitempty"`
+}
+
+type CompleteMultipartUpload struct {
+	Target
+	UploadID  string
+	Parts     []CompletedPart
+	Condition *s3conditional.WriteCondition
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPart `xml:"Part"`
+}
+
+func decodeCompleteMultipartUpload(r *http.Request, target Target) (*CompleteMultipartUpload, error) {
+	input := &CompleteMultipartUpload{Target: target}
+
+	var err error
+
+	if input.UploadID, err = uploadID(r.URL.Query()); err != nil {
+		return nil, err
+	}
+
+	if input.Condition, err = s3conditional.ParseWrite(r.Header); err != nil {
+		return nil, err
+	}
+
+	var payload completeMultipartUpload
+	if err := decodeXML(r, &payload); err != nil {
+		return nil, err
+	}
+
+	if len(payload.Parts) == 0 {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	for i, part := range payload.Parts {
+		if part.PartNumber < 1 || part.PartNumber > MaxPartNumber || part.ETag == "" {
+			return nil, s3errors.MalformedXML.New()
+		}
+
+		if i > 0 && part.PartNumber <= payload.Parts[i-1].PartNumber {
+			return nil, s3errors.InvalidPartOrder.New()
+		}
+	}
+
+	input.Parts = payload.Parts
+
+	return input, nil
+}
+
+type AbortMultipartUpload struct {
+	Target
+	UploadID string
+}
+
+func decodeAbortMultipartUpload(r *http.Request, target Target) (*AbortMultipartUpload, error) {
+	id, err := uploadID(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	return &AbortMultipartUpload{Target: target, UploadID: id}, nil
+}
+
+type ListParts struct {
+	Target
+	UploadID         string
+	MaxParts         int
+	PartNumberMarker int
+}
+
+func decodeListParts(r *http.Request, target Target) (*ListParts, error) {
+	query := r.URL.Query()
+	input := &ListParts{Target: target}
+
+	var err error
+
+	if input.UploadID, err = uploadID(query); err != nil {
+		return nil, err
+	}
+
+	if input.MaxParts, err = maxKeys(query, QueryMaxParts); err != nil {
+		return nil, err
+	}
+
+	if input.PartNumberMarker, err = queryInt(query, QueryPartNumberMark, 0, MaxPartNumber, 0); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
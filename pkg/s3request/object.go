@@ -0,0 +1,559 @@
+package s3request
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lvjp/s3impl/pkg/s3archive"
+	"github.com/lvjp/s3impl/pkg/s3attributes"
+	"github.com/lvjp/s3impl/pkg/s3checksum"
+	"github.com/lvjp/s3impl/pkg/s3conditional"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3response"
+	"github.com/lvjp/s3impl/pkg/s3select"
+)
+
+const (
+	HeaderCopySource        = "x-amz-copy-source"
+	HeaderCopySourceRange   = "x-amz-copy-source-range"
+	HeaderMetadataDirective = "x-amz-metadata-directive"
+	HeaderTaggingDirective  = "x-amz-tagging-directive"
+	HeaderObjectLockMode    = "x-amz-object-lock-mode"
+	HeaderObjectLockDate    = "x-amz-object-lock-retain-until-date"
+	HeaderLegalHold         = "x-amz-object-lock-legal-hold"
+	HeaderDecodedLength     = "x-amz-decoded-content-length"
+	HeaderBypassGovernance  = "x-amz-bypass-governance-retention"
+
+	DirectiveCopy    = "COPY"
+	DirectiveReplace = "REPLACE"
+)
+
+// Conditions are the conditional headers of a read, or of the source of a
+// copy.
+type Conditions struct {
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   *time.Time
+	IfUnmodifiedSince *time.Time
+}
+
+func decodeConditions(header http.Header, prefix string) Conditions {
+	return Conditions{
+		IfMatch:           header.Get(prefix + s3conditional.HeaderIfMatch),
+		IfNoneMatch:       header.Get(prefix + s3conditional.HeaderIfNoneMatch),
+		IfModifiedSince:   headerTime(header, prefix+s3conditional.HeaderIfModifiedSince),
+		IfUnmodifiedSince: headerTime(header, prefix+s3conditional.HeaderIfUnmodifiedSince),
+	}
+}
+
+// GetObject is the input of GetObject and HeadObject.
+type GetObject struct {
+	Target
+	VersionID string
+	// Range is the raw Range header, resolved once the object size is
+	// known. PartNumber is zero when no part was requested.
+	Range        string
+	PartNumber   int
+	Conditions   Conditions
+	Overrides    s3response.Overrides
+	ChecksumMode bool
+}
+
+func decodeGetObject(r *http.Request, target Target) (*GetObject, error) {
+	query := r.URL.Query()
+
+	input := &GetObject{
+		Target:       target,
+		VersionID:    query.Get(QueryVersionID),
+		Range:        r.Header.Get("Range"),
+		Conditions:   decodeConditions(r.Header, ""),
+		Overrides:    s3response.ParseOverrides(query),
+		ChecksumMode: s3checksum.ModeEnabled(r.Header),
+	}
+
+	if query.Has(QueryPartNumber) {
+		if input.Range != "" {
+			return nil, s3errors.InvalidRequest.New().
+				WithMessage("Cannot specify both Range header and partNumber query parameter")
+		}
+
+		var err error
+		if input.PartNumber, err = partNumber(query); err != nil {
+			return nil, err
+		}
+	}
+
+	return input, nil
+}
+
+// ObjectLock is the retention and legal hold set on a new object.
+type ObjectLock struct {
+	Mode            string
+	RetainUntilDate *time.Time
+	LegalHold       string
+}
+
+func decodeObjectLock(header http.Header) (ObjectLock, error) {
+	var (
+		lock ObjectLock
+		err  error
+	)
+
+	if lock.Mode, err = headerEnum(header, HeaderObjectLockMode, "GOVERNANCE", "COMPLIANCE"); err != nil {
+		return ObjectLock{}, err
+	}
+
+	if lock.RetainUntilDate, err = headerISO8601(header, HeaderObjectLockDate); err != nil {
+		return ObjectLock{}, err
+	}
+
+	if (lock.Mode == "") != (lock.RetainUntilDate == nil) {
+		return ObjectLock{}, invalidArgument(HeaderObjectLockMode, lock.Mode, "x-amz-object-lock-retain-until-date and x-amz-object-lock-mode must both be supplied")
+	}
+
+	if lock.LegalHold, err = headerEnum(header, HeaderLegalHold, "ON", "OFF"); err != nil {
+		return ObjectLock{}, err
+	}
+
+	return lock, nil
+}
+
+// NewObject holds the attributes of a stored object, shared by the
+// requests creating one.
+type NewObject struct {
+	ContentType  string
+	StorageClass s3archive.StorageClass
+	Metadata     map[string]string
+	Tags         map[string]string
+	ObjectLock   ObjectLock
+}
+
+func decodeNewObject(header http.Header) (NewObject, error) {
+	object := NewObject{
+		ContentType: header.Get("Content-Type"),
+		Metadata:    metadata(header),
+	}
+
+	var err error
+
+	if object.StorageClass, err = s3archive.ParseStorageClass(header); err != nil {
+		return NewObject{}, err
+	}
+
+	if object.Tags, err = Tagging(header); err != nil {
+		return NewObject{}, err
+	}
+
+	if object.ObjectLock, err = decodeObjectLock(header); err != nil {
+		return NewObject{}, err
+	}
+
+	return object, nil
+}
+
+// ContentLength returns the size of the payload, without the signature
+// chunks of aws-chunked bodies.
+func ContentLength(r *http.Request) (int64, error) {
+	if !s3checksum.IsChunked(r.Header) {
+		if r.ContentLength < 0 {
+			return 0, s3errors.MissingContentLength.New()
+		}
+
+		return r.ContentLength, nil
+	}
+
+	raw := r.Header.Get(HeaderDecodedLength)
+	if raw == "" {
+		return 0, s3errors.MissingContentLength.New()
+	}
+
+	length, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || length < 0 {
+		return 0, invalidArgument(HeaderDecodedLength, raw, "The "+HeaderDecodedLength+" header must be a positive integer")
+	}
+
+	return length, nil
+}
+
+type PutObject struct {
+	Target
+	NewObject
+	ContentLength int64
+	Integrity     *s3checksum.Request
+	Condition     *s3conditional.WriteCondition
+	Body          io.Reader
+}
+
+func decodePutObject(r *http.Request, target Target) (*PutObject, error) {
+	input := &PutObject{Target: target, Body: r.Body}
+
+	var err error
+
+	if input.ContentLength, err = ContentLength(r); err != nil {
+		return nil, err
+	}
+
+	if input.NewObject, err = decodeNewObject(r.Header); err != nil {
+		return nil, err
+	}
+
+	if input.Integrity, err = s3checksum.ParseRequest(r.Header); err != nil {
+		return nil, err
+	}
+
+	if input.Condition, err = s3conditional.ParseWrite(r.Header); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
+
+// CopySource is the object read by CopyObject and UploadPartCopy.
+type CopySource struct {
+	Bucket    string
+	Key       string
+	VersionID string
+}
+
+func decodeCopySource(header http.Header) (CopySource, error) {
+	raw := header.Get(HeaderCopySource)
+
+	path, rawQuery, _ := strings.Cut(raw, "?")
+
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return CopySource{}, invalidArgument(HeaderCopySource, raw, "Invalid copy source encoding")
+	}
+
+	var source CopySource
+
+	source.Bucket, source.Key, _ = strings.Cut(strings.TrimPrefix(unescaped, "/"), "/")
+	if source.Bucket == "" || source.Key == "" {
+		return CopySource{}, invalidArgument(HeaderCopySource, raw, "Copy Source must mention the source bucket and key: sourcebucket/sourcekey")
+	}
+
+	if rawQuery != "" {
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil || !query.Has(QueryVersionID) || query.Get(QueryVersionID) == "" {
+			return CopySource{}, invalidArgument(HeaderCopySource, raw, "Invalid version id specified")
+		}
+
+		source.VersionID = query.Get(QueryVersionID)
+	}
+
+	return source, nil
+}
+
+type CopyObject struct {
+	Target
+	NewObject
+	Source            CopySource
+	SourceConditions  Conditions
+	MetadataDirective string
+	TaggingDirective  string
+}
+
+func decodeCopyObject(r *http.Request, target Target) (*CopyObject, error) {
+	input := &CopyObject{
+		Target:           target,
+		SourceConditions: decodeConditions(r.Header, "x-amz-copy-source-"),
+	}
+
+	var err error
+
+	if input.Source, err = decodeCopySource(r.Header); err != nil {
+		return nil, err
+	}
+
+	if input.NewObject, err = decodeNewObject(r.Header); err != nil {
+		return nil, err
+	}
+
+	if input.MetadataDirective, err = directive(r.Header, HeaderMetadataDirective); err != nil {
+		return nil, err
+	}
+
+	if input.TaggingDirective, err = directive(r.Header, HeaderTaggingDirective); err != nil {
+		return nil, err
+	}
+
+	if input.copyOnItself(r.Header) {
+		return nil, s3errors.InvalidRequest.New().
+			WithMessage("This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.")
+	}
+
+	return input, nil
+}
+
+func directive(header http.Header, name string) (string, error) {
+	value, err := headerEnum(header, name, DirectiveCopy, DirectiveReplace)
+	if err != nil || value != "" {
+		return value, err
+	}
+
+	return DirectiveCopy, nil
+}
+
+// copyOnItself reports whether the copy would not change anything.
+func (c *CopyObject) copyOnItself(header http.Header) bool {
+	return c.Source.Bucket == c.Bucket && c.Source.Key == c.Key && c.Source.VersionID == "" &&
+		c.MetadataDirective == DirectiveCopy && header.Get(s3archive.HeaderStorageClass) == ""
+}
+
+type DeleteObject struct {
+	Target
+	VersionID                 string
+	BypassGovernanceRetention bool
+}
+
+func decodeDeleteObject(r *http.Request, target Target) (*DeleteObject, error) {
+	input := &DeleteObject{
+		Target:    target,
+		VersionID: r.URL.Query().Get(QueryVersionID),
+	}
+
+	var err error
+	if input.BypassGovernanceRetention, err = headerBool(r.Header, HeaderBypassGovernance); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
+
+type GetObjectAttributes struct {
+	Target
+	*s3attributes.Request
+	VersionID string
+}
+
+func decodeGetObjectAttributes(r *http.Request, target Target) (*GetObjectAttributes, error) {
+	req := &s3attributes.Request{Attributes: make(map[s3attributes.Attribute]bool)}
+
+	for _, value := range r.Header.Values(HeaderObjectAttributes) {
+		for _, name := range strings.Split(value, ",") {
+			attribute, found := s3attributes.Lookup(strings.TrimSpace(name))
+			if !found {
+				return nil, invalidArgument(HeaderObjectAttributes, name, "Invalid attribute name specified.")
+			}
+
+			req.Attributes[attribute] = true
+		}
+	}
+
+	if len(req.Attributes) == 0 {
+		return nil, invalidArgument(HeaderObjectAttributes, "", "The x-amz-object-attributes header specifying the attributes to be retrieved is either missing or empty")
+	}
+
+	var err error
+
+	if req.MaxParts, err = headerInt(r.Header, HeaderMaxParts, 0, DefaultMaxKeys, DefaultMaxKeys); err != nil {
+		return nil, err
+	}
+
+	if req.PartNumberMarker, err = headerInt(r.Header, HeaderPartNumberMarker, 0, MaxPartNumber, 0); err != nil {
+		return nil, err
+	}
+
+	return &GetObjectAttributes{
+		Target:    target,
+		Request:   req,
+		VersionID: r.URL.Query().Get(QueryVersionID),
+	}, nil
+}
+
+type RestoreObject struct {
+	Target
+	*s3archive.RestoreRequest
+	VersionID string
+}
+
+func decodeRestoreObject(r *http.Request, target Target) (*RestoreObject, error) {
+	payload, err := readPayload(r, MaxPayloadSize, s3errors.MaxMessageLengthExceeded)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := s3archive.ParseRestoreRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RestoreObject{
+		Target:         target,
+		RestoreRequest: req,
+		VersionID:      r.URL.Query().Get(QueryVersionID),
+	}, nil
+}
+
+type SelectObjectContent struct {
+	Target
+	*s3select.Request
+	Query *s3select.Query
+}
+
+func decodeSelectObjectContent(r *http.Request, target Target) (*SelectObjectContent, error) {
+	payload, err := readPayload(r, MaxPayloadSize, s3errors.MaxMessageLengthExceeded)
+	if err != nil {
+		return nil, err
+	}
+
+	req, query, err := s3select.ParseRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SelectObjectContent{Target: target, Request: req, Query: query}, nil
+}
+
+// Limits of the object tags.
+const (
+	MaxTags           = 10
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+func checkTag(key, value string) error {
+	if n := utf8.RuneCountInString(key); n < 1 || n > MaxTagKeyLength {
+		return s3errors.InvalidTag.New().WithMessage("The TagKey you have provided is invalid")
+	}
+
+	if utf8.RuneCountInString(value) > MaxTagValueLength {
+		return s3errors.InvalidTag.New().WithMessage("The TagValue you have provided is invalid")
+	}
+
+	return nil
+}
+
+type PutObjectTagging struct {
+	Target
+	VersionID string
+	Tags      map[string]string
+}
+
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []struct {
+		Key   string
+		Value string
+	} `xml:"TagSet>Tag"`
+}
+
+func decodePutObjectTagging(r *http.Request, target Target) (*PutObjectTagging, error) {
+	var body tagging
+	if err := decodeXML(r, &body); err != nil {
+		return nil, err
+	}
+
+	if len(body.TagSet) > MaxTags {
+		return nil, s3errors.InvalidTag.New().WithMessage("Object tags cannot be greater than 10")
+	}
+
+	input := &PutObjectTagging{
+		Target:    target,
+		VersionID: r.URL.Query().Get(QueryVersionID),
+		Tags:      make(map[string]string, len(body.TagSet)),
+	}
+
+	for _, tag := range body.TagSet {
+		if err := checkTag(tag.Key, tag.Value); err != nil {
+			return nil, err
+		}
+
+		if _, exists := input.Tags[tag.Key]; exists {
+			return nil, s3errors.InvalidTag.New().WithMessage("Cannot provide multiple Tags with the same key")
+		}
+
+		input.Tags[tag.Key] = tag.Value
+	}
+
+	return input, nil
+}
+
+// PutObjectRetention is the input of PutObjectRetention, Mode and
+// RetainUntilDate being empty when the retention is removed.
+type PutObjectRetention struct {
+	Target
+	VersionID                 string
+	Mode                      string
+	RetainUntilDate           *time.Time
+	BypassGovernanceRetention bool
+}
+
+type retention struct {
+	XMLName         xml.Name `xml:"Retention"`
+	Mode            string
+	RetainUntilDate string
+}
+
+func decodePutObjectRetention(r *http.Request, target Target) (*PutObjectRetention, error) {
+	input := &PutObjectRetention{
+		Target:    target,
+		VersionID: r.URL.Query().Get(QueryVersionID),
+	}
+
+	var err error
+	if input.BypassGovernanceRetention, err = headerBool(r.Header, HeaderBypassGovernance); err != nil {
+		return nil, err
+	}
+
+	var body retention
+	if err := decodeXML(r, &body); err != nil {
+		return nil, err
+	}
+
+	if (body.Mode == "") != (body.RetainUntilDate == "") {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	if body.Mode == "" {
+		return input, nil
+	}
+
+	if body.Mode != "GOVERNANCE" && body.Mode != "COMPLIANCE" {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	date, err := time.Parse(time.RFC3339, body.RetainUntilDate)
+	if err != nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	input.Mode = body.Mode
+	input.RetainUntilDate = &date
+
+	return input, nil
+}
+
+type PutObjectLegalHold struct {
+	Target
+	VersionID string
+	Status    string
+}
+
+type legalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Status  string
+}
+
+func decodePutObjectLegalHold(r *http.Request, target Target) (*PutObjectLegalHold, error) {
+	var body legalHold
+	if err := decodeXML(r, &body); err != nil {
+		return nil, err
+	}
+
+	if body.Status != "ON" && body.Status != "OFF" {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	return &PutObjectLegalHold{
+		Target:    target,
+		VersionID: r.URL.Query().Get(QueryVersionID),
+		Status:    body.Status,
+	}, nil
+}
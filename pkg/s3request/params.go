@@ -0,0 +1,284 @@
+package s3request
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3checksum"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+const (
+	QueryDelimiter      = "delimiter"
+	QueryEncodingType   = "encoding-type"
+	QueryMaxKeys        = "max-keys"
+	QueryMaxUploads     = "max-uploads"
+	QueryMaxParts       = "max-parts"
+	QueryPartNumber     = "partNumber"
+	QueryUploadID       = "uploadId"
+	QueryVersionID      = "versionId"
+	QueryPartNumberMark = "part-number-marker"
+
+	HeaderMetadataPrefix   = "x-amz-meta-"
+	HeaderTagging          = "x-amz-tagging"
+	HeaderObjectAttributes = "x-amz-object-attributes"
+	HeaderMaxParts         = "x-amz-max-parts"
+	HeaderPartNumberMarker = "x-amz-part-number-marker"
+
+	// DefaultMaxKeys is the page size of the listings, larger values are
+	// lowered to it.
+	DefaultMaxKeys = 1000
+	MaxPartNumber  = 10000
+	// MaxPayloadSize bounds the XML bodies read by the decoders.
+	MaxPayloadSize = 1 << 20
+)
+
+func invalidArgument(name, value, message string) *s3errors.S3Error {
+	return s3errors.InvalidArgument.New().WithMessage(message).WithArgument(name, value)
+}
+
+// queryInt reads an integer query parameter between low and high,
+// defaultValue when it is absent.
+func queryInt(query url.Values, name string, low, high, defaultValue int) (int, error) {
+	if !query.Has(name) {
+		return defaultValue, nil
+	}
+
+	return parseInt(name, query.Get(name), low, high)
+}
+
+// headerInt reads an integer header between low and high, defaultValue when
+// it is absent.
+func headerInt(header http.Header, name string, low, high, defaultValue int) (int, error) {
+	raw := header.Get(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	return parseInt(name, raw, low, high)
+}
+
+func parseInt(name, raw string, low, high int) (int, error) {
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < low || value > high {
+		return 0, invalidArgument(name, raw, "Argument "+name+" must be an integer between "+strconv.Itoa(low)+" and "+strconv.Itoa(high))
+	}
+
+	return value, nil
+}
+
+// maxKeys reads a page size parameter, S3 silently lowering the values
+// above DefaultMaxKeys.
+func maxKeys(query url.Values, name string) (int, error) {
+	value, err := queryInt(query, name, 0, math.MaxInt32, DefaultMaxKeys)
+	if err != nil {
+		return 0, err
+	}
+
+	return min(value, DefaultMaxKeys), nil
+}
+
+func partNumber(query url.Values) (int, error) {
+	raw := query.Get(QueryPartNumber)
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 || value > MaxPartNumber {
+		return 0, invalidArgument(QueryPartNumber, raw, "Part number must be an integer between 1 and 10000, inclusive")
+	}
+
+	return value, nil
+}
+
+func uploadID(query url.Values) (string, error) {
+	value := query.Get(QueryUploadID)
+	if value == "" {
+		return "", invalidArgument(QueryUploadID, value, "This operation requires a non empty upload id")
+	}
+
+	return value, nil
+}
+
+func encodingType(query url.Values) (string, error) {
+	value := query.Get(QueryEncodingType)
+	if value != "" && value != "url" {
+		return "", invalidArgument(QueryEncodingType, value, "Invalid Encoding Method specified in Request")
+	}
+
+	return value, nil
+}
+
+func queryBool(query url.Values, name string) (bool, error) {
+	if !query.Has(name) {
+		return false, nil
+	}
+
+	raw := query.Get(name)
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, invalidArgument(name, raw, "Argument "+name+" must be a boolean")
+	}
+
+	return value, nil
+}
+
+func headerBool(header http.Header, name string) (bool, error) {
+	raw := header.Get(name)
+	if raw == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, invalidArgument(name, raw, "The "+name+" header must be a boolean")
+	}
+
+	return value, nil
+}
+
+// headerTime reads an HTTP date header. Like S3, invalid dates are ignored
+// in conditional headers, nil being returned.
+func headerTime(header http.Header, name string) *time.Time {
+	value := header.Get(name)
+	if value == "" {
+		return nil
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return nil
+	}
+
+	return &t
+}
+
+// headerISO8601 reads an ISO 8601 date header, such as the Object Lock
+// retention date.
+func headerISO8601(header http.Header, name string) (*time.Time, error) {
+	value := header.Get(name)
+	if value == "" {
+		return nil, nil //nolint:nilnil // the header is optional
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, invalidArgument(name, value, "The "+name+" header must be an ISO 8601 date")
+	}
+
+	return &t, nil
+}
+
+func headerEnum(header http.Header, name string, values ...string) (string, error) {
+	value := header.Get(name)
+	if value == "" {
+		return "", nil
+	}
+
+	for _, allowed := range values {
+		if value == allowed {
+			return value, nil
+		}
+	}
+
+	return "", invalidArgument(name, value, "The "+name+" header must be one of "+strings.Join(values, ", "))
+}
+
+// metadata returns the user metadata sent with the x-amz-meta- headers,
+// keyed by lower case name without prefix.
+func metadata(header http.Header) map[string]string {
+	result := make(map[string]string)
+
+	for name, values := range header {
+		if suffix, found := strings.CutPrefix(strings.ToLower(name), HeaderMetadataPrefix); found {
+			result[suffix] = strings.Join(values, ",")
+		}
+	}
+
+	return result
+}
+
+// Tagging returns the tags sent with the x-amz-tagging header.
+func Tagging(header http.Header) (map[string]string, error) {
+	raw := header.Get(HeaderTagging)
+	if raw == "" {
+		return map[string]string{}, nil
+	}
+
+	query, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, invalidArgument(HeaderTagging, raw, "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	}
+
+	tags := make(map[string]string, len(query))
+	for key, values := range query {
+		if len(values) > 1 {
+			return nil, invalidArgument(HeaderTagging, raw, "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+		}
+
+		if err := checkTag(key, values[0]); err != nil {
+			return nil, err
+		}
+
+		tags[key] = values[0]
+	}
+
+	if len(tags) > MaxTags {
+		return nil, s3errors.InvalidTag.New().WithMessage("Object tags cannot be greater than 10")
+	}
+
+	return tags, nil
+}
+
+// readPayload reads a body of at most limit bytes, verifying its
+// Content-MD5 and x-amz-checksum- headers or trailer. Larger bodies are
+// answered with tooLarge.
+func readPayload(r *http.Request, limit int, tooLarge s3errors.ErrorCode) ([]byte, error) {
+	integrity, err := s3checksum.ParseRequest(r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	body, trailer := io.Reader(r.Body), func() http.Header { return nil }
+	if s3checksum.IsChunked(r.Header) {
+		chunked := s3checksum.NewChunkedReader(r.Body)
+		body, trailer = chunked, chunked.Trailer
+	}
+
+	// The digests are only verified at the end of payloads within the limit.
+	payload, err := io.ReadAll(io.LimitReader(integrity.NewReader(body, trailer), int64(limit)+1))
+	if err != nil {
+		var s3err *s3errors.S3Error
+		if errors.As(err, &s3err) {
+			return nil, s3err
+		}
+
+		return nil, fmt.Errorf("s3request: cannot read body: %w", err)
+	}
+
+	if len(payload) > limit {
+		return nil, tooLarge.New()
+	}
+
+	return payload, nil
+}
+
+func decodeXML(r *http.Request, v any) error {
+	payload, err := readPayload(r, MaxPayloadSize, s3errors.MaxMessageLengthExceeded)
+	if err != nil {
+		return err
+	}
+
+	if err := xml.Unmarshal(payload, v); err != nil {
+		return s3errors.MalformedXML.New()
+	}
+
+	return nil
+}
@@ -0,0 +1,512 @@
+package s3request
+
+import (
+	"crypto/md5" //nolint:gosec // Content-MD5 is part of the S3 API
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3archive"
+	"github.com/lvjp/s3impl/pkg/s3attributes"
+	"github.com/lvjp/s3impl/pkg/s3delete"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+const host = "s3.example.com"
+
+func decode(t *testing.T, method, target string, header http.Header, body string) (any, error) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, "http://"+host+target, strings.NewReader(body))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+
+	route, err := s3router.DetermineRoute(r, []string{host})
+	require.NoError(t, err)
+
+	return Decode(r, route)
+}
+
+func requireArgument(t *testing.T, err error, code, argument string) {
+	t.Helper()
+
+	var s3err *s3errors.S3Error
+	require.True(t, errors.As(err, &s3err), "%v", err)
+	require.Equal(t, code, s3err.Code)
+
+	if argument != "" {
+		require.Contains(t, s3err.Extra, s3errors.Field{Name: "ArgumentName", Value: argument})
+	}
+}
+
+func TestDecodeListObjects(t *testing.T) {
+	input, err := decode(t, http.MethodGet, "/bucket?prefix=a%2F&delimiter=%2F&max-keys=5000&marker=a%2Fb", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, &ListObjects{
+		Target:  Target{Bucket: "bucket"},
+		Listing: Listing{Prefix: "a/", Delimiter: "/", MaxKeys: DefaultMaxKeys},
+		Marker:  "a/b",
+	}, input)
+
+	input, err = decode(t, http.MethodGet, "/bucket?list-type=2&max-keys=10&fetch-owner=true&start-after=k", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, &ListObjectsV2{
+		Target:     Target{Bucket: "bucket"},
+		Listing:    Listing{MaxKeys: 10},
+		StartAfter: "k",
+		FetchOwner: true,
+	}, input)
+
+	testCases := map[string]struct {
+		target   string
+		argument string
+	}{
+		"NegativeMaxKeys":      {target: "/bucket?max-keys=-1", argument: "max-keys"},
+		"TextMaxKeys":          {target: "/bucket?max-keys=ten", argument: "max-keys"},
+		"EncodingType":         {target: "/bucket?encoding-type=base64", argument: "encoding-type"},
+		"FetchOwner":           {target: "/bucket?list-type=2&fetch-owner=maybe", argument: "fetch-owner"},
+		"VersionIDMarkerAlone": {target: "/bucket?versions&version-id-marker=v1", argument: "version-id-marker"},
+		"MaxUploads":           {target: "/bucket?uploads&max-uploads=-5", argument: "max-uploads"},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			_, err := decode(t, http.MethodGet, tc.target, nil, "")
+			requireArgument(t, err, "InvalidArgument", tc.argument)
+		})
+	}
+}
+
+func TestDecodeGetObject(t *testing.T) {
+	since := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+
+	input, err := decode(t, http.MethodGet, "/bucket/dir/key?versionId=v1&partNumber=2&response-content-type=text%2Fplain", http.Header{
+		"If-Match":            {`"abc"`},
+		"If-Modified-Since":   {since.Format(http.TimeFormat)},
+		"X-Amz-Checksum-Mode": {"ENABLED"},
+	}, "")
+	require.NoError(t, err)
+
+	get := input.(*GetObject)
+	require.Equal(t, Target{Bucket: "bucket", Key: "dir/key"}, get.Target)
+	require.Equal(t, "v1", get.VersionID)
+	require.Equal(t, 2, get.PartNumber)
+	require.Equal(t, `"abc"`, get.Conditions.IfMatch)
+	require.Equal(t, since, *get.Conditions.IfModifiedSince)
+	require.Equal(t, "text/plain", get.Overrides["Content-Type"])
+	require.True(t, get.ChecksumMode)
+
+	_, err = decode(t, http.MethodHead, "/bucket/key?partNumber=10001", nil, "")
+	requireArgument(t, err, "InvalidArgument", "partNumber")
+
+	_, err = decode(t, http.MethodGet, "/bucket/key?partNumber=1", http.Header{"Range": {"bytes=0-9"}}, "")
+	requireArgument(t, err, "InvalidRequest", "")
+}
+
+func TestDecodeGetObjectAttributes(t *testing.T) {
+	input, err := decode(t, http.MethodGet, "/bucket/key?attributes&versionId=v1", http.Header{
+		"X-Amz-Object-Attributes":  {"ETag, ObjectParts", "objectsize"},
+		"X-Amz-Max-Parts":          {"2"},
+		"X-Amz-Part-Number-Marker": {"1"},
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, &GetObjectAttributes{
+		Target: Target{Bucket: "bucket", Key: "key"},
+		Request: &s3attributes.Request{
+			Attributes: map[s3attributes.Attribute]bool{
+				s3attributes.AttributeETag:        true,
+				s3attributes.AttributeObjectParts: true,
+				s3attributes.AttributeObjectSize:  true,
+			},
+			MaxParts:         2,
+			PartNumberMarker: 1,
+		},
+		VersionID: "v1",
+	}, input)
+
+	testCases := map[string]struct {
+		header   http.Header
+		argument string
+	}{
+		"Missing":          {header: http.Header{}, argument: "x-amz-object-attributes"},
+		"Unknown":          {header: http.Header{"X-Amz-Object-Attributes": {"ETag,Owner"}}, argument: "x-amz-object-attributes"},
+		"MaxPartsTooHigh":  {header: http.Header{"X-Amz-Object-Attributes": {"ETag"}, "X-Amz-Max-Parts": {"1001"}}, argument: "x-amz-max-parts"},
+		"NegativeMarker":   {header: http.Header{"X-Amz-Object-Attributes": {"ETag"}, "X-Amz-Part-Number-Marker": {"-1"}}, argument: "x-amz-part-number-marker"},
+		"MaxPartsNotAnInt": {header: http.Header{"X-Amz-Object-Attributes": {"ETag"}, "X-Amz-Max-Parts": {"ten"}}, argument: "x-amz-max-parts"},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			_, err := decode(t, http.MethodGet, "/bucket/key?attributes", tc.header, "")
+			requireArgument(t, err, "InvalidArgument", tc.argument)
+		})
+	}
+}
+
+func TestDecodePutObject(t *testing.T) {
+	input, err := decode(t, http.MethodPut, "/bucket/key", http.Header{
+		"X-Amz-Meta-Color":                    {"blue"},
+		"X-Amz-Tagging":                       {"project=s3impl&env=dev"},
+		"X-Amz-Storage-Class":                 {"GLACIER"},
+		"X-Amz-Object-Lock-Mode":              {"GOVERNANCE"},
+		"X-Amz-Object-Lock-Retain-Until-Date": {"2030-01-02T03:04:05Z"},
+		"If-None-Match":                       {"*"},
+	}, "hello")
+	require.NoError(t, err)
+
+	put := input.(*PutObject)
+	require.Equal(t, int64(5), put.ContentLength)
+	require.Equal(t, map[string]string{"color": "blue"}, put.Metadata)
+	require.Equal(t, map[string]string{"project": "s3impl", "env": "dev"}, put.Tags)
+	require.Equal(t, s3archive.StorageClassGlacier, put.StorageClass)
+	require.Equal(t, "GOVERNANCE", put.ObjectLock.Mode)
+	require.Equal(t, time.Date(2030, time.January, 2, 3, 4, 5, 0, time.UTC), *put.ObjectLock.RetainUntilDate)
+	require.True(t, put.Condition.IfNoneMatch)
+
+	testCases := map[string]struct {
+		header   http.Header
+		code     string
+		argument string
+	}{
+		"RetainUntilDate": {
+			header:   http.Header{"X-Amz-Object-Lock-Mode": {"COMPLIANCE"}, "X-Amz-Object-Lock-Retain-Until-Date": {"tomorrow"}},
+			code:     "InvalidArgument",
+			argument: "x-amz-object-lock-retain-until-date",
+		},
+		"ModeWithoutDate": {
+			header:   http.Header{"X-Amz-Object-Lock-Mode": {"COMPLIANCE"}},
+			code:     "InvalidArgument",
+			argument: "x-amz-object-lock-mode",
+		},
+		"LegalHold": {
+			header:   http.Header{"X-Amz-Object-Lock-Legal-Hold": {"YES"}},
+			code:     "InvalidArgument",
+			argument: "x-amz-object-lock-legal-hold",
+		},
+		"Tagging": {
+			header:   http.Header{"X-Amz-Tagging": {"a=1&a=2"}},
+			code:     "InvalidArgument",
+			argument: "x-amz-tagging",
+		},
+		"StorageClass": {
+			header: http.Header{"X-Amz-Storage-Class": {"COLD"}},
+			code:   "InvalidStorageClass",
+		},
+		"ContentMD5": {
+			header: http.Header{"Content-Md5": {"not-base64"}},
+			code:   "InvalidDigest",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			_, err := decode(t, http.MethodPut, "/bucket/key", tc.header, "hello")
+			requireArgument(t, err, tc.code, tc.argument)
+		})
+	}
+}
+
+func TestDecodeCopyObject(t *testing.T) {
+	input, err := decode(t, http.MethodPut, "/bucket/key", http.Header{
+		"X-Amz-Copy-Source":                   {"/source/dir%2Fa%20b.txt?versionId=v2"},
+		"X-Amz-Metadata-Directive":            {"REPLACE"},
+		"X-Amz-Copy-Source-If-None-Match":     {`"abc"`},
+		"X-Amz-Copy-Source-If-Modified-Since": {"not a date"},
+	}, "")
+	require.NoError(t, err)
+
+	copyObject := input.(*CopyObject)
+	require.Equal(t, CopySource{Bucket: "source", Key: "dir/a b.txt", VersionID: "v2"}, copyObject.Source)
+	require.Equal(t, DirectiveReplace, copyObject.MetadataDirective)
+	require.Equal(t, DirectiveCopy, copyObject.TaggingDirective)
+	require.Equal(t, `"abc"`, copyObject.SourceConditions.IfNoneMatch)
+	require.Nil(t, copyObject.SourceConditions.IfModifiedSince)
+
+	_, err = decode(t, http.MethodPut, "/bucket/key", http.Header{"X-Amz-Copy-Source": {"bucket/key"}}, "")
+	requireArgument(t, err, "InvalidRequest", "")
+
+	_, err = decode(t, http.MethodPut, "/bucket/key", http.Header{"X-Amz-Copy-Source": {"source"}}, "")
+	requireArgument(t, err, "InvalidArgument", "x-amz-copy-source")
+
+	_, err = decode(t, http.MethodPut, "/bucket/key", http.Header{
+		"X-Amz-Copy-Source":        {"source/key"},
+		"X-Amz-Metadata-Directive": {"MERGE"},
+	}, "")
+	requireArgument(t, err, "InvalidArgument", "x-amz-metadata-directive")
+}
+
+func TestDecodeMultipart(t *testing.T) {
+	input, err := decode(t, http.MethodPost, "/bucket/key?uploads", http.Header{
+		"X-Amz-Checksum-Algorithm": {"crc32c"},
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, "CRC32C", string(input.(*CreateMultipartUpload).ChecksumAlgorithm))
+
+	_, err = decode(t, http.MethodPost, "/bucket/key?uploads", http.Header{
+		"X-Amz-Checksum-Algorithm": {"md4"},
+	}, "")
+	requireArgument(t, err, "InvalidArgument", "x-amz-checksum-algorithm")
+
+	input, err = decode(t, http.MethodPut, "/bucket/key?partNumber=3&uploadId=u1", nil, "part")
+	require.NoError(t, err)
+	require.Equal(t, Part{UploadID: "u1", PartNumber: 3}, input.(*UploadPart).Part)
+
+	_, err = decode(t, http.MethodPut, "/bucket/key?partNumber=0&uploadId=u1", nil, "part")
+	requireArgument(t, err, "InvalidArgument", "partNumber")
+
+	input, err = decode(t, http.MethodPut, "/bucket/key?partNumber=1&uploadId=u1", http.Header{
+		"X-Amz-Copy-Source":       {"source/key"},
+		"X-Amz-Copy-Source-Range": {"bytes=0-1023"},
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, &SourceRange{First: 0, Last: 1023}, input.(*UploadPartCopy).SourceRange)
+
+	_, err = decode(t, http.MethodPut, "/bucket/key?partNumber=1&uploadId=u1", http.Header{
+		"X-Amz-Copy-Source":       {"source/key"},
+		"X-Amz-Copy-Source-Range": {"bytes=10-1"},
+	}, "")
+	requireArgument(t, err, "InvalidArgument", "x-amz-copy-source-range")
+
+	complete := "<CompleteMultipartUpload>" +
+		`<Part><PartNumber>1</PartNumber><ETag>"a"</ETag></Part>` +
+		`<Part><PartNumber>2</PartNumber><ETag>"b"</ETag></Part>` +
+		"</CompleteMultipartUpload>"
+
+	input, err = decode(t, http.MethodPost, "/bucket/key?uploadId=u1", nil, complete)
+	require.NoError(t, err)
+	require.Equal(t, []CompletedPart{{PartNumber: 1, ETag: `"a"`}, {PartNumber: 2, ETag: `"b"`}}, input.(*CompleteMultipartUpload).Parts)
+
+	unordered := "<CompleteMultipartUpload>" +
+		`<Part><PartNumber>2</PartNumber><ETag>"b"</ETag></Part>` +
+		`<Part><PartNumber>1</PartNumber><ETag>"a"</ETag></Part>` +
+		"</CompleteMultipartUpload>"
+
+	_, err = decode(t, http.MethodPost, "/bucket/key?uploadId=u1", nil, unordered)
+	requireArgument(t, err, "InvalidPartOrder", "")
+
+	_, err = decode(t, http.MethodPost, "/bucket/key?uploadId=u1", nil, "<CompleteMultipartUpload>")
+	requireArgument(t, err, "MalformedXML", "")
+
+	input, err = decode(t, http.MethodGet, "/bucket/key?uploadId=u1&max-parts=10&part-number-marker=4", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, &ListParts{Target: Target{Bucket: "bucket", Key: "key"}, UploadID: "u1", MaxParts: 10, PartNumberMarker: 4}, input)
+
+	_, err = decode(t, http.MethodDelete, "/bucket/key?uploadId=", nil, "")
+	requireArgument(t, err, "InvalidArgument", "uploadId")
+}
+
+func TestDecodeBodies(t *testing.T) {
+	input, err := decode(t, http.MethodPut, "/bucket", nil,
+		"<CreateBucketConfiguration><LocationConstraint>eu-west-3</LocationConstraint></CreateBucketConfiguration>")
+	require.NoError(t, err)
+	require.Equal(t, "eu-west-3", input.(*CreateBucket).LocationConstraint)
+
+	_, err = decode(t, http.MethodPut, "/bucket", nil, "<Bucket>")
+	requireArgument(t, err, "MalformedXML", "")
+
+	_, err = decode(t, http.MethodPost, "/bucket/key?restore", nil, "<RestoreRequest><Days>0</Days></RestoreRequest>")
+	requireArgument(t, err, "MalformedXML", "")
+
+	input, err = decode(t, http.MethodPost, "/bucket/key?restore&versionId=v1", nil, "<RestoreRequest><Days>2</Days></RestoreRequest>")
+	require.NoError(t, err)
+	require.Equal(t, 2, input.(*RestoreObject).Days)
+	require.Equal(t, "v1", input.(*RestoreObject).VersionID)
+
+	_, err = decode(t, http.MethodPost, "/bucket?delete", nil, "<Delete></Delete>")
+	requireArgument(t, err, "InvalidRequest", "")
+
+	deletion := "<Delete><Object><Key>a</Key></Object></Delete>"
+
+	_, err = decode(t, http.MethodPost, "/bucket?delete", http.Header{
+		"Content-Md5": {"AAAAAAAAAAAAAAAAAAAAAA=="},
+	}, deletion)
+	requireArgument(t, err, "BadDigest", "")
+
+	sum := md5.Sum([]byte(deletion))
+	input, err = decode(t, http.MethodPost, "/bucket?delete", http.Header{
+		"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])},
+	}, deletion)
+	require.NoError(t, err)
+	require.IsType(t, &DeleteObjects{}, input)
+
+	oversized := strings.Repeat(" ", s3delete.MaxPayloadSize+1)
+	sum = md5.Sum([]byte(oversized))
+	_, err = decode(t, http.MethodPost, "/bucket?delete", http.Header{
+		"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])},
+	}, oversized)
+	requireArgument(t, err, "MalformedXML", "")
+
+	_, err = decode(t, http.MethodPut, "/bucket", nil, strings.Repeat(" ", MaxPayloadSize+1))
+	requireArgument(t, err, "MaxMessageLengthExceeded", "")
+}
+
+func TestDecodeObjectSubresources(t *testing.T) {
+	input, err := decode(t, http.MethodPut, "/bucket/key?tagging&versionId=v1", nil,
+		"<Tagging><TagSet><Tag><Key>env</Key><Value>dev</Value></Tag></TagSet></Tagging>")
+	require.NoError(t, err)
+	require.Equal(t, &PutObjectTagging{
+		Target:    Target{Bucket: "bucket", Key: "key"},
+		VersionID: "v1",
+		Tags:      map[string]string{"env": "dev"},
+	}, input)
+
+	_, err = decode(t, http.MethodPut, "/bucket/key?tagging", nil,
+		"<Tagging><TagSet><Tag><Key>a</Key></Tag><Tag><Key>a</Key></Tag></TagSet></Tagging>")
+	requireArgument(t, err, "InvalidTag", "")
+
+	_, err = decode(t, http.MethodPut, "/bucket/key?tagging", nil,
+		"<Tagging><TagSet><Tag><Key></Key><Value>v</Value></Tag></TagSet></Tagging>")
+	requireArgument(t, err, "InvalidTag", "")
+
+	_, err = decode(t, http.MethodPut, "/bucket/key", http.Header{"X-Amz-Tagging": {strings.Repeat("a", MaxTagKeyLength+1) + "=v"}}, "")
+	requireArgument(t, err, "InvalidTag", "")
+
+	input, err = decode(t, http.MethodPut, "/bucket/key?retention", http.Header{"X-Amz-Bypass-Governance-Retention": {"true"}},
+		"<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>2030-01-02T03:04:05Z</RetainUntilDate></Retention>")
+	require.NoError(t, err)
+
+	retention := input.(*PutObjectRetention)
+	require.Equal(t, "GOVERNANCE", retention.Mode)
+	require.Equal(t, time.Date(2030, time.January, 2, 3, 4, 5, 0, time.UTC), *retention.RetainUntilDate)
+	require.True(t, retention.BypassGovernanceRetention)
+
+	input, err = decode(t, http.MethodPut, "/bucket/key?retention", nil, "<Retention></Retention>")
+	require.NoError(t, err)
+	require.Nil(t, input.(*PutObjectRetention).RetainUntilDate)
+
+	for _, body := range []string{
+		"<Retention><Mode>GOVERNANCE</Mode></Retention>",
+		"<Retention><Mode>FOREVER</Mode><RetainUntilDate>2030-01-02T03:04:05Z</RetainUntilDate></Retention>",
+		"<Retention><Mode>COMPLIANCE</Mode><RetainUntilDate>tomorrow</RetainUntilDate></Retention>",
+	} {
+		_, err = decode(t, http.MethodPut, "/bucket/key?retention", nil, body)
+		requireArgument(t, err, "MalformedXML", "")
+	}
+
+	input, err = decode(t, http.MethodPut, "/bucket/key?legal-hold", nil, "<LegalHold><Status>ON</Status></LegalHold>")
+	require.NoError(t, err)
+	require.Equal(t, "ON", input.(*PutObjectLegalHold).Status)
+
+	_, err = decode(t, http.MethodPut, "/bucket/key?legal-hold", nil, "<LegalHold><Status>on</Status></LegalHold>")
+	requireArgument(t, err, "MalformedXML", "")
+}
+
+func TestDecodeACL(t *testing.T) {
+	input, err := decode(t, http.MethodPut, "/bucket?acl", http.Header{"X-Amz-Acl": {"public-read"}}, "")
+	require.NoError(t, err)
+	require.Equal(t, &PutACL{Target: Target{Bucket: "bucket"}, CannedACL: "public-read"}, input)
+
+	input, err = decode(t, http.MethodPut, "/bucket/key?acl", http.Header{
+		"X-Amz-Grant-Read":         {`id="abc", uri="http://acs.amazonaws.com/groups/global/AllUsers"`},
+		"X-Amz-Grant-Full-Control": {`emailAddress="owner@example.com"`},
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, []Grant{
+		{Grantee: Grantee{Type: GranteeEmail, EmailAddress: "owner@example.com"}, Permission: "FULL_CONTROL"},
+		{Grantee: Grantee{Type: GranteeCanonicalUser, ID: "abc"}, Permission: "READ"},
+		{Grantee: Grantee{Type: GranteeGroup, URI: "http://acs.amazonaws.com/groups/global/AllUsers"}, Permission: "READ"},
+	}, input.(*PutACL).Grants)
+
+	input, err = decode(t, http.MethodPut, "/bucket/key?acl", nil, `<AccessControlPolicy>
+		<Owner><ID>owner</ID></Owner>
+		<AccessControlList><Grant>
+			<Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser"><ID>abc</ID></Grantee>
+			<Permission>WRITE_ACP</Permission>
+		</Grant></AccessControlList>
+	</AccessControlPolicy>`)
+	require.NoError(t, err)
+	require.Equal(t, "owner", input.(*PutACL).Owner)
+	require.Equal(t, []Grant{{Grantee: Grantee{Type: GranteeCanonicalUser, ID: "abc"}, Permission: "WRITE_ACP"}}, input.(*PutACL).Grants)
+
+	testCases := map[string]struct {
+		header http.Header
+		body   string
+		code   string
+	}{
+		"Missing":       {code: "MissingSecurityHeader"},
+		"UnknownCanned": {header: http.Header{"X-Amz-Acl": {"everyone"}}, code: "InvalidArgument"},
+		"CannedAndGrant": {
+			header: http.Header{"X-Amz-Acl": {"private"}, "X-Amz-Grant-Read": {`id="abc"`}},
+			code:   "InvalidRequest",
+		},
+		"HeaderAndBody": {header: http.Header{"X-Amz-Acl": {"private"}}, body: "<AccessControlPolicy/>", code: "InvalidRequest"},
+		"BadGrantee":    {header: http.Header{"X-Amz-Grant-Read": {`name="abc"`}}, code: "InvalidArgument"},
+		"BadBody":       {body: "<AccessControlPolicy>", code: "MalformedACLError"},
+		"BadPermission": {
+			body: `<AccessControlPolicy><AccessControlList><Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>u</URI></Grantee><Permission>ALL</Permission></Grant></AccessControlList></AccessControlPolicy>`,
+			code: "MalformedACLError",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			_, err := decode(t, http.MethodPut, "/bucket/key?acl", tc.header, tc.body)
+			requireArgument(t, err, tc.code, "")
+		})
+	}
+}
+
+func TestDecodePutBucketPolicy(t *testing.T) {
+	policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`
+
+	input, err := decode(t, http.MethodPut, "/bucket?policy", nil, policy)
+	require.NoError(t, err)
+	require.Equal(t, &PutBucketPolicy{Target: Target{Bucket: "bucket"}, Policy: []byte(policy)}, input)
+
+	for _, body := range []string{"", "Statement", `{"Version":"2012-10-17"}`, strings.Repeat(" ", MaxPolicySize+1)} {
+		_, err = decode(t, http.MethodPut, "/bucket?policy", nil, body)
+		requireArgument(t, err, "MalformedPolicy", "")
+	}
+}
+
+func TestDecodeTarget(t *testing.T) {
+	input, err := decode(t, http.MethodGet, "/bucket?location", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, &Target{Bucket: "bucket"}, input)
+}
+
+func TestDecodeConfiguration(t *testing.T) {
+	input, err := decode(t, http.MethodPut, "/bucket?tagging", nil, "<Tagging/>")
+	require.NoError(t, err)
+	require.Equal(t, &Configuration{Target: Target{Bucket: "bucket"}, Payload: []byte("<Tagging/>")}, input)
+
+	input, err = decode(t, http.MethodGet, "/bucket?metrics&id=all", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, &Configuration{Target: Target{Bucket: "bucket"}, ID: "all"}, input)
+
+	_, err = decode(t, http.MethodGet, "/bucket?metrics&id=a/b", nil, "")
+	requireArgument(t, err, "InvalidArgument", "id")
+
+	input, err = decode(t, http.MethodGet, "/bucket?metrics&continuation-token=next", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, &Configuration{Target: Target{Bucket: "bucket"}, ContinuationToken: "next"}, input)
+
+	_, err = decode(t, http.MethodPut, "/bucket?cors", nil, strings.Repeat(" ", MaxPayloadSize+1))
+	requireArgument(t, err, "MalformedXML", "")
+}
+
+func TestDecodeListenBucketNotification(t *testing.T) {
+	input, err := decode(t, http.MethodGet, "/bucket?events&prefix=images/&events=s3:ObjectCreated:*,s3:ObjectRemoved:*", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, &ListenBucketNotification{
+		Target: Target{Bucket: "bucket"},
+		Prefix: "images/",
+		Events: []string{"s3:ObjectCreated:*", "s3:ObjectRemoved:*"},
+	}, input)
+}
@@ -0,0 +1,133 @@
+package s3requestpayment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvjp/s3impl/pkg/s3auth"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3response"
+	"github.com/lvjp/s3impl/pkg/s3router"
+	"github.com/lvjp/s3impl/pkg/s3trace"
+)
+
+var tracer = otel.Tracer("github.com/lvjp/s3impl/pkg/s3requestpayment")
+
+// PayerFunc returns the payer configured on a bucket.
+type PayerFunc func(ctx context.Context, bucket string) (Payer, error)
+
+// OwnerFunc reports whether a request was sent by the bucket owner.
+type OwnerFunc func(r *http.Request, bucket string) bool
+
+// Enforcer applies Requester Pays to action handlers and records the
+// charges.
+type Enforcer struct {
+	logger *zerolog.Logger
+	payer  PayerFunc
+	owner  OwnerFunc
+	usage  *Usage
+}
+
+func NewEnforcer(logger *zerolog.Logger, payer PayerFunc, owner OwnerFunc, usage *Usage) *Enforcer {
+	return &Enforcer{logger: logger, payer: payer, owner: owner, usage: usage}
+}
+
+// Middleware guards every routed request with the Requester Pays
+// configuration of the requested bucket.
+func (e *Enforcer) Middleware(handler s3router.ActionHandler) s3router.ActionHandler {
+	return s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+		if !Applies(route) {
+			handler.ServeAction(w, r, route)
+			return
+		}
+
+		charged, err := e.authorize(r, route)
+		if err != nil {
+			e.writeError(w, r, err)
+			return
+		} else if !charged {
+			handler.ServeAction(w, r, route)
+			return
+		}
+
+		body := s3response.CountBody(r)
+		rw := chargedWriter{Recorder: s3response.NewRecorder(w)}
+
+		handler.ServeAction(rw, r, route)
+
+		if rw.StatusCode == 0 {
+			SetChargedHeader(w.Header())
+		} else if rw.StatusCode >= http.StatusMultipleChoices {
+			return
+		}
+
+		e.usage.Record(Charge{
+			Bucket:    route.Bucket,
+			Requester: s3auth.AccessKey(r),
+			Action:    route.Action,
+			BytesIn:   body.N,
+			BytesOut:  rw.Written,
+		})
+	})
+}
+
+// chargedWriter sets the charged header on the successful responses, errors
+// not being charged.
+type chargedWriter struct {
+	*s3response.Recorder
+}
+
+func (c chargedWriter) WriteHeader(statusCode int) {
+	if c.StatusCode == 0 && statusCode < http.StatusMultipleChoices {
+		SetChargedHeader(c.Header())
+	}
+
+	c.Recorder.WriteHeader(statusCode)
+}
+
+func (c chargedWriter) Write(p []byte) (int, error) {
+	if c.StatusCode == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	return c.Recorder.Write(p)
+}
+
+// authorize checks the request against the payer of the bucket.
+func (e *Enforcer) authorize(r *http.Request, route *s3router.Route) (charged bool, err error) {
+	ctx, span := tracer.Start(r.Context(), "AuthorizeRequestPayment", trace.WithAttributes(s3trace.Bucket.String(route.Bucket)))
+	defer func() { s3trace.End(span, err) }()
+
+	payer, err := e.payer(ctx, route.Bucket)
+	if err != nil {
+		return false, err
+	}
+
+	span.SetAttributes(attribute.String("s3.payer", string(payer)))
+
+	return Check(r.Header, payer, e.owner(r, route.Bucket))
+}
+
+func (e *Enforcer) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var s3err *s3errors.S3Error
+	if !errors.As(err, &s3err) {
+		s3logging.Ctx(r, e.logger).Error().Err(err).Str("path", r.URL.Path).Msg("Cannot read request payment configuration")
+		s3err = s3errors.InternalError.New()
+	}
+
+	resp := *s3err
+	resp.RequestID = w.Header().Get("x-amz-request-id")
+	resp.Resource = r.URL.Path
+
+	writer := s3errors.APIWriter{}
+	if err := writer.Write(&resp, w, r); err != nil {
+		s3logging.Ctx(r, e.logger).Warn().Err(err).Msg("Cannot write response")
+	}
+}
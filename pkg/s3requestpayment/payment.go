@@ -0,0 +1,85 @@
+package s3requestpayment
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+type Payer string
+
+const (
+	PayerBucketOwner Payer = "BucketOwner"
+	PayerRequester   Payer = "Requester"
+
+	HeaderRequestPayer   = "x-amz-request-payer"
+	HeaderRequestCharged = "x-amz-request-charged"
+
+	requester = "requester"
+)
+
+type Configuration struct {
+	XMLName   xml.Name `xml:"RequestPaymentConfiguration"`
+	Namespace string   `xml:"xmlns,attr,omitempty"`
+	Payer     Payer
+}
+
+// ParseConfiguration decodes a PutBucketRequestPayment payload.
+func ParseConfiguration(payload []byte) (*Configuration, error) {
+	var config Configuration
+	if err := xml.Unmarshal(payload, &config); err != nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	if config.Payer != PayerBucketOwner && config.Payer != PayerRequester {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	config.Namespace = s3consts.XMLNamespace
+
+	return &config, nil
+}
+
+// bucketActions are the bucket level actions charged to the requester,
+// besides every object level action.
+var bucketActions = map[s3router.Action]bool{
+	s3router.ActionListObjects:          true,
+	s3router.ActionListObjectVersions:   true,
+	s3router.ActionListMultipartUploads: true,
+	s3router.ActionHeadBucket:           true,
+	s3router.ActionDeleteObjects:        true,
+}
+
+// Applies reports whether Requester Pays applies to the action of a route:
+// object operations and listings, not the bucket configuration.
+func Applies(route *s3router.Route) bool {
+	return route.Key != "" || bucketActions[route.Action]
+}
+
+// Check enforces the Requester Pays configuration of a bucket. Callers
+// other than the bucket owner must acknowledge the charges with the
+// x-amz-request-payer header. charged reports whether the requester pays
+// for the request.
+func Check(header http.Header, payer Payer, owner bool) (charged bool, err error) {
+	acknowledged := strings.EqualFold(header.Get(HeaderRequestPayer), requester)
+
+	if payer != PayerRequester || owner {
+		return false, nil
+	}
+
+	if !acknowledged {
+		return false, s3errors.AccessDenied.New().
+			WithMessage("Access Denied: the bucket is configured for Requester Pays, requests must include the x-amz-request-payer header")
+	}
+
+	return true, nil
+}
+
+// SetChargedHeader confirms the requester was charged.
+func SetChargedHeader(header http.Header) {
+	header.Set(HeaderRequestCharged, requester)
+}
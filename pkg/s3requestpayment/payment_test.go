@@ -0,0 +1,190 @@
+package s3requestpayment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3auth"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+func TestParseConfiguration(t *testing.T) {
+	config, err := ParseConfiguration([]byte("<RequestPaymentConfiguration><Payer>Requester</Payer></RequestPaymentConfiguration>"))
+	require.NoError(t, err)
+	require.Equal(t, PayerRequester, config.Payer)
+
+	_, err = ParseConfiguration([]byte("<RequestPaymentConfiguration><Payer>Nobody</Payer></RequestPaymentConfiguration>"))
+	require.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	acknowledged := http.Header{"X-Amz-Request-Payer": {"requester"}}
+
+	charged, err := Check(http.Header{}, PayerBucketOwner, false)
+	require.NoError(t, err)
+	require.False(t, charged)
+
+	charged, err = Check(http.Header{}, PayerRequester, true)
+	require.NoError(t, err)
+	require.False(t, charged)
+
+	_, err = Check(http.Header{}, PayerRequester, false)
+	var s3err *s3errors.S3Error
+	require.ErrorAs(t, err, &s3err)
+	require.Equal(t, "AccessDenied", s3err.Code)
+	require.Equal(t, http.StatusForbidden, s3err.HTTPStatusCode)
+
+	charged, err = Check(acknowledged, PayerRequester, false)
+	require.NoError(t, err)
+	require.True(t, charged)
+}
+
+func TestEnforcer(t *testing.T) {
+	payers := map[string]Payer{"shared": PayerRequester}
+	usage := NewUsage()
+	logger := zerolog.Nop()
+
+	enforcer := NewEnforcer(&logger,
+		func(_ context.Context, bucket string) (Payer, error) {
+			if bucket == "broken" {
+				return "", errors.New("store failure")
+			}
+
+			return payers[bucket], nil
+		},
+		func(r *http.Request, _ string) bool { return s3auth.AccessKey(r) == "OWNER" },
+		usage,
+	)
+
+	handler := enforcer.Middleware(s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, _ *s3router.Route) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte("content"))
+	}))
+
+	serve := func(bucket, key, accessKey string, acknowledge bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/"+bucket+"/"+key, strings.NewReader("body"))
+		r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=x")
+		if acknowledge {
+			r.Header.Set(HeaderRequestPayer, "requester")
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeAction(w, r, &s3router.Route{Action: s3router.ActionPutObject, Bucket: bucket, Key: key})
+
+		return w
+	}
+
+	w := serve("shared", "key", "READER", false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Contains(t, w.Body.String(), "<Code>AccessDenied</Code>")
+
+	w = serve("shared", "key", "READER", true)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "requester", w.Header().Get(HeaderRequestCharged))
+
+	w = serve("shared", "key", "OWNER", false)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get(HeaderRequestCharged))
+
+	w = serve("private", "key", "READER", false)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve("broken", "key", "READER", true)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	serve("shared", "other", "READER", true)
+
+	require.Equal(t, []UsageLine{{
+		Bucket:    "shared",
+		Requester: "READER",
+		Operation: "ActionPutObject",
+		Requests:  2,
+		BytesIn:   8,
+		BytesOut:  14,
+	}}, usage.Report())
+}
+
+func TestUsageRequesters(t *testing.T) {
+	usage := NewUsage()
+
+	for i := 0; i <= MaxRequesters; i++ {
+		usage.Record(Charge{Bucket: "shared", Requester: fmt.Sprintf("KEY%d", i), Action: s3router.ActionGetObject})
+	}
+
+	usage.Record(Charge{Bucket: "shared", Requester: "KEY0", Action: s3router.ActionGetObject})
+
+	report := usage.Report()
+	require.Len(t, report, MaxRequesters+1)
+	require.Equal(t, UsageLine{Bucket: "shared", Requester: "KEY0", Operation: "ActionGetObject", Requests: 2}, report[0])
+	require.Equal(t, UsageLine{Bucket: "shared", Requester: OtherRequesters, Operation: "ActionGetObject", Requests: 1}, report[len(report)-1])
+}
+
+func TestApplies(t *testing.T) {
+	require.True(t, Applies(&s3router.Route{Action: s3router.ActionGetObject, Bucket: "b", Key: "k"}))
+	require.True(t, Applies(&s3router.Route{Action: s3router.ActionListObjects, Bucket: "b"}))
+	require.False(t, Applies(&s3router.Route{Action: s3router.ActionGetBucketRequestPayment, Bucket: "b"}))
+}
+
+func TestEnforcerRouted(t *testing.T) {
+	logger := zerolog.Nop()
+	usage := NewUsage()
+	enforcer := NewEnforcer(&logger,
+		func(context.Context, string) (Payer, error) { return PayerRequester, nil },
+		func(*http.Request, string) bool { return false },
+		usage,
+	)
+
+	// The other object actions answer NotImplemented once the charges are
+	// acknowledged, errors not being charged.
+	handler := s3router.New(&logger, []string{"example.com"}, map[s3router.Action]s3router.ActionHandler{
+		s3router.ActionGetObject: s3router.ActionHandlerFunc(func(w http.ResponseWriter, _ *http.Request, _ *s3router.Route) {
+			_, _ = w.Write([]byte("content"))
+		}),
+	}, enforcer.Middleware)
+
+	serve := func(method, target string, acknowledge bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		if acknowledge {
+			r.Header.Set(HeaderRequestPayer, "requester")
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := serve(http.MethodGet, "/shared/key", false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Contains(t, w.Body.String(), "<Code>AccessDenied</Code>")
+	require.Contains(t, w.Body.String(), "<RequestId>"+w.Header().Get("x-amz-request-id")+"</RequestId>")
+
+	w = serve(http.MethodGet, "/shared/key", true)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "requester", w.Header().Get(HeaderRequestCharged))
+
+	w = serve(http.MethodPut, "/shared/key", true)
+	require.Equal(t, http.StatusNotImplemented, w.Code)
+	require.Empty(t, w.Header().Get(HeaderRequestCharged))
+
+	w = serve(http.MethodGet, "/shared?requestPayment", false)
+	require.Equal(t, http.StatusNotImplemented, w.Code)
+
+	expected := `
+# HELP s3impl_requester_pays_requests_total Requests charged to their requester.
+# TYPE s3impl_requester_pays_requests_total counter
+s3impl_requester_pays_requests_total{bucket="shared",operation="ActionGetObject",requester="anonymous"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(usage, strings.NewReader(expected), "s3impl_requester_pays_requests_total"))
+}
@@ -0,0 +1,154 @@
+package s3requestpayment
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// Charge is a request paid by its requester.
+type Charge struct {
+	Bucket    string
+	Requester string
+	Action    s3router.Action
+	// BytesIn and BytesOut are the transferred request and response bodies.
+	BytesIn  int64
+	BytesOut int64
+}
+
+// UsageLine is the aggregated usage of a requester for an operation.
+type UsageLine struct {
+	Bucket    string
+	Requester string
+	Operation string
+	Requests  int64
+	BytesIn   int64
+	BytesOut  int64
+}
+
+type usageKey struct {
+	bucket    string
+	requester string
+	action    s3router.Action
+}
+
+// MaxRequesters is the number of requesters accounted per bucket, the
+// charges of the following ones being accounted to OtherRequesters. The
+// requesters are the claimed access keys, which any client can vary while
+// signatures are not verified.
+const MaxRequesters = 100
+
+// OtherRequesters is the requester of the charges beyond MaxRequesters.
+const OtherRequesters = "other"
+
+// Usage accounts the charges of Requester Pays buckets. It is a Prometheus
+// collector exporting the usage lines as counters.
+type Usage struct {
+	mu         sync.Mutex
+	lines      map[usageKey]*UsageLine
+	requesters map[string]map[string]struct{}
+}
+
+func NewUsage() *Usage {
+	return &Usage{
+		lines:      make(map[usageKey]*UsageLine),
+		requesters: make(map[string]map[string]struct{}),
+	}
+}
+
+func (u *Usage) Record(charge Charge) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	requesters, exists := u.requesters[charge.Bucket]
+	if !exists {
+		requesters = make(map[string]struct{})
+		u.requesters[charge.Bucket] = requesters
+	}
+
+	if _, seen := requesters[charge.Requester]; !seen {
+		if len(requesters) >= MaxRequesters {
+			charge.Requester = OtherRequesters
+		} else {
+			requesters[charge.Requester] = struct{}{}
+		}
+	}
+
+	key := usageKey{bucket: charge.Bucket, requester: charge.Requester, action: charge.Action}
+
+	line, exists := u.lines[key]
+	if !exists {
+		line = &UsageLine{
+			Bucket:    charge.Bucket,
+			Requester: charge.Requester,
+			Operation: charge.Action.String(),
+		}
+		u.lines[key] = line
+	}
+
+	line.Requests++
+	line.BytesIn += charge.BytesIn
+	line.BytesOut += charge.BytesOut
+}
+
+// Report returns the usage sorted by bucket, requester and operation.
+func (u *Usage) Report() []UsageLine {
+	u.mu.Lock()
+	report := make([]UsageLine, 0, len(u.lines))
+	for _, line := range u.lines {
+		report = append(report, *line)
+	}
+	u.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+
+		if a.Requester != b.Requester {
+			return a.Requester < b.Requester
+		}
+
+		return a.Operation < b.Operation
+	})
+
+	return report
+}
+
+var (
+	usageLabels = []string{"bucket", "requester", "operation"}
+
+	chargedRequests = prometheus.NewDesc(
+		"s3impl_requester_pays_requests_total",
+		"Requests charged to their requester.",
+		usageLabels, nil,
+	)
+	chargedBytesIn = prometheus.NewDesc(
+		"s3impl_requester_pays_bytes_in_total",
+		"Bytes of the request bodies charged to their requester.",
+		usageLabels, nil,
+	)
+	chargedBytesOut = prometheus.NewDesc(
+		"s3impl_requester_pays_bytes_out_total",
+		"Bytes of the response bodies charged to their requester.",
+		usageLabels, nil,
+	)
+)
+
+func (u *Usage) Describe(descs chan<- *prometheus.Desc) {
+	descs <- chargedRequests
+	descs <- chargedBytesIn
+	descs <- chargedBytesOut
+}
+
+func (u *Usage) Collect(metrics chan<- prometheus.Metric) {
+	for _, line := range u.Report() {
+		metrics <- prometheus.MustNewConstMetric(chargedRequests, prometheus.CounterValue, float64(line.Requests), line.Bucket, line.Requester, line.Operation)
+		metrics <- prometheus.MustNewConstMetric(chargedBytesIn, prometheus.CounterValue, float64(line.BytesIn), line.Bucket, line.Requester, line.Operation)
+		metrics <- prometheus.MustNewConstMetric(chargedBytesOut, prometheus.CounterValue, float64(line.BytesOut), line.Bucket, line.Requester, line.Operation)
+	}
+}
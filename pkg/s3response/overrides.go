@@ -0,0 +1,44 @@
+package s3response
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// overrides maps the GetObject query parameters to the response header
+// they replace.
+var overrides = []struct {
+	Query  string
+	Header string
+}{
+	{"response-cache-control", "Cache-Control"},
+	{"response-content-disposition", "Content-Disposition"},
+	{"response-content-encoding", "Content-Encoding"},
+	{"response-content-language", "Content-Language"},
+	{"response-content-type", "Content-Type"},
+	{"response-expires", "Expires"},
+}
+
+// Overrides holds the response headers a GetObject request asked to
+// replace, keyed by canonical header name.
+type Overrides map[string]string
+
+func ParseOverrides(query url.Values) Overrides {
+	result := make(Overrides)
+
+	for _, override := range overrides {
+		if query.Has(override.Query) {
+			result[override.Header] = query.Get(override.Query)
+		}
+	}
+
+	return result
+}
+
+// Apply replaces the headers stored with the object. It must be called
+// after they have been set and before the response is written.
+func (o Overrides) Apply(header http.Header) {
+	for name, value := range o {
+		header.Set(name, value)
+	}
+}
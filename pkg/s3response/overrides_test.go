@@ -0,0 +1,35 @@
+package s3response
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverrides(t *testing.T) {
+	query, err := url.ParseQuery(
+		"response-content-disposition=attachment%3B%20filename%3D%22report.csv%22" +
+			"&response-content-type=text%2Fcsv" +
+			"&response-expires=Thu%2C%2001%20Dec%202023%2016%3A00%3A00%20GMT" +
+			"&response-cache-control=" +
+			"&versionId=123",
+	)
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Language", "en")
+	header.Set("Cache-Control", "max-age=3600")
+
+	ParseOverrides(query).Apply(header)
+
+	require.Equal(t, http.Header{
+		"Cache-Control":       {""},
+		"Content-Disposition": {`attachment; filename="report.csv"`},
+		"Content-Language":    {"en"},
+		"Content-Type":        {"text/csv"},
+		"Expires":             {"Thu, 01 Dec 2023 16:00:00 GMT"},
+	}, header)
+}
@@ -0,0 +1,93 @@
+package s3response
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Recorder is a response writer keeping track of the response for the
+// instrumentation of the requests.
+type Recorder struct {
+	http.ResponseWriter
+
+	// StatusCode is zero until the response starts at FirstByte.
+	StatusCode int
+	FirstByte  time.Time
+	// ErrorCode is the S3 error code of the response, if any.
+	ErrorCode string
+	Written   int64
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+// Status returns the status of the response, http.StatusOK when the
+// handler wrote nothing.
+func (r *Recorder) Status() int {
+	if r.StatusCode == 0 {
+		return http.StatusOK
+	}
+
+	return r.StatusCode
+}
+
+func (r *Recorder) RecordCode(code string) {
+	r.ErrorCode = code
+}
+
+func (r *Recorder) WriteHeader(statusCode int) {
+	if r.StatusCode == 0 {
+		r.StatusCode = statusCode
+		r.FirstByte = time.Now()
+	}
+
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	if r.StatusCode == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	n, err := r.ResponseWriter.Write(p)
+	r.Written += int64(n)
+
+	return n, err
+}
+
+func (r *Recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// CountingReader counts the bytes read from a request body.
+type CountingReader struct {
+	body io.ReadCloser
+	N    int64
+}
+
+// CountBody replaces the body of r with a CountingReader.
+func CountBody(r *http.Request) *CountingReader {
+	body := &CountingReader{body: r.Body}
+	r.Body = body
+
+	return body
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	c.N += int64(n)
+
+	return n, err
+}
+
+func (c *CountingReader) Close() error {
+	return c.body.Close()
+}
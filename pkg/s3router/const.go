@@ -58,8 +58,10 @@ const (
 	ActionGetObjectTagging
 	ActionGetObjectTorrent
 	ActionGetPublicAccessBlock
+	ActionGetWebsiteObject
 	ActionHeadBucket
 	ActionHeadObject
+	ActionHeadWebsiteObject
 	ActionListBucketAnalyticsConfigurations
 	ActionListBucketIntelligentTieringConfigurations
 	ActionListBucketInventoryConfigurations
@@ -69,6 +71,7 @@ const (
 	ActionListObjects
 	ActionListObjectVersions
 	ActionListParts
+	ActionListenBucketNotification
 	ActionPutBucketAccelerateConfiguration
 	ActionPutBucketACL
 	ActionPutBucketAnalyticsConfiguration
@@ -107,6 +110,7 @@ const (
 	RequestStyleVHost
 	RequestStyleVPath
 	RequestStyleCName
+	RequestStyleWebsite
 )
 
 type Route struct {
@@ -7,46 +7,127 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3trace"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func New(logger *zerolog.Logger, hosts []string) http.Handler {
-	return &handler{
-		logger: logger,
-		hosts:  hosts,
+var tracer = otel.Tracer("github.com/lvjp/s3impl/pkg/s3router")
+
+// ActionHandler serves the requests routed to an action.
+type ActionHandler interface {
+	ServeAction(w http.ResponseWriter, r *http.Request, route *Route)
+}
+
+type ActionHandlerFunc func(w http.ResponseWriter, r *http.Request, route *Route)
+
+func (f ActionHandlerFunc) ServeAction(w http.ResponseWriter, r *http.Request, route *Route) {
+	f(w, r, route)
+}
+
+// Middleware wraps the handling of every request, including those answered
+// with an error by the router whose route Action is ActionUnknow.
+type Middleware func(ActionHandler) ActionHandler
+
+// HostID sets the x-amz-id-2 header identifying the node on every response.
+func HostID(id string) Middleware {
+	return func(next ActionHandler) ActionHandler {
+		return ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *Route) {
+			w.Header().Set(s3errors.HeaderHostID, id)
+			next.ServeAction(w, r, route)
+		})
 	}
 }
 
+// New returns the S3 API handler. Actions without an handler answer
+// NotImplemented. The first middleware is the outermost one.
+func New(logger *zerolog.Logger, hosts []string, actions map[Action]ActionHandler, middlewares ...Middleware) http.Handler {
+	h := &handler{
+		logger:      logger,
+		hosts:       hosts,
+		actions:     make(map[Action]ActionHandler, len(actions)),
+		middlewares: middlewares,
+	}
+
+	for action, handler := range actions {
+		h.actions[action] = h.wrap(handler)
+	}
+
+	h.notImplemented = h.wrap(ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, _ *Route) {
+		s3err := s3errors.NotImplemented.New()
+		s3err.RequestID = w.Header().Get("x-amz-request-id")
+		h.writeError(w, r, s3err.WithResource(r.URL.String()))
+	}))
+
+	return h
+}
+
 type handler struct {
-	logger *zerolog.Logger
-	hosts  []string
+	logger         *zerolog.Logger
+	hosts          []string
+	actions        map[Action]ActionHandler
+	middlewares    []Middleware
+	notImplemented ActionHandler
+}
+
+func (h *handler) wrap(handler ActionHandler) ActionHandler {
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		handler = h.middlewares[i](handler)
+	}
+
+	return handler
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	requestID := uuid.NewString()
+	w.Header().Set("x-amz-request-id", requestID)
 
-	resp := &s3errors.S3Error{
-		HTTPStatusCode: http.StatusNotImplemented,
-		Code:           "NotImplemented",
-		Message:        "A header that you provided implies functionality that is not implemented.",
-		RequestID:      requestID,
-		Resource:       r.URL.String(),
-	}
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(s3trace.RequestID.String(requestID))
 
+	_, routing := tracer.Start(r.Context(), "DetermineRoute")
 	route, err := DetermineRoute(r, h.hosts)
+	s3trace.End(routing, err)
+
 	if err != nil {
-		resp.HTTPStatusCode = http.StatusBadRequest
-		resp.Code = "Badrequest"
-		resp.Message = err.Error()
-	} else {
-		h.logger.Trace().
-			Interface("route", route).
-			Msg("Route determinated")
+		var s3err *s3errors.S3Error
+		if !errors.As(err, &s3err) {
+			h.logger.Error().Err(err).Msg("Cannot determine route")
+			s3err = s3errors.InternalError.New()
+		}
+
+		h.wrap(ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, _ *Route) {
+			s3err.RequestID = w.Header().Get("x-amz-request-id")
+			h.writeError(w, r, s3err.WithResource(r.URL.String()))
+		})).ServeAction(w, r, &Route{})
+
+		return
 	}
 
+	h.logger.Trace().
+		Interface("route", route).
+		Msg("Route determinated")
+
+	span.SetName(route.Action.String())
+	span.SetAttributes(
+		s3trace.Action.String(route.Action.String()),
+		s3trace.Bucket.String(route.Bucket),
+		s3trace.Key.String(route.Key),
+	)
+
+	if action, exists := h.actions[route.Action]; exists {
+		action.ServeAction(w, r, route)
+		return
+	}
+
+	h.notImplemented.ServeAction(w, r, route)
+}
+
+func (h *handler) writeError(w http.ResponseWriter, r *http.Request, resp *s3errors.S3Error) {
 	writer := s3errors.APIWriter{}
 
-	if err := writer.Write(resp, w); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+	if err := writer.Write(resp, w, r); err != nil && !errors.Is(err, io.ErrClosedPipe) {
 		h.logger.Warn().Err(err).Msg("Cannot write response")
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 type routeSelector func(Route, url.Values, http.Header) Action
@@ -44,6 +45,13 @@ func init() {
 		if _, exists := tree[""]; !exists {
 			panic(fmt.Sprintf("Routes tree for %s do not have default route", name))
 		}
+
+		// GetObject response header overrides must never select a route.
+		for subresource := range tree {
+			if strings.HasPrefix(subresource, "response-") {
+				panic(fmt.Sprintf("Routes tree for %s use reserved subresource %q", name, subresource))
+			}
+		}
 	}
 }
 
@@ -86,6 +94,9 @@ var routesForbucket = routesTree{
 		http.MethodGet:    staticRoute(ActionGetBucketEncryption),
 		http.MethodPut:    staticRoute(ActionPutBucketEncryption),
 	},
+	"events": {
+		http.MethodGet: staticRoute(ActionListenBucketNotification),
+	},
 	"intelligent-tiering": {
 		http.MethodDelete: staticRoute(ActionDeleteBucketIntelligentTieringConfiguration),
 		http.MethodGet:    conditionalQueryRoute("id", ActionGetBucketIntelligentTieringConfiguration, ActionListBucketIntelligentTieringConfigurations),
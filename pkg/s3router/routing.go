@@ -1,14 +1,17 @@
 package s3router
 
 import (
-	"errors"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 
+	"github.com/lvjp/s3impl/pkg/s3errors"
 	"github.com/lvjp/s3impl/pkg/utils"
 )
 
+// DetermineRoute resolves the action of a request. The returned errors are
+// *s3errors.S3Error.
 func DetermineRoute(r *http.Request, acceptedHosts []string) (*Route, error) {
 	d := derminator{
 		Request:       r,
@@ -16,7 +19,16 @@ func DetermineRoute(r *http.Request, acceptedHosts []string) (*Route, error) {
 	}
 	d.hostname()
 
-	if !d.detectVPath(r) && !d.detectVHost(r) {
+	switch {
+	case d.detectVPath(r):
+	case d.detectWebsite(r):
+		if err := d.websiteAction(); err != nil {
+			return nil, err
+		}
+
+		return &d.Route, nil
+	case d.detectVHost(r):
+	default:
 		d.fillCName(r)
 	}
 
@@ -77,6 +89,44 @@ func (d *derminator) detectVHost(r *http.Request) bool {
 	return false
 }
 
+// websiteLabel separates the bucket from the accepted host in website
+// endpoints, like bucket.s3-website.example.com.
+const websiteLabel = ".s3-website."
+
+func (d *derminator) detectWebsite(r *http.Request) bool {
+	for _, host := range d.AcceptedHosts {
+		bucket, cut := strings.CutSuffix(d.Route.Hostname, websiteLabel+host)
+		if !cut || bucket == "" {
+			continue
+		}
+
+		d.Route.Style = RequestStyleWebsite
+		d.Route.BaseHost = host
+		d.Route.Bucket = bucket
+		d.Route.Key = strings.TrimPrefix(r.URL.Path, "/")
+
+		return true
+	}
+
+	return false
+}
+
+// websiteAction maps website endpoint requests, which only serve objects
+// and ignore subresources. They have their own actions, their responses
+// following the website configuration of the bucket.
+func (d *derminator) websiteAction() error {
+	switch d.Request.Method {
+	case http.MethodGet:
+		d.Route.Action = ActionGetWebsiteObject
+	case http.MethodHead:
+		d.Route.Action = ActionHeadWebsiteObject
+	default:
+		return d.methodNotAllowed("OBJECT")
+	}
+
+	return nil
+}
+
 func (d *derminator) fillCName(r *http.Request) {
 	d.Route.Style = RequestStyleCName
 	d.Route.BaseHost = d.Route.Hostname
@@ -113,17 +163,37 @@ func (d *derminator) action() error {
 	case 1:
 		routeSelectorMap = routesTree[subresources[0]]
 	default:
-		return errors.New(
-			"Conflicting query string parameters: " +
-				strings.Join(subresources, ", "),
-		)
+		sort.Strings(subresources)
+
+		return s3errors.InvalidArgument.New().
+			WithMessage("Conflicting query string parameters: "+strings.Join(subresources, ", ")).
+			WithArgument("ResourceType", subresources[0])
 	}
 
 	selector, exists := routeSelectorMap[d.Request.Method]
 	if !exists {
-		return errors.New("Method not allowed: " + d.Request.Method)
+		return d.methodNotAllowed(d.resourceType())
 	}
 
 	d.Route.Action = selector(d.Route, queries, d.Request.Header)
 	return nil
 }
+
+// resourceType names the kind of resource addressed by the request, as
+// reported by MethodNotAllowed errors.
+func (d *derminator) resourceType() string {
+	switch {
+	case d.Route.Key != "":
+		return "OBJECT"
+	case d.Route.Bucket != "":
+		return "BUCKET"
+	default:
+		return "SERVICE"
+	}
+}
+
+func (d *derminator) methodNotAllowed(resourceType string) *s3errors.S3Error {
+	return s3errors.MethodNotAllowed.New().
+		With("Method", d.Request.Method).
+		With("ResourceType", resourceType)
+}
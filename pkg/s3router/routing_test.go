@@ -7,11 +7,13 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/google/uuid"
+	"github.com/lvjp/s3impl/pkg/s3errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -561,6 +563,39 @@ var testCases = []struct {
 		},
 		expected: ActionGetObject,
 	},
+	{
+		fn: func(client *s3.Client) error {
+			_, err := client.GetObject(
+				context.TODO(),
+				&s3.GetObjectInput{
+					Bucket:     dummy(),
+					Key:        dummy(),
+					PartNumber: aws.Int32(1),
+				},
+			)
+			return err
+		},
+		expected: ActionGetObject,
+	},
+	{
+		fn: func(client *s3.Client) error {
+			_, err := client.GetObject(
+				context.TODO(),
+				&s3.GetObjectInput{
+					Bucket:                     dummy(),
+					Key:                        dummy(),
+					ResponseCacheControl:       dummy(),
+					ResponseContentDisposition: aws.String("attachment"),
+					ResponseContentEncoding:    dummy(),
+					ResponseContentLanguage:    dummy(),
+					ResponseContentType:        dummy(),
+					ResponseExpires:            aws.Time(time.Now()),
+				},
+			)
+			return err
+		},
+		expected: ActionGetObject,
+	},
 	{
 		fn: func(client *s3.Client) error {
 			_, err := client.GetObjectAcl(
@@ -1300,3 +1335,95 @@ func TestDetermineAction(t *testing.T) {
 		})
 	}
 }
+
+func TestDetermineActionExtensions(t *testing.T) {
+	host := "s3.local-dev.example.com"
+
+	for _, tc := range []struct {
+		method   string
+		url      string
+		expected Action
+	}{
+		{http.MethodGet, "http://" + host + "/bucket?events", ActionListenBucketNotification},
+		{http.MethodGet, "http://bucket." + host + "/?events&prefix=images/", ActionListenBucketNotification},
+		{http.MethodGet, "http://bucket.s3-website." + host + "/", ActionGetWebsiteObject},
+		{http.MethodGet, "http://bucket.s3-website." + host + "/docs/?acl", ActionGetWebsiteObject},
+		{http.MethodHead, "http://bucket.s3-website." + host + "/index.html", ActionHeadWebsiteObject},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			r, err := http.NewRequest(tc.method, tc.url, http.NoBody)
+			require.NoError(t, err)
+
+			route, err := DetermineRoute(r, []string{host})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, route.Action)
+		})
+	}
+}
+
+func TestDetermineRouteWebsite(t *testing.T) {
+	host := "s3.local-dev.example.com"
+
+	r, err := http.NewRequest(http.MethodGet, "http://my.bucket.s3-website."+host+"/docs/", http.NoBody)
+	require.NoError(t, err)
+
+	route, err := DetermineRoute(r, []string{host})
+	require.NoError(t, err)
+	require.Equal(t, &Route{
+		Action:   ActionGetWebsiteObject,
+		Hostname: "my.bucket.s3-website." + host,
+		BaseHost: host,
+		Style:    RequestStyleWebsite,
+		Bucket:   "my.bucket",
+		Key:      "docs/",
+	}, route)
+
+	r, err = http.NewRequest(http.MethodPut, "http://bucket.s3-website."+host+"/index.html", http.NoBody)
+	require.NoError(t, err)
+
+	_, err = DetermineRoute(r, []string{host})
+	requireError(t, err, "MethodNotAllowed", http.StatusMethodNotAllowed)
+}
+
+func requireError(t *testing.T, err error, code string, status int) *s3errors.S3Error {
+	t.Helper()
+
+	var s3err *s3errors.S3Error
+	require.ErrorAs(t, err, &s3err)
+	require.Equal(t, code, s3err.Code)
+	require.Equal(t, status, s3err.HTTPStatusCode)
+
+	return s3err
+}
+
+func TestDetermineRouteErrors(t *testing.T) {
+	host := "s3.local-dev.example.com"
+
+	r, err := http.NewRequest(http.MethodPatch, "http://"+host+"/bucket/key", http.NoBody)
+	require.NoError(t, err)
+
+	_, err = DetermineRoute(r, []string{host})
+	s3err := requireError(t, err, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	require.Equal(t, []s3errors.Field{
+		{Name: "Method", Value: http.MethodPatch},
+		{Name: "ResourceType", Value: "OBJECT"},
+	}, s3err.Extra)
+
+	r, err = http.NewRequest(http.MethodGet, "http://"+host+"/bucket?acl&tagging", http.NoBody)
+	require.NoError(t, err)
+
+	_, err = DetermineRoute(r, []string{host})
+	s3err = requireError(t, err, "InvalidArgument", http.StatusBadRequest)
+	require.Contains(t, s3err.Message, "Conflicting query string parameters")
+
+	// The conflicting parameters are reported in a stable order.
+	for i := 0; i < 20; i++ {
+		r, err = http.NewRequest(http.MethodGet, "http://"+host+"/bucket?tagging&acl", http.NoBody)
+		require.NoError(t, err)
+
+		_, err = DetermineRoute(r, []string{host})
+		s3err = requireError(t, err, "InvalidArgument", http.StatusBadRequest)
+		require.Equal(t, "Conflicting query string parameters: acl, tagging", s3err.Message)
+		require.Contains(t, s3err.Extra, s3errors.Field{Name: "ArgumentValue", Value: "acl"})
+	}
+}
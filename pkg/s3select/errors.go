@@ -0,0 +1,39 @@
+package s3select
+
+import (
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+func missingRequiredParameter(name string) *s3errors.S3Error {
+	return s3errors.MissingRequiredParameter.New().
+		WithMessage("The SelectRequest entity is missing a required parameter: " + name)
+}
+
+func parseError(code s3errors.ErrorCode, message string) *s3errors.S3Error {
+	return code.New().WithMessage(message)
+}
+
+// evaluationError is reported in the event stream, the HTTP status having
+// already been sent when records are evaluated.
+type evaluationError struct {
+	Code    string
+	Message string
+}
+
+func (e *evaluationError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func castFailed(value Value, typ string) error {
+	return &evaluationError{
+		Code:    s3errors.CastFailed.Code,
+		Message: "Attempt to convert from one data type to another using CAST failed in the SQL expression: cannot cast " + formatValue(value) + " to " + typ,
+	}
+}
+
+func invalidOperand(message string) error {
+	return &evaluationError{
+		Code:    s3errors.EvaluatorInvalidArguments.Code,
+		Message: message,
+	}
+}
@@ -0,0 +1,140 @@
+package s3select
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+// Event types of the SelectObjectContent response stream.
+const (
+	EventRecords  = "Records"
+	EventStats    = "Stats"
+	EventProgress = "Progress"
+	EventCont     = "Cont"
+	EventEnd      = "End"
+)
+
+// header is a string typed header of an event stream message.
+type header struct {
+	Name  string
+	Value string
+}
+
+const (
+	headerTypeString = 7
+	preludeLength    = 12
+	checksumLength   = 4
+)
+
+// encodeMessage encodes a message in the AWS binary event stream format:
+// a prelude with the lengths and its CRC, the headers, the payload and the
+// CRC of the whole message.
+func encodeMessage(headers []header, payload []byte) []byte {
+	var encodedHeaders []byte
+	for _, h := range headers {
+		encodedHeaders = append(encodedHeaders, byte(len(h.Name)))
+		encodedHeaders = append(encodedHeaders, h.Name...)
+		encodedHeaders = append(encodedHeaders, headerTypeString)
+		encodedHeaders = binary.BigEndian.AppendUint16(encodedHeaders, uint16(len(h.Value)))
+		encodedHeaders = append(encodedHeaders, h.Value...)
+	}
+
+	total := preludeLength + len(encodedHeaders) + len(payload) + checksumLength
+
+	message := make([]byte, 0, total)
+	message = binary.BigEndian.AppendUint32(message, uint32(total))
+	message = binary.BigEndian.AppendUint32(message, uint32(len(encodedHeaders)))
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+	message = append(message, encodedHeaders...)
+	message = append(message, payload...)
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+
+	return message
+}
+
+// eventWriter writes the messages of a SelectObjectContent response.
+type eventWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *eventWriter) event(eventType, contentType string, payload []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	headers := []header{
+		{":event-type", eventType},
+	}
+	if contentType != "" {
+		headers = append(headers, header{":content-type", contentType})
+	}
+	headers = append(headers, header{":message-type", "event"})
+
+	if _, err := e.w.Write(encodeMessage(headers, payload)); err != nil {
+		e.err = fmt.Errorf("s3select: cannot write event: %w", err)
+	}
+
+	if flusher, ok := e.w.(interface{ Flush() }); ok {
+		flusher.Flush()
+	}
+
+	return e.err
+}
+
+func (e *eventWriter) records(payload []byte) error {
+	return e.event(EventRecords, "application/octet-stream", payload)
+}
+
+type counters struct {
+	BytesScanned   int64
+	BytesProcessed int64
+	BytesReturned  int64
+}
+
+func (c counters) xml(element string) []byte {
+	return []byte("<" + element + ">" +
+		"<BytesScanned>" + strconv.FormatInt(c.BytesScanned, 10) + "</BytesScanned>" +
+		"<BytesProcessed>" + strconv.FormatInt(c.BytesProcessed, 10) + "</BytesProcessed>" +
+		"<BytesReturned>" + strconv.FormatInt(c.BytesReturned, 10) + "</BytesReturned>" +
+		"</" + element + ">")
+}
+
+func (e *eventWriter) stats(c counters) error {
+	return e.event(EventStats, "text/xml", c.xml(EventStats))
+}
+
+func (e *eventWriter) progress(c counters) error {
+	return e.event(EventProgress, "text/xml", c.xml(EventProgress))
+}
+
+func (e *eventWriter) cont() error {
+	return e.event(EventCont, "", nil)
+}
+
+func (e *eventWriter) end() error {
+	return e.event(EventEnd, "", nil)
+}
+
+// error reports a failure once the response has started, which can only
+// be done in the stream itself.
+func (e *eventWriter) error(code, message string) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	headers := []header{
+		{":error-code", code},
+		{":error-message", message},
+		{":message-type", "error"},
+	}
+
+	if _, err := e.w.Write(encodeMessage(headers, nil)); err != nil {
+		e.err = fmt.Errorf("s3select: cannot write error: %w", err)
+	}
+
+	return e.err
+}
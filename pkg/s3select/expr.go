@@ -0,0 +1,462 @@
+package s3select
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+// env is the evaluation context of an expression.
+type env struct {
+	record     record
+	aggregates []aggregateState
+}
+
+type expr interface {
+	eval(env *env) (Value, error)
+}
+
+type literal struct {
+	value Value
+}
+
+func (l *literal) eval(*env) (Value, error) {
+	return l.value, nil
+}
+
+type pathElement struct {
+	name    string
+	quoted  bool
+	index   int
+	isIndex bool
+}
+
+// matches reports whether the element designates the given name: quoted
+// names are case sensitive, the others are not.
+func (e pathElement) matches(name string) bool {
+	if e.quoted {
+		return e.name == name
+	}
+
+	return strings.EqualFold(e.name, name)
+}
+
+type reference struct {
+	query *Query
+	path  []pathElement
+}
+
+func (r *reference) eval(env *env) (Value, error) {
+	path := r.path
+	if !path[0].isIndex && path[0].matches(r.query.alias) {
+		path = path[1:]
+	}
+
+	return env.record.field(path), nil
+}
+
+type logical struct {
+	op          string
+	left, right expr
+}
+
+// eval implements the three-valued logic of SQL, nil standing for unknown.
+func (l *logical) eval(env *env) (Value, error) {
+	left, err := l.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	lb, lok := left.(bool)
+	if lok && lb == (l.op == "OR") {
+		return lb, nil
+	}
+
+	right, err := l.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	rb, rok := right.(bool)
+	switch {
+	case rok && rb == (l.op == "OR"):
+		return rb, nil
+	case lok && rok:
+		return rb, nil
+	default:
+		return nil, nil //nolint:nilnil // unknown
+	}
+}
+
+type not struct {
+	operand expr
+}
+
+func (n *not) eval(env *env) (Value, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, ok := v.(bool); ok {
+		return !b, nil
+	}
+
+	return nil, nil //nolint:nilnil // unknown
+}
+
+type comparison struct {
+	op          string
+	left, right expr
+}
+
+func (c *comparison) eval(env *env) (Value, error) {
+	left, err := c.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := c.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	cmp, ok := compare(left, right)
+	if !ok {
+		if left == nil || right == nil {
+			return nil, nil //nolint:nilnil // unknown
+		}
+
+		return c.op == "!=" || c.op == "<>", nil
+	}
+
+	switch c.op {
+	case "=":
+		return cmp == 0, nil
+	case "!=", "<>":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type isNull struct {
+	operand expr
+	negate  bool
+}
+
+func (n *isNull) eval(env *env) (Value, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return (v == nil) != n.negate, nil
+}
+
+type like struct {
+	operand, pattern expr
+	escape           rune
+	negate           bool
+	compiled         *regexp.Regexp
+}
+
+func (l *like) eval(env *env) (Value, error) {
+	v, err := l.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, nil //nolint:nilnil // unknown
+	}
+
+	re := l.compiled
+	if re == nil {
+		pattern, err := l.pattern.eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		p, ok := pattern.(string)
+		if !ok {
+			return nil, nil //nolint:nilnil // unknown
+		}
+
+		re = compileLike(p, l.escape)
+	}
+
+	return re.MatchString(s) != l.negate, nil
+}
+
+type in struct {
+	operand expr
+	list    []expr
+	negate  bool
+}
+
+func (i *in) eval(env *env) (Value, error) {
+	v, err := i.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range i.list {
+		candidate, err := e.eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		if cmp, ok := compare(v, candidate); ok && cmp == 0 {
+			return !i.negate, nil
+		}
+	}
+
+	return i.negate, nil
+}
+
+type arithmetic struct {
+	op          string
+	left, right expr
+}
+
+func (a *arithmetic) eval(env *env) (Value, error) {
+	left, err := a.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := a.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if left == nil || right == nil {
+		return nil, nil //nolint:nilnil // NULL propagates
+	}
+
+	if a.op == "||" {
+		return formatValue(left) + formatValue(right), nil
+	}
+
+	ln, lok := number(left)
+	rn, rok := number(right)
+	if !lok || !rok {
+		return nil, invalidOperand("Arithmetic operator " + a.op + " requires numeric operands")
+	}
+
+	li, lInt := ln.(int64)
+	ri, rInt := rn.(int64)
+	if lInt && rInt {
+		return integerArithmetic(a.op, li, ri)
+	}
+
+	lf, rf := toFloat(ln), toFloat(rn)
+	switch a.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, divisionByZero()
+		}
+
+		return lf / rf, nil
+	default:
+		return nil, invalidOperand("Operator % requires integer operands")
+	}
+}
+
+func integerArithmetic(op string, l, r int64) (Value, error) {
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	}
+
+	if r == 0 {
+		return nil, divisionByZero()
+	}
+
+	if op == "/" {
+		return l / r, nil
+	}
+
+	return l % r, nil
+}
+
+type castExpr struct {
+	operand expr
+	typ     string
+}
+
+func (c *castExpr) eval(env *env) (Value, error) {
+	v, err := c.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return cast(v, c.typ)
+}
+
+type function struct {
+	minArgs, maxArgs int
+	eval             func(args []Value) (Value, error)
+}
+
+var functions = map[string]function{
+	"LOWER":            {1, 1, stringFunction(strings.ToLower)},
+	"UPPER":            {1, 1, stringFunction(strings.ToUpper)},
+	"TRIM":             {1, 1, stringFunction(strings.TrimSpace)},
+	"CHAR_LENGTH":      {1, 1, charLength},
+	"CHARACTER_LENGTH": {1, 1, charLength},
+	"COALESCE":         {1, -1, coalesce},
+}
+
+func stringFunction(fn func(string) string) func([]Value) (Value, error) {
+	return func(args []Value) (Value, error) {
+		if args[0] == nil {
+			return nil, nil //nolint:nilnil // NULL propagates
+		}
+
+		return fn(formatValue(args[0])), nil
+	}
+}
+
+func charLength(args []Value) (Value, error) {
+	if args[0] == nil {
+		return nil, nil //nolint:nilnil // NULL propagates
+	}
+
+	return int64(utf8.RuneCountInString(formatValue(args[0]))), nil
+}
+
+func coalesce(args []Value) (Value, error) {
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil // all arguments are NULL
+}
+
+type call struct {
+	name string
+	fn   func(args []Value) (Value, error)
+	args []expr
+}
+
+func (c *call) eval(env *env) (Value, error) {
+	args := make([]Value, len(c.args))
+	for i, arg := range c.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = v
+	}
+
+	return c.fn(args)
+}
+
+type aggregate struct {
+	fn      string
+	operand expr
+	index   int
+}
+
+type aggregateState struct {
+	count int64
+	sum   Value
+	best  Value
+}
+
+// accumulate adds the current record to the aggregate state.
+func (a *aggregate) accumulate(env *env) error {
+	state := &env.aggregates[a.index]
+
+	if a.operand == nil {
+		state.count++
+		return nil
+	}
+
+	v, err := a.operand.eval(env)
+	if err != nil || v == nil {
+		return err
+	}
+
+	switch a.fn {
+	case "COUNT":
+	case "SUM", "AVG":
+		n, ok := number(v)
+		if !ok {
+			return invalidOperand(a.fn + " requires numeric values")
+		}
+
+		if state.sum == nil {
+			state.sum = n
+		} else if state.sum, err = integerOrFloat("+", state.sum, n); err != nil {
+			return err
+		}
+	default:
+		if n, ok := number(v); ok {
+			v = n
+		}
+
+		cmp, ok := compare(v, state.best)
+		if state.best == nil || ok && (cmp < 0) == (a.fn == "MIN") && cmp != 0 {
+			state.best = v
+		}
+	}
+
+	state.count++
+
+	return nil
+}
+
+func integerOrFloat(op string, l, r Value) (Value, error) {
+	return (&arithmetic{op: op, left: &literal{value: l}, right: &literal{value: r}}).eval(nil)
+}
+
+func (a *aggregate) eval(env *env) (Value, error) {
+	state := env.aggregates[a.index]
+
+	switch a.fn {
+	case "COUNT":
+		return state.count, nil
+	case "SUM":
+		return state.sum, nil
+	case "AVG":
+		if state.count == 0 {
+			return nil, nil //nolint:nilnil // AVG of no value is NULL
+		}
+
+		return toFloat(state.sum) / float64(state.count), nil
+	default:
+		return state.best, nil
+	}
+}
+
+func divisionByZero() error {
+	return &evaluationError{
+		Code:    s3errors.DivisionByZero.Code,
+		Message: "Division by zero is not allowed.",
+	}
+}
@@ -0,0 +1,275 @@
+package s3select
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+// recordReader iterates over the records of the queried object, returning
+// io.EOF after the last one.
+type recordReader interface {
+	next() (record, error)
+}
+
+// countingReader counts the bytes read through it, for the Stats and
+// Progress events.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+
+	return n, err
+}
+
+// rangeReader returns the lines of a scan range: the lines starting within
+// [start, end], the last one being read up to its end even past end.
+type rangeReader struct {
+	r       *bufio.Reader
+	delim   byte
+	start   int64
+	offset  int64
+	end     int64
+	pending []byte
+	done    bool
+}
+
+func newRangeReader(object io.ReaderAt, size int64, scan *ScanRange, delim byte) (*rangeReader, error) {
+	start, end := int64(0), size-1
+
+	switch {
+	case scan.Start != nil:
+		start = *scan.Start
+		if scan.End != nil && *scan.End < end {
+			end = *scan.End
+		}
+	case scan.End != nil:
+		start = max(size-*scan.End, 0)
+	}
+
+	r := &rangeReader{delim: delim, start: start, offset: start, end: end}
+	if start >= size {
+		r.done = true
+		return r, nil
+	}
+
+	if start == 0 {
+		r.r = bufio.NewReader(io.NewSectionReader(object, 0, size))
+		return r, nil
+	}
+
+	// A line starting exactly at start is preceded by a delimiter; any
+	// other partial line belongs to the previous range.
+	r.r = bufio.NewReader(io.NewSectionReader(object, start-1, size-start+1))
+
+	skipped, err := r.r.ReadBytes(delim)
+	r.offset += int64(len(skipped)) - 1
+
+	if errors.Is(err, io.EOF) {
+		r.done = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done || r.offset > r.end {
+			return 0, io.EOF
+		}
+
+		line, err := r.r.ReadBytes(r.delim)
+		r.offset += int64(len(line))
+		r.pending = line
+
+		if errors.Is(err, io.EOF) {
+			r.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
+
+// firstLine reads the first line of the object, the CSV header of a scan
+// range starting after it.
+func firstLine(object io.ReaderAt, size int64, delim byte) ([]byte, error) {
+	line, err := bufio.NewReader(io.NewSectionReader(object, 0, size)).ReadBytes(delim)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+func decompress(r io.Reader, compression string) (io.Reader, error) {
+	switch compression {
+	case CompressionGZIP:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, &evaluationError{Code: s3errors.InvalidCompressionFormat.Code, Message: "The object is not a valid GZIP stream: " + err.Error()}
+		}
+
+		return gz, nil
+	case CompressionBZIP2:
+		return bzip2.NewReader(r), nil
+	default:
+		return r, nil
+	}
+}
+
+// delimiterReader rewrites a custom record delimiter into a newline, the
+// only delimiter encoding/csv understands.
+type delimiterReader struct {
+	r     io.Reader
+	delim byte
+}
+
+func (d delimiterReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	for i := range p[:n] {
+		if p[i] == d.delim {
+			p[i] = '\n'
+		}
+	}
+
+	return n, err
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	names  []string
+	skip   bool
+}
+
+func newCSVReader(r io.Reader, in *CSVInput, header []string) *csvReader {
+	if in.RecordDelimiter != "\n" && in.RecordDelimiter != "\r\n" {
+		r = delimiterReader{r: r, delim: in.RecordDelimiter[0]}
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = []rune(in.FieldDelimiter)[0]
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	if in.Comments != "" {
+		reader.Comment = []rune(in.Comments)[0]
+	}
+
+	c := &csvReader{reader: reader, names: header}
+	if header == nil {
+		c.skip = in.FileHeaderInfo != FileHeaderNone
+	}
+
+	if in.FileHeaderInfo != FileHeaderUse {
+		c.names = nil
+	}
+
+	return c
+}
+
+func (c *csvReader) next() (record, error) {
+	for {
+		fields, err := c.reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, &evaluationError{Code: s3errors.CSVParsingError.Code, Message: "Encountered an error parsing the CSV file: " + err.Error()}
+		}
+
+		if c.skip {
+			c.skip = false
+			c.names = fields
+			continue
+		}
+
+		return &csvRecord{fields: fields, names: c.names}, nil
+	}
+}
+
+type jsonReader struct {
+	decoder *json.Decoder
+	source  []pathElement
+	pending []json.RawMessage
+}
+
+func newJSONReader(r io.Reader, source []pathElement) *jsonReader {
+	return &jsonReader{decoder: json.NewDecoder(r), source: source}
+}
+
+func (j *jsonReader) next() (record, error) {
+	for len(j.pending) == 0 {
+		var raw json.RawMessage
+		if err := j.decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, jsonParsingError(err)
+		}
+
+		values, err := expand(raw, j.source)
+		if err != nil {
+			return nil, err
+		}
+
+		j.pending = values
+	}
+
+	raw := j.pending[0]
+	j.pending = j.pending[1:]
+
+	r, err := newJSONRecord(raw)
+	if err != nil {
+		return nil, jsonParsingError(err)
+	}
+
+	return r, nil
+}
+
+// expand resolves the FROM clause path in a top level JSON value, the
+// elements of an array being distinct records.
+func expand(raw json.RawMessage, source []pathElement) ([]json.RawMessage, error) {
+	for _, element := range source {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, nil
+		}
+
+		var ok bool
+		if raw, ok = object[element.name]; !ok {
+			return nil, nil
+		}
+	}
+
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(trimmed, &elements); err != nil {
+			return nil, jsonParsingError(err)
+		}
+
+		return elements, nil
+	}
+
+	return []json.RawMessage{raw}, nil
+}
+
+func jsonParsingError(err error) error {
+	return &evaluationError{Code: s3errors.JSONParsingError.Code, Message: "Encountered an error parsing the JSON file: " + err.Error()}
+}
@@ -0,0 +1,133 @@
+package s3select
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	index int
+}
+
+// keyword reports whether the token is the given unquoted keyword.
+func (t token) keyword(word string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, word)
+}
+
+func (t token) symbol(symbol string) bool {
+	return t.kind == tokenSymbol && t.text == symbol
+}
+
+var symbols = []string{"<>", "!=", "<=", ">=", "||", "=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ",", ".", "[", "]"}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '\'' || r == '"':
+			text, next, ok := quoted(runes, i)
+			if !ok {
+				return nil, parseError(s3errors.LexerInvalidLiteral, "The SQL expression contains an unterminated literal.")
+			}
+
+			kind := tokenString
+			if r == '"' {
+				kind = tokenQuotedIdent
+			}
+
+			tokens = append(tokens, token{kind: kind, text: text, index: i})
+			i = next
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				i++
+				if i < len(runes) && (runes[i] == '+' || runes[i] == '-') {
+					i++
+				}
+
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), index: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), index: start})
+
+		default:
+			symbol := ""
+			for _, candidate := range symbols {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					symbol = candidate
+					break
+				}
+			}
+
+			if symbol == "" {
+				return nil, parseError(s3errors.LexerInvalidChar, "The SQL expression contains an invalid character: "+string(r))
+			}
+
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol, index: i})
+			i += len(symbol)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, index: len(runes)}), nil
+}
+
+// quoted reads a literal delimited by the quote at runes[start], a doubled
+// quote standing for the quote itself.
+func quoted(runes []rune, start int) (string, int, bool) {
+	quote := runes[start]
+
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] != quote {
+			b.WriteRune(runes[i])
+			continue
+		}
+
+		if i+1 < len(runes) && runes[i+1] == quote {
+			b.WriteRune(quote)
+			i++
+			continue
+		}
+
+		return b.String(), i + 1, true
+	}
+
+	return "", 0, false
+}
@@ -0,0 +1,99 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// outputWriter serializes the selected rows into a Records payload.
+type outputWriter interface {
+	// write appends a row. raw is set for SELECT * on JSON input, so that
+	// the original document is returned as is.
+	write(buf *bytes.Buffer, names []string, values []Value, raw json.RawMessage) error
+}
+
+type csvWriter struct {
+	out *CSVOutput
+}
+
+func (c csvWriter) write(buf *bytes.Buffer, _ []string, values []Value, _ json.RawMessage) error {
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(c.out.FieldDelimiter)
+		}
+
+		field := formatValue(v)
+		if c.out.QuoteFields == QuoteAlways || c.needsQuotes(field) {
+			field = c.out.QuoteCharacter +
+				strings.ReplaceAll(field, c.out.QuoteCharacter, c.out.QuoteEscapeCharacter+c.out.QuoteCharacter) +
+				c.out.QuoteCharacter
+		}
+
+		buf.WriteString(field)
+	}
+
+	buf.WriteString(c.out.RecordDelimiter)
+
+	return nil
+}
+
+func (c csvWriter) needsQuotes(field string) bool {
+	return strings.Contains(field, c.out.FieldDelimiter) ||
+		strings.Contains(field, c.out.QuoteCharacter) ||
+		strings.Contains(field, c.out.RecordDelimiter) ||
+		strings.ContainsAny(field, "\r\n")
+}
+
+type jsonWriter struct {
+	out *JSONOutput
+}
+
+func (j jsonWriter) write(buf *bytes.Buffer, names []string, values []Value, raw json.RawMessage) error {
+	if raw != nil {
+		if err := json.Compact(buf, raw); err != nil {
+			return err
+		}
+
+		buf.WriteString(j.out.RecordDelimiter)
+
+		return nil
+	}
+
+	buf.WriteByte('{')
+
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if err := encodeJSON(buf, name); err != nil {
+			return err
+		}
+
+		buf.WriteByte(':')
+
+		if err := encodeJSON(buf, jsonValue(values[i])); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteByte('}')
+	buf.WriteString(j.out.RecordDelimiter)
+
+	return nil
+}
+
+func encodeJSON(buf *bytes.Buffer, v any) error {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+
+	// Encode terminates the value with a newline.
+	buf.Truncate(buf.Len() - 1)
+
+	return nil
+}
@@ -0,0 +1,732 @@
+package s3select
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+// Query is a parsed SelectObjectContent SQL expression:
+//
+//	SELECT projections FROM S3Object[[*].path] [[AS] alias] [WHERE condition] [LIMIT n]
+type Query struct {
+	// projections is nil for SELECT *.
+	projections []projection
+	alias       string
+	source      []pathElement
+	where       expr
+	limit       int64
+	aggregates  []*aggregate
+}
+
+type projection struct {
+	expr expr
+	name string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+
+	query          *Query
+	inAggregate    bool
+	bareReferences int
+}
+
+// Parse parses the SQL subset supported by SelectObjectContent.
+func Parse(expression string) (*Query, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, query: &Query{limit: -1}}
+	if err := p.parseQuery(); err != nil {
+		return nil, err
+	}
+
+	return p.query, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) acceptKeyword(word string) bool {
+	if p.peek().keyword(word) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if p.peek().symbol(symbol) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if !p.acceptKeyword(word) {
+		return p.unexpected("expected " + word)
+	}
+
+	return nil
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return p.unexpected("expected " + symbol)
+	}
+
+	return nil
+}
+
+func (p *parser) unexpected(reason string) error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return parseError(s3errors.ParseUnexpectedTerm, "Unexpected end of SQL expression: "+reason)
+	}
+
+	return parseError(s3errors.ParseUnexpectedToken, "Unexpected token "+strconv.Quote(t.text)+" at position "+strconv.Itoa(t.index+1)+": "+reason)
+}
+
+func (p *parser) parseQuery() error {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return err
+	}
+
+	if err := p.parseProjections(); err != nil {
+		return err
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return err
+	}
+
+	if err := p.parseSource(); err != nil {
+		return err
+	}
+
+	if p.acceptKeyword("WHERE") {
+		aggregates := len(p.query.aggregates)
+
+		where, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+
+		if len(p.query.aggregates) != aggregates {
+			return s3errors.UnsupportedSyntax.New().WithMessage("Aggregate functions are not allowed in the WHERE clause")
+		}
+
+		p.query.where = where
+	}
+
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+
+		limit, err := strconv.ParseInt(t.text, 10, 64)
+		if t.kind != tokenNumber || err != nil || limit < 0 {
+			return parseError(s3errors.ParseInvalidTypeParam, "LIMIT must be a non-negative integer")
+		}
+
+		p.query.limit = limit
+	}
+
+	if p.peek().kind != tokenEOF {
+		return p.unexpected("expected end of expression")
+	}
+
+	return nil
+}
+
+func (p *parser) parseProjections() error {
+	if p.acceptSymbol("*") {
+		return nil
+	}
+
+	bare := false
+	for {
+		references := p.bareReferences
+
+		e, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+
+		bare = bare || p.bareReferences != references
+
+		name := ""
+		if ref, ok := e.(*reference); ok {
+			name = ref.path[len(ref.path)-1].name
+		}
+
+		if p.acceptKeyword("AS") || p.peek().kind == tokenIdent && !p.peek().keyword("FROM") || p.peek().kind == tokenQuotedIdent {
+			t := p.next()
+			if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+				return p.unexpected("expected an alias")
+			}
+
+			name = t.text
+		}
+
+		if name == "" {
+			name = "_" + strconv.Itoa(len(p.query.projections)+1)
+		}
+
+		p.query.projections = append(p.query.projections, projection{expr: e, name: name})
+
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+
+	if bare && len(p.query.aggregates) > 0 {
+		return s3errors.UnsupportedSyntax.New().WithMessage("Columns must be used in aggregate functions when the query contains aggregate functions")
+	}
+
+	return nil
+}
+
+func (p *parser) parseSource() error {
+	t := p.next()
+	if !t.keyword("S3Object") {
+		return parseError(s3errors.ParseInvalidTableReference, "The FROM clause must reference S3Object")
+	}
+
+	if p.acceptSymbol("[") {
+		if err := p.expectSymbol("*"); err != nil {
+			return err
+		}
+
+		if err := p.expectSymbol("]"); err != nil {
+			return err
+		}
+
+		for p.acceptSymbol(".") {
+			element, err := p.parsePathElement()
+			if err != nil {
+				return err
+			}
+
+			p.query.source = append(p.query.source, element)
+		}
+	}
+
+	p.query.alias = "S3Object"
+	if p.acceptKeyword("AS") || p.peek().kind == tokenIdent && !p.peek().keyword("WHERE") && !p.peek().keyword("LIMIT") {
+		alias := p.next()
+		if alias.kind != tokenIdent {
+			return p.unexpected("expected an alias")
+		}
+
+		p.query.alias = alias.text
+	}
+
+	return nil
+}
+
+func (p *parser) parsePathElement() (pathElement, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenIdent:
+		return pathElement{name: t.text}, nil
+	case tokenQuotedIdent:
+		return pathElement{name: t.text, quoted: true}, nil
+	}
+
+	p.pos--
+
+	return pathElement{}, p.unexpected("expected a name")
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logical{op: "OR", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logical{op: "AND", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return &not{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+var comparisonOperators = []string{"=", "!=", "<>", "<", "<=", ">", ">="}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range comparisonOperators {
+		if p.acceptSymbol(op) {
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+
+			return &comparison{op: op, left: left, right: right}, nil
+		}
+	}
+
+	if p.acceptKeyword("IS") {
+		negate := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+
+		return &isNull{operand: left, negate: negate}, nil
+	}
+
+	negate := p.acceptKeyword("NOT")
+
+	switch {
+	case p.acceptKeyword("LIKE"):
+		return p.parseLike(left, negate)
+	case p.acceptKeyword("BETWEEN"):
+		return p.parseBetween(left, negate)
+	case p.acceptKeyword("IN"):
+		return p.parseIn(left, negate)
+	case negate:
+		return nil, p.unexpected("expected LIKE, BETWEEN or IN")
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseLike(operand expr, negate bool) (expr, error) {
+	pattern, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &like{operand: operand, pattern: pattern, negate: negate}
+
+	if p.acceptKeyword("ESCAPE") {
+		t := p.next()
+		if t.kind != tokenString || len([]rune(t.text)) != 1 {
+			return nil, parseError(s3errors.ParseInvalidTypeParam, "ESCAPE must be a single character string")
+		}
+
+		l.escape = []rune(t.text)[0]
+	}
+
+	if lit, ok := pattern.(*literal); ok {
+		s, ok := lit.value.(string)
+		if !ok {
+			return nil, parseError(s3errors.ParseInvalidTypeParam, "LIKE pattern must be a string")
+		}
+
+		l.compiled = compileLike(s, l.escape)
+	}
+
+	return l, nil
+}
+
+func (p *parser) parseBetween(operand expr, negate bool) (expr, error) {
+	low, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeyword("AND"); err != nil {
+		return nil, err
+	}
+
+	high, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	var e expr = &logical{
+		op:    "AND",
+		left:  &comparison{op: ">=", left: operand, right: low},
+		right: &comparison{op: "<=", left: operand, right: high},
+	}
+
+	if negate {
+		e = &not{operand: e}
+	}
+
+	return e, nil
+}
+
+func (p *parser) parseIn(operand expr, negate bool) (expr, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	return &in{operand: operand, list: list, negate: negate}, nil
+}
+
+// parseList parses comma separated expressions up to the closing
+// parenthesis.
+func (p *parser) parseList() ([]expr, error) {
+	var list []expr
+
+	if p.acceptSymbol(")") {
+		return list, nil
+	}
+
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, e)
+
+		if p.acceptSymbol(")") {
+			return list, nil
+		}
+
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if !op.symbol("+") && !op.symbol("-") && !op.symbol("||") {
+			return left, nil
+		}
+
+		p.pos++
+
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &arithmetic{op: op.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if !op.symbol("*") && !op.symbol("/") && !op.symbol("%") {
+			return left, nil
+		}
+
+		p.pos++
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &arithmetic{op: op.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.acceptSymbol("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &arithmetic{op: "-", left: &literal{value: int64(0)}, right: operand}, nil
+	}
+
+	if p.acceptSymbol("+") {
+		return p.parseUnary()
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenNumber:
+		return parseNumber(t)
+	case tokenString:
+		return &literal{value: t.text}, nil
+	case tokenQuotedIdent:
+		p.pos--
+		return p.parseReference()
+	case tokenSymbol:
+		if t.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			return e, p.expectSymbol(")")
+		}
+	case tokenIdent:
+		switch {
+		case t.keyword("TRUE"):
+			return &literal{value: true}, nil
+		case t.keyword("FALSE"):
+			return &literal{value: false}, nil
+		case t.keyword("NULL"), t.keyword("MISSING"):
+			return &literal{}, nil
+		case t.keyword("CAST"):
+			return p.parseCast()
+		case p.peek().symbol("("):
+			p.pos++
+			return p.parseCall(strings.ToUpper(t.text))
+		case !reserved[strings.ToUpper(t.text)]:
+			p.pos--
+			return p.parseReference()
+		}
+	}
+
+	return nil, parseError(s3errors.ParseExpectedExpression, "Expected an expression at position "+strconv.Itoa(t.index+1))
+}
+
+func parseNumber(t token) (expr, error) {
+	if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+		return &literal{value: i}, nil
+	}
+
+	f, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, parseError(s3errors.ParseInvalidTypeParam, "Invalid number literal "+strconv.Quote(t.text))
+	}
+
+	return &literal{value: f}, nil
+}
+
+// reserved are the keywords that cannot be used as unquoted column names.
+var reserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "IS": true, "LIKE": true,
+	"BETWEEN": true, "IN": true, "ESCAPE": true,
+}
+
+var castTypes = map[string]bool{
+	"INT": true, "INTEGER": true, "BIGINT": true, "SMALLINT": true,
+	"FLOAT": true, "DECIMAL": true, "NUMERIC": true, "REAL": true, "DOUBLE": true,
+	"STRING": true, "VARCHAR": true, "CHAR": true,
+	"BOOL": true, "BOOLEAN": true,
+	"TIMESTAMP": true,
+}
+
+func (p *parser) parseCast() (expr, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	operand, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeyword("AS"); err != nil {
+		return nil, err
+	}
+
+	t := p.next()
+	typ := strings.ToUpper(t.text)
+	if t.kind != tokenIdent || !castTypes[typ] {
+		return nil, parseError(s3errors.ParseUnsupportedType, "Unsupported CAST type "+strconv.Quote(t.text))
+	}
+
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	return &castExpr{operand: operand, typ: typ}, nil
+}
+
+func (p *parser) parseCall(name string) (expr, error) {
+	switch name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		return p.parseAggregate(name)
+	}
+
+	fn, ok := functions[name]
+	if !ok {
+		return nil, parseError(s3errors.ParseUnsupportedCallWithStar, "Unsupported function "+name)
+	}
+
+	args, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, parseError(s3errors.IncorrectSQLFunctionArgumentType, "Incorrect number of arguments for "+name)
+	}
+
+	return &call{name: name, fn: fn.eval, args: args}, nil
+}
+
+func (p *parser) parseAggregate(name string) (expr, error) {
+	if p.inAggregate {
+		return nil, s3errors.UnsupportedSyntax.New().WithMessage("Aggregate functions cannot be nested")
+	}
+
+	agg := &aggregate{fn: name, index: len(p.query.aggregates)}
+
+	if name == "COUNT" && p.acceptSymbol("*") {
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	} else {
+		p.inAggregate = true
+		operand, err := p.parseExpr()
+		p.inAggregate = false
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		agg.operand = operand
+	}
+
+	p.query.aggregates = append(p.query.aggregates, agg)
+
+	return agg, nil
+}
+
+func (p *parser) parseReference() (expr, error) {
+	first, err := p.parsePathElement()
+	if err != nil {
+		return nil, err
+	}
+
+	path := []pathElement{first}
+
+	for {
+		switch {
+		case p.acceptSymbol("."):
+			element, err := p.parsePathElement()
+			if err != nil {
+				return nil, err
+			}
+
+			path = append(path, element)
+
+		case p.acceptSymbol("["):
+			t := p.next()
+
+			index, err := strconv.Atoi(t.text)
+			if t.kind != tokenNumber || err != nil || index < 0 {
+				return nil, parseError(s3errors.ParseInvalidPathComponent, "Array index must be a non-negative integer")
+			}
+
+			if err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+
+			path = append(path, pathElement{index: index, isIndex: true})
+
+		default:
+			if !p.inAggregate {
+				p.bareReferences++
+			}
+
+			return &reference{query: p.query, path: path}, nil
+		}
+	}
+}
+
+// compileLike translates a LIKE pattern, where % matches any sequence and
+// _ any single character, into an anchored regular expression.
+func compileLike(pattern string, escape rune) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case escape != 0 && r == escape:
+			escaped = true
+		case r == '%':
+			b.WriteString(`.*`)
+		case r == '_':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteString(`$`)
+
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,202 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// record is a row of the queried object.
+type record interface {
+	// field returns the value at path, nil when it is missing. An empty
+	// path designates the whole record.
+	field(path []pathElement) Value
+	// columns returns the names and values of every column, as selected by
+	// SELECT *.
+	columns() ([]string, []Value)
+}
+
+type csvRecord struct {
+	fields []string
+	names  []string
+}
+
+// positional parses the _N column references, N starting at 1.
+func positional(name string) (int, bool) {
+	if !strings.HasPrefix(name, "_") {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(name[1:])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+
+	return n - 1, true
+}
+
+func (r *csvRecord) field(path []pathElement) Value {
+	if len(path) == 0 {
+		return strings.Join(r.fields, ",")
+	}
+
+	if len(path) > 1 || path[0].isIndex {
+		return nil
+	}
+
+	index := -1
+	if n, ok := positional(path[0].name); ok && !path[0].quoted {
+		index = n
+	} else {
+		for i, name := range r.names {
+			if path[0].matches(name) {
+				index = i
+				break
+			}
+		}
+	}
+
+	if index < 0 || index >= len(r.fields) {
+		return nil
+	}
+
+	return r.fields[index]
+}
+
+func (r *csvRecord) columns() ([]string, []Value) {
+	names := make([]string, len(r.fields))
+	values := make([]Value, len(r.fields))
+
+	for i, field := range r.fields {
+		if i < len(r.names) {
+			names[i] = r.names[i]
+		} else {
+			names[i] = "_" + strconv.Itoa(i+1)
+		}
+
+		values[i] = field
+	}
+
+	return names, values
+}
+
+type jsonRecord struct {
+	raw   json.RawMessage
+	value any
+}
+
+func newJSONRecord(raw json.RawMessage) (*jsonRecord, error) {
+	r := &jsonRecord{raw: raw}
+	if err := decodeJSON(raw, &r.value); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func decodeJSON(raw []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
+
+func (r *jsonRecord) field(path []pathElement) Value {
+	return normalize(walk(r.value, path))
+}
+
+// walk resolves path inside a decoded JSON value.
+func walk(v any, path []pathElement) any {
+	for _, element := range path {
+		switch current := v.(type) {
+		case map[string]any:
+			if element.isIndex {
+				return nil
+			}
+
+			next, ok := current[element.name]
+			if !ok && !element.quoted {
+				for name, candidate := range current {
+					if strings.EqualFold(name, element.name) {
+						next, ok = candidate, true
+						break
+					}
+				}
+			}
+
+			if !ok {
+				return nil
+			}
+
+			v = next
+		case []any:
+			if !element.isIndex || element.index >= len(current) {
+				return nil
+			}
+
+			v = current[element.index]
+		default:
+			return nil
+		}
+	}
+
+	return v
+}
+
+// normalize converts JSON numbers into int64 or float64.
+func normalize(v any) Value {
+	if n, ok := v.(json.Number); ok {
+		return jsonNumber(n)
+	}
+
+	return v
+}
+
+func (r *jsonRecord) columns() ([]string, []Value) {
+	object, ok := r.value.(map[string]any)
+	if !ok {
+		return []string{"_1"}, []Value{normalize(r.value)}
+	}
+
+	names := objectKeys(r.raw)
+	values := make([]Value, len(names))
+
+	for i, name := range names {
+		values[i] = normalize(object[name])
+	}
+
+	return names, values
+}
+
+// objectKeys returns the keys of a JSON object in document order, which a
+// decoded map does not preserve.
+func objectKeys(raw json.RawMessage) []string {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+
+	if t, err := decoder.Token(); err != nil || t != json.Delim('{') {
+		return nil
+	}
+
+	var keys []string
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			return keys
+		}
+
+		key, ok := t.(string)
+		if !ok {
+			return keys
+		}
+
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return keys
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,248 @@
+package s3select
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type Request struct {
+	XMLName             xml.Name `xml:"SelectObjectContentRequest"`
+	Expression          string
+	ExpressionType      string
+	RequestProgress     *RequestProgress `xml:",omitempty"`
+	InputSerialization  InputSerialization
+	OutputSerialization OutputSerialization
+	ScanRange           *ScanRange `xml:",omitempty"`
+}
+
+type RequestProgress struct {
+	Enabled bool
+}
+
+type InputSerialization struct {
+	CompressionType string     `xml:",omitempty"`
+	CSV             *CSVInput  `xml:",omitempty"`
+	JSON            *JSONInput `xml:",omitempty"`
+	Parquet         *struct{}  `xml:",omitempty"`
+}
+
+type CSVInput struct {
+	AllowQuotedRecordDelimiter bool   `xml:",omitempty"`
+	Comments                   string `xml:",omitempty"`
+	FieldDelimiter             string `xml:",omitempty"`
+	FileHeaderInfo             string `xml:",omitempty"`
+	QuoteCharacter             string `xml:",omitempty"`
+	QuoteEscapeCharacter       string `xml:",omitempty"`
+	RecordDelimiter            string `xml:",omitempty"`
+}
+
+type JSONInput struct {
+	Type string
+}
+
+type OutputSerialization struct {
+	CSV  *CSVOutput  `xml:",omitempty"`
+	JSON *JSONOutput `xml:",omitempty"`
+}
+
+type CSVOutput struct {
+	FieldDelimiter       string `xml:",omitempty"`
+	QuoteCharacter       string `xml:",omitempty"`
+	QuoteEscapeCharacter string `xml:",omitempty"`
+	QuoteFields          string `xml:",omitempty"`
+	RecordDelimiter      string `xml:",omitempty"`
+}
+
+type JSONOutput struct {
+	RecordDelimiter string `xml:",omitempty"`
+}
+
+type ScanRange struct {
+	Start *int64 `xml:",omitempty"`
+	End   *int64 `xml:",omitempty"`
+}
+
+const (
+	CompressionNone  = "NONE"
+	CompressionGZIP  = "GZIP"
+	CompressionBZIP2 = "BZIP2"
+
+	FileHeaderUse    = "USE"
+	FileHeaderIgnore = "IGNORE"
+	FileHeaderNone   = "NONE"
+
+	JSONDocument = "DOCUMENT"
+	JSONLines    = "LINES"
+
+	QuoteAlways   = "ALWAYS"
+	QuoteAsNeeded = "ASNEEDED"
+)
+
+// ParseRequest decodes and validates a SelectObjectContent payload,
+// including the SQL expression, so that every client error is reported
+// before the event stream starts.
+func ParseRequest(payload []byte) (*Request, *Query, error) {
+	var req Request
+	if err := xml.Unmarshal(payload, &req); err != nil {
+		return nil, nil, s3errors.MalformedXML.New()
+	}
+
+	if err := req.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	query, err := Parse(req.Expression)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &req, query, nil
+}
+
+func (req *Request) validate() error {
+	if req.Expression == "" {
+		return missingRequiredParameter("Expression")
+	}
+
+	if !strings.EqualFold(req.ExpressionType, "SQL") {
+		return s3errors.InvalidExpressionType.New()
+	}
+
+	in := &req.InputSerialization
+	switch strings.ToUpper(in.CompressionType) {
+	case "", CompressionNone:
+		in.CompressionType = CompressionNone
+	case CompressionGZIP, CompressionBZIP2:
+		in.CompressionType = strings.ToUpper(in.CompressionType)
+	default:
+		return s3errors.InvalidCompressionFormat.New()
+	}
+
+	switch {
+	case in.Parquet != nil:
+		return s3errors.UnsupportedSyntax.New().WithMessage("Parquet input serialization is not supported")
+	case (in.CSV == nil) == (in.JSON == nil):
+		return s3errors.InvalidRequest.New().WithMessage("The input serialization must have exactly one of CSV or JSON")
+	case in.CSV != nil:
+		if err := in.CSV.validate(); err != nil {
+			return err
+		}
+	default:
+		switch strings.ToUpper(in.JSON.Type) {
+		case JSONDocument, JSONLines:
+			in.JSON.Type = strings.ToUpper(in.JSON.Type)
+		default:
+			return s3errors.InvalidJSONType.New()
+		}
+	}
+
+	out := &req.OutputSerialization
+	switch {
+	case (out.CSV == nil) == (out.JSON == nil):
+		return s3errors.InvalidRequest.New().WithMessage("The output serialization must have exactly one of CSV or JSON")
+	case out.CSV != nil:
+		if err := out.CSV.validate(); err != nil {
+			return err
+		}
+	case out.JSON.RecordDelimiter == "":
+		out.JSON.RecordDelimiter = "\n"
+	}
+
+	if req.ScanRange != nil {
+		return req.validateScanRange()
+	}
+
+	return nil
+}
+
+func (req *Request) validateScanRange() error {
+	in := req.InputSerialization
+	if in.CompressionType != CompressionNone {
+		return s3errors.UnsupportedScanRangeInput.New().WithMessage("Scan range queries are not supported on compressed objects")
+	}
+
+	if in.JSON != nil && in.JSON.Type != JSONLines {
+		return s3errors.UnsupportedScanRangeInput.New().WithMessage("Scan range queries are only supported on JSON Lines objects")
+	}
+
+	if in.CSV != nil && in.CSV.AllowQuotedRecordDelimiter {
+		return s3errors.UnsupportedScanRangeInput.New().WithMessage("Scan range queries are not supported with AllowQuotedRecordDelimiter")
+	}
+
+	r := req.ScanRange
+	if (r.Start != nil && *r.Start < 0) || (r.End != nil && *r.End < 0) ||
+		(r.Start != nil && r.End != nil && *r.Start > *r.End) {
+		return s3errors.InvalidScanRange.New()
+	}
+
+	return nil
+}
+
+func (in *CSVInput) validate() error {
+	switch strings.ToUpper(in.FileHeaderInfo) {
+	case "", FileHeaderNone:
+		in.FileHeaderInfo = FileHeaderNone
+	case FileHeaderUse, FileHeaderIgnore:
+		in.FileHeaderInfo = strings.ToUpper(in.FileHeaderInfo)
+	default:
+		return s3errors.InvalidFileHeaderInfo.New()
+	}
+
+	if in.FieldDelimiter == "" {
+		in.FieldDelimiter = ","
+	}
+
+	if in.RecordDelimiter == "" {
+		in.RecordDelimiter = "\n"
+	}
+
+	if in.QuoteCharacter == "" {
+		in.QuoteCharacter = `"`
+	}
+
+	switch {
+	case len([]rune(in.FieldDelimiter)) != 1:
+		return s3errors.InvalidRequest.New().WithMessage("Unsupported value for FieldDelimiter")
+	case in.RecordDelimiter != "\r\n" && len(in.RecordDelimiter) != 1:
+		return s3errors.InvalidRequest.New().WithMessage("Unsupported value for RecordDelimiter")
+	case in.QuoteCharacter != `"`:
+		return s3errors.InvalidQuoteFields.New().WithMessage("Unsupported value for QuoteCharacter")
+	case in.QuoteEscapeCharacter != "" && in.QuoteEscapeCharacter != `"`:
+		return s3errors.InvalidQuoteFields.New().WithMessage("Unsupported value for QuoteEscapeCharacter")
+	case len([]rune(in.Comments)) > 1:
+		return s3errors.InvalidRequest.New().WithMessage("Unsupported value for Comments")
+	}
+
+	return nil
+}
+
+func (out *CSVOutput) validate() error {
+	switch strings.ToUpper(out.QuoteFields) {
+	case "", QuoteAsNeeded:
+		out.QuoteFields = QuoteAsNeeded
+	case QuoteAlways:
+		out.QuoteFields = QuoteAlways
+	default:
+		return s3errors.InvalidQuoteFields.New().WithMessage("Unsupported value for QuoteFields")
+	}
+
+	if out.FieldDelimiter == "" {
+		out.FieldDelimiter = ","
+	}
+
+	if out.RecordDelimiter == "" {
+		out.RecordDelimiter = "\n"
+	}
+
+	if out.QuoteCharacter == "" {
+		out.QuoteCharacter = `"`
+	}
+
+	if out.QuoteEscapeCharacter == "" {
+		out.QuoteEscapeCharacter = out.QuoteCharacter
+	}
+
+	return nil
+}
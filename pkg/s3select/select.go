@@ -0,0 +1,304 @@
+// Package s3select evaluates the SelectObjectContent queries over CSV and
+// JSON objects and streams their results as events.
+//
+// It is a library only: s3request decodes the requests, but no handler
+// serves SelectObjectContent until objects are stored.
+package s3select
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RecordsPayloadSize is the amount of selected data buffered before it is
+// sent in a Records event.
+const RecordsPayloadSize = 64 << 10
+
+// ContInterval is the longest time the stream stays silent while records
+// are being scanned: a Cont event then keeps the connection alive.
+const ContInterval = 10 * time.Second
+
+// Serve answers a SelectObjectContent request whose payload was verified by
+// ParseRequest. Once the status is sent, errors are reported in the event
+// stream; the returned error is only meant for logging.
+func Serve(ctx context.Context, w http.ResponseWriter, req *Request, query *Query, object io.ReaderAt, size int64) error {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+
+	return Select(ctx, w, req, query, object, size)
+}
+
+// Select runs query over the size bytes of object and writes the result as
+// an AWS event stream: Records, Cont, Progress when requested, then Stats
+// and End, or an error message if the evaluation fails.
+func Select(ctx context.Context, w io.Writer, req *Request, query *Query, object io.ReaderAt, size int64) error {
+	s := &selection{
+		req:        req,
+		query:      query,
+		events:     &eventWriter{w: w},
+		lastEvent:  time.Now(),
+		aggregates: make([]aggregateState, len(query.aggregates)),
+	}
+
+	err := s.run(ctx, object, size)
+	if s.events.err != nil {
+		return s.events.err
+	}
+
+	var evalErr *evaluationError
+	switch {
+	case errors.As(err, &evalErr):
+		return s.events.error(evalErr.Code, evalErr.Message)
+	case err != nil:
+		if writeErr := s.events.error("InternalError", "We encountered an internal error. Please try again."); writeErr != nil {
+			return writeErr
+		}
+
+		return fmt.Errorf("s3select: %w", err)
+	}
+
+	if err := s.progress(); err != nil {
+		return err
+	}
+
+	if err := s.events.stats(s.counters()); err != nil {
+		return err
+	}
+
+	return s.events.end()
+}
+
+type selection struct {
+	req    *Request
+	query  *Query
+	events *eventWriter
+	output outputWriter
+
+	scanned, processed atomic.Int64
+	returned           int64
+	lastEvent          time.Time
+
+	payload    bytes.Buffer
+	aggregates []aggregateState
+}
+
+func (s *selection) counters() counters {
+	return counters{
+		BytesScanned:   s.scanned.Load(),
+		BytesProcessed: s.processed.Load(),
+		BytesReturned:  s.returned,
+	}
+}
+
+func (s *selection) run(ctx context.Context, object io.ReaderAt, size int64) error {
+	records, err := s.open(object, size)
+	if err != nil {
+		return err
+	}
+
+	if s.req.OutputSerialization.CSV != nil {
+		s.output = csvWriter{out: s.req.OutputSerialization.CSV}
+	} else {
+		s.output = jsonWriter{out: s.req.OutputSerialization.JSON}
+	}
+
+	env := &env{aggregates: s.aggregates}
+
+	var selected int64
+	for s.query.limit < 0 || selected < s.query.limit || len(s.query.aggregates) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := s.keepAlive(); err != nil {
+			return err
+		}
+
+		if env.record, err = records.next(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if s.query.where != nil {
+			match, err := s.query.where.eval(env)
+			if err != nil {
+				return err
+			}
+
+			if !truthy(match) {
+				continue
+			}
+		}
+
+		if len(s.query.aggregates) > 0 {
+			for _, agg := range s.query.aggregates {
+				if err := agg.accumulate(env); err != nil {
+					return err
+				}
+			}
+		} else if err := s.emit(env); err != nil {
+			return err
+		}
+
+		selected++
+	}
+
+	if len(s.query.aggregates) > 0 && s.query.limit != 0 {
+		if err := s.emit(env); err != nil {
+			return err
+		}
+	}
+
+	return s.flush()
+}
+
+// open returns the records of the object, decompressed and restricted to
+// the scan range.
+func (s *selection) open(object io.ReaderAt, size int64) (recordReader, error) {
+	in := s.req.InputSerialization
+
+	var (
+		input  io.Reader = io.NewSectionReader(object, 0, size)
+		header []string
+	)
+
+	if s.req.ScanRange != nil {
+		delim := byte('\n')
+		if in.CSV != nil {
+			delim = in.CSV.RecordDelimiter[len(in.CSV.RecordDelimiter)-1]
+		}
+
+		r, err := newRangeReader(object, size, s.req.ScanRange, delim)
+		if err != nil {
+			return nil, err
+		}
+
+		if in.CSV != nil && r.start > 0 {
+			if header, err = csvHeader(object, size, in.CSV, delim); err != nil {
+				return nil, err
+			}
+		}
+
+		input = r
+	}
+
+	input, err := decompress(countingReader{r: input, n: &s.scanned}, in.CompressionType)
+	if err != nil {
+		return nil, err
+	}
+
+	input = countingReader{r: input, n: &s.processed}
+
+	if in.CSV != nil {
+		return newCSVReader(input, in.CSV, header), nil
+	}
+
+	return newJSONReader(input, s.query.source), nil
+}
+
+// csvHeader reads the header of a CSV object for a scan range that does not
+// include it. The result is never nil, so that the first line of the range
+// is not mistaken for a header.
+func csvHeader(object io.ReaderAt, size int64, in *CSVInput, delim byte) ([]string, error) {
+	if in.FileHeaderInfo != FileHeaderUse {
+		return []string{}, nil
+	}
+
+	line, err := firstLine(object, size, delim)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newCSVReader(bytes.NewReader(line), in, []string{}).next()
+	if errors.Is(err, io.EOF) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return r.(*csvRecord).fields, nil
+}
+
+// emit writes the projection of the current record.
+func (s *selection) emit(env *env) error {
+	var (
+		names  []string
+		values []Value
+		raw    json.RawMessage
+	)
+
+	if s.query.projections == nil {
+		names, values = env.record.columns()
+		if r, ok := env.record.(*jsonRecord); ok {
+			raw = r.raw
+		}
+	} else {
+		names = make([]string, len(s.query.projections))
+		values = make([]Value, len(s.query.projections))
+
+		for i, p := range s.query.projections {
+			v, err := p.expr.eval(env)
+			if err != nil {
+				return err
+			}
+
+			names[i], values[i] = p.name, v
+		}
+	}
+
+	if err := s.output.write(&s.payload, names, values, raw); err != nil {
+		return err
+	}
+
+	if s.payload.Len() >= RecordsPayloadSize {
+		return s.flush()
+	}
+
+	return nil
+}
+
+// flush sends the buffered records.
+func (s *selection) flush() error {
+	if s.payload.Len() == 0 {
+		return nil
+	}
+
+	s.returned += int64(s.payload.Len())
+
+	if err := s.events.records(s.payload.Bytes()); err != nil {
+		return err
+	}
+
+	s.payload.Reset()
+	s.lastEvent = time.Now()
+
+	return s.progress()
+}
+
+// progress reports the counters after each Records event, when the client
+// asked for it.
+func (s *selection) progress() error {
+	if s.req.RequestProgress == nil || !s.req.RequestProgress.Enabled {
+		return nil
+	}
+
+	return s.events.progress(s.counters())
+}
+
+func (s *selection) keepAlive() error {
+	if time.Since(s.lastEvent) < ContInterval {
+		return nil
+	}
+
+	s.lastEvent = time.Now()
+
+	return s.events.cont()
+}
@@ -0,0 +1,221 @@
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type message struct {
+	headers map[string]string
+	payload string
+}
+
+func decodeMessages(t *testing.T, stream []byte) []message {
+	t.Helper()
+
+	var messages []message
+	for len(stream) > 0 {
+		require.GreaterOrEqual(t, len(stream), preludeLength+checksumLength)
+
+		total := int(binary.BigEndian.Uint32(stream[0:4]))
+		headersLength := int(binary.BigEndian.Uint32(stream[4:8]))
+		require.Equal(t, crc32.ChecksumIEEE(stream[:8]), binary.BigEndian.Uint32(stream[8:12]))
+		require.Equal(t, crc32.ChecksumIEEE(stream[:total-4]), binary.BigEndian.Uint32(stream[total-4:total]))
+
+		m := message{headers: map[string]string{}}
+		for h := stream[preludeLength : preludeLength+headersLength]; len(h) > 0; {
+			nameLength := int(h[0])
+			name := string(h[1 : 1+nameLength])
+			require.EqualValues(t, headerTypeString, h[1+nameLength])
+			valueLength := int(binary.BigEndian.Uint16(h[2+nameLength:]))
+			m.headers[name] = string(h[4+nameLength : 4+nameLength+valueLength])
+			h = h[4+nameLength+valueLength:]
+		}
+
+		m.payload = string(stream[preludeLength+headersLength : total-4])
+		messages = append(messages, m)
+		stream = stream[total:]
+	}
+
+	return messages
+}
+
+// run selects object and returns the records, the event types and the
+// error code, if any.
+func run(t *testing.T, payload, object string) (string, []string, string) {
+	t.Helper()
+
+	req, query, err := ParseRequest([]byte(payload))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, Select(context.Background(), &out, req, query, strings.NewReader(object), int64(len(object))))
+
+	var (
+		records strings.Builder
+		events  []string
+		code    string
+	)
+
+	for _, m := range decodeMessages(t, out.Bytes()) {
+		if m.headers[":message-type"] == "error" {
+			code = m.headers[":error-code"]
+			continue
+		}
+
+		events = append(events, m.headers[":event-type"])
+		if m.headers[":event-type"] == EventRecords {
+			records.WriteString(m.payload)
+		}
+	}
+
+	return records.String(), events, code
+}
+
+func request(expression, input, output string) string {
+	return "<SelectObjectContentRequest><Expression>" + expression + "</Expression><ExpressionType>SQL</ExpressionType>" +
+		"<InputSerialization>" + input + "</InputSerialization>" +
+		"<OutputSerialization>" + output + "</OutputSerialization></SelectObjectContentRequest>"
+}
+
+const (
+	csvUse    = "<CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>"
+	csvOutput = "<CSV/>"
+	jsonLines = "<JSON><Type>LINES</Type></JSON>"
+	jsonOut   = "<JSON/>"
+
+	people = "name,age,city\nalice,34,Paris\nbob,27,\"New York, NY\"\ncarol,41,paris\n"
+)
+
+func TestSelectCSV(t *testing.T) {
+	testCases := []struct {
+		expression string
+		output     string
+		expected   string
+	}{
+		{"SELECT * FROM S3Object", csvOutput, "alice,34,Paris\nbob,27,\"New York, NY\"\ncarol,41,paris\n"},
+		{"SELECT s.name FROM S3Object s WHERE CAST(s.age AS INT) &gt; 30", csvOutput, "alice\ncarol\n"},
+		{"SELECT name, age FROM S3Object WHERE LOWER(city) = 'paris' LIMIT 1", jsonOut, `{"name":"alice","age":"34"}` + "\n"},
+		{"SELECT _1 FROM S3Object WHERE _3 LIKE 'New%'", csvOutput, "bob\n"},
+		{"SELECT COUNT(*), SUM(CAST(age AS INT)), MIN(age), MAX(name), AVG(age) FROM S3Object", jsonOut, `{"_1":3,"_2":102,"_3":27,"_4":"carol","_5":34}` + "\n"},
+		{"SELECT name AS who, CAST(age AS INT) + 1 FROM S3Object s WHERE age BETWEEN 30 AND 40", jsonOut, `{"who":"alice","_2":35}` + "\n"},
+		{"SELECT name FROM S3Object WHERE city IN ('Paris', 'Lyon') OR name = 'bob' AND NOT age &lt; 20", csvOutput, "alice\nbob\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			records, events, code := run(t, request(tc.expression, csvUse, tc.output), people)
+			require.Empty(t, code)
+			require.Equal(t, tc.expected, records)
+			require.Equal(t, []string{EventStats, EventEnd}, events[len(events)-2:])
+		})
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	object := `{"id":1,"user":{"name":"alice"},"tags":["a","b"]}` + "\n" +
+		`{"id":2,"user":{"name":"bob"},"tags":["c"]}` + "\n"
+
+	records, _, code := run(t, request("SELECT * FROM S3Object s WHERE s.id = 2", jsonLines, jsonOut), object)
+	require.Empty(t, code)
+	require.Equal(t, `{"id":2,"user":{"name":"bob"},"tags":["c"]}`+"\n", records)
+
+	records, _, code = run(t, request("SELECT s.user.name, s.tags[0] AS tag FROM S3Object s", jsonLines, csvOutput), object)
+	require.Empty(t, code)
+	require.Equal(t, "alice,a\nbob,c\n", records)
+
+	document := `{"items":[{"n":1},{"n":2},{"n":3}]}`
+	records, _, code = run(t, request("SELECT SUM(s.n) FROM S3Object[*].items s", "<JSON><Type>DOCUMENT</Type></JSON>", csvOutput), document)
+	require.Empty(t, code)
+	require.Equal(t, "6\n", records)
+}
+
+func TestSelectCompressed(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write([]byte(people))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	input := "<CompressionType>GZIP</CompressionType>" + csvUse
+	records, _, code := run(t, request("SELECT COUNT(*) FROM S3Object", input, csvOutput), gz.String())
+	require.Empty(t, code)
+	require.Equal(t, "3\n", records)
+}
+
+func TestSelectScanRange(t *testing.T) {
+	payload := func(scanRange string) string {
+		return strings.Replace(request("SELECT name FROM S3Object", csvUse, csvOutput),
+			"</SelectObjectContentRequest>", "<ScanRange>"+scanRange+"</ScanRange></SelectObjectContentRequest>", 1)
+	}
+
+	// "alice" starts at 14, "bob" at 29 and "carol" at 51.
+	records, _, code := run(t, payload("<Start>15</Start><End>29</End>"), people)
+	require.Empty(t, code)
+	require.Equal(t, "bob\n", records)
+
+	records, _, _ = run(t, payload("<Start>0</Start><End>14</End>"), people)
+	require.Equal(t, "alice\n", records)
+
+	records, _, _ = run(t, payload("<End>15</End>"), people)
+	require.Equal(t, "carol\n", records)
+}
+
+func TestSelectErrors(t *testing.T) {
+	_, events, code := run(t, request("SELECT CAST(name AS INT) FROM S3Object", csvUse, csvOutput), people)
+	require.Equal(t, "CastFailed", code)
+	require.NotContains(t, events, EventEnd)
+
+	_, _, code = run(t, request("SELECT * FROM S3Object", jsonLines, jsonOut), "{not json}")
+	require.Equal(t, "JSONParsingError", code)
+}
+
+func TestSelectProgress(t *testing.T) {
+	payload := strings.Replace(request("SELECT * FROM S3Object", csvUse, csvOutput),
+		"<InputSerialization>", "<RequestProgress><Enabled>true</Enabled></RequestProgress><InputSerialization>", 1)
+
+	_, events, _ := run(t, payload, people)
+	require.Equal(t, []string{EventRecords, EventProgress, EventProgress, EventStats, EventEnd}, events)
+}
+
+func TestParseRequest(t *testing.T) {
+	testCases := []struct {
+		name    string
+		payload string
+		code    string
+	}{
+		{"malformed", "<SelectObjectContentRequest>", "MalformedXML"},
+		{"expression type", strings.Replace(request("SELECT * FROM S3Object", csvUse, csvOutput), ">SQL<", ">XPATH<", 1), "InvalidExpressionType"},
+		{"compression", request("SELECT * FROM S3Object", "<CompressionType>ZIP</CompressionType>"+csvUse, csvOutput), "InvalidCompressionFormat"},
+		{"header info", request("SELECT * FROM S3Object", "<CSV><FileHeaderInfo>FIRST</FileHeaderInfo></CSV>", csvOutput), "InvalidFileHeaderInfo"},
+		{"json type", request("SELECT * FROM S3Object", "<JSON><Type>XML</Type></JSON>", csvOutput), "InvalidJsonType"},
+		{"no input", request("SELECT * FROM S3Object", "", csvOutput), "InvalidRequest"},
+		{"syntax", request("SELECT FROM S3Object", csvUse, csvOutput), "ParseExpectedExpression"},
+		{"unexpected", request("SELECT * FROM S3Object LIMIT 1 2", csvUse, csvOutput), "ParseUnexpectedToken"},
+		{"end", request("SELECT * FROM S3Object WHERE a =", csvUse, csvOutput), "ParseExpectedExpression"},
+		{"table", request("SELECT * FROM t", csvUse, csvOutput), "ParseInvalidTableReference"},
+		{"mixed aggregate", request("SELECT name, COUNT(*) FROM S3Object", csvUse, csvOutput), "UnsupportedSyntax"},
+		{"cast type", request("SELECT CAST(a AS BLOB) FROM S3Object", csvUse, csvOutput), "ParseUnsupportedType"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := ParseRequest([]byte(tc.payload))
+
+			var s3err *s3errors.S3Error
+			require.ErrorAs(t, err, &s3err)
+			require.Equal(t, tc.code, s3err.Code)
+			require.Equal(t, http.StatusBadRequest, s3err.HTTPStatusCode)
+		})
+	}
+}
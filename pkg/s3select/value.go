@@ -0,0 +1,252 @@
+package s3select
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value is the result of an expression: nil (NULL or MISSING), bool,
+// int64, float64, string or time.Time. JSON objects and arrays evaluate
+// to map[string]any and []any.
+type Value any
+
+// number returns the numeric value of v. CSV fields are strings, so
+// numeric looking strings are accepted as numbers.
+func number(v Value) (Value, bool) {
+	switch v := v.(type) {
+	case int64, float64:
+		return v, true
+	case json.Number:
+		return jsonNumber(v), true
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+
+	return nil, false
+}
+
+func jsonNumber(n json.Number) Value {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return n.String()
+	}
+
+	return f
+}
+
+func toFloat(v Value) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+
+	return math.NaN()
+}
+
+// compare orders a and b. ok is false when the values are not comparable,
+// which makes the comparison evaluate to false.
+func compare(a, b Value) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	_, aString := a.(string)
+	_, bString := b.(string)
+
+	if aString && bString {
+		return strings.Compare(a.(string), b.(string)), true
+	}
+
+	if ab, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case ab == bb:
+			return 0, true
+		case bb:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+
+	if at, ok := a.(time.Time); ok {
+		bt, ok := toTime(b)
+		if !ok {
+			return 0, false
+		}
+
+		return at.Compare(bt), true
+	}
+
+	if bt, ok := b.(time.Time); ok {
+		at, ok := toTime(a)
+		if !ok {
+			return 0, false
+		}
+
+		return at.Compare(bt), true
+	}
+
+	an, aok := number(a)
+	bn, bok := number(b)
+	if !aok || !bok {
+		return 0, false
+	}
+
+	ai, aInt := an.(int64)
+	bi, bInt := bn.(int64)
+	if aInt && bInt {
+		switch {
+		case ai < bi:
+			return -1, true
+		case ai > bi:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	af, bf := toFloat(an), toFloat(bn)
+	switch {
+	case af < bf:
+		return -1, true
+	case af > bf:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func toTime(v Value) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04", "2006-01-02", "2006-01", "2006"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// truthy reports whether a WHERE clause value selects the record.
+func truthy(v Value) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+// cast implements CAST(v AS typ).
+func cast(v Value, typ string) (Value, error) {
+	if v == nil {
+		return nil, nil //nolint:nilnil // CAST(NULL AS t) is NULL
+	}
+
+	switch typ {
+	case "INT", "INTEGER", "BIGINT", "SMALLINT":
+		switch n, _ := number(v); n := n.(type) {
+		case int64:
+			return n, nil
+		case float64:
+			return int64(n), nil
+		}
+
+		if b, ok := v.(bool); ok {
+			if b {
+				return int64(1), nil
+			}
+
+			return int64(0), nil
+		}
+	case "FLOAT", "DECIMAL", "NUMERIC", "REAL", "DOUBLE":
+		if n, ok := number(v); ok {
+			return toFloat(n), nil
+		}
+	case "STRING", "VARCHAR", "CHAR":
+		return formatValue(v), nil
+	case "BOOL", "BOOLEAN":
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		case int64:
+			return v != 0, nil
+		case float64:
+			return v != 0, nil
+		}
+	case "TIMESTAMP":
+		if t, ok := toTime(v); ok {
+			return t, nil
+		}
+	}
+
+	return nil, castFailed(v, typ)
+}
+
+// formatValue is the text representation of v used in CSV output and by
+// CAST to STRING.
+func formatValue(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+
+		return string(b)
+	}
+}
+
+// jsonValue converts v into a value encoding/json renders the way S3
+// Select does.
+func jsonValue(v Value) any {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+	}
+
+	return v
+}
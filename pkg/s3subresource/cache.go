@@ -0,0 +1,84 @@
+package s3subresource
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Cache keeps the parsed configuration documents of a sub-resource without
+// id, reading a bucket document again once the store reports a change.
+type Cache[T any] struct {
+	store       *Store
+	subresource string
+	parse       func(document []byte) (T, error)
+
+	mu         sync.Mutex
+	entries    map[string]cacheEntry[T]
+	generation uint64
+}
+
+type cacheEntry[T any] struct {
+	value T
+	found bool
+}
+
+// NewCache returns a cache of the documents of subresource, watching the
+// store to invalidate them.
+func NewCache[T any](store *Store, subresource string, parse func(document []byte) (T, error)) *Cache[T] {
+	c := &Cache[T]{
+		store:       store,
+		subresource: subresource,
+		parse:       parse,
+		entries:     make(map[string]cacheEntry[T]),
+	}
+
+	store.Watch(subresource, c.Invalidate)
+
+	return c
+}
+
+// Get returns the parsed document of a bucket, found being false when the
+// bucket has none.
+func (c *Cache[T]) Get(ctx context.Context, bucket string) (value T, found bool, err error) {
+	c.mu.Lock()
+	entry, cached := c.entries[bucket]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if cached {
+		return entry.value, entry.found, nil
+	}
+
+	document, err := c.store.Get(ctx, bucket, c.subresource, "")
+	if err == nil {
+		entry.found = true
+		entry.value, err = c.parse(document)
+	} else if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+
+	if err != nil {
+		return value, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A document changed while it was read, the next request reads it
+	// again.
+	if generation == c.generation {
+		c.entries[bucket] = entry
+	}
+
+	return entry.value, entry.found, nil
+}
+
+// Invalidate drops the cached document of a bucket.
+func (c *Cache[T]) Invalidate(bucket string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, bucket)
+	c.generation++
+}
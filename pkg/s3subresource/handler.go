@@ -0,0 +1,259 @@
+package s3subresource
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3logging"
+	"github.com/lvjp/s3impl/pkg/s3request"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+const (
+	// MaxListResults is the number of documents returned per List page.
+	MaxListResults = 100
+	// MaxConfigurations is the number of documents of a collection.
+	MaxConfigurations = 1000
+)
+
+// Handler serves the Put, Get, Delete and List actions of the stored
+// sub-resources.
+type Handler struct {
+	logger *zerolog.Logger
+	store  *Store
+	kinds  []Kind
+}
+
+func NewHandler(logger *zerolog.Logger, store *Store, kinds []Kind) *Handler {
+	return &Handler{logger: logger, store: store, kinds: kinds}
+}
+
+type serveFunc func(w http.ResponseWriter, r *http.Request, kind *Kind, input *s3request.Configuration) error
+
+// Actions returns the action handlers of every kind.
+func (h *Handler) Actions() map[s3router.Action]s3router.ActionHandler {
+	actions := make(map[s3router.Action]s3router.ActionHandler)
+
+	for i := range h.kinds {
+		kind := &h.kinds[i]
+
+		for action, serve := range map[s3router.Action]serveFunc{
+			kind.Put:    h.put,
+			kind.Get:    h.get,
+			kind.Delete: h.delete,
+			kind.List:   h.list,
+		} {
+			if action == s3router.ActionUnknow {
+				continue
+			}
+
+			serve := serve
+
+			actions[action] = s3router.ActionHandlerFunc(func(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+				if err := h.serve(w, r, route, kind, serve); err != nil {
+					h.writeError(w, r, err)
+				}
+			})
+		}
+	}
+
+	return actions
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var s3err *s3errors.S3Error
+	if !errors.As(err, &s3err) {
+		s3logging.Ctx(r, h.logger).Error().Err(err).Str("path", r.URL.Path).Msg("Cannot serve configuration")
+		s3err = s3errors.InternalError.New()
+	}
+
+	resp := *s3err
+	resp.RequestID = w.Header().Get("x-amz-request-id")
+	resp.Resource = r.URL.Path
+
+	writer := s3errors.APIWriter{}
+	if err := writer.Write(&resp, w, r); err != nil {
+		s3logging.Ctx(r, h.logger).Warn().Err(err).Msg("Cannot write response")
+	}
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, route *s3router.Route, kind *Kind, serve serveFunc) error {
+	decoded, err := s3request.Decode(r, route)
+	if err != nil {
+		return err
+	}
+
+	input, ok := decoded.(*s3request.Configuration)
+	if !ok {
+		return fmt.Errorf("s3subresource: unexpected input %T for %s", decoded, route.Action)
+	}
+
+	return serve(w, r, kind, input)
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, kind *Kind, input *s3request.Configuration) error {
+	document, err := Canonicalize(kind, input.Payload, input.ID)
+	if err != nil {
+		return err
+	}
+
+	if !kind.Collection {
+		err = h.store.Put(r.Context(), input.Bucket, kind.Subresource, input.ID, document)
+	} else {
+		err = h.store.PutCollection(r.Context(), input.Bucket, kind.Subresource, input.ID, document, MaxConfigurations)
+	}
+
+	if errors.Is(err, ErrCollectionFull) {
+		return s3errors.TooManyConfigurations.New().
+			WithMessage("You are attempting to create a new configuration but have already reached the " + strconv.Itoa(MaxConfigurations) + "-configuration limit.")
+	} else if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// Canonicalize validates a document and returns its canonical form.
+func Canonicalize(kind *Kind, payload []byte, id string) ([]byte, error) {
+	tree, err := parseDocument(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind.Schema != nil {
+		if err := kind.Schema.validate(tree); err != nil {
+			return nil, err
+		}
+	}
+
+	if kind.Validate != nil {
+		if err := kind.Validate(payload, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if documentID, _ := tree.childText("Id"); kind.Collection && documentID != id {
+		return nil, s3errors.InvalidArgument.New().WithMessage("The configuration Id must match the id query parameter")
+	}
+
+	var buf bytes.Buffer
+	if err := tree.encode(&buf, true); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, kind *Kind, input *s3request.Configuration) error {
+	document, err := h.store.Get(r.Context(), input.Bucket, kind.Subresource, input.ID)
+	switch {
+	case errors.Is(err, ErrNotFound) && kind.Default != "":
+		if document, err = Canonicalize(&Kind{}, []byte(kind.Default), ""); err != nil {
+			return err
+		}
+	case errors.Is(err, ErrNotFound):
+		return kind.NotFound.New().WithBucketName(input.Bucket)
+	case err != nil:
+		return err
+	}
+
+	h.writeXML(w, r, document)
+
+	return nil
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, kind *Kind, input *s3request.Configuration) error {
+	if err := h.store.Delete(r.Context(), input.Bucket, kind.Subresource, input.ID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, kind *Kind, input *s3request.Configuration) error {
+	token := input.ContinuationToken
+
+	ids, err := h.store.List(r.Context(), input.Bucket, kind.Subresource)
+	if err != nil {
+		return err
+	}
+
+	page := ids[sort.Search(len(ids), func(i int) bool { return ids[i] > token }):]
+	truncated := len(page) > MaxListResults
+	if truncated {
+		page = page[:MaxListResults]
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<" + kind.ListResult + ` xmlns="` + s3consts.XMLNamespace + `">`)
+
+	for _, id := range page {
+		document, err := h.store.Get(r.Context(), input.Bucket, kind.Subresource, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		tree, err := parseDocument(document)
+		if err != nil {
+			return err
+		}
+
+		if err := tree.encode(&buf, false); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString("<IsTruncated>" + strconv.FormatBool(truncated) + "</IsTruncated>")
+
+	if token != "" {
+		if err := writeElement(&buf, "ContinuationToken", token); err != nil {
+			return err
+		}
+	}
+
+	if truncated {
+		if err := writeElement(&buf, "NextContinuationToken", page[len(page)-1]); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString("</" + kind.ListResult + ">")
+
+	h.writeXML(w, r, buf.Bytes())
+
+	return nil
+}
+
+func writeElement(buf *bytes.Buffer, name, value string) error {
+	buf.WriteString("<" + name + ">")
+	if err := xml.EscapeText(buf, []byte(value)); err != nil {
+		return err
+	}
+	buf.WriteString("</" + name + ">")
+
+	return nil
+}
+
+func (h *Handler) writeXML(w http.ResponseWriter, r *http.Request, document []byte) {
+	w.Header().Set("Content-Type", s3consts.MimetypeApplicationXML)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(append([]byte(xml.Header), document...)); err != nil {
+		s3logging.Ctx(r, h.logger).Warn().Err(err).Msg("Cannot write response")
+	}
+}
@@ -0,0 +1,293 @@
+package s3subresource
+
+import (
+	"github.com/lvjp/s3impl/pkg/s3accesslog"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3inventory"
+	"github.com/lvjp/s3impl/pkg/s3metrics"
+	"github.com/lvjp/s3impl/pkg/s3router"
+	"github.com/lvjp/s3impl/pkg/s3website"
+)
+
+// Kind describes a bucket sub-resource holding a configuration document.
+type Kind struct {
+	Subresource string
+	// Schema validates the documents, it may be nil when Validate does.
+	Schema *Element
+	// Validate runs additional checks, id being the value of the id query
+	// parameter of collections.
+	Validate func(payload []byte, id string) error
+	// Collection sub-resources store several documents, selected by the id
+	// query parameter and matching their Id element.
+	Collection bool
+	// ListResult is the root element of the List response of collections.
+	ListResult string
+	// Default is returned when no document is stored, NotFound is answered
+	// when it is empty.
+	Default  string
+	NotFound s3errors.ErrorCode
+
+	// Actions routed to the sub-resource, ActionUnknow when not supported.
+	Put, Get, Delete, List s3router.Action
+}
+
+func leaf(name string, values ...string) Element {
+	return Element{Name: name, Values: values}
+}
+
+func required(e Element) Element {
+	e.Required = true
+	return e
+}
+
+func multiple(e Element) Element {
+	e.Multiple = true
+	return e
+}
+
+func integer(name string) Element {
+	return Element{Name: name, Type: TypeInteger}
+}
+
+func boolean(name string) Element {
+	return Element{Name: name, Type: TypeBoolean}
+}
+
+func parent(name string, children ...Element) Element {
+	return Element{Name: name, Children: children}
+}
+
+var tag = parent("Tag", required(leaf("Key")), required(leaf("Value")))
+
+func filter(extra ...Element) Element {
+	and := parent("And", append([]Element{leaf("Prefix"), multiple(tag)}, extra...)...)
+	return parent("Filter", append([]Element{leaf("Prefix"), tag, and}, extra...)...)
+}
+
+// DefaultKinds returns the sub-resources stored without side effect. The
+// notification and replication configurations are validated against the
+// configured targets and are added by the app when targets are set.
+func DefaultKinds() []Kind {
+	return []Kind{
+		{
+			Subresource: "accelerate",
+			Schema:      &Element{Name: "AccelerateConfiguration", Children: []Element{leaf("Status", "Enabled", "Suspended")}},
+			Default:     "<AccelerateConfiguration/>",
+			Put:         s3router.ActionPutBucketAccelerateConfiguration,
+			Get:         s3router.ActionGetBucketAccelerateConfiguration,
+		},
+		{
+			Subresource: "analytics",
+			Schema: &Element{Name: "AnalyticsConfiguration", Children: []Element{
+				required(leaf("Id")),
+				filter(),
+				required(parent("StorageClassAnalysis",
+					parent("DataExport",
+						required(leaf("OutputSchemaVersion", "V_1")),
+						required(parent("Destination",
+							required(parent("S3BucketDestination",
+								required(leaf("Format", "CSV")),
+								leaf("BucketAccountId"),
+								required(leaf("Bucket")),
+								leaf("Prefix"),
+							)),
+						)),
+					),
+				)),
+			}},
+			Collection: true,
+			ListResult: "ListBucketAnalyticsConfigurationResult",
+			NotFound:   s3errors.NoSuchConfiguration,
+			Put:        s3router.ActionPutBucketAnalyticsConfiguration,
+			Get:        s3router.ActionGetBucketAnalyticsConfiguration,
+			Delete:     s3router.ActionDeleteBucketAnalyticsConfiguration,
+			List:       s3router.ActionListBucketAnalyticsConfigurations,
+		},
+		{
+			Subresource: "cors",
+			Schema: &Element{Name: "CORSConfiguration", Children: []Element{
+				required(multiple(parent("CORSRule",
+					leaf("ID"),
+					multiple(leaf("AllowedHeader")),
+					required(multiple(leaf("AllowedMethod", "GET", "PUT", "POST", "DELETE", "HEAD"))),
+					required(multiple(leaf("AllowedOrigin"))),
+					multiple(leaf("ExposeHeader")),
+					integer("MaxAgeSeconds"),
+				))),
+			}},
+			NotFound: s3errors.NoSuchCORSConfiguration,
+			Put:      s3router.ActionPutBucketCors,
+			Get:      s3router.ActionGetBucketCors,
+			Delete:   s3router.ActionDeleteBucketCors,
+		},
+		{
+			Subresource: "encryption",
+			Schema: &Element{Name: "ServerSideEncryptionConfiguration", Children: []Element{
+				required(multiple(parent("Rule",
+					parent("ApplyServerSideEncryptionByDefault",
+						required(leaf("SSEAlgorithm", "AES256", "aws:kms", "aws:kms:dsse")),
+						leaf("KMSMasterKeyID"),
+					),
+					boolean("BucketKeyEnabled"),
+				))),
+			}},
+			NotFound: s3errors.ServerSideEncryptionConfigurationNotFoundError,
+			Put:      s3router.ActionPutBucketEncryption,
+			Get:      s3router.ActionGetBucketEncryption,
+			Delete:   s3router.ActionDeleteBucketEncryption,
+		},
+		{
+			Subresource: "intelligent-tiering",
+			Schema: &Element{Name: "IntelligentTieringConfiguration", Children: []Element{
+				required(leaf("Id")),
+				filter(),
+				required(leaf("Status", "Enabled", "Disabled")),
+				required(multiple(parent("Tiering",
+					required(integer("Days")),
+					required(leaf("AccessTier", "ARCHIVE_ACCESS", "DEEP_ARCHIVE_ACCESS")),
+				))),
+			}},
+			Collection: true,
+			ListResult: "ListBucketIntelligentTieringConfigurationsOutput",
+			NotFound:   s3errors.NoSuchConfiguration,
+			Put:        s3router.ActionPutBucketIntelligentTieringConfiguration,
+			Get:        s3router.ActionGetBucketIntelligentTieringConfiguration,
+			Delete:     s3router.ActionDeleteBucketIntelligentTieringConfiguration,
+			List:       s3router.ActionListBucketIntelligentTieringConfigurations,
+		},
+		{
+			Subresource: "inventory",
+			Validate: func(payload []byte, id string) error {
+				_, err := s3inventory.ParseConfiguration(payload, id)
+				return err
+			},
+			Collection: true,
+			ListResult: "ListInventoryConfigurationsResult",
+			NotFound:   s3errors.NoSuchConfiguration,
+			Put:        s3router.ActionPutBucketInventoryConfiguration,
+			Get:        s3router.ActionGetBucketInventoryConfiguration,
+			Delete:     s3router.ActionDeleteBucketInventoryConfiguration,
+			List:       s3router.ActionListBucketInventoryConfigurations,
+		},
+		{
+			Subresource: "lifecycle",
+			Schema: &Element{Name: "LifecycleConfiguration", Children: []Element{
+				required(multiple(parent("Rule",
+					leaf("ID"),
+					leaf("Prefix"),
+					filter(integer("ObjectSizeGreaterThan"), integer("ObjectSizeLessThan")),
+					required(leaf("Status", "Enabled", "Disabled")),
+					parent("Expiration", leaf("Date"), integer("Days"), boolean("ExpiredObjectDeleteMarker")),
+					multiple(parent("Transition", leaf("Date"), integer("Days"), required(leaf("StorageClass")))),
+					multiple(parent("NoncurrentVersionTransition",
+						integer("NoncurrentDays"), integer("NewerNoncurrentVersions"), required(leaf("StorageClass")))),
+					parent("NoncurrentVersionExpiration", integer("NoncurrentDays"), integer("NewerNoncurrentVersions")),
+					parent("AbortIncompleteMultipartUpload", integer("DaysAfterInitiation")),
+				))),
+			}},
+			NotFound: s3errors.NoSuchLifecycleConfiguration,
+			Put:      s3router.ActionPutBucketLifecycleConfiguration,
+			Get:      s3router.ActionGetBucketLifecycleConfiguration,
+			Delete:   s3router.ActionDeleteBucketLifecycle,
+		},
+		{
+			Subresource: "logging",
+			Validate: func(payload []byte, _ string) error {
+				_, err := s3accesslog.ParseStatus(payload)
+				return err
+			},
+			Default: "<BucketLoggingStatus/>",
+			Put:     s3router.ActionPutBucketLogging,
+			Get:     s3router.ActionGetBucketLogging,
+		},
+		{
+			Subresource: "metrics",
+			Validate: func(payload []byte, id string) error {
+				_, err := s3metrics.ParseConfiguration(payload, id)
+				return err
+			},
+			Collection: true,
+			ListResult: "ListMetricsConfigurationsResult",
+			NotFound:   s3errors.NoSuchConfiguration,
+			Put:        s3router.ActionPutBucketMetricsConfiguration,
+			Get:        s3router.ActionGetBucketMetricsConfiguration,
+			Delete:     s3router.ActionDeleteBucketMetricsConfiguration,
+			List:       s3router.ActionListBucketMetricsConfigurations,
+		},
+		{
+			Subresource: "object-lock",
+			Schema: &Element{Name: "ObjectLockConfiguration", Children: []Element{
+				leaf("ObjectLockEnabled", "Enabled"),
+				parent("Rule", parent("DefaultRetention", leaf("Mode", "GOVERNANCE", "COMPLIANCE"), integer("Days"), integer("Years"))),
+			}},
+			NotFound: s3errors.ObjectLockConfigurationNotFoundError,
+			Put:      s3router.ActionPutObjectLockConfiguration,
+			Get:      s3router.ActionGetObjectLockConfiguration,
+		},
+		{
+			Subresource: "ownershipControls",
+			Schema: &Element{Name: "OwnershipControls", Children: []Element{
+				required(multiple(parent("Rule",
+					required(leaf("ObjectOwnership", "BucketOwnerPreferred", "ObjectWriter", "BucketOwnerEnforced")),
+				))),
+			}},
+			NotFound: s3errors.OwnershipControlsNotFoundError,
+			Put:      s3router.ActionPutBucketOwnershipControls,
+			Get:      s3router.ActionGetBucketOwnershipControls,
+			Delete:   s3router.ActionDeleteBucketOwnershipControls,
+		},
+		{
+			Subresource: "publicAccessBlock",
+			Schema: &Element{Name: "PublicAccessBlockConfiguration", Children: []Element{
+				boolean("BlockPublicAcls"),
+				boolean("IgnorePublicAcls"),
+				boolean("BlockPublicPolicy"),
+				boolean("RestrictPublicBuckets"),
+			}},
+			NotFound: s3errors.NoSuchPublicAccessBlockConfiguration,
+			Put:      s3router.ActionPutPublicAccessBlock,
+			Get:      s3router.ActionGetPublicAccessBlock,
+			Delete:   s3router.ActionDeletePublicAccessBlock,
+		},
+		{
+			Subresource: "requestPayment",
+			Schema: &Element{Name: "RequestPaymentConfiguration", Children: []Element{
+				required(leaf("Payer", "Requester", "BucketOwner")),
+			}},
+			Default: "<RequestPaymentConfiguration><Payer>BucketOwner</Payer></RequestPaymentConfiguration>",
+			Put:     s3router.ActionPutBucketRequestPayment,
+			Get:     s3router.ActionGetBucketRequestPayment,
+		},
+		{
+			Subresource: "tagging",
+			Schema: &Element{Name: "Tagging", Children: []Element{
+				required(parent("TagSet", multiple(tag))),
+			}},
+			NotFound: s3errors.NoSuchTagSet,
+			Put:      s3router.ActionPutBucketTagging,
+			Get:      s3router.ActionGetBucketTagging,
+			Delete:   s3router.ActionDeleteBucketTagging,
+		},
+		{
+			Subresource: "versioning",
+			Schema: &Element{Name: "VersioningConfiguration", Children: []Element{
+				leaf("Status", "Enabled", "Suspended"),
+				leaf("MfaDelete", "Enabled", "Disabled"),
+			}},
+			Default: "<VersioningConfiguration/>",
+			Put:     s3router.ActionPutBucketVersioning,
+			Get:     s3router.ActionGetBucketVersioning,
+		},
+		{
+			Subresource: "website",
+			Validate: func(payload []byte, _ string) error {
+				_, err := s3website.ParseConfiguration(payload)
+				return err
+			},
+			NotFound: s3errors.NoSuchWebsiteConfiguration,
+			Put:      s3router.ActionPutBucketWebsite,
+			Get:      s3router.ActionGetBucketWebsite,
+			Delete:   s3router.ActionDeleteBucketWebsite,
+		},
+	}
+}
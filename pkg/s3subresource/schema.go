@@ -0,0 +1,204 @@
+package s3subresource
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type ValueType int
+
+const (
+	TypeString ValueType = iota
+	TypeInteger
+	TypeBoolean
+)
+
+// Element describes an XML element of a configuration document. Elements
+// without children hold a value.
+type Element struct {
+	Name     string
+	Required bool
+	Multiple bool
+	Type     ValueType
+	// Values restricts the value of the element when not empty.
+	Values   []string
+	Children []Element
+}
+
+// node is a parsed XML element.
+type node struct {
+	name     string
+	text     string
+	children []*node
+}
+
+// parseDocument parses an XML document into a tree, ignoring namespaces,
+// comments and processing instructions.
+func parseDocument(payload []byte) (*node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(payload))
+
+	var (
+		root  *node
+		stack []*node
+	)
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, s3errors.MalformedXML.New()
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local}
+
+			switch {
+			case len(stack) > 0:
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			case root == nil:
+				root = n
+			default:
+				return nil, s3errors.MalformedXML.New()
+			}
+
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	return root, nil
+}
+
+// validate checks a parsed document against the schema of its root.
+func (e *Element) validate(n *node) error {
+	if n.name != e.Name {
+		return s3errors.MalformedXML.New()
+	}
+
+	if len(e.Children) == 0 {
+		if len(n.children) > 0 {
+			return s3errors.MalformedXML.New()
+		}
+
+		return e.validateValue(strings.TrimSpace(n.text))
+	}
+
+	counts := make(map[string]int)
+	for _, child := range n.children {
+		schema := e.child(child.name)
+		if schema == nil {
+			return s3errors.MalformedXML.New()
+		}
+
+		counts[child.name]++
+		if counts[child.name] > 1 && !schema.Multiple {
+			return s3errors.MalformedXML.New()
+		}
+
+		if err := schema.validate(child); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range e.Children {
+		if child.Required && counts[child.Name] == 0 {
+			return s3errors.MalformedXML.New()
+		}
+	}
+
+	return nil
+}
+
+func (e *Element) child(name string) *Element {
+	for i := range e.Children {
+		if e.Children[i].Name == name {
+			return &e.Children[i]
+		}
+	}
+
+	return nil
+}
+
+func (e *Element) validateValue(value string) error {
+	switch e.Type {
+	case TypeInteger:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return s3errors.MalformedXML.New()
+		}
+	case TypeBoolean:
+		if value != "true" && value != "false" {
+			return s3errors.MalformedXML.New()
+		}
+	}
+
+	if len(e.Values) == 0 {
+		return nil
+	}
+
+	for _, allowed := range e.Values {
+		if value == allowed {
+			return nil
+		}
+	}
+
+	return s3errors.MalformedXML.New()
+}
+
+// childText returns the value of the first child element named name.
+func (n *node) childText(name string) (string, bool) {
+	for _, child := range n.children {
+		if child.name == name {
+			return strings.TrimSpace(child.text), true
+		}
+	}
+
+	return "", false
+}
+
+// encode writes the canonical form of the tree: values trimmed and the S3
+// namespace on the root element only.
+func (n *node) encode(buf *bytes.Buffer, root bool) error {
+	buf.WriteByte('<')
+	buf.WriteString(n.name)
+
+	if root {
+		buf.WriteString(` xmlns="` + s3consts.XMLNamespace + `"`)
+	}
+
+	buf.WriteByte('>')
+
+	if len(n.children) == 0 {
+		if err := xml.EscapeText(buf, []byte(strings.TrimSpace(n.text))); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range n.children {
+		if err := child.encode(buf, false); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString("</" + n.name + ">")
+
+	return nil
+}
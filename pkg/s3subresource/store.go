@@ -0,0 +1,295 @@
+package s3subresource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvjp/s3impl/pkg/s3trace"
+)
+
+var (
+	// ErrNotFound is returned when no document is stored.
+	ErrNotFound = errors.New("s3subresource: configuration not found")
+	// ErrCollectionFull is returned when a collection cannot hold another
+	// document.
+	ErrCollectionFull = errors.New("s3subresource: too many configurations")
+)
+
+// singleton is the document name of sub-resources without id.
+const singleton = "configuration"
+
+type Config struct {
+	// Dir is where the documents are stored, the sub-resources are not
+	// served when it is empty.
+	Dir string
+}
+
+// Store persists the configuration documents of the buckets, one file per
+// document under dir/<bucket>/<subresource>/.
+type Store struct {
+	dir      string
+	mu       sync.RWMutex
+	watchers map[string][]func(bucket string)
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("s3subresource: cannot create store: %w", err)
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+var tracer = otel.Tracer("github.com/lvjp/s3impl/pkg/s3subresource")
+
+func startSpan(ctx context.Context, operation, bucket, subresource, id string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "Store."+operation, trace.WithAttributes(
+		s3trace.Bucket.String(bucket),
+		attribute.String("s3.subresource", subresource),
+		attribute.String("s3.configuration_id", id),
+	))
+}
+
+// endSpan ends a span of the store, a missing document is not an error.
+func endSpan(span trace.Span, err error) {
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+
+	s3trace.End(span, err)
+}
+
+// Watch registers fn to be called when a document of a sub-resource is
+// stored or deleted, so that its readers can invalidate their caches.
+func (s *Store) Watch(subresource string, fn func(bucket string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.watchers == nil {
+		s.watchers = make(map[string][]func(string))
+	}
+
+	s.watchers[subresource] = append(s.watchers[subresource], fn)
+}
+
+// notify calls the watchers of a sub-resource, s.mu being held.
+func (s *Store) notify(bucket, subresource string) {
+	for _, fn := range s.watchers[subresource] {
+		fn(bucket)
+	}
+}
+
+func escape(name string) string {
+	escaped := url.PathEscape(name)
+	if strings.HasPrefix(escaped, ".") {
+		escaped = "%2E" + escaped[1:]
+	}
+
+	return escaped
+}
+
+func (s *Store) path(bucket, subresource, id string) string {
+	if id == "" {
+		id = singleton
+	}
+
+	return filepath.Join(s.dir, escape(bucket), escape(subresource), escape(id)+".xml")
+}
+
+// Put stores a document, id being empty for sub-resources without id.
+func (s *Store) Put(ctx context.Context, bucket, subresource, id string, document []byte) (err error) {
+	_, span := startSpan(ctx, "Put", bucket, subresource, id)
+	defer func() { endSpan(span, err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(bucket, subresource, id, document)
+}
+
+// PutCollection stores a document of a collection holding at most limit
+// documents. ErrCollectionFull is returned when a new document would
+// exceed it, replacing a document is always allowed.
+func (s *Store) PutCollection(ctx context.Context, bucket, subresource, id string, document []byte, limit int) (err error) {
+	_, span := startSpan(ctx, "PutCollection", bucket, subresource, id)
+	defer func() { endSpan(span, err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.list(bucket, subresource)
+	if err != nil {
+		return err
+	}
+
+	if i := sort.SearchStrings(ids, id); (i == len(ids) || ids[i] != id) && len(ids) >= limit {
+		return ErrCollectionFull
+	}
+
+	return s.write(bucket, subresource, id, document)
+}
+
+// write stores a document, s.mu being held.
+func (s *Store) write(bucket, subresource, id string, document []byte) error {
+	path := s.path(bucket, subresource, id)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("s3subresource: cannot store configuration: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, document, 0o600); err != nil {
+		return fmt.Errorf("s3subresource: cannot store configuration: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("s3subresource: cannot store configuration: %w", err)
+	}
+
+	s.notify(bucket, subresource)
+
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, bucket, subresource, id string) (_ []byte, err error) {
+	_, span := startSpan(ctx, "Get", bucket, subresource, id)
+	defer func() { endSpan(span, err) }()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	document, err := os.ReadFile(s.path(bucket, subresource, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("s3subresource: cannot read configuration: %w", err)
+	}
+
+	return document, nil
+}
+
+// Delete removes a document, deleting a missing document is not an error.
+func (s *Store) Delete(ctx context.Context, bucket, subresource, id string) (err error) {
+	_, span := startSpan(ctx, "Delete", bucket, subresource, id)
+	defer func() { endSpan(span, err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(bucket, subresource, id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("s3subresource: cannot delete configuration: %w", err)
+	}
+
+	s.notify(bucket, subresource)
+
+	return nil
+}
+
+// List returns the sorted ids of the documents of a sub-resource.
+func (s *Store) List(ctx context.Context, bucket, subresource string) (_ []string, err error) {
+	_, span := startSpan(ctx, "List", bucket, subresource, "")
+	defer func() { endSpan(span, err) }()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.list(bucket, subresource)
+}
+
+// list returns the sorted ids of the documents of a sub-resource, s.mu
+// being held.
+func (s *Store) list(bucket, subresource string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, escape(bucket), escape(subresource)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("s3subresource: cannot list configurations: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".xml")
+		if !ok {
+			continue
+		}
+
+		id, err := url.PathUnescape(name)
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// BucketUsage is the storage used by the documents of a bucket.
+type BucketUsage struct {
+	Bucket    string
+	Bytes     int64
+	Documents int64
+}
+
+// Usage returns the storage used by the documents of each bucket, sorted
+// by bucket.
+func (s *Store) Usage(ctx context.Context) (_ []BucketUsage, err error) {
+	_, span := tracer.Start(ctx, "Store.Usage")
+	defer func() { endSpan(span, err) }()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	buckets, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("s3subresource: cannot list buckets: %w", err)
+	}
+
+	var usages []BucketUsage
+	for _, entry := range buckets {
+		bucket, err := url.PathUnescape(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		usage := BucketUsage{Bucket: bucket}
+
+		err = filepath.WalkDir(filepath.Join(s.dir, entry.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".xml") {
+				return err
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			usage.Bytes += info.Size()
+			usage.Documents++
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("s3subresource: cannot compute usage: %w", err)
+		}
+
+		usages = append(usages, usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Bucket < usages[j].Bucket })
+
+	return usages, nil
+}
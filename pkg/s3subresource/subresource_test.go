@@ -0,0 +1,305 @@
+package s3subresource
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // Content-MD5 is part of the S3 API
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3request"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+func TestSchema(t *testing.T) {
+	schema := &Element{Name: "Root", Children: []Element{
+		required(leaf("Status", "Enabled", "Disabled")),
+		multiple(parent("Item", required(integer("Days")))),
+		boolean("Flag"),
+	}}
+
+	testCases := []struct {
+		payload string
+		valid   bool
+	}{
+		{"<Root><Status>Enabled</Status></Root>", true},
+		{`<Root xmlns="x"><Status> Enabled </Status><Item><Days>3</Days></Item><Item><Days>4</Days></Item><Flag>true</Flag></Root>`, true},
+		{"<Root></Root>", false},
+		{"<Other><Status>Enabled</Status></Other>", false},
+		{"<Root><Status>On</Status></Root>", false},
+		{"<Root><Status>Enabled</Status><Status>Enabled</Status></Root>", false},
+		{"<Root><Status>Enabled</Status><Unknown/></Root>", false},
+		{"<Root><Status>Enabled</Status><Item><Days>x</Days></Item></Root>", false},
+		{"<Root><Status>Enabled</Status><Item/></Root>", false},
+		{"<Root><Status>Enabled</Status><Flag>yes</Flag></Root>", false},
+		{"<Root><Status>Enabled<b/></Status></Root>", false},
+		{"<Root><Status>Enabled</Status>", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.payload, func(t *testing.T) {
+			tree, err := parseDocument([]byte(tc.payload))
+			if err == nil {
+				err = schema.validate(tree)
+			}
+
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+type server struct {
+	t       *testing.T
+	store   *Store
+	actions map[s3router.Action]s3router.ActionHandler
+}
+
+func newServer(t *testing.T, dir string) *server {
+	t.Helper()
+
+	store, err := NewStore(dir)
+	require.NoError(t, err)
+
+	logger := zerolog.Nop()
+
+	return &server{t: t, store: store, actions: NewHandler(&logger, store, DefaultKinds()).Actions()}
+}
+
+func (s *server) do(action s3router.Action, target, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	handler, exists := s.actions[action]
+	require.True(s.t, exists, action.String())
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, target, strings.NewReader(body))
+	handler.ServeAction(w, r, &s3router.Route{Action: action, Bucket: "bucket"})
+
+	return w
+}
+
+func requireError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	require.Equal(t, status, w.Code)
+	require.Contains(t, w.Body.String(), "<Code>"+code+"</Code>")
+}
+
+func TestSingleton(t *testing.T) {
+	dir := t.TempDir()
+	s := newServer(t, dir)
+
+	w := s.do(s3router.ActionGetBucketCors, "/bucket?cors", "")
+	requireError(t, w, http.StatusNotFound, "NoSuchCORSConfiguration")
+
+	w = s.do(s3router.ActionPutBucketCors, "/bucket?cors", "<CORSConfiguration><CORSRule><AllowedMethod>PATCH</AllowedMethod><AllowedOrigin>*</AllowedOrigin></CORSRule></CORSConfiguration>")
+	requireError(t, w, http.StatusBadRequest, "MalformedXML")
+
+	w = s.do(s3router.ActionPutBucketCors, "/bucket?cors", "<CORSConfiguration>\n  <CORSRule>\n    <AllowedMethod>GET</AllowedMethod>\n    <AllowedOrigin>*</AllowedOrigin>\n  </CORSRule>\n</CORSConfiguration>")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<CORSConfiguration xmlns="` + s3consts.XMLNamespace + `"><CORSRule><AllowedMethod>GET</AllowedMethod><AllowedOrigin>*</AllowedOrigin></CORSRule></CORSConfiguration>`
+
+	// Documents survive restarts.
+	s = newServer(t, dir)
+	w = s.do(s3router.ActionGetBucketCors, "/bucket?cors", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, s3consts.MimetypeApplicationXML, w.Header().Get("Content-Type"))
+	require.Equal(t, expected, w.Body.String())
+
+	w = s.do(s3router.ActionDeleteBucketCors, "/bucket?cors", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	requireError(t, s.do(s3router.ActionGetBucketCors, "/bucket?cors", ""), http.StatusNotFound, "NoSuchCORSConfiguration")
+
+	w = s.do(s3router.ActionGetBucketRequestPayment, "/bucket?requestPayment", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "<Payer>BucketOwner</Payer>")
+}
+
+func TestCollection(t *testing.T) {
+	s := newServer(t, t.TempDir())
+
+	metrics := func(id string) string {
+		return "<MetricsConfiguration><Id>" + id + "</Id><Filter><Prefix>logs/</Prefix></Filter></MetricsConfiguration>"
+	}
+
+	var changed []string
+	s.store.Watch("metrics", func(bucket string) { changed = append(changed, bucket) })
+
+	requireError(t, s.do(s3router.ActionPutBucketMetricsConfiguration, "/bucket?metrics", metrics("a")), http.StatusBadRequest, "InvalidArgument")
+	requireError(t, s.do(s3router.ActionPutBucketMetricsConfiguration, "/bucket?metrics&id=a", metrics("b")), http.StatusBadRequest, "InvalidArgument")
+	requireError(t, s.do(s3router.ActionGetBucketMetricsConfiguration, "/bucket?metrics&id=a", ""), http.StatusNotFound, "NoSuchConfiguration")
+
+	for i := 0; i < MaxListResults+1; i++ {
+		id := "m" + strconv.Itoa(1000+i)
+		require.Equal(t, http.StatusOK, s.do(s3router.ActionPutBucketMetricsConfiguration, "/bucket?metrics&id="+id, metrics(id)).Code)
+	}
+
+	w := s.do(s3router.ActionGetBucketMetricsConfiguration, "/bucket?metrics&id=m1000", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "<Id>m1000</Id>")
+
+	w = s.do(s3router.ActionListBucketMetricsConfigurations, "/bucket?metrics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, MaxListResults, strings.Count(w.Body.String(), "<MetricsConfiguration>"))
+	require.Contains(t, w.Body.String(), "<IsTruncated>true</IsTruncated><NextContinuationToken>m1099</NextContinuationToken></ListMetricsConfigurationsResult>")
+
+	w = s.do(s3router.ActionListBucketMetricsConfigurations, "/bucket?metrics&continuation-token=m1099", "")
+	require.Contains(t, w.Body.String(), `<ListMetricsConfigurationsResult xmlns="`+s3consts.XMLNamespace+`"><MetricsConfiguration><Id>m1100</Id>`)
+	require.Contains(t, w.Body.String(), "<IsTruncated>false</IsTruncated><ContinuationToken>m1099</ContinuationToken>")
+
+	require.Equal(t, http.StatusNoContent, s.do(s3router.ActionDeleteBucketMetricsConfiguration, "/bucket?metrics&id=m1000", "").Code)
+	requireError(t, s.do(s3router.ActionGetBucketMetricsConfiguration, "/bucket?metrics&id=m1000", ""), http.StatusNotFound, "NoSuchConfiguration")
+
+	require.Len(t, changed, MaxListResults+2)
+	require.Equal(t, "bucket", changed[0])
+}
+
+func TestValidate(t *testing.T) {
+	s := newServer(t, t.TempDir())
+
+	w := s.do(s3router.ActionPutBucketInventoryConfiguration, "/bucket?inventory&id=a", "<InventoryConfiguration><Id>a</Id></InventoryConfiguration>")
+	requireError(t, w, http.StatusBadRequest, "MalformedXML")
+
+	w = s.do(s3router.ActionPutBucketWebsite, "/bucket?website", "<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>")
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestIntegrity(t *testing.T) {
+	s := newServer(t, t.TempDir())
+	handler := s.actions[s3router.ActionPutBucketTagging]
+
+	body := "<Tagging><TagSet><Tag><Key>k</Key><Value>v</Value></Tag></TagSet></Tagging>"
+	digest := md5.Sum([]byte(body)) //nolint:gosec // Content-MD5 is part of the S3 API
+
+	put := func(name, value string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPut, "/bucket?tagging", strings.NewReader(body))
+		r.Header.Set(name, value)
+		handler.ServeAction(w, r, &s3router.Route{Action: s3router.ActionPutBucketTagging, Bucket: "bucket"})
+
+		return w
+	}
+
+	requireError(t, put("Content-MD5", base64.StdEncoding.EncodeToString(make([]byte, md5.Size))), http.StatusBadRequest, "BadDigest")
+	requireError(t, put("Content-MD5", "invalid"), http.StatusBadRequest, "InvalidDigest")
+	requireError(t, put("x-amz-checksum-crc32", "AAAAAA=="), http.StatusBadRequest, "BadDigest")
+	require.Equal(t, http.StatusOK, put("Content-MD5", base64.StdEncoding.EncodeToString(digest[:])).Code)
+}
+
+func TestPutCollection(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, store.PutCollection(ctx, "bucket", "metrics", "a", []byte("<a/>"), 2))
+	require.NoError(t, store.PutCollection(ctx, "bucket", "metrics", "b", []byte("<b/>"), 2))
+	require.ErrorIs(t, store.PutCollection(ctx, "bucket", "metrics", "c", []byte("<c/>"), 2), ErrCollectionFull)
+	require.NoError(t, store.PutCollection(ctx, "bucket", "metrics", "a", []byte("<a2/>"), 2))
+
+	document, err := store.Get(ctx, "bucket", "metrics", "a")
+	require.NoError(t, err)
+	require.Equal(t, "<a2/>", string(document))
+}
+
+func TestUsage(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	usages, err := store.Usage(ctx)
+	require.NoError(t, err)
+	require.Empty(t, usages)
+
+	require.NoError(t, store.Put(ctx, "bucket", "cors", "", []byte("<CORSConfiguration/>")))
+	require.NoError(t, store.Put(ctx, "bucket", "metrics", "all", []byte("<MetricsConfiguration/>")))
+	require.NoError(t, store.Put(ctx, ".other", "tagging", "", []byte("<Tagging/>")))
+
+	usages, err = store.Usage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []BucketUsage{
+		{Bucket: ".other", Bytes: 10, Documents: 1},
+		{Bucket: "bucket", Bytes: 43, Documents: 2},
+	}, usages)
+}
+
+func TestCache(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	parsed := 0
+
+	cache := NewCache(store, "website", func(document []byte) (string, error) {
+		parsed++
+		return string(document), nil
+	})
+
+	_, found, err := cache.Get(ctx, "bucket")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, store.Put(ctx, "bucket", "website", "", []byte("first")))
+	require.NoError(t, store.Put(ctx, "bucket", "cors", "", []byte("<CORSConfiguration/>")))
+
+	for i := 0; i < 2; i++ {
+		value, found, err := cache.Get(ctx, "bucket")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "first", value)
+	}
+
+	require.Equal(t, 1, parsed)
+
+	require.NoError(t, store.Put(ctx, "bucket", "website", "", []byte("second")))
+
+	value, _, err := cache.Get(ctx, "bucket")
+	require.NoError(t, err)
+	require.Equal(t, "second", value)
+
+	require.NoError(t, store.Delete(ctx, "bucket", "website", ""))
+
+	_, found, err = cache.Get(ctx, "bucket")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestKindsDecoding(t *testing.T) {
+	for _, kind := range DefaultKinds() {
+		for action, needsID := range map[s3router.Action]bool{
+			kind.Put:    kind.Collection,
+			kind.Get:    kind.Collection,
+			kind.Delete: kind.Collection,
+			kind.List:   false,
+		} {
+			if action == s3router.ActionUnknow {
+				continue
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/bucket?"+kind.Subresource, http.NoBody)
+			input, err := s3request.Decode(r, &s3router.Route{Action: action, Bucket: "bucket"})
+
+			if needsID {
+				require.Error(t, err, "%s must require an id", action)
+				continue
+			}
+
+			require.NoError(t, err, action.String())
+			require.IsType(t, &s3request.Configuration{}, input, action.String())
+		}
+	}
+}
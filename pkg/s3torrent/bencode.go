@@ -0,0 +1,58 @@
+package s3torrent
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// bencode encodes v in the BitTorrent encoding. Dictionary keys are sorted,
+// which makes the info hash of a torrent reproducible.
+func bencode(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case int:
+		return bencode(buf, int64(v))
+	case int64:
+		buf.WriteByte('i')
+		buf.WriteString(strconv.FormatInt(v, 10))
+		buf.WriteByte('e')
+	case string:
+		return bencode(buf, []byte(v))
+	case []byte:
+		buf.WriteString(strconv.Itoa(len(v)))
+		buf.WriteByte(':')
+		buf.Write(v)
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range v {
+			if err := bencode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		buf.WriteByte('d')
+		for _, key := range keys {
+			if err := bencode(buf, key); err != nil {
+				return err
+			}
+
+			if err := bencode(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("s3torrent: cannot bencode %T", v)
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package s3torrent generates the torrent files of GetObjectTorrent.
+//
+// It is a library only: nothing builds a Server and GetObjectTorrent is not
+// served until objects are stored.
+package s3torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec // SHA1 piece hashes are part of the BitTorrent protocol
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultPieceLength = 256 << 10
+
+	ContentType = "application/x-bittorrent"
+)
+
+// Pieces are the SHA1 hashes of the consecutive pieces of an object
+// version, identified by its ETag.
+type Pieces struct {
+	ETag        string
+	PieceLength int64
+	Length      int64
+	Hashes      []byte
+}
+
+// ComputePieces hashes the content of an object.
+func ComputePieces(r io.Reader, etag string, pieceLength int64) (*Pieces, error) {
+	if pieceLength <= 0 {
+		return nil, fmt.Errorf("s3torrent: invalid piece length %d", pieceLength)
+	}
+
+	pieces := &Pieces{ETag: etag, PieceLength: pieceLength}
+	piece := make([]byte, pieceLength)
+
+	for {
+		n, err := io.ReadFull(r, piece)
+		if n > 0 {
+			sum := sha1.Sum(piece[:n]) //nolint:gosec // see import
+			pieces.Hashes = append(pieces.Hashes, sum[:]...)
+			pieces.Length += int64(n)
+		}
+
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return pieces, nil
+		case err != nil:
+			return nil, fmt.Errorf("s3torrent: cannot read object: %w", err)
+		}
+	}
+}
+
+// MarshalText serializes the pieces for the internal storage of the
+// object version.
+func (p *Pieces) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatInt(p.PieceLength, 10) + ";" +
+		strconv.FormatInt(p.Length, 10) + ";" +
+		p.ETag + ";" +
+		base64.StdEncoding.EncodeToString(p.Hashes)), nil
+}
+
+func (p *Pieces) UnmarshalText(text []byte) error {
+	fields := strings.SplitN(string(text), ";", 4)
+	if len(fields) != 4 {
+		return errors.New("s3torrent: malformed pieces")
+	}
+
+	pieceLength, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("s3torrent: malformed piece length: %w", err)
+	}
+
+	length, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("s3torrent: malformed length: %w", err)
+	}
+
+	hashes, err := base64.StdEncoding.DecodeString(fields[3])
+	if err != nil {
+		return fmt.Errorf("s3torrent: malformed hashes: %w", err)
+	}
+
+	*p = Pieces{ETag: fields[2], PieceLength: pieceLength, Length: length, Hashes: hashes}
+
+	return nil
+}
+
+// Torrent builds a single file .torrent whose web seed (BEP 19) is the
+// object URL, so that clients can download from this server without any
+// peer. The tracker is optional.
+func Torrent(name, webSeed, announce string, pieces *Pieces) ([]byte, error) {
+	torrent := map[string]any{
+		"info": map[string]any{
+			"length":       pieces.Length,
+			"name":         name,
+			"piece length": pieces.PieceLength,
+			"pieces":       pieces.Hashes,
+		},
+		"url-list": []any{webSeed},
+	}
+
+	if announce != "" {
+		torrent["announce"] = announce
+	}
+
+	var buf bytes.Buffer
+	if err := bencode(&buf, torrent); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type Config struct {
+	PieceLength int64 `yaml:"pieceLength"`
+	// Announce is the tracker URL, the torrents only rely on web seeding
+	// when it is empty.
+	Announce string
+}
+
+// Object is the object version a torrent is requested for.
+type Object struct {
+	Bucket string
+	Key    string
+	ETag   string
+	Size   int64
+}
+
+// Store gives access to the object content and caches the piece hashes
+// with the object version. They must not be kept in the user metadata,
+// which is returned to the clients and limited to 2 KB.
+type Store interface {
+	Open(ctx context.Context, object *Object) (io.ReadCloser, error)
+	// Pieces returns the cached piece hashes of object, nil when there are
+	// none.
+	Pieces(ctx context.Context, object *Object) (*Pieces, error)
+	SetPieces(ctx context.Context, object *Object, pieces *Pieces) error
+}
+
+type Server struct {
+	store  Store
+	config Config
+}
+
+func NewServer(store Store, config Config) (*Server, error) {
+	switch {
+	case config.PieceLength == 0:
+		config.PieceLength = DefaultPieceLength
+	case config.PieceLength < 0:
+		return nil, fmt.Errorf("s3torrent: piece length must be positive, got %d", config.PieceLength)
+	}
+
+	return &Server{store: store, config: config}, nil
+}
+
+// Pieces returns the piece hashes of object, computing and caching them
+// when none are cached for this version and piece length.
+func (s *Server) Pieces(ctx context.Context, object *Object) (*Pieces, error) {
+	cached, err := s.store.Pieces(ctx, object)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil && cached.ETag == object.ETag && cached.PieceLength == s.config.PieceLength {
+		return cached, nil
+	}
+
+	body, err := s.store.Open(ctx, object)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	pieces, err := ComputePieces(body, object.ETag, s.config.PieceLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.SetPieces(ctx, object, pieces); err != nil {
+		return nil, err
+	}
+
+	return pieces, nil
+}
+
+// ServeTorrent answers a GetObjectTorrent request.
+func (s *Server) ServeTorrent(w http.ResponseWriter, r *http.Request, object *Object) error {
+	pieces, err := s.Pieces(r.Context(), object)
+	if err != nil {
+		return err
+	}
+
+	torrent, err := Torrent(path.Base(object.Key), ObjectURL(r), s.config.Announce, pieces)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(torrent)))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(torrent); err != nil {
+		return fmt.Errorf("s3torrent: cannot write torrent: %w", err)
+	}
+
+	return nil
+}
+
+// ObjectURL is the URL of the object a GetObjectTorrent request was sent
+// for, used as web seed.
+func ObjectURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + r.URL.EscapedPath()
+}
@@ -0,0 +1,106 @@
+package s3torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec // test vectors
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBencode(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, bencode(&buf, map[string]any{
+		"z": []any{1, "ab"},
+		"a": int64(-3),
+	}))
+	require.Equal(t, "d1:ai-3e1:zli1e2:abee", buf.String())
+
+	require.Error(t, bencode(&buf, 1.5))
+}
+
+func TestComputePieces(t *testing.T) {
+	pieces, err := ComputePieces(strings.NewReader("abcdefghij"), `"etag"`, 4)
+	require.NoError(t, err)
+	require.EqualValues(t, 10, pieces.Length)
+
+	var expected []byte
+	for _, piece := range []string{"abcd", "efgh", "ij"} {
+		sum := sha1.Sum([]byte(piece)) //nolint:gosec // test vectors
+		expected = append(expected, sum[:]...)
+	}
+	require.Equal(t, expected, pieces.Hashes)
+
+	text, err := pieces.MarshalText()
+	require.NoError(t, err)
+
+	var parsed Pieces
+	require.NoError(t, parsed.UnmarshalText(text))
+	require.Equal(t, pieces, &parsed)
+
+	require.Error(t, parsed.UnmarshalText([]byte("garbage")))
+
+	_, err = ComputePieces(strings.NewReader("abc"), `"etag"`, -1)
+	require.Error(t, err)
+}
+
+type store struct {
+	content string
+	opens   int
+	pieces  *Pieces
+}
+
+func (s *store) Open(context.Context, *Object) (io.ReadCloser, error) {
+	s.opens++
+	return io.NopCloser(strings.NewReader(s.content)), nil
+}
+
+func (s *store) Pieces(context.Context, *Object) (*Pieces, error) {
+	return s.pieces, nil
+}
+
+func (s *store) SetPieces(_ context.Context, _ *Object, pieces *Pieces) error {
+	s.pieces = pieces
+	return nil
+}
+
+func TestServeTorrent(t *testing.T) {
+	_, err := NewServer(&store{}, Config{PieceLength: -1})
+	require.Error(t, err)
+
+	s := &store{content: "hello"}
+	server, err := NewServer(s, Config{PieceLength: 4})
+	require.NoError(t, err)
+
+	object := &Object{Bucket: "bucket", Key: "data/file.bin", ETag: `"1"`, Size: 5}
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://bucket.example.com/data/file.bin?torrent", nil)
+		require.NoError(t, server.ServeTorrent(w, r, object))
+
+		return w
+	}
+
+	w := serve()
+	require.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+	hell, o := sha1.Sum([]byte("hell")), sha1.Sum([]byte("o")) //nolint:gosec // test vectors
+	expected := "d4:infod6:lengthi5e4:name8:file.bin12:piece lengthi4e6:pieces40:" +
+		string(hell[:]) + string(o[:]) +
+		"e8:url-listl39:http://bucket.example.com/data/file.binee"
+	require.Equal(t, expected, w.Body.String())
+	require.NotNil(t, s.pieces)
+
+	serve()
+	require.Equal(t, 1, s.opens, "piece hashes must be cached")
+
+	object.ETag = `"2"`
+	serve()
+	require.Equal(t, 2, s.opens, "cache must be invalidated by a new version")
+}
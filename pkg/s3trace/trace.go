@@ -0,0 +1,139 @@
+package s3trace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvjp/s3impl/pkg/s3response"
+)
+
+// Attributes of the spans.
+const (
+	Action    = attribute.Key("s3.action")
+	Bucket    = attribute.Key("s3.bucket")
+	Key       = attribute.Key("s3.key")
+	RequestID = attribute.Key("s3.request_id")
+	ErrorCode = attribute.Key("s3.error_code")
+)
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+type Config struct {
+	// Exporter is otlp or stdout, tracing is disabled when it is empty.
+	Exporter string
+	// Endpoint is the host:port of the OTLP/HTTP collector, localhost:4318
+	// by default.
+	Endpoint    string
+	Insecure    bool
+	ServiceName string `yaml:"serviceName"`
+}
+
+// NewProvider returns the tracer provider exporting the spans as
+// configured, nil when tracing is disabled.
+func NewProvider(ctx context.Context, config Config) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch config.Exporter {
+	case "":
+		return nil, nil //nolint:nilnil // tracing is disabled
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{}
+		if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("s3trace: unknown exporter %q", config.Exporter)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("s3trace: cannot create exporter: %w", err)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = "s3impl"
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("s3trace: cannot create resource: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// Install makes provider and the W3C trace context propagation the global
+// ones, used by the instrumented packages.
+func Install(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+var tracer = otel.Tracer("github.com/lvjp/s3impl/pkg/s3trace")
+
+// Handler starts the server span of the requests, continuing the trace of
+// their traceparent header.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := tracer.Start(ctx, "ServeHTTP",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rw := s3response.NewRecorder(w)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rw.Status()))
+
+		if rw.ErrorCode != "" {
+			span.SetAttributes(ErrorCode.String(rw.ErrorCode))
+		}
+
+		if rw.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, rw.ErrorCode)
+		}
+	})
+}
+
+// End ends a span, recording err when it is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,66 @@
+package s3trace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+func TestNewProvider(t *testing.T) {
+	provider, err := NewProvider(context.Background(), Config{})
+	require.NoError(t, err)
+	require.Nil(t, provider)
+
+	_, err = NewProvider(context.Background(), Config{Exporter: "zipkin"})
+	require.Error(t, err)
+
+	provider, err = NewProvider(context.Background(), Config{Exporter: ExporterStdout})
+	require.NoError(t, err)
+	require.NoError(t, provider.Shutdown(context.Background()))
+}
+
+func TestHandler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	Install(provider)
+
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := provider.Tracer("test").Start(r.Context(), "Store.Get")
+		End(span, errors.New("disk failure"))
+
+		writer := s3errors.APIWriter{}
+		require.NoError(t, writer.Write(&s3errors.S3Error{HTTPStatusCode: http.StatusInternalServerError, Code: "InternalError"}, w, r))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	store, server := spans[0], spans[1]
+	require.Equal(t, "Store.Get", store.Name())
+	require.Equal(t, codes.Error, store.Status().Code)
+	require.Equal(t, server.SpanContext().SpanID(), store.Parent().SpanID())
+
+	require.Equal(t, "ServeHTTP", server.Name())
+	require.Equal(t, trace.SpanKindServer, server.SpanKind())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	require.True(t, server.Parent().IsRemote())
+	require.Equal(t, codes.Error, server.Status().Code)
+	require.Contains(t, server.Attributes(), ErrorCode.String("InternalError"))
+	require.Contains(t, server.Attributes(), attribute.Int("http.response.status_code", http.StatusInternalServerError))
+}
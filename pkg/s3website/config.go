@@ -0,0 +1,137 @@
+package s3website
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+)
+
+type Configuration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration"`
+	Namespace             string                 `xml:"xmlns,attr,omitempty"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:",omitempty"`
+	IndexDocument         *IndexDocument         `xml:",omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:",omitempty"`
+	RoutingRules          *RoutingRules          `xml:",omitempty"`
+}
+
+type RedirectAllRequestsTo struct {
+	HostName string
+	Protocol string `xml:",omitempty"`
+}
+
+type IndexDocument struct {
+	Suffix string
+}
+
+type ErrorDocument struct {
+	Key string
+}
+
+type RoutingRules struct {
+	Rules []RoutingRule `xml:"RoutingRule"`
+}
+
+type RoutingRule struct {
+	Condition *Condition `xml:",omitempty"`
+	Redirect  Redirect
+}
+
+type Condition struct {
+	KeyPrefixEquals             string `xml:",omitempty"`
+	HTTPErrorCodeReturnedEquals int    `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+type Redirect struct {
+	HostName             string `xml:",omitempty"`
+	HTTPRedirectCode     int    `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string `xml:",omitempty"`
+	ReplaceKeyPrefixWith string `xml:",omitempty"`
+	ReplaceKeyWith       string `xml:",omitempty"`
+}
+
+// ParseConfiguration decodes and validates a PutBucketWebsite payload.
+func ParseConfiguration(payload []byte) (*Configuration, error) {
+	var config Configuration
+	if err := xml.Unmarshal(payload, &config); err != nil {
+		return nil, s3errors.MalformedXML.New()
+	}
+
+	config.Namespace = s3consts.XMLNamespace
+
+	if redirect := config.RedirectAllRequestsTo; redirect != nil {
+		if config.IndexDocument != nil || config.ErrorDocument != nil || config.RoutingRules != nil {
+			return nil, s3errors.InvalidArgument.New().WithMessage("RedirectAllRequestsTo cannot be provided in conjunction with other Routing Rules.")
+		}
+
+		if redirect.HostName == "" || !validProtocol(redirect.Protocol) {
+			return nil, s3errors.InvalidArgument.New().WithMessage("Invalid RedirectAllRequestsTo element.")
+		}
+
+		return &config, nil
+	}
+
+	if config.IndexDocument == nil {
+		return nil, s3errors.InvalidArgument.New().WithMessage("A value for IndexDocument Suffix must be provided if RedirectAllRequestsTo is empty")
+	}
+
+	if suffix := config.IndexDocument.Suffix; suffix == "" || strings.Contains(suffix, "/") {
+		return nil, s3errors.InvalidArgument.New().WithMessage("The IndexDocument Suffix is not well formed")
+	}
+
+	if config.ErrorDocument != nil && config.ErrorDocument.Key == "" {
+		return nil, s3errors.InvalidArgument.New().WithMessage("The ErrorDocument Key is not well formed")
+	}
+
+	if config.RoutingRules != nil {
+		for _, rule := range config.RoutingRules.Rules {
+			if err := rule.validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+func (r *RoutingRule) validate() error {
+	redirect := r.Redirect
+
+	if redirect.ReplaceKeyPrefixWith != "" && redirect.ReplaceKeyWith != "" {
+		return s3errors.InvalidArgument.New().WithMessage("You can only define ReplaceKeyPrefix or ReplaceKey but not both.")
+	}
+
+	if !validProtocol(redirect.Protocol) {
+		return s3errors.InvalidArgument.New().WithMessage("Invalid protocol, protocol can be http or https.")
+	}
+
+	if code := redirect.HTTPRedirectCode; code != 0 && (code < 300 || code > 399) {
+		return s3errors.InvalidArgument.New().WithMessage("The provided HTTP redirect code is not valid. It should be a string containing a number 3XX.")
+	}
+
+	if cond := r.Condition; cond != nil && cond.HTTPErrorCodeReturnedEquals != 0 &&
+		(cond.HTTPErrorCodeReturnedEquals < 400 || cond.HTTPErrorCodeReturnedEquals > 599) {
+		return s3errors.InvalidArgument.New().WithMessage("The provided HTTP error code is not valid. Valid codes are 4XX or 5XX.")
+	}
+
+	return nil
+}
+
+func validProtocol(protocol string) bool {
+	return protocol == "" || protocol == "http" || protocol == "https"
+}
+
+// ValidateRedirectLocation checks the x-amz-website-redirect-location
+// header of PutObject and CopyObject.
+func ValidateRedirectLocation(location string) error {
+	if location == "" ||
+		strings.HasPrefix(location, "/") ||
+		strings.HasPrefix(location, "http://") ||
+		strings.HasPrefix(location, "https://") {
+		return nil
+	}
+
+	return s3errors.InvalidArgument.New().WithMessage("The website redirect location must have a prefix of 'http://' or 'https://' or '/'.")
+}
@@ -0,0 +1,278 @@
+package s3website
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lvjp/s3impl/pkg/s3consts"
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+)
+
+// Object is an object served by the website endpoint.
+type Object struct {
+	Content      io.ReadSeeker
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	// RedirectLocation is the x-amz-website-redirect-location stored with
+	// the object.
+	RedirectLocation string
+}
+
+// Store reads the objects of website buckets. A missing key must be
+// reported as a *s3errors.S3Error with the NoSuchKey code.
+type Store interface {
+	GetObject(ctx context.Context, bucket, key string) (*Object, error)
+}
+
+// ConfigurationFunc returns the website configuration of a bucket, nil
+// when it has none.
+type ConfigurationFunc func(ctx context.Context, bucket string) (*Configuration, error)
+
+type Server struct {
+	Store          Store
+	Configurations ConfigurationFunc
+}
+
+// ServeAction serves the website actions with the configuration of the
+// bucket, answering NoSuchWebsiteConfiguration when it has none.
+func (s *Server) ServeAction(w http.ResponseWriter, r *http.Request, route *s3router.Route) {
+	config, err := s.Configurations(r.Context(), route.Bucket)
+	if err == nil && config == nil {
+		err = s3errors.NoSuchWebsiteConfiguration.New()
+	}
+
+	if err != nil {
+		status, code := errorStatus(err)
+		writeError(w, r, status, code, "")
+
+		return
+	}
+
+	s.ServeWebsite(w, r, route, config)
+}
+
+// ServeWebsite answers a request routed with the website request style.
+func (s *Server) ServeWebsite(w http.ResponseWriter, r *http.Request, route *s3router.Route, config *Configuration) {
+	if redirect := config.RedirectAllRequestsTo; redirect != nil {
+		location := scheme(r, redirect.Protocol) + "://" + redirect.HostName + keyPath(route.Key)
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+
+		return
+	}
+
+	if rule, matched := config.matchRule(route.Key, 0); matched {
+		s.redirect(w, r, route, rule)
+		return
+	}
+
+	key := route.Key
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += config.IndexDocument.Suffix
+	}
+
+	obj, err := s.Store.GetObject(r.Context(), route.Bucket, key)
+	if err == nil {
+		defer closeContent(obj)
+		s.serveObject(w, r, obj, http.StatusOK)
+
+		return
+	}
+
+	status, code := errorStatus(err)
+
+	if code == "NoSuchKey" && key == route.Key {
+		// Like S3, redirect to the directory when it has an index document.
+		if index, err := s.Store.GetObject(r.Context(), route.Bucket, key+"/"+config.IndexDocument.Suffix); err == nil {
+			closeContent(index)
+			http.Redirect(w, r, keyPath(key+"/"), http.StatusFound)
+
+			return
+		}
+	}
+
+	if rule, matched := config.matchRule(route.Key, status); matched {
+		s.redirect(w, r, route, rule)
+		return
+	}
+
+	if config.ErrorDocument != nil && status != http.StatusInternalServerError {
+		if doc, err := s.Store.GetObject(r.Context(), route.Bucket, config.ErrorDocument.Key); err == nil {
+			defer closeContent(doc)
+			s.serveObject(w, r, doc, status)
+
+			return
+		}
+	}
+
+	writeError(w, r, status, code, route.Key)
+}
+
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, obj *Object, status int) {
+	if obj.RedirectLocation != "" {
+		http.Redirect(w, r, obj.RedirectLocation, http.StatusMovedPermanently)
+		return
+	}
+
+	header := w.Header()
+	if obj.ContentType != "" {
+		header.Set("Content-Type", obj.ContentType)
+	}
+	if obj.ETag != "" {
+		header.Set("ETag", obj.ETag)
+	}
+
+	if status == http.StatusOK {
+		http.ServeContent(w, r, "", obj.LastModified, obj.Content)
+		return
+	}
+
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, obj.Content)
+	}
+}
+
+func (s *Server) redirect(w http.ResponseWriter, r *http.Request, route *s3router.Route, rule *RoutingRule) {
+	redirect := rule.Redirect
+
+	host := redirect.HostName
+	if host == "" {
+		host = r.Host
+	}
+
+	key := route.Key
+	switch {
+	case redirect.ReplaceKeyWith != "":
+		key = redirect.ReplaceKeyWith
+	case redirect.ReplaceKeyPrefixWith != "":
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+
+		key = redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+
+	code := redirect.HTTPRedirectCode
+	if code == 0 {
+		code = http.StatusMovedPermanently
+	}
+
+	http.Redirect(w, r, scheme(r, redirect.Protocol)+"://"+host+keyPath(key), code)
+}
+
+// matchRule returns the first routing rule applying to key. With a zero
+// status, only rules without error code condition are considered.
+func (c *Configuration) matchRule(key string, status int) (*RoutingRule, bool) {
+	if c.RoutingRules == nil {
+		return nil, false
+	}
+
+	for i := range c.RoutingRules.Rules {
+		rule := &c.RoutingRules.Rules[i]
+
+		errorCode := 0
+		prefix := ""
+		if rule.Condition != nil {
+			errorCode = rule.Condition.HTTPErrorCodeReturnedEquals
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+
+		if errorCode == status && strings.HasPrefix(key, prefix) {
+			return rule, true
+		}
+	}
+
+	return nil, false
+}
+
+// keyPath returns the escaped path of a key, for the Location headers.
+func keyPath(key string) string {
+	return (&url.URL{Path: "/" + key}).EscapedPath()
+}
+
+func scheme(r *http.Request, protocol string) string {
+	switch {
+	case protocol != "":
+		return protocol
+	case r.TLS != nil:
+		return "https"
+	default:
+		return "http"
+	}
+}
+
+func closeContent(obj *Object) {
+	if closer, ok := obj.Content.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func errorStatus(err error) (int, string) {
+	var s3err *s3errors.S3Error
+	if errors.As(err, &s3err) {
+		return s3err.HTTPStatusCode, s3err.Code
+	}
+
+	return http.StatusInternalServerError, "InternalError"
+}
+
+var errorMessages = map[string]string{
+	"AccessDenied":  "Access Denied",
+	"InternalError": "We encountered an internal error. Please try again.",
+	"NoSuchBucket":  "The specified bucket does not exist",
+	"NoSuchKey":     "The specified key does not exist.",
+
+	"NoSuchWebsiteConfiguration": "The specified bucket does not have a website configuration",
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<html>
+<head><title>{{.Status}}</title></head>
+<body>
+<h1>{{.Status}}</h1>
+<ul>
+<li>Code: {{.Code}}</li>
+<li>Message: {{.Message}}</li>
+{{- if .Key}}
+<li>Key: {{.Key}}</li>
+{{- end}}
+<li>RequestId: {{.RequestID}}</li>
+</ul>
+<hr/>
+</body>
+</html>
+`))
+
+// writeError renders an error the way S3 website endpoints do: as an HTML
+// page instead of an XML document.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, key string) {
+	w.Header().Set("Content-Type", s3consts.ContentTypeTextHTML)
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	_ = errorPage.Execute(w, struct {
+		Status    string
+		Code      string
+		Message   string
+		Key       string
+		RequestID string
+	}{
+		Status:    fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Code:      code,
+		Message:   errorMessages[code],
+		Key:       key,
+		RequestID: w.Header().Get("x-amz-request-id"),
+	})
+}
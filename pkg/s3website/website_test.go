@@ -0,0 +1,180 @@
+package s3website
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lvjp/s3impl/pkg/s3errors"
+	"github.com/lvjp/s3impl/pkg/s3router"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore map[string]*Object
+
+func (m memoryStore) GetObject(_ context.Context, bucket, key string) (*Object, error) {
+	obj, exists := m[bucket+"/"+key]
+	if !exists {
+		return nil, &s3errors.S3Error{HTTPStatusCode: http.StatusNotFound, Code: "NoSuchKey"}
+	}
+
+	copied := *obj
+	copied.Content = strings.NewReader(key)
+
+	return &copied, nil
+}
+
+func TestParseConfiguration(t *testing.T) {
+	_, err := ParseConfiguration([]byte(`<WebsiteConfiguration>` +
+		`<IndexDocument><Suffix>index.html</Suffix></IndexDocument>` +
+		`<ErrorDocument><Key>error.html</Key></ErrorDocument>` +
+		`<RoutingRules><RoutingRule><Condition><KeyPrefixEquals>old/</KeyPrefixEquals></Condition>` +
+		`<Redirect><ReplaceKeyPrefixWith>new/</ReplaceKeyPrefixWith></Redirect></RoutingRule></RoutingRules>` +
+		`</WebsiteConfiguration>`))
+	require.NoError(t, err)
+
+	for name, payload := range map[string]string{
+		"NoIndex":       `<WebsiteConfiguration></WebsiteConfiguration>`,
+		"IndexSlash":    `<WebsiteConfiguration><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>`,
+		"RedirectMixed": `<WebsiteConfiguration><RedirectAllRequestsTo><HostName>a</HostName></RedirectAllRequestsTo><IndexDocument><Suffix>i</Suffix></IndexDocument></WebsiteConfiguration>`,
+		"BadProtocol":   `<WebsiteConfiguration><RedirectAllRequestsTo><HostName>a</HostName><Protocol>ftp</Protocol></RedirectAllRequestsTo></WebsiteConfiguration>`,
+		"BadCode": `<WebsiteConfiguration><IndexDocument><Suffix>i</Suffix></IndexDocument>` +
+			`<RoutingRules><RoutingRule><Redirect><HttpRedirectCode>200</HttpRedirectCode></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConfiguration([]byte(payload))
+			require.Error(t, err)
+			require.Equal(t, "InvalidArgument", err.(*s3errors.S3Error).Code)
+		})
+	}
+
+	require.NoError(t, ValidateRedirectLocation("/docs/"))
+	require.NoError(t, ValidateRedirectLocation("https://example.com/"))
+	require.Error(t, ValidateRedirectLocation("example.com"))
+}
+
+func TestServeWebsite(t *testing.T) {
+	server := &Server{Store: memoryStore{
+		"site/index.html":         {ContentType: "text/html"},
+		"site/docs/index.html":    {ContentType: "text/html"},
+		"site/my docs/index.html": {ContentType: "text/html"},
+		"site/error.html":         {ContentType: "text/html"},
+		"site/moved.html":         {RedirectLocation: "/index.html"},
+		"site/style.css":          {ContentType: "text/css", ETag: `"abc"`},
+	}}
+
+	config, err := ParseConfiguration([]byte(`<WebsiteConfiguration>` +
+		`<IndexDocument><Suffix>index.html</Suffix></IndexDocument>` +
+		`<ErrorDocument><Key>error.html</Key></ErrorDocument>` +
+		`<RoutingRules>` +
+		`<RoutingRule><Condition><KeyPrefixEquals>old/</KeyPrefixEquals></Condition><Redirect><ReplaceKeyPrefixWith>docs/</ReplaceKeyPrefixWith></Redirect></RoutingRule>` +
+		`<RoutingRule><Condition><KeyPrefixEquals>images/</KeyPrefixEquals><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>` +
+		`<Redirect><HostName>cdn.example.com</HostName><Protocol>https</Protocol><HttpRedirectCode>302</HttpRedirectCode></Redirect></RoutingRule>` +
+		`</RoutingRules>` +
+		`</WebsiteConfiguration>`))
+	require.NoError(t, err)
+
+	noErrorDocument := *config
+	noErrorDocument.ErrorDocument = nil
+
+	redirectAll, err := ParseConfiguration([]byte(`<WebsiteConfiguration><RedirectAllRequestsTo><HostName>www.example.com</HostName></RedirectAllRequestsTo></WebsiteConfiguration>`))
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		config   *Configuration
+		method   string
+		key      string
+		status   int
+		body     string
+		location string
+	}{
+		"Root":             {config: config, status: http.StatusOK, body: "index.html"},
+		"Object":           {config: config, key: "style.css", status: http.StatusOK, body: "style.css"},
+		"Directory":        {config: config, key: "docs/", status: http.StatusOK, body: "docs/index.html"},
+		"DirectoryNoSlash": {config: config, key: "docs", status: http.StatusFound, location: "/docs/"},
+		"ObjectRedirect":   {config: config, key: "moved.html", status: http.StatusMovedPermanently, location: "/index.html"},
+		"DirectorySpace":   {config: config, key: "my docs", status: http.StatusFound, location: "/my%20docs/"},
+		"RoutingRule":      {config: config, key: "old/page.html", status: http.StatusMovedPermanently, location: "http://site.s3-website.example.com/docs/page.html"},
+		"RoutingRuleSpace": {config: config, key: "old/my page.html", status: http.StatusMovedPermanently, location: "http://site.s3-website.example.com/docs/my%20page.html"},
+		"ErrorRule":        {config: config, key: "images/cat.png", status: http.StatusFound, location: "https://cdn.example.com/images/cat.png"},
+		"ErrorDocument":    {config: config, key: "missing.html", status: http.StatusNotFound, body: "error.html"},
+		"HTMLError":        {config: &noErrorDocument, key: "missing.html", status: http.StatusNotFound, body: "<li>Code: NoSuchKey</li>"},
+		"HTMLErrorHead":    {config: &noErrorDocument, method: http.MethodHead, key: "missing.html", status: http.StatusNotFound},
+		"RedirectAll":      {config: redirectAll, key: "docs/a.html", status: http.StatusMovedPermanently, location: "http://www.example.com/docs/a.html"},
+		"RedirectAllSpace": {config: redirectAll, key: "a b.html", status: http.StatusMovedPermanently, location: "http://www.example.com/a%20b.html"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			r := httptest.NewRequest(method, "http://site.s3-website.example.com"+keyPath(tc.key), http.NoBody)
+			recorder := httptest.NewRecorder()
+
+			server.ServeWebsite(recorder, r, &s3router.Route{
+				Action: s3router.ActionGetObject,
+				Style:  s3router.RequestStyleWebsite,
+				Bucket: "site",
+				Key:    tc.key,
+			}, tc.config)
+
+			require.Equal(t, tc.status, recorder.Code)
+			require.Equal(t, tc.location, recorder.Header().Get("Location"))
+
+			if tc.body != "" {
+				require.Contains(t, recorder.Body.String(), tc.body)
+			}
+
+			if name == "HTMLError" || name == "HTMLErrorHead" {
+				require.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+			}
+
+			if method == http.MethodHead {
+				require.Empty(t, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeAction(t *testing.T) {
+	config, err := ParseConfiguration([]byte(`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>`))
+	require.NoError(t, err)
+
+	server := &Server{
+		Store: memoryStore{"site/index.html": {ContentType: "text/html"}},
+		Configurations: func(_ context.Context, bucket string) (*Configuration, error) {
+			if bucket != "site" {
+				return nil, nil //nolint:nilnil // no configuration
+			}
+
+			return config, nil
+		},
+	}
+
+	logger := zerolog.Nop()
+	handler := s3router.New(&logger, []string{"example.com"}, map[s3router.Action]s3router.ActionHandler{
+		s3router.ActionGetWebsiteObject:  server,
+		s3router.ActionHeadWebsiteObject: server,
+	})
+
+	for name, tc := range map[string]struct {
+		host   string
+		status int
+		body   string
+	}{
+		"Index":           {host: "site.s3-website.example.com", status: http.StatusOK, body: "index.html"},
+		"NoConfiguration": {host: "other.s3-website.example.com", status: http.StatusNotFound, body: "<li>Code: NoSuchWebsiteConfiguration</li>"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://"+tc.host+"/", http.NoBody))
+
+			require.Equal(t, tc.status, recorder.Code)
+			require.Contains(t, recorder.Body.String(), tc.body)
+		})
+	}
+}